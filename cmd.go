@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kataras/golog"
+	"github.com/kataras/iris/v12"
+	"github.com/spf13/cobra"
+
+	"github.com/xaxys/maintainman/core/logger"
+	"github.com/xaxys/maintainman/core/module"
+)
+
+var rootCmd = &cobra.Command{
+	Use:          "maintainman",
+	Short:        "MaintainMan maintenance management server",
+	Long:         "MaintainMan maintenance management server.\nIt serves the API when no command is given.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		serve()
+	},
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the server",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		serve()
+	},
+}
+
+func init() {
+	rootCmd.Version = fmt.Sprintf("%s (built %s, commit %s, %s)", BuildTags, BuildTime, GitCommit, GoVersion)
+	rootCmd.AddCommand(serveCmd)
+}
+
+// useCommandLogger logs to the console without the server.
+func useCommandLogger() {
+	logger.Logger = golog.Default
+	logger.Logger.SetLevel(logLevel)
+}
+
+// loadModules loads the modules with the given names for commands working
+// without the server. Modules are loaded by their CommandPoint, so that no
+// route is served, no job is scheduled and no default data is created.
+// The returned function unloads them.
+func loadModules(names ...string) (func(), error) {
+	mods, err := findModules(names)
+	if err != nil {
+		return nil, err
+	}
+	app := iris.New()
+	// commands print their own results, only problems are logged
+	app.Logger().SetLevel("warn")
+	logger.Logger = app.Logger()
+	registry = module.NewCommandRegistry(newServer(app))
+	registry.Register(mods...)
+	for _, m := range mods {
+		if info, _ := registry.Info(m.ModuleName); info == nil || !info.Loaded {
+			registry.Shutdown()
+			return nil, fmt.Errorf("module %s is not loaded", m.ModuleName)
+		}
+	}
+	return func() {
+		registry.Shutdown()
+	}, nil
+}
+
+// findModules returns registered modules with the given names, or all modules if names is empty.
+func findModules(names []string) ([]*module.Module, error) {
+	if len(names) == 0 {
+		return modules, nil
+	}
+	found := []*module.Module{}
+	for _, name := range names {
+		var mod *module.Module
+		for _, m := range modules {
+			if m.ModuleName == name {
+				mod = m
+				break
+			}
+		}
+		if mod == nil {
+			return nil, fmt.Errorf("module %s not found", name)
+		}
+		found = append(found, mod)
+	}
+	return found, nil
+}
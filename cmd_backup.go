@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xaxys/maintainman/core/backup"
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/database"
+	"github.com/xaxys/maintainman/core/module"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Back up database, storages and configurations into an archive",
+	Long: "Back up the database, local storages and configuration files of app and all modules\n" +
+		"into a single archive. The archive is named by the current time if file is not given.",
+	Args: cobra.MaximumNArgs(1),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		useCommandLogger()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := fmt.Sprintf("maintainman-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+		if len(args) == 1 {
+			file = args[0]
+		}
+		spec, err := backupSpec()
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		manifest, err := backup.Create(f, spec)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(file)
+			return err
+		}
+		fmt.Printf("Backup written to %s.\n", file)
+		printManifest(manifest)
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore database, storages and configurations from an archive",
+	Long: "Restore the database, local storages and configuration files from an archive written by backup.\n" +
+		"The archive is refused if it is written by newer versions of app or modules.\n" +
+		"The server must be stopped during restoring.",
+	Args: cobra.ExactArgs(1),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		useCommandLogger()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := backupSpec()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			manifest, err := backup.ReadManifest(f)
+			if err != nil {
+				return err
+			}
+			printManifest(manifest)
+			if err := manifest.Check(&spec.Manifest); err != nil {
+				return err
+			}
+			fmt.Println("Archive can be restored.")
+			return nil
+		}
+		manifest, err := backup.Restore(f, spec)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup %s restored.\n", args[0])
+		printManifest(manifest)
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().Bool("dry-run", false, "only check whether the archive can be restored")
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+}
+
+// backupSpec describes the database, storages and configuration files of app and all modules.
+func backupSpec() (*backup.Spec, error) {
+	spec := &backup.Spec{
+		Manifest: backup.Manifest{
+			AppVersion:    BuildTags,
+			ConfigVersion: config.AppConfigVersion,
+			Database:      config.AppConfig.GetString("database.driver"),
+			Modules:       map[string]string{},
+		},
+		DB:       database.DB,
+		DBPath:   config.AppConfig.GetString("database.sqlite.path"),
+		Configs:  map[string]string{"app": config.AppConfig.ConfigFileUsed()},
+		Storages: map[string]string{},
+	}
+	addStorage(spec, "app", config.AppConfig.Viper())
+	for _, m := range modules {
+		spec.Modules[m.ModuleName] = m.ModuleVersion
+		if m.ModuleConfig == nil {
+			continue
+		}
+		if err := module.ReadConfig(m); err != nil {
+			return nil, err
+		}
+		spec.Configs[m.ModuleName] = m.ModuleConfig.ConfigFileUsed()
+		addStorage(spec, m.ModuleName, m.ModuleConfig.Viper())
+	}
+	return spec, nil
+}
+
+// addStorage adds the local storage configured by v. Sub-storages, such as
+// the image cache, are directories of it and are included as well.
+func addStorage(spec *backup.Spec, name string, v *viper.Viper) {
+	switch v.GetString("storage.driver") {
+	case "local":
+		spec.Storages[name] = filepath.Clean(v.GetString("storage.local.path"))
+	case "s3":
+		fmt.Printf("Storage of %s is on s3 and is not included, back up the bucket with the tools of the provider.\n", name)
+	}
+}
+
+func printManifest(m *backup.Manifest) {
+	mods := []string{}
+	for name, version := range m.Modules {
+		mods = append(mods, name+"@"+version)
+	}
+	sort.Strings(mods)
+	fmt.Printf("Created at:     %s\n", m.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("App version:    %s (configuration %s)\n", m.AppVersion, m.ConfigVersion)
+	fmt.Printf("Database:       %s\n", m.Database)
+	fmt.Printf("Modules:        %s\n", strings.Join(mods, " "))
+	fmt.Printf("Configurations: %s\n", strings.Join(m.Configs, " "))
+	fmt.Printf("Storages:       %s\n", strings.Join(m.Storages, " "))
+}
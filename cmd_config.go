@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/module"
+)
+
+// secretKeyRegex matches keys whose values are masked when printed.
+var secretKeyRegex = regexp.MustCompile(`(^|[._])(password|secret|key)$`)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect configuration files",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		useCommandLogger()
+	},
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print [module]",
+	Short: "Print the effective configuration of app (by default) or the module",
+	Long:  "Print the effective configuration of app (by default) or the module,\nwith environment variables applied. Secrets are masked unless --show-secrets is given.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v := config.AppConfig
+		if len(args) == 1 && args[0] != "app" {
+			mods, err := findModules(args)
+			if err != nil {
+				return err
+			}
+			if mods[0].ModuleConfig == nil {
+				return fmt.Errorf("module %s has no configuration", args[0])
+			}
+			if err := module.ReadConfig(mods[0]); err != nil {
+				return err
+			}
+			v = mods[0].ModuleConfig
+		}
+		showSecrets, _ := cmd.Flags().GetBool("show-secrets")
+		return printConfig(v.Viper(), showSecrets)
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate app configuration and configurations of all modules",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failed := 0
+		report := func(name string, err error) {
+			if err != nil {
+				failed++
+				fmt.Printf("%s: %v\n", name, err)
+				return
+			}
+			fmt.Printf("%s: ok\n", name)
+		}
+		report("app", config.ValidateConfig(config.AppConfig))
+		for _, m := range modules {
+			if m.ModuleConfig == nil {
+				continue
+			}
+			err := module.ReadConfig(m)
+			if err == nil {
+				err = config.ValidateConfig(m.ModuleConfig)
+			}
+			report(m.ModuleName, err)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d configurations are invalid", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	configPrintCmd.Flags().Bool("show-secrets", false, "print secrets without masking")
+	configCmd.AddCommand(configPrintCmd, configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func printConfig(v *viper.Viper, showSecrets bool) error {
+	output := viper.New()
+	for _, key := range v.AllKeys() {
+		value := v.Get(key)
+		if !showSecrets && secretKeyRegex.MatchString(key) && value != "" {
+			value = "******"
+		}
+		output.Set(key, value)
+	}
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(output.AllSettings()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xaxys/maintainman/core/database"
+	"github.com/xaxys/maintainman/core/module"
+	"github.com/xaxys/maintainman/core/util"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage database migrations of modules",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		useCommandLogger()
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up [module...]",
+	Short: "Apply pending migrations (all modules by default)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mods, err := findModules(args)
+		if err != nil {
+			return err
+		}
+		loadable, err := module.Loadable(modules...)
+		if err != nil {
+			return err
+		}
+		// migrations of modules which are not loaded are applied when they are loaded
+		pending := []*module.Module{}
+		for _, m := range mods {
+			if util.In(m, loadable...) {
+				pending = append(pending, m)
+			} else {
+				fmt.Printf("Module %s is disabled or skipped, its migrations are not applied.\n", m.ModuleName)
+			}
+		}
+		return module.MigrateUp(database.DB, pending...)
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down <module>",
+	Short: "Revert the latest applied migrations of the module",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mods, err := findModules(args)
+		if err != nil {
+			return err
+		}
+		steps, _ := cmd.Flags().GetInt("steps")
+		return module.MigrateDown(database.DB, mods[0], steps)
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status [module...]",
+	Short: "Show migration states (all modules by default)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mods, err := findModules(args)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatus(mods)
+	},
+}
+
+func init() {
+	migrateDownCmd.Flags().Int("steps", 1, "number of migrations to revert")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func printMigrationStatus(mods []*module.Module) error {
+	states, err := module.MigrationStatus(database.DB, mods...)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tVERSION\tNAME\tSTATUS")
+	for _, s := range states {
+		status := "pending"
+		if s.Applied {
+			status = "applied at " + s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Module, s.Version, s.Name, status)
+	}
+	return w.Flush()
+}
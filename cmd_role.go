@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xaxys/maintainman/core/rbac"
+)
+
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Inspect roles",
+}
+
+var roleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all roles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stop, err := loadModules("role")
+		if err != nil {
+			return err
+		}
+		defer stop()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDISPLAY NAME\tDEFAULT\tGUEST\tINHERITANCE")
+		roles := rbac.GetAllRoles()
+		sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+		for _, r := range roles {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%t\t%s\n", r.Name, r.DisplayName, r.Default, r.Guest, strings.Join(r.Inheritance, ","))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	roleCmd.AddCommand(roleListCmd)
+	rootCmd.AddCommand(roleCmd)
+}
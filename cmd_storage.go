@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/module"
+	"github.com/xaxys/maintainman/core/storage"
+	"github.com/xaxys/maintainman/core/util"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage storages of app and modules",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		useCommandLogger()
+	},
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate <app|module> <file>",
+	Short: "Copy all files of the storage of app or the module to another storage",
+	Long: "Copy all files of the storage of app or the module, including sub-directories such as the\n" +
+		"image cache, to the storage configured by the storage section of file, which is written like\n" +
+		"the storage section of app.yml. Each copy is verified by its SHA-256 checksum.\n" +
+		"Migrated files are recorded in the journal, so that an interrupted migration is resumed\n" +
+		"by running the command again. The server should be stopped during migrating.",
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		v := config.AppConfig
+		if name != "app" {
+			mods, err := findModules(args[:1])
+			if err != nil {
+				return err
+			}
+			if mods[0].ModuleConfig == nil {
+				return fmt.Errorf("module %s has no configuration", name)
+			}
+			if err := module.ReadConfig(mods[0]); err != nil {
+				return err
+			}
+			v = mods[0].ModuleConfig
+		}
+		src, err := initMigrateStorage(v.Viper())
+		if err != nil {
+			return fmt.Errorf("source storage of %s: %v", name, err)
+		}
+
+		target := viper.New()
+		target.SetConfigFile(args[1])
+		if err := target.ReadInConfig(); err != nil {
+			return err
+		}
+		dst, err := initMigrateStorage(target)
+		if err != nil {
+			return fmt.Errorf("destination storage of %s: %v", args[1], err)
+		}
+
+		journalFile, _ := cmd.Flags().GetString("journal")
+		if journalFile == "" {
+			journalFile = fmt.Sprintf("storage-migrate-%s.journal", name)
+		}
+		journal, err := storage.OpenJournal(journalFile, src, dst)
+		if errors.Is(err, storage.ErrJournalMismatch) {
+			return fmt.Errorf("%v, remove it or use another journal with --journal", err)
+		}
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+		if n := journal.Len(); n > 0 {
+			fmt.Printf("Resuming migration of %s, %d files have been migrated.\n", name, n)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		migration := &storage.Migration{
+			Src:     src,
+			Dst:     dst,
+			Journal: journal,
+			Progress: func(id string, size int64, skipped bool) {
+				if verbose {
+					fmt.Printf("%s %s (%d bytes)\n", util.Tenary(skipped, "skipped", "copied "), id, size)
+				}
+			},
+		}
+		result, err := migration.Run(ctx)
+		if result == nil {
+			return err
+		}
+		fmt.Printf("%d files copied (%d bytes), %d skipped, %d failed.\n", result.Copied, result.Bytes, result.Skipped, result.Failed)
+		if err != nil {
+			return fmt.Errorf("migration of %s is not finished, run the command again to resume: %v", name, err)
+		}
+		fmt.Printf("Storage of %s migrated from %s to %s.\n", name, src.Path(), dst.Path())
+
+		if rewrite, _ := cmd.Flags().GetBool("rewrite"); rewrite {
+			for _, key := range target.AllKeys() {
+				if strings.HasPrefix(key, "storage.") {
+					v.Set(key, target.Get(key))
+				}
+			}
+			if err := config.WriteConfig(v); err != nil {
+				return fmt.Errorf("failed to write %s configuration file: %v", name, err)
+			}
+			fmt.Printf("Storage configuration of %s written to %s.\n", name, v.ConfigFileUsed())
+		}
+		// the migration is finished, and the journal is no longer needed
+		journal.Close()
+		return os.Remove(journalFile)
+	},
+}
+
+func init() {
+	storageMigrateCmd.Flags().String("journal", "", "journal file of migrated files (default storage-migrate-<name>.journal)")
+	storageMigrateCmd.Flags().Bool("rewrite", false, "write the destination storage into the configuration file after migration")
+	storageMigrateCmd.Flags().Bool("verbose", false, "print every migrated file")
+	storageCmd.AddCommand(storageMigrateCmd)
+	rootCmd.AddCommand(storageCmd)
+}
+
+// initMigrateStorage initializes the storage configured by v. Storages are
+// never cleaned on initialization during migration.
+func initMigrateStorage(v *viper.Viper) (s storage.IStorage, err error) {
+	if v.GetString("storage.driver") == "" {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+	c := viper.New()
+	for _, key := range v.AllKeys() {
+		if strings.HasPrefix(key, "storage.") {
+			c.Set(key, v.Get(key))
+		}
+	}
+	c.Set("storage.local.clean", false)
+	c.Set("storage.s3.clean", false)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return storage.InitStorage(c), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xaxys/maintainman/core/module"
+	"github.com/xaxys/maintainman/core/util"
+	"github.com/xaxys/maintainman/modules/user"
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage user accounts",
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a user",
+	Long:  "Create a user. A random password is generated and printed if --password is not given.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password, generated, err := passwordFlag(cmd)
+		if err != nil {
+			return err
+		}
+		displayName, _ := cmd.Flags().GetString("display-name")
+		role, _ := cmd.Flags().GetString("role")
+		req := &user.CreateUserRequest{RoleName: role}
+		req.Name = args[0]
+		req.Password = password
+		req.DisplayName = util.NotEmpty(displayName, req.Name)
+		return withUserAdmin(func(admin user.UserAdmin) error {
+			u, err := admin.CreateUser(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("User %s created with id %d and role %s.\n", u.Name, u.ID, u.RoleName)
+			printGeneratedPassword(password, generated)
+			return nil
+		})
+	},
+}
+
+var userResetPasswordCmd = &cobra.Command{
+	Use:   "reset-password <name>",
+	Short: "Reset the password of a user",
+	Long:  "Reset the password of a user. A random password is generated and printed if --password is not given.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password, generated, err := passwordFlag(cmd)
+		if err != nil {
+			return err
+		}
+		return withUserAdmin(func(admin user.UserAdmin) error {
+			if err := admin.ResetPassword(cmd.Context(), args[0], password); err != nil {
+				return err
+			}
+			fmt.Printf("Password of user %s reset.\n", args[0])
+			printGeneratedPassword(password, generated)
+			return nil
+		})
+	},
+}
+
+var userSetRoleCmd = &cobra.Command{
+	Use:   "set-role <name> <role>",
+	Short: "Set the role of a user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withUserAdmin(func(admin user.UserAdmin) error {
+			if err := admin.SetRole(cmd.Context(), args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Role of user %s set to %s.\n", args[0], args[1])
+			return nil
+		})
+	},
+}
+
+func init() {
+	userCreateCmd.Flags().String("password", "", "password of the user")
+	userCreateCmd.Flags().String("display-name", "", "display name of the user (name by default)")
+	userCreateCmd.Flags().String("role", "", "role of the user (default role by default)")
+	userResetPasswordCmd.Flags().String("password", "", "new password of the user")
+	userCmd.AddCommand(userCreateCmd, userResetPasswordCmd, userSetRoleCmd)
+	rootCmd.AddCommand(userCmd)
+}
+
+// withUserAdmin loads role and user modules and calls fn with the user admin service.
+func withUserAdmin(fn func(admin user.UserAdmin) error) error {
+	stop, err := loadModules("role", "user")
+	if err != nil {
+		return err
+	}
+	defer stop()
+	admin, ok := module.Resolve[user.UserAdmin](registry)
+	if !ok {
+		return fmt.Errorf("user admin service is not provided")
+	}
+	return fn(admin)
+}
+
+// passwordFlag returns the password given by --password, or a random one if it is not given.
+func passwordFlag(cmd *cobra.Command) (password string, generated bool, err error) {
+	password, _ = cmd.Flags().GetString("password")
+	if password != "" {
+		return password, false, nil
+	}
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
+
+func printGeneratedPassword(password string, generated bool) {
+	if generated {
+		fmt.Printf("Generated password: %s\n", password)
+	}
+}
@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/xaxys/maintainman/core/logger"
+	"github.com/xaxys/maintainman/core/model"
+
+	"github.com/olebedev/emitter"
+)
+
+// Topic is the event bus topic audit logs are published to.
+// The audit module subscribes to it and saves the logs.
+const Topic = "audit:record"
+
+// Actions of audit logs.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// Log records an action on a resource and the fields it changed.
+type Log struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index; comment:操作时间"`
+	UserID     uint      `gorm:"not null; index; comment:操作用户ID 0:系统"`
+	UserName   string    `gorm:"not null; size:191; comment:操作用户名"`
+	IP         string    `gorm:"not null; size:64; comment:操作IP"`
+	Resource   string    `gorm:"not null; size:50; index:idx_audit_resource,priority:1; comment:资源类型"`
+	ResourceID string    `gorm:"not null; size:191; index:idx_audit_resource,priority:2; comment:资源ID"`
+	Action     string    `gorm:"not null; size:20; index; comment:操作 create update delete restore purge"`
+	Changes    string    `gorm:"not null; comment:字段变化 json"`
+}
+
+// Change is the values of a field before and after an action.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff returns the fields which differ between before and after, compared
+// by their json representations. A nil before or after has no fields, so
+// all fields of the other are returned, e.g. on create and delete.
+func Diff(before, after any) (map[string]*Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]*Change{}
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !reflect.DeepEqual(v, w) {
+			changes[k] = &Change{Before: v, After: w}
+		}
+	}
+	for k, w := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = &Change{After: w}
+		}
+	}
+	return changes, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	m := map[string]any{}
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return m, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%T is not an object: %v", v, err)
+	}
+	return m, nil
+}
+
+// Record publishes the log of the action on the resource with id done by
+// auth to bus. before and after are the json representations of the
+// resource, nil on create and delete respectively. auth is nil for actions
+// done by the system, such as scheduled jobs. Records are dropped if no
+// one subscribes to Topic, i.e. the audit module is disabled. Logs are
+// emitted synchronously, so that the subscriber receives them in order.
+func Record(bus *emitter.Emitter, auth *model.AuthInfo, resource string, id any, action string, before, after any) {
+	changes, err := Diff(before, after)
+	if err != nil {
+		logger.Logger.Warnf("Audit %s %s %v failed: %v", action, resource, id, err)
+		return
+	}
+	if action == ActionUpdate && len(changes) == 0 {
+		return
+	}
+	data, _ := json.Marshal(changes)
+	log := &Log{
+		CreatedAt:  time.Now(),
+		Resource:   resource,
+		ResourceID: fmt.Sprint(id),
+		Action:     action,
+		Changes:    string(data),
+	}
+	if auth != nil {
+		log.UserID = auth.User
+		log.UserName = auth.Name
+		log.IP = auth.IP
+	}
+	bus.Emit(Topic, log)
+}
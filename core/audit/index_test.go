@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kataras/golog"
+	"github.com/olebedev/emitter"
+	"github.com/xaxys/maintainman/core/logger"
+)
+
+type diffTestJson struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Tags  []uint `json:"tags,omitempty"`
+}
+
+func TestDiff(t *testing.T) {
+	before := &diffTestJson{Name: "a", Count: 1, Tags: []uint{1}}
+	after := &diffTestJson{Name: "a", Count: 2}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expect count and tags changed, but got %v", changes)
+	}
+	if c := changes["count"]; c == nil || c.Before != 1.0 || c.After != 2.0 {
+		t.Errorf("Expect count changed from 1 to 2, but got %+v", c)
+	}
+	if c := changes["tags"]; c == nil || c.After != nil {
+		t.Errorf("Expect tags removed, but got %+v", c)
+	}
+
+	changes, err = Diff(nil, after)
+	if err != nil || len(changes) != 2 || changes["name"].After != "a" || changes["name"].Before != nil {
+		t.Errorf("Expect all fields created, but got %v %v", changes, err)
+	}
+	changes, err = Diff(before, (*diffTestJson)(nil))
+	if err != nil || len(changes) != 3 {
+		t.Errorf("Expect all fields deleted, but got %v %v", changes, err)
+	}
+	changes, err = Diff(after, &diffTestJson{Name: "a", Count: 2})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Expect nothing changed, but got %v %v", changes, err)
+	}
+	if _, err := Diff(1, after); err == nil {
+		t.Errorf("Expect error diffing a non object")
+	}
+}
+
+func TestRecordInOrder(t *testing.T) {
+	logger.Logger = golog.New()
+	bus := emitter.New(4)
+	events := bus.On(Topic, emitter.Sync)
+	received := make(chan []string)
+	go func() {
+		ids := []string{}
+		for event := range events {
+			ids = append(ids, event.Args[0].(*Log).ResourceID)
+		}
+		received <- ids
+	}()
+	// more logs than the queue holds, Record blocks instead of dropping them
+	for i := 0; i < 100; i++ {
+		Record(bus, nil, "test", i, ActionCreate, nil, &diffTestJson{Count: i})
+	}
+	bus.Off(Topic, events)
+	ids := <-received
+	if len(ids) != 100 {
+		t.Fatalf("Expect 100 logs, but got %d", len(ids))
+	}
+	for i, id := range ids {
+		if id != fmt.Sprint(i) {
+			t.Fatalf("Expect logs in order, but got %s at %d", id, i)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package backup
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tableDump is a table dumped by dumpTables.
+type tableDump struct {
+	Table   string   `json:"table"`
+	DDL     []string `json:"ddl"`            // statements creating the table and its indexes
+	Post    []string `json:"post,omitempty"` // statements run after all tables are loaded, such as foreign keys
+	Columns []string `json:"columns"`
+	Types   []string `json:"types"` // database type names of columns
+	Rows    [][]any  `json:"rows"`
+}
+
+// dumpTables dumps the schema and rows of all tables to json files in dir
+// in one transaction, so that they are consistent with each other.
+// It is used by databases which cannot be copied as a file.
+func dumpTables(db *gorm.DB, driver, dir string) error {
+	var opts []*sql.TxOptions
+	if driver == "mysql" || driver == "postgres" {
+		opts = append(opts, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		tables, err := tx.Migrator().GetTables()
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if isInternalTable(driver, table) {
+				continue
+			}
+			dump, err := dumpTable(tx, driver, table)
+			if err != nil {
+				return fmt.Errorf("%s: %v", table, err)
+			}
+			data, err := json.Marshal(dump)
+			if err != nil {
+				return fmt.Errorf("%s: %v", table, err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, table+".json"), data, 0644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, opts...)
+}
+
+func dumpTable(tx *gorm.DB, driver, table string) (*tableDump, error) {
+	ddl, post, err := tableDDL(tx, driver, table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Table(table).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	dump := &tableDump{Table: table, DDL: ddl, Post: post, Rows: [][]any{}}
+	for _, c := range columns {
+		dump.Columns = append(dump.Columns, c.Name())
+		dump.Types = append(dump.Types, c.DatabaseTypeName())
+	}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		dump.Rows = append(dump.Rows, values)
+	}
+	return dump, rows.Err()
+}
+
+func tableDDL(tx *gorm.DB, driver, table string) (ddl, post []string, err error) {
+	switch driver {
+	case "mysql":
+		var name, create string
+		if err := tx.Raw("SHOW CREATE TABLE "+tx.Statement.Quote(table)).Row().Scan(&name, &create); err != nil {
+			return nil, nil, err
+		}
+		return []string{create}, nil, nil
+	case "postgres":
+		return postgresDDL(tx, table)
+	case "sqlite":
+		err := tx.Raw("SELECT sql FROM sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL ORDER BY type = 'table' DESC", table).
+			Scan(&ddl).Error
+		return ddl, nil, err
+	default:
+		return nil, nil, fmt.Errorf("dumping %s database is not supported", driver)
+	}
+}
+
+type postgresColumn struct {
+	Name     string
+	Type     string
+	NotNull  bool
+	Default  *string
+	Identity string
+}
+
+type postgresConstraint struct {
+	Name string
+	Type string
+	Def  string
+}
+
+// postgresDDL builds the DDL of the table from the catalog, as postgres has
+// no statement showing it. Foreign keys and values of sequences are returned
+// in post, so that rows can be loaded in any order.
+func postgresDDL(tx *gorm.DB, table string) (ddl, post []string, err error) {
+	quote := tx.Statement.Quote
+	columns := []*postgresColumn{}
+	if err := tx.Raw(`SELECT a.attname::text AS name, format_type(a.atttypid, a.atttypmod) AS type, a.attnotnull AS not_null,
+		pg_get_expr(d.adbin, d.adrelid) AS "default", a.attidentity::text AS identity
+		FROM pg_attribute a LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = ?::regclass AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum`, quote(table)).
+		Scan(&columns).Error; err != nil {
+		return nil, nil, err
+	}
+	constraints := []*postgresConstraint{}
+	if err := tx.Raw(`SELECT conname::text AS name, contype::text AS type, pg_get_constraintdef(oid) AS def
+		FROM pg_constraint WHERE conrelid = ?::regclass ORDER BY contype, conname`, quote(table)).
+		Scan(&constraints).Error; err != nil {
+		return nil, nil, err
+	}
+	indexes := []string{}
+	if err := tx.Raw(`SELECT indexdef FROM pg_indexes WHERE schemaname = current_schema() AND tablename = ?
+		AND indexname NOT IN (SELECT conname FROM pg_constraint WHERE conrelid = ?::regclass) ORDER BY indexname`, table, quote(table)).
+		Scan(&indexes).Error; err != nil {
+		return nil, nil, err
+	}
+
+	defs := []string{}
+	for _, c := range columns {
+		def := quote(c.Name) + " " + c.Type
+		sequence := c.Identity != ""
+		if c.Identity != "" {
+			// rows are loaded with their values, which GENERATED ALWAYS refuses
+			def += " GENERATED BY DEFAULT AS IDENTITY"
+		} else if c.Default != nil && strings.HasPrefix(*c.Default, "nextval(") {
+			if serial, ok := postgresSerials[c.Type]; ok {
+				def, sequence = quote(c.Name)+" "+serial, true
+			}
+		}
+		if c.NotNull && !sequence {
+			def += " NOT NULL"
+		}
+		if c.Default != nil && !sequence {
+			def += " DEFAULT " + *c.Default
+		}
+		defs = append(defs, def)
+		if sequence {
+			// the next value follows the loaded rows
+			post = append(post, fmt.Sprintf("SELECT setval(pg_get_serial_sequence(%s, %s), COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+				postgresString(quote(table)), postgresString(c.Name), quote(c.Name), quote(table)))
+		}
+	}
+	for _, c := range constraints {
+		switch c.Type {
+		case "p", "u", "c", "x":
+			defs = append(defs, "CONSTRAINT "+quote(c.Name)+" "+c.Def)
+		case "f":
+			post = append(post, "ALTER TABLE "+quote(table)+" ADD CONSTRAINT "+quote(c.Name)+" "+c.Def)
+		}
+	}
+	ddl = append([]string{"CREATE TABLE " + quote(table) + " (\n  " + strings.Join(defs, ",\n  ") + "\n)"}, indexes...)
+	return ddl, post, nil
+}
+
+var postgresSerials = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
+}
+
+func postgresString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func isInternalTable(driver, table string) bool {
+	return driver == "sqlite" && strings.HasPrefix(table, "sqlite_")
+}
+
+// loadTables replaces all tables of the database with the tables dumped
+// to dir by dumpTables. Nothing is changed if any table fails to load.
+func loadTables(db *gorm.DB, driver, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	dumps := []*tableDump{}
+	for _, file := range files {
+		dump, err := readTableDump(file)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Base(file), err)
+		}
+		dumps = append(dumps, dump)
+	}
+
+	if driver == "mysql" {
+		return swapTables(db, dumps)
+	}
+	// DDL of sqlite and postgres is transactional, so the database is either
+	// restored as a whole or left untouched
+	return db.Transaction(func(tx *gorm.DB) error {
+		tables, err := tx.Migrator().GetTables()
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if isInternalTable(driver, table) {
+				continue
+			}
+			if err := tx.Migrator().DropTable(table); err != nil {
+				return fmt.Errorf("%s: %v", table, err)
+			}
+		}
+		return loadDumps(tx, dumps)
+	})
+}
+
+// loadDumps creates and fills the tables, then runs their post statements.
+func loadDumps(conn *gorm.DB, dumps []*tableDump) error {
+	for _, dump := range dumps {
+		if err := loadTable(conn, dump); err != nil {
+			return fmt.Errorf("%s: %v", dump.Table, err)
+		}
+	}
+	for _, dump := range dumps {
+		for _, stmt := range dump.Post {
+			if err := conn.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%s: %v", dump.Table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// swapTables loads the dumps into a staging database, and swaps the tables
+// in by a single RENAME TABLE, as DDL of mysql commits implicitly and cannot
+// be rolled back. Tables replaced are moved to another database and dropped.
+// The database user needs the privilege to create databases.
+func swapTables(db *gorm.DB, dumps []*tableDump) error {
+	// foreign key checks and the current database are changed for the connection only,
+	// as tables are created and filled in no particular order
+	return db.Connection(func(conn *gorm.DB) error {
+		var current string
+		if err := conn.Raw("SELECT DATABASE()").Row().Scan(&current); err != nil {
+			return err
+		}
+		quote := conn.Statement.Quote
+		staging, old := current+"_restore", current+"_replaced"
+		for _, name := range []string{staging, old} {
+			// left by an interrupted restore
+			if err := conn.Exec("DROP DATABASE IF EXISTS " + quote(name)).Error; err != nil {
+				return err
+			}
+			if err := conn.Exec("CREATE DATABASE " + quote(name)).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("DROP DATABASE IF EXISTS " + quote(name))
+		}
+		if err := conn.Exec("SET FOREIGN_KEY_CHECKS = 0").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SET FOREIGN_KEY_CHECKS = 1")
+
+		if err := conn.Exec("USE " + quote(staging)).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("USE " + quote(current))
+		if err := loadDumps(conn, dumps); err != nil {
+			return err
+		}
+		if err := conn.Exec("USE " + quote(current)).Error; err != nil {
+			return err
+		}
+
+		tables, err := conn.Migrator().GetTables()
+		if err != nil {
+			return err
+		}
+		renames := []string{}
+		for _, table := range tables {
+			renames = append(renames, quote(current+"."+table)+" TO "+quote(old+"."+table))
+		}
+		for _, dump := range dumps {
+			renames = append(renames, quote(staging+"."+dump.Table)+" TO "+quote(current+"."+dump.Table))
+		}
+		if len(renames) == 0 {
+			return nil
+		}
+		return conn.Exec("RENAME TABLE " + strings.Join(renames, ", ")).Error
+	})
+}
+
+func readTableDump(file string) (*tableDump, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+	dump := &tableDump{}
+	if err := decoder.Decode(dump); err != nil {
+		return nil, err
+	}
+	if len(dump.Types) != len(dump.Columns) {
+		return nil, fmt.Errorf("%d types for %d columns", len(dump.Types), len(dump.Columns))
+	}
+	return dump, nil
+}
+
+func loadTable(conn *gorm.DB, dump *tableDump) error {
+	for _, ddl := range dump.DDL {
+		if err := conn.Exec(ddl).Error; err != nil {
+			return err
+		}
+	}
+	if len(dump.Rows) == 0 {
+		return nil
+	}
+	records := make([]map[string]any, 0, len(dump.Rows))
+	for _, row := range dump.Rows {
+		if len(row) != len(dump.Columns) {
+			return fmt.Errorf("%d values for %d columns", len(row), len(dump.Columns))
+		}
+		record := make(map[string]any, len(row))
+		for i, v := range row {
+			record[dump.Columns[i]] = loadValue(v, dump.Types[i])
+		}
+		records = append(records, record)
+	}
+	return conn.Table(dump.Table).CreateInBatches(records, 100).Error
+}
+
+// loadValue converts a value decoded from json back to the column type.
+func loadValue(value any, typ string) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case string:
+		typ = strings.ToUpper(typ)
+		if strings.Contains(typ, "DATE") || strings.Contains(typ, "TIME") {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
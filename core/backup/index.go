@@ -0,0 +1,410 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+
+	"gorm.io/gorm"
+)
+
+// FormatVersion is the version of the archive layout.
+// Archives of other versions are refused on restore.
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	databaseDir  = "database"
+	configDir    = "config"
+	storageDir   = "storage"
+	sqliteName   = "sqlite.db"
+)
+
+// Manifest is the first entry of an archive. It describes the application
+// which wrote the archive and what the archive contains.
+type Manifest struct {
+	Format        int               `json:"format"`
+	AppVersion    string            `json:"app_version"`
+	ConfigVersion string            `json:"config_version"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Database      string            `json:"database"` // database driver
+	Modules       map[string]string `json:"modules"`  // module name -> module version
+	Configs       []string          `json:"configs"`  // names of configuration files
+	Storages      []string          `json:"storages"` // names of storages
+}
+
+// Spec describes the running application: its versions, and where its
+// state is kept. It tells Create what to back up and Restore where to restore to.
+type Spec struct {
+	Manifest
+	DB       *gorm.DB
+	DBPath   string            // database file, sqlite only
+	Configs  map[string]string // configuration name -> configuration file
+	Storages map[string]string // storage name -> directory of local storage
+}
+
+// Check returns an error if the archive described by m cannot be restored
+// to the running application described by running.
+func (m *Manifest) Check(running *Manifest) error {
+	if m.Format != FormatVersion {
+		return fmt.Errorf("unsupported archive format %d, expected %d", m.Format, FormatVersion)
+	}
+	errs := []error{}
+	if m.Database != running.Database {
+		errs = append(errs, fmt.Errorf("archive of %s database cannot be restored to %s database", m.Database, running.Database))
+	}
+	if config.VersionCompare(m.ConfigVersion, running.ConfigVersion) > 0 {
+		errs = append(errs, fmt.Errorf("archive app configuration version %s is newer than %s", m.ConfigVersion, running.ConfigVersion))
+	}
+	names := make([]string, 0, len(m.Modules))
+	for name := range m.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		version, ok := running.Modules[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("archive module %s is not registered", name))
+			continue
+		}
+		if config.VersionCompare(m.Modules[name], version) > 0 {
+			errs = append(errs, fmt.Errorf("archive module %s version %s is newer than %s", name, m.Modules[name], version))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Create writes a gzipped tar archive of the database, configuration files
+// and storages of spec to w. Missing configuration files and storages are skipped.
+func Create(w io.Writer, spec *Spec) (*Manifest, error) {
+	manifest := spec.Manifest
+	manifest.Format = FormatVersion
+	manifest.CreatedAt = time.Now()
+	manifest.Configs = existing(spec.Configs)
+	manifest.Storages = existing(spec.Storages)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	data, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, data); err != nil {
+		return nil, err
+	}
+
+	temp, err := os.MkdirTemp("", "maintainman-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(temp)
+	if manifest.Database == "sqlite" {
+		// VACUUM INTO writes a consistent copy even if the database is in use
+		file := filepath.Join(temp, sqliteName)
+		if err := spec.DB.Exec("VACUUM INTO ?", file).Error; err != nil {
+			return nil, fmt.Errorf("failed to snapshot database: %v", err)
+		}
+		if err := addFile(tw, path.Join(databaseDir, sqliteName), file); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := dumpTables(spec.DB, manifest.Database, temp); err != nil {
+			return nil, fmt.Errorf("failed to dump database: %v", err)
+		}
+		if err := addDir(tw, databaseDir, temp); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range manifest.Configs {
+		if err := addFile(tw, path.Join(configDir, name+".yaml"), spec.Configs[name]); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range manifest.Storages {
+		if err := addDir(tw, path.Join(storageDir, name), spec.Storages[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// ReadManifest reads the manifest of the archive.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %v", err)
+	}
+	defer gr.Close()
+	return readManifest(tar.NewReader(gr))
+}
+
+// Restore checks the archive against spec, then replaces the database,
+// configuration files and storages of spec with those in the archive.
+// Nothing is overwritten if the check fails or the archive is broken.
+// The database of spec is closed if it is a sqlite database.
+func Restore(r io.Reader, spec *Spec) (*Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %v", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Check(&spec.Manifest); err != nil {
+		return nil, err
+	}
+	for _, name := range manifest.Configs {
+		if spec.Configs[name] == "" {
+			return nil, fmt.Errorf("no place to restore %s configuration to", name)
+		}
+	}
+	for _, name := range manifest.Storages {
+		if spec.Storages[name] == "" {
+			return nil, fmt.Errorf("no place to restore %s storage to", name)
+		}
+	}
+
+	// extract the whole archive first, so that a broken archive overwrites nothing
+	staging, err := os.MkdirTemp("", "maintainman-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+	if err := extract(tr, staging); err != nil {
+		return nil, fmt.Errorf("invalid archive: %v", err)
+	}
+
+	if manifest.Database == "sqlite" {
+		if err := restoreSqlite(spec, filepath.Join(staging, databaseDir, sqliteName)); err != nil {
+			return nil, fmt.Errorf("failed to restore database: %v", err)
+		}
+	} else {
+		if err := loadTables(spec.DB, manifest.Database, filepath.Join(staging, databaseDir)); err != nil {
+			return nil, fmt.Errorf("failed to restore database: %v", err)
+		}
+	}
+	for _, name := range manifest.Configs {
+		if err := copyFile(filepath.Join(staging, configDir, name+".yaml"), spec.Configs[name]); err != nil {
+			return nil, fmt.Errorf("failed to restore %s configuration: %v", name, err)
+		}
+	}
+	for _, name := range manifest.Storages {
+		if err := replaceDir(filepath.Join(staging, storageDir, name), spec.Storages[name]); err != nil {
+			return nil, fmt.Errorf("failed to restore %s storage: %v", name, err)
+		}
+	}
+	return manifest, nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %v", err)
+	}
+	if header.Name != manifestName {
+		return nil, fmt.Errorf("invalid archive: %s should be the first entry, but got %s", manifestName, header.Name)
+	}
+	manifest := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+// existing returns sorted names whose paths exist.
+func existing(paths map[string]string) []string {
+	names := []string{}
+	for name, p := range paths {
+		if p == "" {
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func addFile(tw *tar.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// addDir adds regular files under dir to the archive with the prefix.
+func addDir(tw *tar.Writer, prefix, dir string) error {
+	return filepath.WalkDir(dir, func(file string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, path.Join(prefix, filepath.ToSlash(rel)), file)
+	})
+}
+
+func extract(tr *tar.Reader, dir string) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("entry %s is outside of the archive", header.Name)
+		}
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(file, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func writeFile(file string, r io.Reader) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// copyFile copies src to dst through a temporary file, so that dst is
+// either left untouched or replaced as a whole.
+func copyFile(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	temp := dst + ".restore"
+	if err := writeFile(temp, f); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return os.Rename(temp, dst)
+}
+
+// replaceDir replaces the contents of dst with the files under src.
+// src does not exist if the storage was empty.
+func replaceDir(src, dst string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(file string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		return copyFile(file, filepath.Join(dst, rel))
+	})
+}
+
+// restoreSqlite closes the database and replaces the database file.
+func restoreSqlite(spec *Spec, file string) error {
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+	sqlDB, err := spec.DB.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+	if err := copyFile(file, spec.DBPath); err != nil {
+		return err
+	}
+	// journals of the replaced database would corrupt the restored one
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(spec.DBPath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
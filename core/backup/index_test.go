@@ -0,0 +1,234 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type backupTestItem struct {
+	ID        uint
+	Name      string `gorm:"uniqueIndex"`
+	Count     int
+	CreatedAt time.Time
+}
+
+func newBackupTestDB(t *testing.T, file string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(file), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func writeTestFile(t *testing.T, file, content string) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectFile(t *testing.T, file, content string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Errorf("Expect %s, but got %v", file, err)
+		return
+	}
+	if string(data) != content {
+		t.Errorf("Expect %s to be %q, but got %q", file, content, data)
+	}
+}
+
+func expectItems(t *testing.T, db *gorm.DB, names ...string) {
+	items := []*backupTestItem{}
+	if err := db.Order("id").Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, item := range items {
+		got = append(got, item.Name)
+	}
+	if strings.Join(got, ",") != strings.Join(names, ",") {
+		t.Errorf("Expect items %v, but got %v", names, got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	running := &Manifest{
+		ConfigVersion: "1.3.7",
+		Database:      "sqlite",
+		Modules:       map[string]string{"user": "1.2.0", "order": "1.2.0"},
+	}
+	archive := func(fn func(m *Manifest)) *Manifest {
+		m := &Manifest{
+			Format:        FormatVersion,
+			ConfigVersion: "1.3.7",
+			Database:      "sqlite",
+			Modules:       map[string]string{"user": "1.1.0", "order": "1.2.0"},
+		}
+		if fn != nil {
+			fn(m)
+		}
+		return m
+	}
+
+	if err := archive(nil).Check(running); err != nil {
+		t.Errorf("Expect no error, but got %v", err)
+	}
+	cases := map[string]func(m *Manifest){
+		"unsupported archive format":  func(m *Manifest) { m.Format = FormatVersion + 1 },
+		"cannot be restored to":       func(m *Manifest) { m.Database = "mysql" },
+		"configuration version 1.4.0": func(m *Manifest) { m.ConfigVersion = "1.4.0" },
+		"module order version 1.10.0": func(m *Manifest) { m.Modules["order"] = "1.10.0" },
+		"module wiki is not":          func(m *Manifest) { m.Modules["wiki"] = "1.0.0" },
+	}
+	for expected, fn := range cases {
+		err := archive(fn).Check(running)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expect error containing %q, but got %v", expected, err)
+		}
+	}
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	db := newBackupTestDB(t, dbPath)
+	if err := db.AutoMigrate(&backupTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&backupTestItem{Name: "a"})
+	db.Create(&backupTestItem{Name: "b"})
+	configFile := filepath.Join(dir, "role.yaml")
+	writeTestFile(t, configFile, "role: []\n")
+	images := filepath.Join(dir, "images")
+	writeTestFile(t, filepath.Join(images, "1"), "image")
+	writeTestFile(t, filepath.Join(images, "cache", "1-thumb"), "thumb")
+
+	spec := &Spec{
+		Manifest: Manifest{Database: "sqlite", Modules: map[string]string{"role": "1.0.0"}},
+		DB:       db,
+		DBPath:   dbPath,
+		Configs:  map[string]string{"role": configFile, "user": filepath.Join(dir, "missing.yaml")},
+		Storages: map[string]string{"image": images},
+	}
+	archive := &bytes.Buffer{}
+	manifest, err := Create(archive, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Configs) != 1 || manifest.Configs[0] != "role" {
+		t.Errorf("Expect missing configuration skipped, but got %v", manifest.Configs)
+	}
+
+	// change everything after the backup
+	db.Create(&backupTestItem{Name: "c"})
+	writeTestFile(t, configFile, "role: [changed]\n")
+	writeTestFile(t, filepath.Join(images, "2"), "new image")
+	os.Remove(filepath.Join(images, "cache", "1-thumb"))
+
+	read, err := ReadManifest(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Format != FormatVersion || read.Modules["role"] != "1.0.0" {
+		t.Errorf("Expect manifest written, but got %+v", read)
+	}
+
+	// newer archive is refused without overwriting anything
+	older := *spec
+	older.Manifest.Modules = map[string]string{"role": "0.9.0"}
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), &older); err == nil {
+		t.Error("Expect archive of newer module refused")
+	}
+	expectFile(t, configFile, "role: [changed]\n")
+	expectItems(t, db, "a", "b", "c")
+
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), spec); err != nil {
+		t.Fatal(err)
+	}
+	expectFile(t, configFile, "role: []\n")
+	expectFile(t, filepath.Join(images, "1"), "image")
+	expectFile(t, filepath.Join(images, "cache", "1-thumb"), "thumb")
+	if _, err := os.Stat(filepath.Join(images, "2")); !os.IsNotExist(err) {
+		t.Errorf("Expect file created after the backup removed, but got %v", err)
+	}
+	expectItems(t, newBackupTestDB(t, dbPath), "a", "b")
+}
+
+func TestDumpAndLoadTables(t *testing.T) {
+	dir := t.TempDir()
+	db := newBackupTestDB(t, filepath.Join(dir, "test.db"))
+	if err := db.AutoMigrate(&backupTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2022, 5, 1, 8, 30, 0, 0, time.UTC)
+	db.Create(&backupTestItem{Name: "a", Count: 3, CreatedAt: created})
+	db.Create(&backupTestItem{Name: "b", Count: -1, CreatedAt: created})
+
+	dumpDir := filepath.Join(dir, "dump")
+	os.Mkdir(dumpDir, 0755)
+	if err := dumpTables(db, "sqlite", dumpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Create(&backupTestItem{Name: "c"})
+	db.Exec("CREATE TABLE extra (id integer)")
+	if err := loadTables(db, "sqlite", dumpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	expectItems(t, db, "a", "b")
+	if db.Migrator().HasTable("extra") {
+		t.Error("Expect table created after the dump dropped")
+	}
+	item := &backupTestItem{}
+	db.First(item, "name = ?", "b")
+	if item.Count != -1 || !item.CreatedAt.Equal(created) {
+		t.Errorf("Expect count -1 created at %v, but got %d at %v", created, item.Count, item.CreatedAt)
+	}
+	// unique index is restored
+	if err := db.Create(&backupTestItem{Name: "a"}).Error; err == nil {
+		t.Error("Expect unique index restored")
+	}
+}
+
+func TestLoadTablesAtomic(t *testing.T) {
+	dir := t.TempDir()
+	db := newBackupTestDB(t, filepath.Join(dir, "test.db"))
+	if err := db.AutoMigrate(&backupTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&backupTestItem{Name: "a"})
+	db.Exec("CREATE TABLE extra (id integer)")
+
+	dumpDir := filepath.Join(dir, "dump")
+	os.Mkdir(dumpDir, 0755)
+	writeTestFile(t, filepath.Join(dumpDir, "backup_test_items.json"),
+		`{"table":"backup_test_items","ddl":["CREATE TABLE backup_test_items (id integer, name text)"],"columns":["id","name"],"types":["INTEGER","TEXT"],"rows":[[1,"b"]]}`)
+	// the table loaded last is broken
+	writeTestFile(t, filepath.Join(dumpDir, "broken.json"),
+		`{"table":"broken","ddl":["CREATE TABLE broken (id integer)"],"columns":["id"],"types":["INTEGER"],"rows":[[1,2]]}`)
+	if err := loadTables(db, "sqlite", dumpDir); err == nil {
+		t.Fatal("Expect broken table failed to load")
+	}
+
+	// nothing is changed
+	expectItems(t, db, "a")
+	if !db.Migrator().HasTable("extra") || db.Migrator().HasTable("broken") {
+		t.Error("Expect tables kept as before the restore")
+	}
+}
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/database"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const postgresTestSchema = "maintainman_backup_test"
+
+type backupTestOwner struct {
+	ID    uint
+	Name  string
+	Items []backupTestOwnedItem `gorm:"foreignKey:OwnerID"`
+}
+
+type backupTestOwnedItem struct {
+	ID      uint
+	OwnerID uint
+	Done    bool
+}
+
+// newPostgresTestDB opens the postgres database configured by environment
+// variables, in a schema of its own, as restoring drops all tables.
+func newPostgresTestDB(t *testing.T) *gorm.DB {
+	if config.AppConfig.GetString("database.driver") != "postgres" {
+		t.Skip("postgres is not configured, set MAINTAINMAN_DATABASE_DRIVER=postgres to run")
+	}
+	if err := database.DB.Exec("DROP SCHEMA IF EXISTS " + postgresTestSchema + " CASCADE").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Exec("CREATE SCHEMA " + postgresTestSchema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.DB.Exec("DROP SCHEMA IF EXISTS " + postgresTestSchema + " CASCADE")
+	})
+
+	v := viper.New()
+	for _, key := range config.AppConfig.AllKeys() {
+		if strings.HasPrefix(key, "database.") {
+			v.Set(key, config.AppConfig.Get(key))
+		}
+	}
+	params := strings.Trim(v.GetString("database.postgres.params")+"&search_path="+postgresTestSchema, "&")
+	v.Set("database.postgres.params", params)
+	db, err := database.Open(v, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestPostgresCreateAndRestore(t *testing.T) {
+	db := newPostgresTestDB(t)
+	if err := db.AutoMigrate(&backupTestItem{}, &backupTestOwner{}, &backupTestOwnedItem{}); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2022, 5, 1, 8, 30, 0, 0, time.UTC)
+	db.Create(&backupTestItem{Name: "a", Count: 3, CreatedAt: created})
+	db.Create(&backupTestItem{Name: "b", Count: -1, CreatedAt: created})
+	db.Create(&backupTestOwner{Name: "owner", Items: []backupTestOwnedItem{{Done: true}, {Done: false}}})
+
+	dir := t.TempDir()
+	spec := &Spec{
+		Manifest: Manifest{Database: "postgres", Modules: map[string]string{}},
+		DB:       db,
+		Configs:  map[string]string{},
+		Storages: map[string]string{},
+	}
+	archive := &bytes.Buffer{}
+	if _, err := Create(archive, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// change everything after the backup
+	db.Create(&backupTestItem{Name: "c"})
+	db.Exec("CREATE TABLE extra (id integer)")
+	db.Exec("DELETE FROM backup_test_owned_items")
+
+	// a broken archive changes nothing
+	broken := filepath.Join(dir, "broken")
+	os.Mkdir(broken, 0755)
+	writeTestFile(t, filepath.Join(broken, "backup_test_items.json"),
+		`{"table":"backup_test_items","ddl":["CREATE TABLE backup_test_items (id integer)"],"columns":["id"],"types":["INT4"],"rows":[[1,2]]}`)
+	if err := loadTables(db, "postgres", broken); err == nil {
+		t.Error("Expect broken table failed to load")
+	}
+	expectItems(t, db, "a", "b", "c")
+
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), spec); err != nil {
+		t.Fatal(err)
+	}
+	expectItems(t, db, "a", "b")
+	if db.Migrator().HasTable("extra") {
+		t.Error("Expect table created after the backup dropped")
+	}
+	item := &backupTestItem{}
+	db.First(item, "name = ?", "b")
+	if item.Count != -1 || !item.CreatedAt.Equal(created) {
+		t.Errorf("Expect count -1 created at %v, but got %d at %v", created, item.Count, item.CreatedAt)
+	}
+	owner := &backupTestOwner{}
+	if err := db.Preload("Items", "done = ?", true).First(owner, "name = ?", "owner").Error; err != nil || len(owner.Items) != 1 {
+		t.Errorf("Expect 1 done item of the owner restored, but got %v %v", owner.Items, err)
+	}
+
+	// unique index, sequences and foreign keys are restored
+	if err := db.Create(&backupTestItem{Name: "a"}).Error; err == nil {
+		t.Error("Expect unique index restored")
+	}
+	next := &backupTestItem{Name: "d"}
+	if err := db.Create(next).Error; err != nil || next.ID <= item.ID {
+		t.Errorf("Expect id after %d from the restored sequence, but got %d %v", item.ID, next.ID, err)
+	}
+	if err := db.Create(&backupTestOwnedItem{OwnerID: owner.ID + 100}).Error; err == nil {
+		t.Error("Expect foreign key restored")
+	}
+}
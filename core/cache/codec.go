@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec serializes values stored in caches which can not hold go values
+// directly, e.g. redis.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// encoded is the value held by redis, which is decoded by Decode.
+type encoded []byte
+
+type jsonCodec struct{}
+
+type gobCodec struct{}
+
+type msgpackCodec struct{}
+
+var (
+	JSONCodec    Codec = jsonCodec{}
+	GobCodec     Codec = gobCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// NewCodec returns the codec by name. Empty name means json.
+func NewCodec(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec, nil
+	case "gob":
+		return GobCodec, nil
+	case "msgpack":
+		return MsgpackCodec, nil
+	default:
+		return nil, fmt.Errorf("unknown cache codec: %s", name)
+	}
+}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (msgpackCodec) Name() string                       { return "msgpack" }
+func (msgpackCodec) Marshal(v any) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error { return msgpack.Unmarshal(data, v) }
+
+// Decode converts a value returned by Get or passed to the evict function
+// into T. Values held by local caches are returned as they are, and encoded
+// values held by redis are decoded with the codec of the cache.
+func Decode[T any](c ICache, value any) (T, bool) {
+	var t T
+	data, ok := value.(encoded)
+	if !ok {
+		t, ok = value.(T)
+		return t, ok
+	}
+	if err := c.Codec().Unmarshal(data, &t); err != nil {
+		return t, false
+	}
+	return t, true
+}
+
+// GetAs gets the value of key as T. It returns false if the key is not
+// found or the value can not be converted into T.
+func GetAs[T any](c ICache, key string) (T, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		var t T
+		return t, false
+	}
+	return Decode[T](c, value)
+}
+
+// SetAs sets the value of key as T.
+func SetAs[T any](c ICache, key string, value T, expire time.Duration) bool {
+	return c.Set(key, value, expire)
+}
+
+// SetAsWithCost sets the value of key as T with cost.
+func SetAsWithCost[T any](c ICache, key string, value T, cost int64, expire time.Duration) bool {
+	return c.SetWithCost(key, value, cost, expire)
+}
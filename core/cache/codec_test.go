@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/kataras/golog"
+)
+
+type codecTestUser struct {
+	ID        uint
+	Name      string
+	Division  *codecTestDivision
+	LoginTime time.Time
+}
+
+type codecTestDivision struct {
+	ID   uint
+	Name string
+}
+
+func TestCodec(t *testing.T) {
+	user := codecTestUser{
+		ID:        1,
+		Name:      "admin",
+		Division:  &codecTestDivision{ID: 2, Name: "maintainer"},
+		LoginTime: time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+	for _, name := range []string{"json", "gob", "msgpack"} {
+		codec, err := NewCodec(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if codec.Name() != name {
+			t.Errorf("Expect codec %s, but got %s", name, codec.Name())
+		}
+		// values held by redis are decoded by the codec of the cache
+		c := newRistretto(0, codec, nil)
+		data, err := codec.Marshal(user)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, ok := Decode[codecTestUser](c, encoded(data))
+		if !ok {
+			t.Errorf("%s: Expect user decoded, but failed", name)
+		}
+		if got.LoginTime.Equal(user.LoginTime) {
+			got.LoginTime = user.LoginTime
+		}
+		if !reflect.DeepEqual(got, user) {
+			t.Errorf("%s: Expect %+v, but got %+v", name, user, got)
+		}
+		if _, ok := Decode[codecTestDivision](c, encoded("not encoded")); ok {
+			t.Errorf("%s: Expect invalid data not decoded", name)
+		}
+	}
+	if _, err := NewCodec("xml"); err == nil {
+		t.Error("Expect unknown codec rejected")
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	c := newRistretto(0, JSONCodec, nil)
+	user := codecTestUser{ID: 1, Name: "admin"}
+	if !SetAs(c, "user", user, 0) {
+		t.Fatal("Expect user set")
+	}
+	SetAs(c, "id", "abc", 0)
+	c.(*Ristretto).cache.Wait()
+
+	if got, ok := GetAs[codecTestUser](c, "user"); !ok || got.Name != "admin" {
+		t.Errorf("Expect user admin, but got %+v, %v", got, ok)
+	}
+	if got, ok := GetAs[string](c, "id"); !ok || got != "abc" {
+		t.Errorf("Expect id abc, but got %q, %v", got, ok)
+	}
+	if _, ok := GetAs[codecTestUser](c, "id"); ok {
+		t.Error("Expect string not converted into user")
+	}
+	if _, ok := GetAs[string](c, "missing"); ok {
+		t.Error("Expect missing key not found")
+	}
+}
+
+func TestRedisGetAs(t *testing.T) {
+	logger.Logger = golog.New()
+	s := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { conn.Close() })
+
+	user := codecTestUser{
+		ID:        1,
+		Name:      "admin",
+		Division:  &codecTestDivision{ID: 2, Name: "maintainer"},
+		LoginTime: time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+	for _, codec := range []Codec{JSONCodec, GobCodec, MsgpackCodec} {
+		c := newRedis(conn, codec.Name(), 0, codec, nil)
+		if !SetAs(c, "user", user, time.Minute) {
+			t.Fatalf("%s: Expect user set", codec.Name())
+		}
+		got, ok := GetAs[codecTestUser](c, "user")
+		if !ok {
+			t.Errorf("%s: Expect user got, but failed", codec.Name())
+		}
+		if got.LoginTime.Equal(user.LoginTime) {
+			got.LoginTime = user.LoginTime
+		}
+		if !reflect.DeepEqual(got, user) {
+			t.Errorf("%s: Expect %+v, but got %+v", codec.Name(), user, got)
+		}
+		if ttl := s.TTL(codec.Name() + ":user"); ttl != time.Minute {
+			t.Errorf("%s: Expect ttl 1m, but got %v", codec.Name(), ttl)
+		}
+		if _, ok := GetAs[codecTestUser](c, "missing"); ok {
+			t.Errorf("%s: Expect missing key not found", codec.Name())
+		}
+	}
+}
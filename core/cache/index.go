@@ -3,6 +3,9 @@ package cache
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -16,8 +19,12 @@ import (
 )
 
 var (
-	Cache     ICache
-	redisConn *redis.Client
+	Cache      ICache
+	redisConn  *redis.Client
+	redisConns []*redis.Client            // all opened redis connections, closed on shutdown
+	redisMu    sync.Mutex                 // guards redisInUse
+	redisInUse = map[*redis.Client]bool{} // redis connections used by caches, checked by Ping
+	caches     = map[string]ICache{}      // caches initialized by InitCache by name
 )
 
 type ICache interface {
@@ -25,10 +32,14 @@ type ICache interface {
 	Set(key string, value any, expire time.Duration) bool
 	SetWithCost(key string, value any, cost int64, expire time.Duration) bool
 	Del(key string)
+	Codec() Codec
+	Stats() Stats
+	Purge()
 }
 
 type Ristretto struct {
 	limit int64
+	codec Codec
 	cache *ristretto.Cache
 }
 
@@ -36,38 +47,65 @@ type Ristretto struct {
 type Redis struct {
 	prefix  string
 	limit   int64
+	codec   Codec
 	onEvict func(any) error
 	rdb     *redis.Client
+	hits    atomic.Uint64
+	misses  atomic.Uint64
 }
 
 func init() {
-	redisConn = initRedisConn(config.AppConfig)
-	Cache = InitCache("app", config.AppConfig, nil)
+	redisConn = initRedisConn(config.AppConfig.Viper())
+	Cache = InitCache("app", config.AppConfig.Viper(), nil)
 }
 
 func InitCache(name string, config *viper.Viper, fn func(any) error) ICache {
+	c := newCache(name, config, fn)
+	if c != nil {
+		caches[name] = c
+	}
+	return c
+}
+
+func newCache(name string, config *viper.Viper, fn func(any) error) ICache {
 	if config == nil {
 		return nil
 	}
 	cacheType := config.GetString("cache.driver")
 	limit := config.GetInt64("cache.limit")
+	codec, err := NewCodec(config.GetString("cache.codec"))
+	if err != nil {
+		panic(err)
+	}
 	switch cacheType {
 	case "":
 		return nil
 	case "local":
-		return newRistretto(limit, fn)
-	case "redis":
-		conn := initRedisConn(config)
-		if conn == nil {
-			if redisConn == nil {
-				panic("no redis connection specified in both config and env")
-			}
-			conn = redisConn
+		local := newRistretto(limit, codec, fn)
+		if !config.GetBool("cache.sync") {
+			return local
 		}
-		return newRedis(conn, name, limit, fn)
+		return newSynced(getRedisConn(config), name, local)
+	case "redis":
+		return newRedis(getRedisConn(config), name, limit, codec, fn)
+	case "tiered":
+		return newTiered(getRedisConn(config), name, limit, config.GetDuration("cache.local_ttl"), codec, fn)
 	default:
-		panic("support local and redis only")
+		panic("support local, redis and tiered only")
+	}
+}
+
+// getRedisConn returns the redis connection of config, or the one of
+// app config if not specified.
+func getRedisConn(config *viper.Viper) *redis.Client {
+	conn := initRedisConn(config)
+	if conn == nil {
+		if redisConn == nil {
+			panic("no redis connection specified in both config and env")
+		}
+		conn = redisConn
 	}
+	return conn
 }
 
 func initRedisConn(config *viper.Viper) *redis.Client {
@@ -79,14 +117,61 @@ func initRedisConn(config *viper.Viper) *redis.Client {
 	if rdbHost == "" {
 		return nil
 	}
-	return redis.NewClient(&redis.Options{
+	conn := redis.NewClient(&redis.Options{
 		Addr:     rdbAddr,
 		Password: rdbPasswd,
 		DB:       0,
 	})
+	redisConns = append(redisConns, conn)
+	return conn
 }
 
-func newRistretto(limit int64, onEvict func(any) error) ICache {
+// Close closes all redis connections opened by caches.
+func Close() error {
+	var err error
+	for _, b := range buses {
+		if e := b.pubsub.Close(); e != nil {
+			err = e
+		}
+	}
+	buses = map[*redis.Client]*bus{}
+	for _, conn := range redisConns {
+		if e := conn.Close(); e != nil {
+			err = e
+		}
+	}
+	redisConns = nil
+	redisMu.Lock()
+	redisInUse = map[*redis.Client]bool{}
+	redisMu.Unlock()
+	return err
+}
+
+// useRedis marks the redis connection as used by caches.
+func useRedis(conn *redis.Client) {
+	redisMu.Lock()
+	defer redisMu.Unlock()
+	redisInUse[conn] = true
+}
+
+// Ping checks the redis connections used by caches.
+// It always succeeds if no cache uses redis.
+func Ping(ctx context.Context) error {
+	redisMu.Lock()
+	conns := make([]*redis.Client, 0, len(redisInUse))
+	for conn := range redisInUse {
+		conns = append(conns, conn)
+	}
+	redisMu.Unlock()
+	for _, conn := range conns {
+		if err := conn.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("redis %s: %v", conn.Options().Addr, err)
+		}
+	}
+	return nil
+}
+
+func newRistretto(limit int64, codec Codec, onEvict func(any) error) ICache {
 	max_cost := util.Tenary(limit > 0, limit, 1024)
 	num_counters := util.Tenary(max_cost > 1e5, 1e6, max_cost<<3)
 	ristretto, err := ristretto.NewCache(&ristretto.Config{
@@ -94,6 +179,7 @@ func newRistretto(limit int64, onEvict func(any) error) ICache {
 		NumCounters:        num_counters,
 		MaxCost:            max_cost,
 		BufferItems:        64,
+		Metrics:            true,
 		OnEvict:            util.Tenary(onEvict != nil, func(item *ristretto.Item) { onEvict(item.Value) }, nil),
 	})
 	if err != nil {
@@ -101,15 +187,18 @@ func newRistretto(limit int64, onEvict func(any) error) ICache {
 	}
 	cache := &Ristretto{
 		limit: limit,
+		codec: codec,
 		cache: ristretto,
 	}
 	return cache
 }
 
-func newRedis(conn *redis.Client, prefix string, limit int64, onEvict func(any) error) ICache {
+func newRedis(conn *redis.Client, prefix string, limit int64, codec Codec, onEvict func(any) error) ICache {
+	useRedis(conn)
 	cache := &Redis{
 		prefix:  prefix,
 		limit:   limit,
+		codec:   codec,
 		onEvict: onEvict,
 		rdb:     conn,
 	}
@@ -122,9 +211,11 @@ func (client *Ristretto) Get(key string) (any, bool) {
 
 func (client *Ristretto) Set(key string, value any, expire time.Duration) bool {
 	size := util.Tenary(client.limit > 0, int64(unsafe.Sizeof(value)), 0)
-	return client.cache.SetWithTTL(key, value, size, expire)
+	return client.SetWithCost(key, value, size, expire)
 }
 
+// SetWithCost sets the value asynchronously, as ristretto does, so that it
+// may not be got right after.
 func (client *Ristretto) SetWithCost(key string, value any, cost int64, expire time.Duration) bool {
 	size := util.Tenary(client.limit > 0, cost, 0)
 	return client.cache.SetWithTTL(key, value, size, expire)
@@ -134,22 +225,31 @@ func (client *Ristretto) Del(key string) {
 	client.cache.Del(key)
 }
 
+// Codec is not used by Ristretto, which holds values as they are.
+func (client *Ristretto) Codec() Codec {
+	return client.codec
+}
+
 func (client *Redis) Get(key string) (any, bool) {
 	ctx := context.Background()
 	redisKey := fmt.Sprintf("%s:%s", client.prefix, key)
-	value, err := client.rdb.Get(ctx, redisKey).Result()
+	value, err := client.rdb.Get(ctx, redisKey).Bytes()
 	if err == redis.Nil {
+		client.misses.Add(1)
 		return nil, false
 	}
 	if err != nil && err != redis.Nil {
 		logger.Logger.Warnf("Redis error: %+v", err)
+		client.misses.Add(1)
+		return nil, false
 	}
+	client.hits.Add(1)
 	if client.limit > 0 {
 		if _, err := client.rdb.ZAdd(ctx, client.prefix+"timestamp", &redis.Z{Score: float64(time.Now().Unix()), Member: redisKey}).Result(); err != nil {
 			logger.Logger.Warnf("Redis error: %+v", err)
 		}
 	}
-	return value, true
+	return encoded(value), true
 }
 
 func (client *Redis) Set(key string, value any, expire time.Duration) bool {
@@ -160,7 +260,12 @@ func (client *Redis) Set(key string, value any, expire time.Duration) bool {
 func (client *Redis) SetWithCost(key string, value any, cost int64, expire time.Duration) bool {
 	ctx := context.Background()
 	redisKey := fmt.Sprintf("%s:%s", client.prefix, key)
-	if _, err := client.rdb.Set(ctx, redisKey, value, expire).Result(); err != nil {
+	data, err := client.codec.Marshal(value)
+	if err != nil {
+		logger.Logger.Warnf("Redis encode error: %+v", err)
+		return false
+	}
+	if _, err := client.rdb.Set(ctx, redisKey, data, expire).Result(); err != nil {
 		logger.Logger.Warnf("Redis error: %+v", err)
 		return false
 	}
@@ -184,18 +289,21 @@ func (client *Redis) SetWithCost(key string, value any, cost int64, expire time.
 				}
 				if client.onEvict != nil {
 					for _, candidate := range candidates {
-						value, err := client.rdb.Get(ctx, redisKey).Result()
-						if err != nil && err != redis.Nil {
-							logger.Logger.Warnf("Redis error: %+v", err)
+						value, err := client.rdb.Get(ctx, candidate).Bytes()
+						if err != nil {
+							if err != redis.Nil {
+								logger.Logger.Warnf("Redis error: %+v", err)
+							}
+							continue
 						}
 
-						if err := client.onEvict(value); err != nil {
+						if err := client.onEvict(encoded(value)); err != nil {
 							logger.Logger.Debugf("Failed to run evict function on %s: %+v", candidate, err)
 						}
 					}
 				}
 				for _, candidate := range candidates {
-					client.Del(candidate)
+					client.Del(strings.TrimPrefix(candidate, client.prefix+":"))
 				}
 			}()
 		}
@@ -225,3 +333,7 @@ func (client *Redis) Del(key string) {
 		}
 	}
 }
+
+func (client *Redis) Codec() Codec {
+	return client.codec
+}
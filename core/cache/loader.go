@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+)
+
+// Loader loads values into a cache on misses. Concurrent misses of the same
+// key share one load, and not found results are cached for a while, so that
+// a miss storm can not hammer the database.
+type Loader[T any] struct {
+	cache       ICache
+	ttl         time.Duration
+	jitter      time.Duration
+	negativeTTL time.Duration
+	notFound    error
+	group       group[T]
+}
+
+var errPanicked = errors.New("cache: load panicked")
+
+type call[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// group runs only one load of the same key at a time.
+type group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+// NewLoader returns a loader of cache c. Values are cached for ttl plus a
+// random duration up to jitter, and 0 ttl means they never expire. Load
+// errors matching notFound are cached for negativeTTL, and 0 negativeTTL or
+// nil notFound disables it. c can be nil, in which case only concurrent
+// loads are shared.
+func NewLoader[T any](c ICache, ttl, jitter, negativeTTL time.Duration, notFound error) *Loader[T] {
+	return &Loader[T]{
+		cache:       c,
+		ttl:         ttl,
+		jitter:      jitter,
+		negativeTTL: negativeTTL,
+		notFound:    notFound,
+	}
+}
+
+// Get gets the value of key, and calls load on misses.
+func (l *Loader[T]) Get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	return l.GetWithCost(ctx, key, func(ctx context.Context) (T, int64, error) {
+		value, err := load(ctx)
+		return value, -1, err
+	})
+}
+
+// GetWithCost is like Get, but load also returns the cost of the value.
+// Negative cost means the cost is computed by the cache.
+//
+// The load is shared by concurrent misses, so it is not canceled with ctx
+// of the caller which happens to run it, but after database.timeout.
+// Other callers stop waiting for it once their own ctx is done.
+func (l *Loader[T]) GetWithCost(ctx context.Context, key string, load func(ctx context.Context) (T, int64, error)) (T, error) {
+	if l.cache != nil {
+		if value, ok := GetAs[T](l.cache, key); ok {
+			return value, nil
+		}
+		if l.negative() {
+			if _, ok := l.cache.Get(negativeKey(key)); ok {
+				var t T
+				return t, l.notFound
+			}
+		}
+	}
+	return l.group.do(ctx, key, func() (T, error) {
+		ctx, cancel := loadContext(ctx)
+		defer cancel()
+		value, cost, err := load(ctx)
+		if l.cache == nil {
+			return value, err
+		}
+		if err != nil {
+			if l.negative() && errors.Is(err, l.notFound) {
+				SetAs(l.cache, negativeKey(key), true, l.negativeTTL)
+			}
+			return value, err
+		}
+		if cost < 0 {
+			SetAs(l.cache, key, value, l.expire())
+		} else {
+			SetAsWithCost(l.cache, key, value, cost, l.expire())
+		}
+		return value, nil
+	})
+}
+
+// Del deletes key and its not found result from the cache.
+func (l *Loader[T]) Del(key string) {
+	if l.cache == nil {
+		return
+	}
+	l.cache.Del(key)
+	if l.negative() {
+		l.cache.Del(negativeKey(key))
+	}
+}
+
+func (l *Loader[T]) negative() bool {
+	return l.notFound != nil && l.negativeTTL > 0
+}
+
+func (l *Loader[T]) expire() time.Duration {
+	if l.ttl <= 0 || l.jitter <= 0 {
+		return l.ttl
+	}
+	return l.ttl + time.Duration(rand.Int63n(int64(l.jitter)))
+}
+
+func loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if timeout := config.AppConfig.GetDuration("database.timeout"); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func negativeKey(key string) string {
+	return "!" + key
+}
+
+func (g *group[T]) do(ctx context.Context, key string, fn func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call[T]{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.value, c.err
+		case <-ctx.Done():
+			var t T
+			return t, ctx.Err()
+		}
+	}
+	// followers get errPanicked if fn panics
+	c := &call[T]{done: make(chan struct{}), err: errPanicked}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errLoaderTestNotFound = errors.New("not found")
+
+func TestLoaderSingleflight(t *testing.T) {
+	c := newRistretto(0, JSONCodec, nil)
+	l := NewLoader[string](c, 0, 0, 0, nil)
+
+	loads := int32(0)
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := l.Get(context.Background(), "key", load); err != nil || v != "value" {
+				t.Errorf("Expect value, but got %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if loads != 1 {
+		t.Errorf("Expect 1 load of concurrent misses, but got %d", loads)
+	}
+
+	c.(*Ristretto).cache.Wait()
+	if v, err := l.Get(context.Background(), "key", load); err != nil || v != "value" || loads != 1 {
+		t.Errorf("Expect value from the cache, but got %q, %v with %d loads", v, err, loads)
+	}
+	l.Del("key")
+	l.Get(context.Background(), "key", load)
+	if loads != 2 {
+		t.Errorf("Expect value loaded again after Del, but got %d loads", loads)
+	}
+}
+
+func TestLoaderCancel(t *testing.T) {
+	l := NewLoader[string](nil, 0, 0, 0, nil)
+	started := make(chan struct{})
+	once := sync.Once{}
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		once.Do(func() { close(started) })
+		<-release
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "value", nil
+	}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := l.Get(leaderCtx, "key", load)
+		leader <- err
+	}()
+	<-started
+	follower := make(chan string, 1)
+	go func() {
+		v, _ := l.Get(context.Background(), "key", load)
+		follower <- v
+	}()
+	// a follower gives up with its own context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Get(ctx, "key", load); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expect follower timed out, but got %v", err)
+	}
+
+	// the leader canceled does not fail the shared load
+	cancelLeader()
+	close(release)
+	if err := <-leader; err != nil {
+		t.Errorf("Expect load not canceled with the leader, but got %v", err)
+	}
+	if v := <-follower; v != "value" {
+		t.Errorf("Expect follower got value, but got %q", v)
+	}
+}
+
+func TestLoaderNegative(t *testing.T) {
+	c := newRistretto(0, JSONCodec, nil)
+	l := NewLoader[int](c, 0, 0, time.Minute, errLoaderTestNotFound)
+
+	loads := 0
+	load := func(ctx context.Context) (int, error) {
+		loads++
+		return 0, errLoaderTestNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := l.Get(context.Background(), "missing", load); !errors.Is(err, errLoaderTestNotFound) {
+			t.Errorf("Expect not found, but got %v", err)
+		}
+		c.(*Ristretto).cache.Wait()
+	}
+	if loads != 1 {
+		t.Errorf("Expect not found cached, but got %d loads", loads)
+	}
+
+	l.Del("missing")
+	v, err := l.Get(context.Background(), "missing", func(ctx context.Context) (int, error) { return 42, nil })
+	if err != nil || v != 42 {
+		t.Errorf("Expect 42 after Del, but got %d, %v", v, err)
+	}
+
+	// other errors are not cached
+	fails := 0
+	fail := func(ctx context.Context) (int, error) {
+		fails++
+		return 0, errors.New("connection refused")
+	}
+	l.Get(context.Background(), "broken", fail)
+	c.(*Ristretto).cache.Wait()
+	l.Get(context.Background(), "broken", fail)
+	if fails != 2 {
+		t.Errorf("Expect errors not cached, but got %d loads", fails)
+	}
+}
+
+func TestLoaderExpire(t *testing.T) {
+	l := NewLoader[int](nil, time.Minute, 10*time.Second, 0, nil)
+	for i := 0; i < 100; i++ {
+		if d := l.expire(); d < time.Minute || d >= time.Minute+10*time.Second {
+			t.Fatalf("Expect expire in [1m, 1m10s), but got %v", d)
+		}
+	}
+	if d := NewLoader[int](nil, 0, time.Minute, 0, nil).expire(); d != 0 {
+		t.Errorf("Expect no expire with 0 ttl, but got %v", d)
+	}
+
+	// nil cache only shares concurrent loads
+	loads := 0
+	load := func(ctx context.Context) (int, error) {
+		loads++
+		return 1, nil
+	}
+	l.Get(context.Background(), "key", load)
+	l.Get(context.Background(), "key", load)
+	if loads != 2 {
+		t.Errorf("Expect no cache, but got %d loads", loads)
+	}
+}
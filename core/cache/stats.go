@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Stats is the statistics of a cache. Cost is the total cost of entries,
+// which is counted only when limit is set. Entries is counted by redis only,
+// as local caches admit, expire and evict entries asynchronously.
+type Stats struct {
+	Driver   string  `json:"driver"`
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Entries  *int64  `json:"entries,omitempty"`
+	Cost     int64   `json:"cost"`
+	Limit    int64   `json:"limit"`
+	Local    *Stats  `json:"local,omitempty"` // local tier of tiered cache
+}
+
+// Names returns names of caches initialized by InitCache in order.
+func Names() []string {
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetCache returns the cache initialized by InitCache with name.
+func GetCache(name string) (ICache, bool) {
+	c, ok := caches[name]
+	return c, ok
+}
+
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+func (client *Ristretto) Stats() Stats {
+	m := client.cache.Metrics
+	return Stats{
+		Driver:   "local",
+		Hits:     m.Hits(),
+		Misses:   m.Misses(),
+		HitRatio: m.Ratio(),
+		Cost:     int64(m.CostAdded() - m.CostEvicted()),
+		Limit:    client.limit,
+	}
+}
+
+// Purge deletes all entries, and calls the evict function on them.
+func (client *Ristretto) Purge() {
+	client.cache.Clear()
+}
+
+func (client *Redis) Stats() Stats {
+	ctx := context.Background()
+	stats := Stats{
+		Driver: "redis",
+		Hits:   client.hits.Load(),
+		Misses: client.misses.Load(),
+		Limit:  client.limit,
+	}
+	stats.HitRatio = hitRatio(stats.Hits, stats.Misses)
+	entries := int64(0)
+	if err := client.scan(ctx, func(keys []string) {
+		entries += int64(len(keys))
+	}); err != nil {
+		logger.Logger.Warnf("Redis error: %+v", err)
+	}
+	stats.Entries = &entries
+	cost, err := client.rdb.Get(ctx, client.prefix+"size").Int64()
+	if err != nil && err != redis.Nil {
+		logger.Logger.Warnf("Redis error: %+v", err)
+	}
+	stats.Cost = cost
+	return stats
+}
+
+// Purge deletes all entries, and calls the evict function on them.
+func (client *Redis) Purge() {
+	ctx := context.Background()
+	err := client.scan(ctx, func(keys []string) {
+		if client.onEvict != nil {
+			values, err := client.rdb.MGet(ctx, keys...).Result()
+			if err != nil {
+				logger.Logger.Warnf("Redis error: %+v", err)
+			}
+			for i, value := range values {
+				if s, ok := value.(string); ok {
+					if err := client.onEvict(encoded(s)); err != nil {
+						logger.Logger.Debugf("Failed to run evict function on %s: %+v", keys[i], err)
+					}
+				}
+			}
+		}
+		if err := client.rdb.Del(ctx, keys...).Err(); err != nil {
+			logger.Logger.Warnf("Redis error: %+v", err)
+		}
+	})
+	if err != nil {
+		logger.Logger.Warnf("Redis error: %+v", err)
+	}
+	if err := client.rdb.Del(ctx, client.prefix+"size", client.prefix+"timestamp").Err(); err != nil {
+		logger.Logger.Warnf("Redis error: %+v", err)
+	}
+}
+
+// scan calls fn with batches of keys of the cache.
+func (client *Redis) scan(ctx context.Context, fn func(keys []string)) error {
+	match := fmt.Sprintf("%s:*", client.prefix)
+	cursor := uint64(0)
+	for {
+		keys, next, err := client.rdb.Scan(ctx, cursor, match, 256).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			fn(keys)
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func (client *Synced) Purge() {
+	client.ICache.Purge()
+	client.bus.publishPurge(client.name)
+}
+
+func (client *Tiered) Stats() Stats {
+	stats := client.remote.Stats()
+	stats.Driver = "tiered"
+	local := client.local.Stats()
+	stats.Local = &local
+	return stats
+}
+
+func (client *Tiered) Purge() {
+	client.remote.Purge()
+	client.local.Purge()
+	client.bus.publishPurge(client.name)
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/kataras/golog"
+)
+
+func TestRistrettoStats(t *testing.T) {
+	evicted := []any{}
+	c := newRistretto(1024, JSONCodec, func(v any) error {
+		evicted = append(evicted, v)
+		return nil
+	}).(*Ristretto)
+
+	c.SetWithCost("a", "image-a", 100, 0)
+	c.SetWithCost("b", "image-b", 200, 0)
+	c.cache.Wait()
+	c.Get("a")
+	c.Get("missing")
+
+	stats := c.Stats()
+	if stats.Driver != "local" || stats.Limit != 1024 {
+		t.Errorf("Expect local cache with limit 1024, but got %+v", stats)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.HitRatio != 0.5 {
+		t.Errorf("Expect 1 hit and 1 miss, but got %+v", stats)
+	}
+	if stats.Entries != nil || stats.Cost != 300 {
+		t.Errorf("Expect cost 300 without entries, but got %+v", stats)
+	}
+
+	c.Del("b")
+	c.cache.Wait()
+	if stats := c.Stats(); stats.Cost != 100 {
+		t.Errorf("Expect cost 100 after Del, but got %+v", stats)
+	}
+
+	c.Purge()
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expect entries purged")
+	}
+	if len(evicted) != 1 || evicted[0] != "image-a" {
+		t.Errorf("Expect evict function called on purged entries, but got %v", evicted)
+	}
+	if stats := c.Stats(); stats.Cost != 0 {
+		t.Errorf("Expect no cost after Purge, but got %+v", stats)
+	}
+}
+
+func TestRedisStats(t *testing.T) {
+	logger.Logger = golog.New()
+	s := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	t.Cleanup(func() { conn.Close() })
+	c := newRedis(conn, "image", 1024, JSONCodec, nil)
+
+	c.SetWithCost("a", "image-a", 100, 0)
+	c.SetWithCost("b", "image-b", 200, 0)
+	c.Del("b")
+	c.Get("a")
+	c.Get("missing")
+
+	stats := c.Stats()
+	if stats.Driver != "redis" || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expect redis cache with 1 hit and 1 miss, but got %+v", stats)
+	}
+	if stats.Entries == nil || *stats.Entries != 1 {
+		t.Errorf("Expect 1 entry, but got %+v", stats.Entries)
+	}
+
+	c.Purge()
+	if stats := c.Stats(); stats.Entries == nil || *stats.Entries != 0 {
+		t.Errorf("Expect no entries after Purge, but got %+v", stats.Entries)
+	}
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const invalidateChannel = "maintainman:cache:invalidate"
+
+var buses = map[*redis.Client]*bus{}
+
+// bus publishes deleted keys to other instances over redis pub/sub, and
+// deletes keys published by other instances from local caches. Each bus
+// is an instance of its own, identified by id.
+type bus struct {
+	id     string
+	conn   *redis.Client
+	pubsub *redis.PubSub
+	mu     sync.RWMutex
+	caches map[string]ICache
+}
+
+type invalidateMessage struct {
+	From  string `json:"from"`
+	Cache string `json:"cache"`
+	Key   string `json:"key"`
+	Purge bool   `json:"purge,omitempty"`
+}
+
+// Synced is a local cache whose deletions are published to other instances.
+type Synced struct {
+	ICache
+	name string
+	bus  *bus
+}
+
+// Tiered is a two-tier cache, which holds entries in a local cache for a
+// short while in front of redis. Deletions are published to other instances.
+type Tiered struct {
+	local  *Ristretto
+	remote *Redis
+	ttl    time.Duration
+	name   string
+	bus    *bus
+}
+
+func getBus(conn *redis.Client) *bus {
+	if b, ok := buses[conn]; ok {
+		return b
+	}
+	b := &bus{
+		id:     uuid.NewString(),
+		conn:   conn,
+		pubsub: conn.Subscribe(context.Background(), invalidateChannel),
+		caches: map[string]ICache{},
+	}
+	go b.listen()
+	buses[conn] = b
+	useRedis(conn)
+	return b
+}
+
+// register sets the local cache, in which keys published by other
+// instances are deleted.
+func (b *bus) register(name string, local ICache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.caches[name] = local
+}
+
+func (b *bus) publish(name, key string) {
+	b.send(invalidateMessage{From: b.id, Cache: name, Key: key})
+}
+
+func (b *bus) publishPurge(name string) {
+	b.send(invalidateMessage{From: b.id, Cache: name, Purge: true})
+}
+
+func (b *bus) send(m invalidateMessage) {
+	data, _ := json.Marshal(m)
+	if err := b.conn.Publish(context.Background(), invalidateChannel, data).Err(); err != nil {
+		logger.Logger.Warnf("Redis publish error: %+v", err)
+	}
+}
+
+func (b *bus) listen() {
+	for msg := range b.pubsub.Channel() {
+		b.handle(msg.Payload)
+	}
+}
+
+func (b *bus) handle(payload string) {
+	m := invalidateMessage{}
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		logger.Logger.Debugf("Invalid cache invalidation: %s", payload)
+		return
+	}
+	if m.From == b.id {
+		return
+	}
+	b.mu.RLock()
+	local, ok := b.caches[m.Cache]
+	b.mu.RUnlock()
+	switch {
+	case !ok:
+	case m.Purge:
+		local.Purge()
+	default:
+		local.Del(m.Key)
+	}
+}
+
+func newSynced(conn *redis.Client, name string, local ICache) ICache {
+	b := getBus(conn)
+	b.register(name, local)
+	return &Synced{
+		ICache: local,
+		name:   name,
+		bus:    b,
+	}
+}
+
+func (client *Synced) Del(key string) {
+	client.ICache.Del(key)
+	client.bus.publish(client.name, key)
+}
+
+func newTiered(conn *redis.Client, name string, limit int64, ttl time.Duration, codec Codec, onEvict func(any) error) ICache {
+	// entries evicted from the local cache are still in redis,
+	// so onEvict is called by redis only
+	local := newRistretto(limit, codec, nil).(*Ristretto)
+	b := getBus(conn)
+	b.register(name, local)
+	return &Tiered{
+		local:  local,
+		remote: newRedis(conn, name, limit, codec, onEvict).(*Redis),
+		ttl:    ttl,
+		name:   name,
+		bus:    b,
+	}
+}
+
+func (client *Tiered) Get(key string) (any, bool) {
+	if value, ok := client.local.Get(key); ok {
+		return value, true
+	}
+	value, ok := client.remote.Get(key)
+	if ok {
+		client.local.Set(key, value, client.ttl)
+	}
+	return value, ok
+}
+
+func (client *Tiered) Set(key string, value any, expire time.Duration) bool {
+	if !client.remote.Set(key, value, expire) {
+		return false
+	}
+	client.local.Set(key, value, client.localExpire(expire))
+	return true
+}
+
+func (client *Tiered) SetWithCost(key string, value any, cost int64, expire time.Duration) bool {
+	if !client.remote.SetWithCost(key, value, cost, expire) {
+		return false
+	}
+	client.local.SetWithCost(key, value, cost, client.localExpire(expire))
+	return true
+}
+
+func (client *Tiered) Del(key string) {
+	client.remote.Del(key)
+	client.local.Del(key)
+	client.bus.publish(client.name, key)
+}
+
+func (client *Tiered) Codec() Codec {
+	return client.remote.codec
+}
+
+func (client *Tiered) localExpire(expire time.Duration) time.Duration {
+	if expire > 0 && (client.ttl <= 0 || expire < client.ttl) {
+		return expire
+	}
+	return client.ttl
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/kataras/golog"
+)
+
+func TestBusHandle(t *testing.T) {
+	logger.Logger = golog.New()
+	user := newRistretto(0, JSONCodec, nil).(*Ristretto)
+	image := newRistretto(0, JSONCodec, nil).(*Ristretto)
+	b := &bus{id: "self", caches: map[string]ICache{"user": user, "image": image}}
+	for _, key := range []string{"1", "2"} {
+		user.Set(key, key, 0)
+		image.Set(key, key, 0)
+	}
+	user.cache.Wait()
+	image.cache.Wait()
+
+	publish := func(from, cache, key string) {
+		data, _ := json.Marshal(invalidateMessage{From: from, Cache: cache, Key: key})
+		b.handle(string(data))
+	}
+	publish("other", "user", "1")
+	publish("self", "user", "2")
+	publish("other", "unknown", "2")
+	b.handle("not json")
+
+	if _, ok := user.Get("1"); ok {
+		t.Error("Expect key deleted by other instance")
+	}
+	if _, ok := user.Get("2"); !ok {
+		t.Error("Expect key published by itself kept")
+	}
+	if _, ok := image.Get("1"); !ok {
+		t.Error("Expect key of other cache kept")
+	}
+}
+
+func TestTieredLocalExpire(t *testing.T) {
+	c := &Tiered{ttl: time.Minute}
+	cases := map[time.Duration]time.Duration{
+		0:                time.Minute,
+		time.Second:      time.Second,
+		time.Hour:        time.Minute,
+		-1 * time.Second: time.Minute,
+	}
+	for expire, want := range cases {
+		if got := c.localExpire(expire); got != want {
+			t.Errorf("Expect local expire %v of %v, but got %v", want, expire, got)
+		}
+	}
+	c.ttl = 0
+	if got := c.localExpire(time.Hour); got != time.Hour {
+		t.Errorf("Expect local expire 1h without ttl, but got %v", got)
+	}
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	logger.Logger = golog.New()
+	s := miniredis.RunT(t)
+	// each connection has a bus of its own, as two instances sharing redis
+	connA := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	connB := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	a := newTiered(connA, "user", 0, time.Minute, JSONCodec, nil).(*Tiered)
+	b := newTiered(connB, "user", 0, time.Minute, JSONCodec, nil).(*Tiered)
+	t.Cleanup(func() {
+		Close()
+		connA.Close()
+		connB.Close()
+	})
+
+	if !a.Set("1", "admin", 0) {
+		t.Fatal("Expect key set")
+	}
+	if got, ok := GetAs[string](b, "1"); !ok || got != "admin" {
+		t.Fatalf("Expect admin read through redis, but got %q, %v", got, ok)
+	}
+	b.local.cache.Wait()
+	if _, ok := b.local.Get("1"); !ok {
+		t.Fatal("Expect key held locally after read")
+	}
+
+	a.Del("1")
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if _, ok := b.local.Get("1"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expect local copy of the other instance evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := b.Get("1"); ok {
+		t.Error("Expect key deleted from both tiers")
+	}
+}
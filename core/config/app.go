@@ -1,17 +1,23 @@
 package config
 
 import (
-	"github.com/spf13/viper"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 )
 
-const AppConfigVersion = "1.3.2"
+const AppConfigVersion = "1.3.18"
+
+// DefaultTokenKey is the insecure token secret shipped by default.
+const DefaultTokenKey = "xaxys_2022_all_rights_reserved"
 
 var (
-	AppConfig *viper.Viper
+	AppConfig *Config
 )
 
 func init() {
-	AppConfig = viper.New()
+	AppConfig = New()
 	AppConfig.SetConfigName("app")
 	AppConfig.SetConfigType("yaml")
 	AppConfig.AddConfigPath(".")
@@ -20,25 +26,47 @@ func init() {
 	AppConfig.AddConfigPath("$HOME/.maintainman/")
 
 	AppConfig.SetDefault("app.name", "maintainman")
+	AppConfig.SetDefault("app.mode", "development")
 	AppConfig.SetDefault("app.listen", ":8787")
 	AppConfig.SetDefault("app.loglevel", "info")
 	AppConfig.SetDefault("app.page.limit", 100)
 	AppConfig.SetDefault("app.page.default", 50)
+	AppConfig.SetDefault("app.shutdown_timeout", "10s")
+	AppConfig.SetDefault("app.hot_reload", true)
+	AppConfig.SetDefault("app.health_timeout", "3s")
 
-	AppConfig.SetDefault("token.key", "xaxys_2022_all_rights_reserved")
+	AppConfig.SetDefault("token.key", DefaultTokenKey)
 	AppConfig.SetDefault("token.expire", "30m")
 
 	AppConfig.SetDefault("database.driver", "sqlite")
 	AppConfig.SetDefault("database.sqlite.path", "maintainman.db")
+	AppConfig.SetDefault("database.sqlite.busy_timeout", "5s")
 	AppConfig.SetDefault("database.mysql.host", "localhost")
 	AppConfig.SetDefault("database.mysql.port", 3306)
 	AppConfig.SetDefault("database.mysql.name", "maintainman")
 	AppConfig.SetDefault("database.mysql.params", "parseTime=true&loc=Local&charset=utf8mb4")
 	AppConfig.SetDefault("database.mysql.user", "root")
 	AppConfig.SetDefault("database.mysql.password", "123456")
+	AppConfig.SetDefault("database.postgres.host", "localhost")
+	AppConfig.SetDefault("database.postgres.port", 5432)
+	AppConfig.SetDefault("database.postgres.name", "maintainman")
+	AppConfig.SetDefault("database.postgres.params", "sslmode=disable")
+	AppConfig.SetDefault("database.postgres.user", "postgres")
+	AppConfig.SetDefault("database.postgres.password", "123456")
+	AppConfig.SetDefault("database.pool.max_open", 0)
+	AppConfig.SetDefault("database.pool.max_idle", 2)
+	AppConfig.SetDefault("database.pool.max_lifetime", "0s")
+	AppConfig.SetDefault("database.pool.max_idle_time", "0s")
+	AppConfig.SetDefault("database.replicas", []string{})
+	AppConfig.SetDefault("database.timeout", "30s")
+	AppConfig.SetDefault("database.trash.retention", "0s")
+	AppConfig.SetDefault("database.trash.purge", "1h")
 
 	AppConfig.SetDefault("cache.driver", "local")
 	AppConfig.SetDefault("cache.limit", 268435456)
+	AppConfig.SetDefault("cache.codec", "json")
+	AppConfig.SetDefault("cache.sync", false)
+	AppConfig.SetDefault("cache.local_ttl", "1m")
 	AppConfig.SetDefault("cache.redis.host", "localhost")
 	AppConfig.SetDefault("cache.redis.port", 6379)
 	AppConfig.SetDefault("cache.redis.password", "")
@@ -49,6 +77,15 @@ func init() {
 	AppConfig.SetDefault("storage.s3.secret_key", "SECRET_KEY")
 	AppConfig.SetDefault("storage.s3.bucket", "BUCKET")
 	AppConfig.SetDefault("storage.s3.region", "REGION")
+	AppConfig.SetDefault("storage.s3.endpoint", "")
+	AppConfig.SetDefault("storage.s3.path_style", false)
+	AppConfig.SetDefault("storage.s3.tls.insecure_skip_verify", false)
+	AppConfig.SetDefault("storage.s3.tls.ca_file", "")
+	AppConfig.SetDefault("storage.s3.multipart.threshold", 67108864)
+	AppConfig.SetDefault("storage.s3.multipart.part_size", 16777216)
+	AppConfig.SetDefault("storage.sign.key", "")
+	AppConfig.SetDefault("storage.sign.base_url", "")
+	AppConfig.SetDefault("storage.sign.max_expire", "168h")
 
 	AppConfig.SetDefault("throttling.enable", false)
 	AppConfig.SetDefault("throttling.burst", 100)
@@ -64,8 +101,92 @@ func init() {
 	AppConfig.SetDefault("module.wxnotify", true)
 	AppConfig.SetDefault("module.word", true)
 	AppConfig.SetDefault("module.sysinfo", true)
+	AppConfig.SetDefault("module.module", true)
+	AppConfig.SetDefault("module.audit", true)
+	AppConfig.SetDefault("module.cache", true)
 
 	AppConfig.SetDefault("bus_buffer", 1000)
 
-	ReadAndUpdateConfig(AppConfig, "app", AppConfigVersion)
+	SetConfigSchema(AppConfig, Schema{
+		"app.name":             String(),
+		"app.listen":           NonEmpty(),
+		"app.loglevel":         String(),
+		"app.mode":             OneOf("development", "production"),
+		"app.page.limit":       IntMin(1),
+		"app.page.default":     IntMin(1),
+		"app.shutdown_timeout": DurationMin(0),
+		"app.hot_reload":       Bool(),
+		"app.health_timeout":   DurationMin(time.Millisecond),
+
+		"token.key":    NonEmpty(),
+		"token.expire": DurationMin(time.Second),
+
+		"database.driver":              OneOf("sqlite", "mysql", "postgres"),
+		"database.sqlite.path":         NonEmpty(),
+		"database.sqlite.busy_timeout": DurationMin(0),
+		"database.mysql.host":          NonEmpty(),
+		"database.mysql.port":          Int(1, 65535),
+		"database.mysql.name":          NonEmpty(),
+		"database.mysql.params":        String(),
+		"database.mysql.user":          NonEmpty(),
+		"database.mysql.password":      String(),
+		"database.postgres.host":       NonEmpty(),
+		"database.postgres.port":       Int(1, 65535),
+		"database.postgres.name":       NonEmpty(),
+		"database.postgres.params":     String(),
+		"database.postgres.user":       NonEmpty(),
+		"database.postgres.password":   String(),
+		"database.pool.max_open":       IntMin(0),
+		"database.pool.max_idle":       IntMin(0),
+		"database.pool.max_lifetime":   DurationMin(0),
+		"database.pool.max_idle_time":  DurationMin(0),
+		"database.replicas":            List(),
+		"database.timeout":             DurationMin(0),
+		"database.trash.retention":     DurationMin(0),
+		"database.trash.purge":         DurationMin(time.Second),
+
+		"cache.driver":         OneOf("local", "redis", "tiered"),
+		"cache.limit":          IntMin(0),
+		"cache.codec":          OneOf("json", "gob", "msgpack"),
+		"cache.sync":           Bool(),
+		"cache.local_ttl":      DurationMin(0),
+		"cache.redis.host":     String(),
+		"cache.redis.port":     Int(1, 65535),
+		"cache.redis.password": String(),
+
+		"storage.driver":        OneOf("local", "s3"),
+		"storage.local.path":    NonEmpty(),
+		"storage.s3.access_key": String(),
+		"storage.s3.secret_key": String(),
+		"storage.s3.bucket":     String(),
+		"storage.s3.region":     String(),
+		"storage.s3.endpoint":   String(),
+		"storage.s3.path_style": Bool(),
+
+		"storage.s3.tls.insecure_skip_verify": Bool(),
+		"storage.s3.tls.ca_file":              String(),
+		"storage.s3.multipart.threshold":      IntMin(0),
+		"storage.s3.multipart.part_size":      IntMin(5242880),
+		"storage.sign.key":                    String(),
+		"storage.sign.base_url":               String(),
+		"storage.sign.max_expire":             DurationMin(0),
+
+		"throttling.enable": Bool(),
+		"throttling.burst":  IntMin(0),
+		"throttling.rate":   IntMin(0),
+		"throttling.purge":  DurationMin(0),
+		"throttling.expire": DurationMin(0),
+
+		"bus_buffer": IntMin(0),
+	})
+
+	if err := ReadAndUpdateConfig(AppConfig, "app", AppConfigVersion); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// IsProduction reports whether the app runs in production mode.
+func IsProduction() bool {
+	return strings.ToLower(AppConfig.GetString("app.mode")) == "production"
 }
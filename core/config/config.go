@@ -0,0 +1,56 @@
+package config
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config is a configuration read from a snapshot, which is replaced as a
+// whole on reload, so that reads never race with reloads. A snapshot is
+// never changed once the configuration is watched, and SetConfig changes
+// it at runtime instead. The methods changing the current snapshot, such as
+// SetDefault and Set, are only for startup and tests.
+type Config struct {
+	snapshot atomic.Pointer[viper.Viper]
+}
+
+// New returns an empty configuration.
+func New() *Config {
+	c := &Config{}
+	c.snapshot.Store(viper.New())
+	return c
+}
+
+// Viper returns the current snapshot of the configuration, which must not
+// be changed after the configuration is watched. It is nil if c is nil.
+func (c *Config) Viper() *viper.Viper {
+	if c == nil {
+		return nil
+	}
+	return c.snapshot.Load()
+}
+
+func (c *Config) publish(v *viper.Viper) {
+	c.snapshot.Store(v)
+}
+
+func (c *Config) Get(key string) any                     { return c.Viper().Get(key) }
+func (c *Config) GetString(key string) string            { return c.Viper().GetString(key) }
+func (c *Config) GetBool(key string) bool                { return c.Viper().GetBool(key) }
+func (c *Config) GetInt(key string) int                  { return c.Viper().GetInt(key) }
+func (c *Config) GetInt64(key string) int64              { return c.Viper().GetInt64(key) }
+func (c *Config) GetUint(key string) uint                { return c.Viper().GetUint(key) }
+func (c *Config) GetDuration(key string) time.Duration   { return c.Viper().GetDuration(key) }
+func (c *Config) GetStringSlice(key string) []string     { return c.Viper().GetStringSlice(key) }
+func (c *Config) IsSet(key string) bool                  { return c.Viper().IsSet(key) }
+func (c *Config) AllKeys() []string                      { return c.Viper().AllKeys() }
+func (c *Config) ConfigFileUsed() string                 { return c.Viper().ConfigFileUsed() }
+func (c *Config) UnmarshalKey(key string, out any) error { return c.Viper().UnmarshalKey(key, out) }
+
+func (c *Config) SetDefault(key string, value any) { c.Viper().SetDefault(key, value) }
+func (c *Config) Set(key string, value any)        { c.Viper().Set(key, value) }
+func (c *Config) SetConfigName(name string)        { c.Viper().SetConfigName(name) }
+func (c *Config) SetConfigType(typ string)         { c.Viper().SetConfigType(typ) }
+func (c *Config) AddConfigPath(path string)        { c.Viper().AddConfigPath(path) }
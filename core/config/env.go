@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of environment variables overriding configurations.
+// Keys of app configuration are overridden by MAINTAINMAN_<KEY>, and keys of
+// module configuration are overridden by MAINTAINMAN_<MODULE>_<KEY>, where
+// dots in the key are replaced by underscores, e.g. MAINTAINMAN_TOKEN_KEY.
+// MAINTAINMAN_<KEY>_FILE reads the value from the file instead, which is
+// convenient for secrets mounted as files.
+const EnvPrefix = "MAINTAINMAN"
+
+type envOverride struct {
+	prefix string
+	keys   map[string]bool // keys overridden by environment variables
+}
+
+var (
+	envMu        sync.Mutex
+	envOverrides = map[*Config]*envOverride{}
+)
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// EnvName returns the name of environment variable overriding the key.
+func EnvName(name, key string) string {
+	prefix := EnvPrefix
+	if name != "app" {
+		prefix = prefix + "_" + name
+	}
+	return strings.ToUpper(envKeyReplacer.Replace(prefix + "_" + key))
+}
+
+// ApplyEnv overrides keys of the configuration by environment variables.
+// Keys of its schema are overridden too, even if they are not set.
+// The overridden values are never written back to the configuration file by WriteConfig.
+func ApplyEnv(config *Config, name string) error {
+	keys, err := applyEnv(config, config.Viper(), name)
+	if err != nil {
+		return err
+	}
+	envMu.Lock()
+	envOverrides[config] = &envOverride{prefix: name, keys: keys}
+	envMu.Unlock()
+	return nil
+}
+
+// envKeys returns keys of the configuration and its schema.
+func envKeys(config *Config, target *viper.Viper) []string {
+	keys := target.AllKeys()
+	for key := range getSchema(config) {
+		if !target.IsSet(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func applyEnv(config *Config, target *viper.Viper, name string) (map[string]bool, error) {
+	keys := map[string]bool{}
+	for _, key := range envKeys(config, target) {
+		env := EnvName(name, key)
+		value, ok := os.LookupEnv(env)
+		file, fileOk := os.LookupEnv(env + "_FILE")
+		if ok && fileOk {
+			return nil, fmt.Errorf("both %s and %s_FILE are set", env, env)
+		}
+		if fileOk {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s_FILE: %v", env, err)
+			}
+			value, ok = strings.TrimRight(string(data), "\r\n"), true
+		}
+		if ok {
+			target.Set(key, envValue(target.Get(key), value))
+			keys[key] = true
+		}
+	}
+	return keys, nil
+}
+
+// envValue converts the value of an environment variable to a list
+// of comma separated values, if the key is a list.
+func envValue(current any, value string) any {
+	if kind := reflect.ValueOf(current).Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return value
+	}
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// reapplyEnv applies environment variables again to target, a new snapshot
+// of the configuration, using the name they were applied with, and returns
+// the overridden keys. It does nothing if ApplyEnv is never called.
+func reapplyEnv(config *Config, target *viper.Viper) (map[string]bool, error) {
+	envMu.Lock()
+	override, ok := envOverrides[config]
+	envMu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	return applyEnv(config, target, override.prefix)
+}
+
+// setEnvKeys records the keys overridden in the published snapshot.
+func setEnvKeys(config *Config, keys map[string]bool) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	if override, ok := envOverrides[config]; ok {
+		override.keys = keys
+	}
+}
+
+// IsEnvOverridden reports whether the key of the configuration is overridden by environment variables.
+func IsEnvOverridden(config *Config, key string) bool {
+	envMu.Lock()
+	defer envMu.Unlock()
+	override, ok := envOverrides[config]
+	return ok && override.keys[strings.ToLower(key)]
+}
+
+// WriteConfig writes the configuration to the file it was read from.
+// Keys overridden by environment variables keep the values in the file,
+// so that secrets passed by environment are never persisted.
+func WriteConfig(c *Config) error {
+	config := c.Viper()
+	envMu.Lock()
+	override, ok := envOverrides[c]
+	keys := map[string]bool{}
+	if ok {
+		for k := range override.keys {
+			keys[k] = true
+		}
+	}
+	envMu.Unlock()
+	if len(keys) == 0 {
+		return config.WriteConfig()
+	}
+
+	file := config.ConfigFileUsed()
+	origin := viper.New()
+	origin.SetConfigFile(file)
+	if err := origin.ReadInConfig(); err != nil {
+		return err
+	}
+	output := viper.New()
+	for _, key := range config.AllKeys() {
+		if !keys[key] {
+			output.Set(key, config.Get(key))
+		} else if origin.IsSet(key) {
+			output.Set(key, origin.Get(key))
+		}
+	}
+	return output.WriteConfigAs(file)
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	if name := EnvName("app", "token.key"); name != "MAINTAINMAN_TOKEN_KEY" {
+		t.Errorf("Expect MAINTAINMAN_TOKEN_KEY, but got %s", name)
+	}
+	if name := EnvName("user", "wechat.appid"); name != "MAINTAINMAN_USER_WECHAT_APPID" {
+		t.Errorf("Expect MAINTAINMAN_USER_WECHAT_APPID, but got %s", name)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	v, file := newWatchTestConfig(t, "rate: 5\nsecret: file\nname: test\nhosts: [a]\n")
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(secretFile, []byte("from file\n"), 0600)
+	t.Setenv("MAINTAINMAN_TEST_RATE", "20")
+	t.Setenv("MAINTAINMAN_TEST_SECRET_FILE", secretFile)
+	t.Setenv("MAINTAINMAN_TEST_HOSTS", "b, c,")
+
+	if err := ApplyEnv(v, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetInt("rate") != 20 {
+		t.Errorf("Expect rate 20, but got %d", v.GetInt("rate"))
+	}
+	if v.GetString("secret") != "from file" {
+		t.Errorf("Expect secret from file, but got %q", v.GetString("secret"))
+	}
+	if hosts := v.GetStringSlice("hosts"); len(hosts) != 2 || hosts[0] != "b" || hosts[1] != "c" {
+		t.Errorf("Expect hosts [b c], but got %v", hosts)
+	}
+	if !IsEnvOverridden(v, "secret") || IsEnvOverridden(v, "name") {
+		t.Error("Expect only rate, secret and hosts overridden")
+	}
+
+	// overridden values are not persisted
+	v.Set("name", "changed")
+	if err := WriteConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(file)
+	content := string(data)
+	if !strings.Contains(content, "rate: 5") || !strings.Contains(content, "secret: file") || !strings.Contains(content, "name: changed") {
+		t.Errorf("Expect overridden values kept in file, but got:\n%s", content)
+	}
+
+	// overrides survive reloading
+	os.WriteFile(file, []byte("rate: 7\nsecret: file\nextra: reloaded\n"), 0644)
+	if err := ReloadConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetInt("rate") != 20 || v.GetString("extra") != "reloaded" {
+		t.Errorf("Expect rate 20 and extra reloaded, but got %d %s", v.GetInt("rate"), v.GetString("extra"))
+	}
+
+	t.Setenv("MAINTAINMAN_TEST_RATE_FILE", secretFile)
+	if err := ApplyEnv(v, "test"); err == nil {
+		t.Error("Expect error when both variable and file are set")
+	}
+}
+
+func TestApplyEnvSchema(t *testing.T) {
+	v, _ := newWatchTestConfig(t, "rate: 5\n")
+	SetConfigSchema(v, Schema{
+		"rate":           IntMin(0),
+		"s3.tls.ca_file": String(),
+	})
+	t.Setenv("MAINTAINMAN_TEST_S3_TLS_CA_FILE", "/etc/ca.pem")
+
+	// keys of the schema are overridden even if they are not set
+	if err := ApplyEnv(v, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetString("s3.tls.ca_file") != "/etc/ca.pem" || !IsEnvOverridden(v, "s3.tls.ca_file") {
+		t.Errorf("Expect s3.tls.ca_file overridden, but got %q", v.GetString("s3.tls.ca_file"))
+	}
+	if err := ReloadConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetString("s3.tls.ca_file") != "/etc/ca.pem" {
+		t.Errorf("Expect s3.tls.ca_file kept after reloading, but got %q", v.GetString("s3.tls.ca_file"))
+	}
+}
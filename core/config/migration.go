@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigMigration is a declarative change of configuration keys introduced by
+// a version. It is applied when a configuration file older than Version is updated.
+type ConfigMigration struct {
+	Version string
+	Changes []ConfigChange
+}
+
+// ConfigChange changes the settings read from a configuration file.
+type ConfigChange func(settings map[string]any) error
+
+// AddConfigMigration registers migrations of the configuration.
+// They must be registered before the configuration is read.
+func AddConfigMigration(config *Config, migrations ...*ConfigMigration) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	w := getWatched(config)
+	w.migrations = append(w.migrations, migrations...)
+}
+
+// RenameKey renames the last segment of key to name, e.g.
+// RenameKey("notify.comment.messgae", "message") renames it to notify.comment.message.
+func RenameKey(key, name string) ConfigChange {
+	to := name
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		to = key[:i+1] + name
+	}
+	return MoveKey(key, to)
+}
+
+// MoveKey moves the value of from to to. If to is already set,
+// the value of to is kept and from is dropped.
+func MoveKey(from, to string) ConfigChange {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	return func(settings map[string]any) error {
+		value, ok := getPath(settings, from)
+		if !ok {
+			return nil
+		}
+		deletePath(settings, from)
+		if _, ok := getPath(settings, to); ok {
+			fmt.Printf("%s is dropped, as %s is already set.\n", from, to)
+			return nil
+		}
+		if err := setPath(settings, to, value); err != nil {
+			return fmt.Errorf("%s: %v", to, err)
+		}
+		return nil
+	}
+}
+
+// DropKey removes the key.
+func DropKey(key string) ConfigChange {
+	key = strings.ToLower(key)
+	return func(settings map[string]any) error {
+		deletePath(settings, key)
+		return nil
+	}
+}
+
+// TransformKey replaces the value of key with the result of fn, if key is set.
+func TransformKey(key string, fn func(value any) (any, error)) ConfigChange {
+	key = strings.ToLower(key)
+	return func(settings map[string]any) error {
+		value, ok := getPath(settings, key)
+		if !ok {
+			return nil
+		}
+		value, err := fn(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		return setPath(settings, key, value)
+	}
+}
+
+func getPath(settings map[string]any, key string) (any, bool) {
+	path := strings.Split(key, ".")
+	node := settings
+	for _, k := range path[:len(path)-1] {
+		next, ok := node[k].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		node = next
+	}
+	value, ok := node[path[len(path)-1]]
+	return value, ok
+}
+
+func setPath(settings map[string]any, key string, value any) error {
+	path := strings.Split(key, ".")
+	node := settings
+	for i, k := range path[:len(path)-1] {
+		if node[k] == nil {
+			node[k] = map[string]any{}
+		}
+		next, ok := node[k].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is not a map", strings.Join(path[:i+1], "."))
+		}
+		node = next
+	}
+	node[path[len(path)-1]] = value
+	return nil
+}
+
+// deletePath removes the key and its parents left empty.
+func deletePath(settings map[string]any, key string) {
+	path := strings.Split(key, ".")
+	if len(path) > 1 {
+		next, ok := settings[path[0]].(map[string]any)
+		if !ok {
+			return
+		}
+		deletePath(next, strings.Join(path[1:], "."))
+		if len(next) > 0 {
+			return
+		}
+	}
+	delete(settings, path[0])
+}
+
+// migrateConfig applies migrations newer than from and not newer than to
+// to the configuration file, and reads it again.
+func migrateConfig(c *Config, name, from, to string) error {
+	config := c.Viper()
+	watchMu.Lock()
+	pending := []*ConfigMigration{}
+	for _, m := range getWatched(c).migrations {
+		if VersionCompare(m.Version, from) > 0 && VersionCompare(m.Version, to) <= 0 {
+			pending = append(pending, m)
+		}
+	}
+	watchMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		return VersionCompare(pending[i].Version, pending[j].Version) < 0
+	})
+
+	file := viper.New()
+	file.SetConfigFile(config.ConfigFileUsed())
+	if err := file.ReadInConfig(); err != nil {
+		return fmt.Errorf("fatal error reading %s configuration: %v", name, err)
+	}
+	settings := file.AllSettings()
+	for _, m := range pending {
+		for _, change := range m.Changes {
+			if err := change(settings); err != nil {
+				return fmt.Errorf("migrating %s configuration to version %s failed: %v", name, m.Version, err)
+			}
+		}
+		fmt.Printf("%s configuration file migrated to version %s.\n", name, m.Version)
+	}
+
+	output := viper.New()
+	for key, value := range settings {
+		output.Set(key, value)
+	}
+	if err := output.WriteConfigAs(config.ConfigFileUsed()); err != nil {
+		return fmt.Errorf("failed to write %s configuration file: %v", name, err)
+	}
+	if err := config.ReadInConfig(); err != nil {
+		return fmt.Errorf("fatal error reading %s configuration: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+func newMigrationTestConfig(t *testing.T, content string) (*Config, string) {
+	file := filepath.Join(t.TempDir(), "test.yml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := New()
+	v.Viper().SetConfigFile(file)
+	return v, file
+}
+
+func TestConfigMigration(t *testing.T) {
+	v, file := newMigrationTestConfig(t, strings.Join([]string{
+		"version: 1.0.0",
+		"notify:",
+		"  comment:",
+		"    messgae: content",
+		"    tmpl: id",
+		"appraise:",
+		"  timeout: 72",
+		"legacy:",
+		"  key: value",
+		"old:",
+		"  name: moved",
+		"",
+	}, "\n"))
+	v.SetDefault("appraise.timeout", "72h")
+	AddConfigMigration(v,
+		&ConfigMigration{
+			Version: "1.1.0",
+			Changes: []ConfigChange{
+				RenameKey("notify.comment.messgae", "message"),
+				DropKey("legacy.key"),
+				TransformKey("appraise.timeout", func(value any) (any, error) {
+					hours, err := cast.ToIntE(value)
+					if err != nil {
+						return nil, err
+					}
+					return fmt.Sprintf("%dh", hours), nil
+				}),
+			},
+		},
+		&ConfigMigration{
+			Version: "1.2.0",
+			Changes: []ConfigChange{MoveKey("old.name", "new.name")},
+		},
+		&ConfigMigration{
+			Version: "2.0.0", // newer than the target version
+			Changes: []ConfigChange{DropKey("notify")},
+		},
+	)
+	SetConfigSchema(v, Schema{"appraise.timeout": DurationMin(0)})
+
+	if err := ReadAndUpdateConfig(v, "test", "1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := v.GetString("notify.comment.message"); msg != "content" {
+		t.Errorf("Expect renamed message, but got %q", msg)
+	}
+	if v.IsSet("notify.comment.messgae") || v.IsSet("legacy.key") || v.IsSet("old.name") {
+		t.Error("Expect old keys removed")
+	}
+	if v.GetString("new.name") != "moved" || v.GetString("appraise.timeout") != "72h" {
+		t.Errorf("Expect moved and transformed keys, but got %q %q", v.GetString("new.name"), v.GetString("appraise.timeout"))
+	}
+	data, _ := os.ReadFile(file)
+	if content := string(data); strings.Contains(content, "messgae") || strings.Contains(content, "legacy") || !strings.Contains(content, "1.2.0") {
+		t.Errorf("Expect migrated file, but got:\n%s", content)
+	}
+
+	// migrations are not applied again
+	if err := ReadAndUpdateConfig(v, "test", "1.2.0"); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetString("notify.comment.message") != "content" {
+		t.Error("Expect migrated file kept")
+	}
+}
+
+func TestConfigMigrationConflict(t *testing.T) {
+	v, _ := newMigrationTestConfig(t, "version: 1.0.0\na:\n  old: 1\n  new: 2\nb: 3\n")
+	AddConfigMigration(v, &ConfigMigration{
+		Version: "1.1.0",
+		Changes: []ConfigChange{
+			RenameKey("a.old", "new"),
+			MoveKey("a.new", "b.c"),
+		},
+	})
+	err := ReadAndUpdateConfig(v, "test", "1.1.0")
+	if err == nil || !strings.Contains(err.Error(), "b is not a map") {
+		t.Errorf("Expect error pointing at b, but got %v", err)
+	}
+}
+
+func TestReadAndUpdateConfigInvalid(t *testing.T) {
+	v, _ := newMigrationTestConfig(t, "version: 1.0.0\nrate: -1\n")
+	SetConfigSchema(v, Schema{"rate": IntMin(0)})
+	err := ReadAndUpdateConfig(v, "test", "1.0.0")
+	if err == nil || !strings.Contains(err.Error(), "rate: should be at least 0, but got -1") {
+		t.Errorf("Expect error pointing at rate, but got %v", err)
+	}
+}
+
+func TestReadAndUpdateConfigError(t *testing.T) {
+	// unparsable file
+	v, _ := newMigrationTestConfig(t, "version: [\n")
+	if err := ReadAndUpdateConfig(v, "test", "1.0.0"); err == nil || !strings.Contains(err.Error(), "reading test configuration") {
+		t.Errorf("Expect error reading the file, but got %v", err)
+	}
+
+	// file not found and can not be created
+	v = New()
+	v.SetConfigName("test")
+	v.SetConfigType("yaml")
+	v.AddConfigPath(filepath.Join(t.TempDir(), "missing"))
+	if err := ReadAndUpdateConfig(v, "test", "1.0.0"); err == nil || !strings.Contains(err.Error(), "write test configuration") {
+		t.Errorf("Expect error writing the file, but got %v", err)
+	}
+}
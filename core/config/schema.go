@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Rule checks the value of a configuration key.
+type Rule func(value any) error
+
+// Schema maps configuration keys to their rules.
+// Keys absent from the configuration are not checked.
+type Schema map[string]Rule
+
+// KeyError is an invalid value of a configuration key.
+type KeyError struct {
+	Key   string
+	Value any
+	Err   error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("%s: %v, but got %v", e.Key, e.Err, e.Value)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaError is all invalid keys of a configuration.
+type SchemaError []*KeyError
+
+func (e SchemaError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// SetConfigSchema sets the schema of the configuration. The configuration is
+// checked against it on startup, and changes violating it are rejected.
+func SetConfigSchema(config *Config, schema Schema) {
+	watchMu.Lock()
+	w := getWatched(config)
+	w.schema = schema
+	watchMu.Unlock()
+	AddConfigValidator(config, schema.Validate)
+}
+
+func getSchema(config *Config) Schema {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	return getWatched(config).schema
+}
+
+// Validate checks the configuration against the schema.
+// It returns a SchemaError listing all invalid keys.
+func (s Schema) Validate(config *viper.Viper) error {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	errs := SchemaError{}
+	for _, key := range keys {
+		value := config.Get(key)
+		if value == nil {
+			continue
+		}
+		if err := s[key](value); err != nil {
+			errs = append(errs, &KeyError{Key: key, Value: value, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func isComposite(value any) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
+
+// isNumeric reports whether value can be a number, which rejects booleans cast to 0 or 1.
+func isNumeric(value any) bool {
+	if _, ok := value.(bool); ok {
+		return false
+	}
+	return !isComposite(value)
+}
+
+// Bool accepts a boolean.
+func Bool() Rule {
+	return func(value any) error {
+		if _, err := cast.ToBoolE(value); err != nil {
+			return fmt.Errorf("should be a boolean")
+		}
+		return nil
+	}
+}
+
+// Int accepts an integer in [min, max].
+func Int(min, max int64) Rule {
+	return func(value any) error {
+		i, err := cast.ToInt64E(value)
+		if err != nil || !isNumeric(value) {
+			return fmt.Errorf("should be an integer")
+		}
+		if i < min || i > max {
+			if max == math.MaxInt64 {
+				return fmt.Errorf("should be at least %d", min)
+			}
+			return fmt.Errorf("should be in %d-%d", min, max)
+		}
+		return nil
+	}
+}
+
+// IntMin accepts an integer not less than min.
+func IntMin(min int64) Rule {
+	return Int(min, math.MaxInt64)
+}
+
+// Duration accepts a duration in [min, max], such as "1m30s".
+func Duration(min, max time.Duration) Rule {
+	return func(value any) error {
+		d, err := cast.ToDurationE(value)
+		if err != nil || !isNumeric(value) {
+			return fmt.Errorf("should be a duration such as \"1m30s\"")
+		}
+		if d < min || d > max {
+			if max == math.MaxInt64 {
+				return fmt.Errorf("should be at least %s", min)
+			}
+			return fmt.Errorf("should be in %s-%s", min, max)
+		}
+		return nil
+	}
+}
+
+// DurationMin accepts a duration not less than min.
+func DurationMin(min time.Duration) Rule {
+	return Duration(min, math.MaxInt64)
+}
+
+// String accepts a scalar value.
+func String() Rule {
+	return func(value any) error {
+		if _, err := cast.ToStringE(value); err != nil || isComposite(value) {
+			return fmt.Errorf("should be a string")
+		}
+		return nil
+	}
+}
+
+// NonEmpty accepts a non-empty string.
+func NonEmpty() Rule {
+	return func(value any) error {
+		s, err := cast.ToStringE(value)
+		if err != nil || isComposite(value) {
+			return fmt.Errorf("should be a string")
+		}
+		if s == "" {
+			return fmt.Errorf("should not be empty")
+		}
+		return nil
+	}
+}
+
+// OneOf accepts one of the strings.
+func OneOf(values ...string) Rule {
+	return func(value any) error {
+		s, err := cast.ToStringE(value)
+		if err != nil || isComposite(value) {
+			return fmt.Errorf("should be a string")
+		}
+		for _, v := range values {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("should be one of %s", strings.Join(values, ", "))
+	}
+}
+
+// List accepts a list.
+func List() Rule {
+	return func(value any) error {
+		switch reflect.ValueOf(value).Kind() {
+		case reflect.Slice, reflect.Array:
+			return nil
+		}
+		return fmt.Errorf("should be a list")
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{
+		"app.mode":    OneOf("development", "production"),
+		"app.limit":   Int(1, 100),
+		"app.timeout": DurationMin(time.Second),
+		"app.enable":  Bool(),
+		"app.name":    NonEmpty(),
+		"app.list":    List(),
+	}
+
+	v := viper.New()
+	v.Set("app.mode", "production")
+	v.Set("app.limit", "20") // environment variables are strings
+	v.Set("app.timeout", "10s")
+	v.Set("app.enable", true)
+	v.Set("app.name", "maintainman")
+	v.Set("app.list", []any{"a"})
+	if err := schema.Validate(v); err != nil {
+		t.Errorf("Expect valid configuration, but got %v", err)
+	}
+
+	v.Set("app.mode", "test")
+	v.Set("app.limit", 0)
+	v.Set("app.timeout", "forever")
+	v.Set("app.enable", "maybe")
+	v.Set("app.name", "")
+	v.Set("app.list", "a")
+	err := schema.Validate(v)
+	schemaErr := SchemaError{}
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("Expect schema error, but got %v", err)
+	}
+	keys := []string{}
+	for _, e := range schemaErr {
+		keys = append(keys, e.Key)
+	}
+	if got := strings.Join(keys, ","); got != "app.enable,app.limit,app.list,app.mode,app.name,app.timeout" {
+		t.Errorf("Expect all invalid keys, but got %s", got)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "app.limit: should be in 1-100, but got 0") {
+		t.Errorf("Expect error pointing at app.limit, but got %s", msg)
+	}
+
+	// absent keys are not checked
+	if err := schema.Validate(viper.New()); err != nil {
+		t.Errorf("Expect absent keys skipped, but got %v", err)
+	}
+}
@@ -8,27 +8,53 @@ import (
 	"github.com/spf13/viper"
 )
 
-func ReadAndUpdateConfig(config *viper.Viper, name string, version string) {
-	if config == nil {
-		return
+// ReadAndUpdateConfig reads the configuration file, creates it if not found, and
+// updates it by the registered migrations if it is older than version.
+// The configuration is checked against its schema after environment variables
+// are applied, and the error returned points at the invalid keys.
+func ReadAndUpdateConfig(c *Config, name string, version string) error {
+	if c == nil {
+		return nil
 	}
+	config := c.Viper()
+	recordDefaults(c)
 	created := false
 	if err := config.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			fmt.Printf("%s configuration file not found: %v\n", name, err)
 			config.SetDefault("version", version)
 			if err := config.SafeWriteConfig(); err != nil {
-				panic(fmt.Errorf("failed to write %s configuration file: %v", name, err))
+				return fmt.Errorf("failed to write %s configuration file: %v", name, err)
 			}
 			fmt.Printf("default %s configuration file created.\n", name)
+			// read it back, so that the file used is known
+			if err := config.ReadInConfig(); err != nil {
+				return fmt.Errorf("fatal error reading %s configuration: %v", name, err)
+			}
 			created = true
 		} else {
-			panic(fmt.Errorf("fatal error reading %s configuration: %v", name, err))
+			return fmt.Errorf("fatal error reading %s configuration: %v", name, err)
+		}
+	}
+	if !created {
+		if err := updateConfig(c, name, version); err != nil {
+			return err
 		}
 	}
-	if created {
-		return
+	// apply environment variables after the file is written, so that they are not persisted
+	if err := ApplyEnv(c, name); err != nil {
+		return fmt.Errorf("failed to apply environment variables to %s configuration: %v", name, err)
+	}
+	if schema := getSchema(c); schema != nil {
+		if err := schema.Validate(config); err != nil {
+			return fmt.Errorf("invalid %s configuration: %v", name, err)
+		}
 	}
+	return nil
+}
+
+func updateConfig(c *Config, name string, version string) error {
+	config := c.Viper()
 	fileVersion := config.GetString("version")
 	config.SetDefault("version", version)
 	if cmp := VersionCompare(version, fileVersion); cmp != 0 {
@@ -38,13 +64,17 @@ func ReadAndUpdateConfig(config *viper.Viper, name string, version string) {
 		}
 		if cmp > 0 {
 			fmt.Printf("updating your %s configuration file. conflict entries will not be updated.\n", name)
+			if err := migrateConfig(c, name, fileVersion, version); err != nil {
+				return err
+			}
 			config.Set("version", version)
 			if err := config.WriteConfig(); err != nil {
-				panic(fmt.Errorf("failed to write %s configuration file: %v", name, err))
+				return fmt.Errorf("failed to write %s configuration file: %v", name, err)
 			}
 			fmt.Printf("%s configuration file updated to version %s.\n", name, version)
 		}
 	}
+	return nil
 }
 
 func VersionCompare(a, b string) int {
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// reloadDelay merges the burst of events caused by a single save.
+const reloadDelay = 100 * time.Millisecond
+
+type watchedConfig struct {
+	name       string
+	schema     Schema
+	defaults   map[string]any // values of keys before the file is read
+	migrations []*ConfigMigration
+	validators []func(*viper.Viper) error
+	callbacks  []func(*viper.Viper)
+	watcher    *fsnotify.Watcher
+}
+
+var (
+	watchMu sync.Mutex
+	watched = map[*Config]*watchedConfig{}
+	// reloadMu serializes snapshots read from the files
+	reloadMu sync.Mutex
+)
+
+func getWatched(config *Config) *watchedConfig {
+	w, ok := watched[config]
+	if !ok {
+		w = &watchedConfig{}
+		watched[config] = w
+	}
+	return w
+}
+
+// recordDefaults records the current values of the configuration as the
+// values of keys absent from its file. It is called before the file is read.
+func recordDefaults(config *Config) {
+	defaults := map[string]any{}
+	v := config.Viper()
+	for _, key := range v.AllKeys() {
+		defaults[key] = v.Get(key)
+	}
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	getWatched(config).defaults = defaults
+}
+
+// AddConfigValidator registers fn to validate the changed configuration before it is applied.
+// The change is rejected if fn returns an error.
+func AddConfigValidator(config *Config, fn func(*viper.Viper) error) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	w := getWatched(config)
+	w.validators = append(w.validators, fn)
+}
+
+// ValidateConfig checks the configuration by all its validators, including the schema.
+func ValidateConfig(config *Config) error {
+	watchMu.Lock()
+	validators := append([]func(*viper.Viper) error{}, getWatched(config).validators...)
+	watchMu.Unlock()
+	for _, validate := range validators {
+		if err := validate(config.Viper()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnConfigChange registers fn to be called with the snapshot after the
+// changed configuration is applied.
+func OnConfigChange(config *Config, fn func(*viper.Viper)) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	w := getWatched(config)
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// WatchConfig watches the configuration file and reloads it on change.
+func WatchConfig(config *Config, name string) error {
+	file := config.ConfigFileUsed()
+	if file == "" {
+		return fmt.Errorf("%s configuration file not loaded", name)
+	}
+	file = filepath.Clean(file)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// watch the directory to pick up files replaced by editors
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	watchMu.Lock()
+	w := getWatched(config)
+	if w.watcher != nil {
+		w.watcher.Close()
+	}
+	w.name = name
+	w.watcher = watcher
+	watchMu.Unlock()
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != file || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, func() {
+					if err := ReloadConfig(config); err != nil {
+						logger.Logger.Warnf("%s configuration change rejected: %v", name, err)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Logger.Warnf("%s configuration watcher error: %v", name, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// StopWatchConfig stops watching all configuration files.
+func StopWatchConfig() {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	for _, w := range watched {
+		if w.watcher != nil {
+			w.watcher.Close()
+			w.watcher = nil
+		}
+	}
+}
+
+// ReloadConfig reads the configuration file again into a new snapshot. The
+// snapshot is validated first, and published only if all validators accept
+// it, so that exactly the validated content is applied.
+func ReloadConfig(config *Config) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	watchMu.Lock()
+	w := getWatched(config)
+	name := w.name
+	validators := append([]func(*viper.Viper) error{}, w.validators...)
+	callbacks := append([]func(*viper.Viper){}, w.callbacks...)
+	watchMu.Unlock()
+
+	candidate, keys, err := readSnapshot(config)
+	if err != nil {
+		return err
+	}
+	for _, validate := range validators {
+		if err := validate(candidate); err != nil {
+			return err
+		}
+	}
+	config.publish(candidate)
+	setEnvKeys(config, keys)
+
+	for _, callback := range callbacks {
+		runCallback(name, candidate, callback)
+	}
+	logger.Logger.Infof("%s configuration reloaded", name)
+	return nil
+}
+
+// SetConfig sets the key in a copy of the current snapshot, publishes the
+// copy, and writes it to the configuration file. Unlike Set, it is safe while
+// the configuration is being read.
+func SetConfig(config *Config, key string, value any) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	candidate := copySnapshot(config.Viper())
+	candidate.Set(key, value)
+	config.publish(candidate)
+	return WriteConfig(config)
+}
+
+// copySnapshot copies the snapshot. Keys read from the file stay in the
+// file part of the copy, so that they still follow the file on reload.
+func copySnapshot(current *viper.Viper) *viper.Viper {
+	file := viper.New()
+	candidate := viper.New()
+	for _, key := range current.AllKeys() {
+		if current.InConfig(key) {
+			file.Set(key, current.Get(key))
+		} else {
+			candidate.SetDefault(key, current.Get(key))
+		}
+	}
+	if used := current.ConfigFileUsed(); used != "" {
+		candidate.SetConfigFile(used)
+	}
+	candidate.MergeConfigMap(file.AllSettings())
+	return candidate
+}
+
+// readSnapshot reads the configuration file into a new snapshot, and
+// returns the keys overridden by environment variables. Keys removed from
+// the file fall back to their default values, and keys overridden by
+// environment variables keep the variables.
+func readSnapshot(config *Config) (*viper.Viper, map[string]bool, error) {
+	watchMu.Lock()
+	defaults := getWatched(config).defaults
+	watchMu.Unlock()
+
+	current := config.Viper()
+	file := current.ConfigFileUsed()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	candidate := viper.New()
+	for key, value := range defaults {
+		candidate.SetDefault(key, value)
+	}
+	for _, key := range current.AllKeys() {
+		// keys absent from the current file are not changed by the file
+		if !current.InConfig(key) {
+			candidate.SetDefault(key, current.Get(key))
+		}
+	}
+	candidate.SetConfigFile(file)
+	if err := candidate.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, nil, err
+	}
+	keys, err := reapplyEnv(config, candidate)
+	if err != nil {
+		return nil, nil, err
+	}
+	return candidate, keys, nil
+}
+
+func runCallback(name string, v *viper.Viper, callback func(*viper.Viper)) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Logger.Errorf("%s configuration change callback panic: %v", name, err)
+		}
+	}()
+	callback(v)
+}
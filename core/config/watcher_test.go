@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kataras/golog"
+	"github.com/spf13/viper"
+	"github.com/xaxys/maintainman/core/logger"
+)
+
+func newWatchTestConfig(t *testing.T, content string) (*Config, string) {
+	logger.Logger = golog.New()
+	file := filepath.Join(t.TempDir(), "test.yml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := New()
+	v.SetDefault("rate", 10)
+	recordDefaults(v)
+	v.Viper().SetConfigFile(file)
+	if err := v.Viper().ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return v, file
+}
+
+func TestReloadConfig(t *testing.T) {
+	v, file := newWatchTestConfig(t, "rate: 5\n")
+	AddConfigValidator(v, func(v *viper.Viper) error {
+		if v.GetInt("rate") <= 0 {
+			return fmt.Errorf("rate should be positive")
+		}
+		return nil
+	})
+	validated := []int{}
+	AddConfigValidator(v, func(v *viper.Viper) error {
+		validated = append(validated, v.GetInt("rate"))
+		return nil
+	})
+	rates := []int{}
+	OnConfigChange(v, func(v *viper.Viper) {
+		rates = append(rates, v.GetInt("rate"))
+	})
+	OnConfigChange(v, func(v *viper.Viper) {
+		panic("callback panic should not stop reloading")
+	})
+
+	os.WriteFile(file, []byte("rate: 20\n"), 0644)
+	if err := ReloadConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetInt("rate") != 20 || len(rates) != 1 || rates[0] != 20 {
+		t.Errorf("Expect rate 20, but got %d %v", v.GetInt("rate"), rates)
+	}
+
+	// invalid value is rejected
+	os.WriteFile(file, []byte("rate: -1\n"), 0644)
+	if err := ReloadConfig(v); err == nil {
+		t.Error("Expect validation error")
+	}
+	if v.GetInt("rate") != 20 || len(rates) != 1 {
+		t.Errorf("Expect rate 20 kept, but got %d %v", v.GetInt("rate"), rates)
+	}
+
+	// invalid yaml is rejected
+	os.WriteFile(file, []byte("rate: [\n"), 0644)
+	if err := ReloadConfig(v); err == nil {
+		t.Error("Expect parse error")
+	}
+	if v.GetInt("rate") != 20 {
+		t.Errorf("Expect rate 20 kept, but got %d", v.GetInt("rate"))
+	}
+
+	// removed key falls back to default
+	os.WriteFile(file, []byte("other: 1\n"), 0644)
+	if err := ReloadConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetInt("rate") != 10 || v.GetInt("other") != 1 {
+		t.Errorf("Expect default rate 10 and other 1, but got %d %d", v.GetInt("rate"), v.GetInt("other"))
+	}
+	// removed key is validated with the value applied
+	if last := validated[len(validated)-1]; last != 10 {
+		t.Errorf("Expect default rate 10 validated, but got %d", last)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	v, file := newWatchTestConfig(t, "rate: 5\n")
+	changed := make(chan int, 1)
+	OnConfigChange(v, func(v *viper.Viper) {
+		changed <- v.GetInt("rate")
+	})
+	if err := WatchConfig(v, "test"); err != nil {
+		t.Fatal(err)
+	}
+	defer StopWatchConfig()
+
+	os.WriteFile(file, []byte("rate: 30\n"), 0644)
+	select {
+	case rate := <-changed:
+		if rate != 30 {
+			t.Errorf("Expect rate 30, but got %d", rate)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expect configuration reloaded")
+	}
+}
+
+func TestConfigSnapshot(t *testing.T) {
+	v, file := newWatchTestConfig(t, "rate: 5\n")
+	old := v.Viper()
+
+	// readers never see a snapshot being reloaded
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if rate := v.GetInt("rate"); rate != 5 && rate != 20 {
+				t.Errorf("Expect rate 5 or 20, but got %d", rate)
+				return
+			}
+		}
+	}()
+	os.WriteFile(file, []byte("rate: 20\n"), 0644)
+	if err := ReloadConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if v.Viper() == old || old.GetInt("rate") != 5 || v.GetInt("rate") != 20 {
+		t.Errorf("Expect new snapshot of rate 20 and old of rate 5, but got %d %d", v.GetInt("rate"), old.GetInt("rate"))
+	}
+
+	// set value is published, written, and kept on reload
+	current := v.Viper()
+	if err := SetConfig(v, "burst", 3); err != nil {
+		t.Fatal(err)
+	}
+	if current.IsSet("burst") || v.GetInt("burst") != 3 {
+		t.Errorf("Expect burst 3 set in a new snapshot, but got %d", v.GetInt("burst"))
+	}
+	if err := ReloadConfig(v); err != nil {
+		t.Fatal(err)
+	}
+	if v.GetInt("rate") != 20 || v.GetInt("burst") != 3 {
+		t.Errorf("Expect rate 20 and burst 3 after reload, but got %d %d", v.GetInt("rate"), v.GetInt("burst"))
+	}
+}
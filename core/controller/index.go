@@ -1,6 +1,10 @@
 package controller
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
@@ -14,3 +18,25 @@ func ExtractPageParam(ctx iris.Context) *model.PageParam {
 		Limit:   util.ToUint(ctx.URLParamIntDefault("limit", 0)),
 	}
 }
+
+// ExtractIfMatch returns the version in the If-Match header, such as "3" or
+// W/"3", which an update is conditional on. It returns 0 if the header is
+// absent or *, which makes the update unconditional.
+func ExtractIfMatch(ctx iris.Context) (uint, error) {
+	etag := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if etag == "" || etag == "*" {
+		return 0, nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(etag, "W/"), `"`)
+	version, err := strconv.ParseUint(tag, 10, 0)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("invalid If-Match %s", etag)
+	}
+	return uint(version), nil
+}
+
+// SetETag sets the ETag header to the version of the resource read, which
+// is sent back in If-Match to make an update conditional on it.
+func SetETag(ctx iris.Context, version uint) {
+	ctx.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
@@ -31,3 +31,12 @@ func TxFilter(tx *gorm.DB, orderBy string, uoffset, ulimit uint) (db *gorm.DB) {
 	db = db.Limit(limit)
 	return
 }
+
+// TxLike filters column by the LIKE pattern case-insensitively,
+// as LIKE of mysql and sqlite does, but that of postgres does not.
+func TxLike(tx *gorm.DB, column, pattern string) *gorm.DB {
+	if tx.Dialector.Name() == "postgres" {
+		return tx.Where(column+" ILIKE ?", pattern)
+	}
+	return tx.Where(column+" LIKE ?", pattern)
+}
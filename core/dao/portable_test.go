@@ -0,0 +1,52 @@
+package dao
+
+import (
+	"testing"
+
+	"github.com/xaxys/maintainman/core/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type portableTestItem struct {
+	ID      uint
+	Title   string
+	Count   int
+	Current bool
+}
+
+// TestPortableQueries runs the queries written for all databases against the
+// configured database, which is postgres if MAINTAINMAN_DATABASE_DRIVER=postgres.
+func TestPortableQueries(t *testing.T) {
+	db := database.DB
+	if err := db.AutoMigrate(&portableTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Migrator().DropTable(&portableTestItem{}) })
+	db.Where("1 = 1").Delete(&portableTestItem{})
+	items := []*portableTestItem{
+		{Title: "Broken Light", Count: 1, Current: true},
+		{Title: "broken door", Count: 2},
+		{Title: "Window", Count: 3, Current: true},
+	}
+	db.Create(items)
+
+	found := []*portableTestItem{}
+	if err := TxLike(db, "title", "%BROKEN%").Order("id").Find(&found).Error; err != nil || len(found) != 2 {
+		t.Errorf("Expect 2 items liked case-insensitively, but got %d %v", len(found), err)
+	}
+
+	// columns named by keywords are quoted
+	err := db.Model(items[0]).Update("count", gorm.Expr("? + ?", clause.Column{Name: "count"}, 1)).Error
+	item := &portableTestItem{}
+	db.First(item, items[0].ID)
+	if err != nil || item.Count != 2 {
+		t.Errorf("Expect count increased to 2, but got %d %v", item.Count, err)
+	}
+
+	found = []*portableTestItem{}
+	if err := db.Where("current = ?", true).Find(&found).Error; err != nil || len(found) != 2 {
+		t.Errorf("Expect 2 current items, but got %d %v", len(found), err)
+	}
+}
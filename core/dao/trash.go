@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xaxys/maintainman/core/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrConflict is returned by TxRestore if restoring the row violates
+// a unique index, such as one only covering rows which are not deleted.
+var ErrConflict = errors.New("conflicts with an existing record")
+
+// txTrashed selects soft deleted rows only.
+func txTrashed(tx *gorm.DB) *gorm.DB {
+	return tx.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+// TxGetTrash lists soft deleted rows of T, the latest deleted first by default.
+func TxGetTrash[T any](tx *gorm.DB, param *model.PageParam) (rows []*T, count uint, err error) {
+	if param.OrderBy == "" {
+		tx = tx.Order("deleted_at desc")
+	}
+	tx = TxPageFilter(txTrashed(tx.Model(new(T))), param)
+	if err = tx.Find(&rows).Error; err != nil {
+		return
+	}
+	cnt := int64(0)
+	if err = tx.Offset(-1).Limit(-1).Count(&cnt).Error; err != nil {
+		return
+	}
+	count = uint(cnt)
+	return
+}
+
+// TxRestore undeletes the soft deleted row of T with id. Uniqueness is
+// left to the unique indexes of T, and ErrConflict is returned if any of
+// them is violated by restoring the row.
+func TxRestore[T any](tx *gorm.DB, id uint) (*T, error) {
+	row := new(T)
+	if err := txTrashed(tx).First(row, id).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Model(row).Update("deleted_at", nil).Error; err != nil {
+		if isDuplicatedKey(tx, err) {
+			return nil, fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+		return nil, err
+	}
+	if err := tx.First(row, id).Error; err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// isDuplicatedKey reports whether err is a unique constraint violation,
+// translated by the dialector whether or not TranslateError is set.
+func isDuplicatedKey(tx *gorm.DB, err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	if translator, ok := tx.Dialector.(gorm.ErrorTranslator); ok {
+		return errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey)
+	}
+	return false
+}
+
+// TxPurge deletes the soft deleted row of T with id permanently,
+// with the given associations, such as join tables of many2many.
+func TxPurge[T any](tx *gorm.DB, id uint, associations ...string) error {
+	row := new(T)
+	if err := txTrashed(tx).First(row, id).Error; err != nil {
+		return err
+	}
+	db := tx.Unscoped()
+	if len(associations) > 0 {
+		db = db.Select(associations)
+	}
+	return db.Delete(row).Error
+}
+
+// TxPurgeBefore deletes rows of T soft deleted before the time permanently,
+// and returns how many are deleted. Rows failing to be deleted, such as
+// those still referenced by others, are skipped and reported in the error.
+func TxPurgeBefore[T any](tx *gorm.DB, before time.Time, associations ...string) (int, error) {
+	ids := []uint{}
+	if err := txTrashed(tx.Model(new(T))).Where("deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	purged := 0
+	errs := []error{}
+	for _, id := range ids {
+		// each row is purged in its own transaction, so that a failure is not contagious
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			return TxPurge[T](tx, id, associations...)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%d: %v", id, err))
+			continue
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
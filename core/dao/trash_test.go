@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xaxys/maintainman/core/model"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type trashTestItem struct {
+	gorm.Model
+	// unique among rows which are not deleted
+	Name string `gorm:"uniqueIndex:idx_trash_test_item_name,where:deleted_at IS NULL"`
+}
+
+func openTrashTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "trash.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&trashTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTrash(t *testing.T) {
+	db := openTrashTestDB(t)
+	items := []*trashTestItem{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	db.Create(items)
+	db.Delete(items[0])
+	db.Delete(items[1])
+
+	trash, count, err := TxGetTrash[trashTestItem](db, &model.PageParam{})
+	if err != nil || count != 2 || len(trash) != 2 {
+		t.Fatalf("Expect 2 deleted rows, but got %d %d %v", len(trash), count, err)
+	}
+	if trash[0].ID != items[1].ID {
+		t.Errorf("Expect the latest deleted first, but got %d", trash[0].ID)
+	}
+	trash, count, err = TxGetTrash[trashTestItem](db, &model.PageParam{Offset: 1})
+	if err != nil || count != 2 || len(trash) != 1 {
+		t.Errorf("Expect 1 of 2 deleted rows with offset, but got %d %d %v", len(trash), count, err)
+	}
+
+	if _, err := TxRestore[trashTestItem](db, items[2].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expect row not deleted not found, but got %v", err)
+	}
+	db.Create(&trashTestItem{Name: "a"})
+	if _, err := TxRestore[trashTestItem](db, items[0].ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expect conflict with the new row of the same name, but got %v", err)
+	}
+	restored, err := TxRestore[trashTestItem](db, items[1].ID)
+	if err != nil || restored.Name != "b" || restored.DeletedAt.Valid {
+		t.Errorf("Expect b restored, but got %+v %v", restored, err)
+	}
+	if err := db.First(&trashTestItem{}, items[1].ID).Error; err != nil {
+		t.Errorf("Expect restored row found, but got %v", err)
+	}
+
+	if err := TxPurge[trashTestItem](db, items[2].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expect row not deleted not purged, but got %v", err)
+	}
+	if err := TxPurge[trashTestItem](db, items[0].ID); err != nil {
+		t.Errorf("Expect a purged, but got %v", err)
+	}
+	if err := db.Unscoped().First(&trashTestItem{}, items[0].ID).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expect purged row gone, but got %v", err)
+	}
+}
+
+func TestPurgeBefore(t *testing.T) {
+	db := openTrashTestDB(t)
+	items := []*trashTestItem{{Name: "old"}, {Name: "new"}, {Name: "alive"}}
+	db.Create(items)
+	db.Delete(items[0])
+	db.Delete(items[1])
+	db.Unscoped().Model(items[0]).Update("deleted_at", time.Now().Add(-48*time.Hour))
+
+	n, err := TxPurgeBefore[trashTestItem](db, time.Now().Add(-24*time.Hour))
+	if err != nil || n != 1 {
+		t.Errorf("Expect 1 row purged, but got %d %v", n, err)
+	}
+	count := int64(0)
+	db.Unscoped().Model(&trashTestItem{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expect 2 rows left, but got %d", count)
+	}
+}
@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrStale is returned by TxBumpVersion if the row has been modified
+// since its version was read.
+var ErrStale = errors.New("record has been modified")
+
+// CheckVersion returns ErrStale if expected, such as the version in If-Match,
+// is not 0 and differs from version, the version read.
+func CheckVersion(expected, version uint) error {
+	if expected != 0 && expected != version {
+		return ErrStale
+	}
+	return nil
+}
+
+// TxBumpVersion increments the version column of the row of T with id if it
+// is still version, so that of concurrent updates having read the same
+// version, only the first one succeeds and the others get ErrStale. Call it
+// before other updates of the row in the same transaction. A version of 0
+// bumps unconditionally, for updates which never go stale, such as those by
+// scheduled jobs. It returns gorm.ErrRecordNotFound if there is no such row.
+func TxBumpVersion[T any](tx *gorm.DB, id, version uint) error {
+	db := tx.Model(new(T)).Where("id = ?", id)
+	if version != 0 {
+		db = db.Where("version = ?", version)
+	}
+	db = db.Update("version", gorm.Expr("version + 1"))
+	if err := db.Error; err != nil {
+		return err
+	}
+	if db.RowsAffected > 0 {
+		return nil
+	}
+	if err := tx.Select("id").First(new(T), id).Error; err != nil {
+		return err
+	}
+	return ErrStale
+}
@@ -0,0 +1,65 @@
+package dao
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type versionTestItem struct {
+	gorm.Model
+	Count   int
+	Version uint `gorm:"not null; default:1"`
+}
+
+func TestBumpVersion(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "version.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&versionTestItem{}); err != nil {
+		t.Fatal(err)
+	}
+	item := &versionTestItem{}
+	db.Create(item)
+	if item.Version != 1 {
+		t.Fatalf("Expect version 1 of a new row, but got %d", item.Version)
+	}
+
+	if err := TxBumpVersion[versionTestItem](db, item.ID, 1); err != nil {
+		t.Errorf("Expect version 1 bumped, but got %v", err)
+	}
+	if err := TxBumpVersion[versionTestItem](db, item.ID, 1); !errors.Is(err, ErrStale) {
+		t.Errorf("Expect stale version 1, but got %v", err)
+	}
+	if err := TxBumpVersion[versionTestItem](db, item.ID, 0); err != nil {
+		t.Errorf("Expect version bumped unconditionally, but got %v", err)
+	}
+	db.First(item, item.ID)
+	if item.Version != 3 {
+		t.Errorf("Expect version 3, but got %d", item.Version)
+	}
+	if err := TxBumpVersion[versionTestItem](db, item.ID+1, 1); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expect row not found, but got %v", err)
+	}
+
+	// the update after a stale bump is rolled back with it
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := TxBumpVersion[versionTestItem](tx, item.ID, 2); err != nil {
+			return err
+		}
+		return tx.Model(item).Update("count", 1).Error
+	})
+	db.First(item, item.ID)
+	if !errors.Is(err, ErrStale) || item.Count != 0 {
+		t.Errorf("Expect stale update not applied, but got %d %v", item.Count, err)
+	}
+}
@@ -1,58 +1,205 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/xaxys/maintainman/core/config"
 
-	"gorm.io/driver/mysql"
+	"github.com/spf13/viper"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var (
 	DB *gorm.DB
+
+	// replicas serve read-only queries, see Replica.
+	replicas    []*gorm.DB
+	replicaNext atomic.Uint32
+	// reader serves read-only queries of sqlite without replicas, see sqliteDSN.
+	reader *gorm.DB
 )
 
 func init() {
-	dbType := config.AppConfig.GetString("database.driver")
-	switch dbType {
+	db, err := Open(config.AppConfig.Viper(), "")
+	if err != nil {
+		panic(fmt.Errorf("No error should happen when connecting to database, but got: %+v", err))
+	}
+	DB = db
+	for _, replica := range config.AppConfig.GetStringSlice("database.replicas") {
+		db, err := Open(config.AppConfig.Viper(), replica)
+		if err != nil {
+			panic(fmt.Errorf("No error should happen when connecting to database replica %s, but got: %+v", replica, err))
+		}
+		replicas = append(replicas, db)
+	}
+	if len(replicas) == 0 && config.AppConfig.GetString("database.driver") == "sqlite" {
+		db, err := open(config.AppConfig.Viper(), sqlite.Open(sqliteReaderDSN(config.AppConfig.Viper())))
+		if err != nil {
+			panic(fmt.Errorf("No error should happen when connecting to database, but got: %+v", err))
+		}
+		reader = db
+	}
+}
+
+// Open connects to the database configured in config with the pool settings.
+// If replica is not empty, it connects to the replica instead, which is
+// a database file for sqlite, or a host with an optional port for others.
+func Open(config *viper.Viper, replica string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch config.GetString("database.driver") {
 	case "mysql":
-		DB = initMysql()
+		d, err := mysqlDialector(mysqlDSN(config, replica))
+		if err != nil {
+			return nil, err
+		}
+		dialector = d
+	case "postgres":
+		dialector = postgres.Open(postgresDSN(config, replica))
 	case "sqlite":
-		DB = initSqlite()
+		dialector = sqlite.Open(sqliteDSN(config, replica))
 	default:
-		panic(fmt.Errorf("support mysql and sqlite only"))
+		return nil, fmt.Errorf("support mysql, postgres and sqlite only")
 	}
+	return open(config, dialector)
 }
 
-func initSqlite() *gorm.DB {
-	dbPath := config.AppConfig.GetString("database.sqlite.path")
-	db, err := gorm.Open(sqlite.Open(dbPath))
+func open(config *viper.Viper, dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector)
 	if err != nil {
-		panic(fmt.Errorf("No error should happen when connecting to database, but got: %+v", err))
+		return nil, err
 	}
-	return db
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(config.GetInt("database.pool.max_open"))
+	sqlDB.SetMaxIdleConns(config.GetInt("database.pool.max_idle"))
+	sqlDB.SetConnMaxLifetime(config.GetDuration("database.pool.max_lifetime"))
+	sqlDB.SetConnMaxIdleTime(config.GetDuration("database.pool.max_idle_time"))
+	return db, nil
 }
 
-func initMysql() *gorm.DB {
-	dbHost := config.AppConfig.GetString("database.mysql.host")
-	dbPort := config.AppConfig.GetInt("database.mysql.port")
-	dbName := config.AppConfig.GetString("database.mysql.name")
-	dbParams := config.AppConfig.GetString("database.mysql.params")
-	dbUser := config.AppConfig.GetString("database.mysql.user")
-	dbPasswd := config.AppConfig.GetString("database.mysql.password")
-	dbURL := fmt.Sprintf("%s:%s@(%s:%d)/%s?%s", dbUser, dbPasswd, dbHost, dbPort, dbName, dbParams)
+// sqliteDSN opens replicas read-only, as they are copies of the primary.
+// The primary is opened in WAL mode, so that readers do not block the writer.
+// Its transactions begin immediately, and wait up to
+// database.sqlite.busy_timeout for other writers, such as the audit log, as
+// a deferred transaction fails with database is locked if another writer
+// commits after it reads. Read-only queries without replicas go to the
+// pool of sqliteReaderDSN, so that they never wait for writers.
+func sqliteDSN(config *viper.Viper, replica string) string {
+	if replica != "" {
+		return "file:" + replica + "?mode=ro"
+	}
+	return sqlitePrimaryDSN(config, "_txlock=immediate")
+}
 
-	db, err := gorm.Open(mysql.Open(dbURL))
-	if err != nil {
-		panic(fmt.Errorf("No error should happen when connecting to database, but got: %+v", err))
+// sqliteReaderDSN opens the primary for read-only queries, whose
+// transactions begin deferred.
+func sqliteReaderDSN(config *viper.Viper) string {
+	return sqlitePrimaryDSN(config, "_txlock=deferred&_query_only=1")
+}
+
+func sqlitePrimaryDSN(config *viper.Viper, params string) string {
+	path := config.GetString("database.sqlite.path")
+	params = fmt.Sprintf("_journal_mode=WAL&_busy_timeout=%d&%s", config.GetDuration("database.sqlite.busy_timeout").Milliseconds(), params)
+	if strings.Contains(path, "?") {
+		return path + "&" + params
 	}
-	return db
+	return path + "?" + params
 }
 
-func SyncModel(model ...any) {
-	if err := DB.AutoMigrate(model...); err != nil {
+func mysqlDSN(config *viper.Viper, replica string) string {
+	dbHost, dbPort := replicaHost(config, "mysql", replica)
+	dbName := config.GetString("database.mysql.name")
+	dbParams := config.GetString("database.mysql.params")
+	dbUser := config.GetString("database.mysql.user")
+	dbPasswd := config.GetString("database.mysql.password")
+	return fmt.Sprintf("%s:%s@(%s:%s)/%s?%s", dbUser, dbPasswd, dbHost, dbPort, dbName, dbParams)
+}
+
+// postgresDSN builds a connection URL, so that user and password may contain any characters.
+func postgresDSN(config *viper.Viper, replica string) string {
+	dbHost, dbPort := replicaHost(config, "postgres", replica)
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.GetString("database.postgres.user"), config.GetString("database.postgres.password")),
+		Host:     net.JoinHostPort(dbHost, dbPort),
+		Path:     "/" + config.GetString("database.postgres.name"),
+		RawQuery: config.GetString("database.postgres.params"),
+	}
+	return dsn.String()
+}
+
+// replicaHost returns the host and port of the replica, which default to those of the primary.
+func replicaHost(config *viper.Viper, driver, replica string) (host, port string) {
+	host = config.GetString("database." + driver + ".host")
+	port = strconv.Itoa(config.GetInt("database." + driver + ".port"))
+	if replica == "" {
+		return
+	}
+	if h, p, err := net.SplitHostPort(replica); err == nil {
+		return h, p
+	}
+	return replica, port
+}
+
+// Replica returns the replicas in turn, or DB if there is no replica.
+// Replicas may lag behind DB, so only read-only queries which tolerate
+// stale results should use it, such as listing. Writes and transactions
+// reading before writing should always use DB. Without replicas, sqlite
+// reads through another pool of the same database, which is never stale.
+func Replica() *gorm.DB {
+	if len(replicas) == 0 {
+		if reader != nil {
+			return reader
+		}
+		return DB
+	}
+	n := replicaNext.Add(1)
+	return replicas[int(n)%len(replicas)]
+}
+
+func SyncModel(db *gorm.DB, model ...any) {
+	if err := db.AutoMigrate(model...); err != nil {
 		panic(fmt.Errorf("Unable to sync the struct to database: %+v", err))
 	}
 }
+
+// Close closes DB and replicas.
+func Close() error {
+	for _, db := range append([]*gorm.DB{DB}, replicas...) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping checks the connections of DB and replicas.
+func Ping(ctx context.Context) error {
+	for i, db := range append([]*gorm.DB{DB}, replicas...) {
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		if err != nil && i > 0 {
+			return fmt.Errorf("replica %d: %v", i, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
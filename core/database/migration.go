@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a versioned schema change of a module.
+// Migrations run before the models are synchronized, so Up and Down
+// should check the existence of tables and columns they touch.
+type Migration struct {
+	Version string // module version which introduces the migration
+	Name    string // short description
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // nil if the migration is irreversible
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	ID        uint   `gorm:"primaryKey"`
+	Module    string `gorm:"size:64;uniqueIndex:idx_schema_migration"`
+	Version   string `gorm:"size:32;uniqueIndex:idx_schema_migration"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState is the state of a migration of a module.
+type MigrationState struct {
+	Module    string
+	Version   string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func sortMigrations(module string, migrations []*Migration) ([]*Migration, error) {
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return config.VersionCompare(sorted[i].Version, sorted[j].Version) < 0
+	})
+	for i := 1; i < len(sorted); i++ {
+		if config.VersionCompare(sorted[i-1].Version, sorted[i].Version) == 0 {
+			return nil, fmt.Errorf("module %s has more than one migration of version %s", module, sorted[i].Version)
+		}
+	}
+	return sorted, nil
+}
+
+func appliedMigrations(db *gorm.DB, module string) (map[string]*SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	records := []*SchemaMigration{}
+	if err := db.Where("module = ?", module).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[string]*SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// MigrateUp applies pending migrations of the module in version order,
+// each in its own transaction. It returns the versions applied.
+func MigrateUp(db *gorm.DB, module string, migrations []*Migration) ([]string, error) {
+	sorted, err := sortMigrations(module, migrations)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db, module)
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, m := range sorted {
+		if applied[m.Version] != nil {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if m.Up != nil {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+			}
+			record := &SchemaMigration{
+				Module:    module,
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}
+			return tx.Create(record).Error
+		})
+		if err != nil {
+			return versions, fmt.Errorf("migration %s of module %s failed: %v", m.Version, module, err)
+		}
+		versions = append(versions, m.Version)
+	}
+	return versions, nil
+}
+
+// MigrateDown reverts the latest applied migration of the module.
+// It returns the version reverted, or an empty string if there is none.
+func MigrateDown(db *gorm.DB, module string, migrations []*Migration) (string, error) {
+	if _, err := sortMigrations(module, migrations); err != nil {
+		return "", err
+	}
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return "", err
+	}
+	record := &SchemaMigration{}
+	if err := db.Where("module = ?", module).Order("id desc").Limit(1).Find(record).Error; err != nil {
+		return "", err
+	}
+	if record.ID == 0 {
+		return "", nil
+	}
+	var migration *Migration
+	for _, m := range migrations {
+		if config.VersionCompare(m.Version, record.Version) == 0 {
+			migration = m
+			break
+		}
+	}
+	if migration == nil {
+		return "", fmt.Errorf("migration %s of module %s is applied but not registered", record.Version, module)
+	}
+	if migration.Down == nil {
+		return "", fmt.Errorf("migration %s of module %s is irreversible", record.Version, module)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(record).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("reverting migration %s of module %s failed: %v", record.Version, module, err)
+	}
+	return record.Version, nil
+}
+
+// MigrationStatus returns the states of migrations of the module in version order.
+func MigrationStatus(db *gorm.DB, module string, migrations []*Migration) ([]*MigrationState, error) {
+	sorted, err := sortMigrations(module, migrations)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db, module)
+	if err != nil {
+		return nil, err
+	}
+	states := []*MigrationState{}
+	for _, m := range sorted {
+		state := &MigrationState{
+			Module:  module,
+			Version: m.Version,
+			Name:    m.Name,
+		}
+		if r := applied[m.Version]; r != nil {
+			state.Applied = true
+			state.AppliedAt = r.AppliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
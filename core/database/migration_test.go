@@ -0,0 +1,130 @@
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type migrationTestUser struct {
+	ID   uint
+	Name string
+}
+
+func newMigrationTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection of memory database has its own database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := newMigrationTestDB(t)
+	migrations := []*Migration{
+		{
+			Version: "1.10.0",
+			Name:    "rename name to nick",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().RenameColumn(&migrationTestUser{}, "name", "nick")
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().RenameColumn(&migrationTestUser{}, "nick", "name")
+			},
+		},
+		{
+			Version: "1.2.0",
+			Name:    "create users",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&migrationTestUser{})
+			},
+		},
+	}
+
+	versions, err := MigrateUp(db, "test", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0] != "1.2.0" || versions[1] != "1.10.0" {
+		t.Errorf("Expect [1.2.0 1.10.0] applied, but got %v", versions)
+	}
+	if !db.Migrator().HasColumn(&migrationTestUser{}, "nick") {
+		t.Error("Expect column nick")
+	}
+
+	// applied migrations are skipped
+	versions, err = MigrateUp(db, "test", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 0 {
+		t.Errorf("Expect nothing applied, but got %v", versions)
+	}
+
+	version, err := MigrateDown(db, "test", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "1.10.0" {
+		t.Errorf("Expect 1.10.0 reverted, but got %s", version)
+	}
+	if !db.Migrator().HasColumn(&migrationTestUser{}, "name") {
+		t.Error("Expect column name")
+	}
+
+	states, err := MigrationStatus(db, "test", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || !states[0].Applied || states[1].Applied {
+		t.Errorf("Expect 1.2.0 applied and 1.10.0 pending, but got %+v %+v", states[0], states[1])
+	}
+
+	// 1.2.0 has no Down
+	if _, err := MigrateDown(db, "test", migrations); err == nil {
+		t.Error("Expect irreversible migration error")
+	}
+
+	// other modules are not affected
+	version, err = MigrateDown(db, "other", migrations)
+	if err != nil || version != "" {
+		t.Errorf("Expect nothing reverted, but got %s %v", version, err)
+	}
+}
+
+func TestMigrateFailed(t *testing.T) {
+	db := newMigrationTestDB(t)
+	migrations := []*Migration{
+		{
+			Version: "1.0.0",
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Migrator().CreateTable(&migrationTestUser{}); err != nil {
+					return err
+				}
+				return tx.Exec("SELECT * FROM not_exist").Error
+			},
+		},
+	}
+	if _, err := MigrateUp(db, "test", migrations); err == nil {
+		t.Fatal("Expect migration error")
+	}
+	states, err := MigrationStatus(db, "test", migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states[0].Applied {
+		t.Error("Expect failed migration not recorded")
+	}
+
+	_, err = MigrateUp(db, "test", []*Migration{{Version: "1.0"}, {Version: "1.0.0"}})
+	if err != nil {
+		t.Errorf("Expect 1.0 and 1.0.0 to be different versions, but got %v", err)
+	}
+	if _, err := MigrateUp(db, "dup", []*Migration{{Version: "1.0.0"}, {Version: "1.0.0"}}); err == nil {
+		t.Error("Expect duplicate version error")
+	}
+}
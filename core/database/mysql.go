@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xaxys/maintainman/core/logger"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// killTimeout limits the connection used to kill a query.
+const killTimeout = 5 * time.Second
+
+// mysqlDialector opens mysql with connections killing their queries when
+// the contexts are done. Canceling a context only closes the connection on
+// mysql, and the query keeps running on the server, unlike sqlite and
+// postgres which abort it. KILL QUERY aborts any statement, on mysql and
+// mariadb alike, and the connection is discarded afterwards.
+func mysqlDialector(dsn string) (gorm.Dialector, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysqldriver.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.New(mysql.Config{Conn: sql.OpenDB(&killConnector{Connector: connector})}), nil
+}
+
+type mysqlConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+	driver.NamedValueChecker
+}
+
+type mysqlStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+	driver.NamedValueChecker
+}
+
+type mysqlRows interface {
+	driver.Rows
+	driver.RowsNextResultSet
+	driver.RowsColumnTypeDatabaseTypeName
+	driver.RowsColumnTypeLength
+	driver.RowsColumnTypeNullable
+	driver.RowsColumnTypePrecisionScale
+	driver.RowsColumnTypeScanType
+}
+
+type killConnector struct {
+	driver.Connector
+}
+
+func (c *killConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mc, ok := conn.(mysqlConn)
+	if !ok {
+		return conn, nil
+	}
+	id, err := connectionID(ctx, mc)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &killConn{mysqlConn: mc, connector: c, id: id}, nil
+}
+
+// kill aborts the running query of the connection id through a new connection.
+func (c *killConnector) kill(id uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return fmt.Errorf("mysql connection cannot execute")
+	}
+	_, err = execer.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", id), nil)
+	return err
+}
+
+func connectionID(ctx context.Context, conn mysqlConn) (uint64, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT CONNECTION_ID()", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		return 0, err
+	}
+	switch id := dest[0].(type) {
+	case int64:
+		return uint64(id), nil
+	case []byte:
+		return strconv.ParseUint(string(id), 10, 64)
+	default:
+		return 0, fmt.Errorf("unexpected connection id %v", id)
+	}
+}
+
+type killConn struct {
+	mysqlConn
+	connector *killConnector
+	id        uint64
+	killed    atomic.Bool
+}
+
+// watch kills the query once ctx is done, until the returned stop is called.
+// If the kill has started, stop waits for it and marks the connection bad
+// before it is returned to the pool, so that the kill never reaches the next
+// query on the connection.
+func (c *killConn) watch(ctx context.Context) (stop func()) {
+	done := make(chan struct{})
+	stopKill := context.AfterFunc(ctx, func() {
+		defer close(done)
+		if err := c.connector.kill(c.id); err != nil {
+			logger.Logger.Warnf("Unable to kill mysql query of connection %d: %+v", c.id, err)
+		}
+	})
+	return sync.OnceFunc(func() {
+		if !stopKill() {
+			<-done
+			c.killed.Store(true)
+		}
+	})
+}
+
+func (c *killConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stop := c.watch(ctx)
+	rows, err := c.mysqlConn.QueryContext(ctx, query, args)
+	return c.wrapRows(rows, err, stop)
+}
+
+func (c *killConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stop := c.watch(ctx)
+	defer stop()
+	return c.mysqlConn.ExecContext(ctx, query, args)
+}
+
+func (c *killConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.mysqlConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	ms, ok := stmt.(mysqlStmt)
+	if !ok {
+		return stmt, nil
+	}
+	return &killStmt{mysqlStmt: ms, conn: c}, nil
+}
+
+// wrapRows keeps watching ctx until the rows are closed, as the result set
+// is still being read from the server.
+func (c *killConn) wrapRows(rows driver.Rows, err error, stop func()) (driver.Rows, error) {
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	mr, ok := rows.(mysqlRows)
+	if !ok {
+		stop()
+		return rows, nil
+	}
+	return &killRows{mysqlRows: mr, stop: stop}, nil
+}
+
+// ResetSession and IsValid discard the connection once a query is killed,
+// as its state is unknown afterwards.
+func (c *killConn) ResetSession(ctx context.Context) error {
+	if c.killed.Load() {
+		return driver.ErrBadConn
+	}
+	return c.mysqlConn.ResetSession(ctx)
+}
+
+func (c *killConn) IsValid() bool {
+	return !c.killed.Load() && c.mysqlConn.IsValid()
+}
+
+type killStmt struct {
+	mysqlStmt
+	conn *killConn
+}
+
+func (s *killStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	stop := s.conn.watch(ctx)
+	rows, err := s.mysqlStmt.QueryContext(ctx, args)
+	return s.conn.wrapRows(rows, err, stop)
+}
+
+func (s *killStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	stop := s.conn.watch(ctx)
+	defer stop()
+	return s.mysqlStmt.ExecContext(ctx, args)
+}
+
+type killRows struct {
+	mysqlRows
+	stop func()
+}
+
+func (r *killRows) Close() error {
+	r.stop()
+	return r.mysqlRows.Close()
+}
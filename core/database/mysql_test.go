@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMysql is a mysql server whose kills are slow, and whose queries call
+// hook when they run.
+type fakeMysql struct {
+	mu     sync.Mutex
+	conns  uint64
+	killed []uint64
+	last   uint64
+	hook   func()
+}
+
+func (s *fakeMysql) Connect(ctx context.Context) (driver.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns++
+	return &fakeMysqlConn{server: s, id: s.conns}, nil
+}
+
+func (s *fakeMysql) Driver() driver.Driver { return nil }
+
+func (s *fakeMysql) run(id uint64) {
+	s.mu.Lock()
+	s.last = id
+	hook := s.hook
+	s.hook = nil
+	s.mu.Unlock()
+	if hook != nil {
+		hook()
+	}
+}
+
+func (s *fakeMysql) kill(id uint64) {
+	time.Sleep(50 * time.Millisecond)
+	s.mu.Lock()
+	s.killed = append(s.killed, id)
+	s.mu.Unlock()
+}
+
+type fakeMysqlConn struct {
+	server *fakeMysql
+	id     uint64
+}
+
+func (c *fakeMysqlConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeMysqlConn) Close() error              { return nil }
+func (c *fakeMysqlConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+func (c *fakeMysqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeMysqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeMysqlConn) Ping(ctx context.Context) error                 { return nil }
+func (c *fakeMysqlConn) ResetSession(ctx context.Context) error         { return nil }
+func (c *fakeMysqlConn) IsValid() bool                                  { return true }
+func (c *fakeMysqlConn) CheckNamedValue(value *driver.NamedValue) error { return nil }
+
+func (c *fakeMysqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var id uint64
+	if _, err := fmt.Sscanf(query, "KILL QUERY %d", &id); err == nil {
+		c.server.kill(id)
+		return driver.RowsAffected(0), nil
+	}
+	c.server.run(c.id)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeMysqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == "SELECT CONNECTION_ID()" {
+		return &fakeMysqlRows{values: []driver.Value{int64(c.id)}}, nil
+	}
+	c.server.run(c.id)
+	return &fakeMysqlRows{values: []driver.Value{int64(1)}}, nil
+}
+
+type fakeMysqlRows struct {
+	values []driver.Value
+}
+
+func (r *fakeMysqlRows) Columns() []string { return []string{"value"} }
+func (r *fakeMysqlRows) Close() error      { return nil }
+
+func (r *fakeMysqlRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func (r *fakeMysqlRows) HasNextResultSet() bool                      { return false }
+func (r *fakeMysqlRows) NextResultSet() error                        { return io.EOF }
+func (r *fakeMysqlRows) ColumnTypeDatabaseTypeName(index int) string { return "BIGINT" }
+func (r *fakeMysqlRows) ColumnTypeLength(index int) (int64, bool)    { return 0, false }
+func (r *fakeMysqlRows) ColumnTypeNullable(index int) (bool, bool)   { return false, false }
+func (r *fakeMysqlRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	return 0, 0, false
+}
+func (r *fakeMysqlRows) ColumnTypeScanType(index int) any { return nil }
+
+// TestKillConn finishes queries just as their contexts are done, so that the
+// kills are still running when the queries return, and checks that the
+// connections are discarded instead of being killed in their next queries.
+func TestKillConn(t *testing.T) {
+	server := &fakeMysql{}
+	db := sql.OpenDB(&killConnector{Connector: server})
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	queries := map[string]func(ctx context.Context) error{
+		"exec": func(ctx context.Context) error {
+			_, err := db.ExecContext(ctx, "DO 1")
+			return err
+		},
+		"query": func(ctx context.Context) error {
+			rows, err := db.QueryContext(ctx, "SELECT 1")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		},
+	}
+	for name, query := range queries {
+		ctx, cancel := context.WithCancel(context.Background())
+		server.mu.Lock()
+		server.hook = cancel
+		server.mu.Unlock()
+		if err := query(ctx); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		server.mu.Lock()
+		killed, id := append([]uint64{}, server.killed...), server.last
+		server.mu.Unlock()
+		if len(killed) == 0 || killed[len(killed)-1] != id {
+			t.Errorf("%s: expect kill of connection %d finished before return, but got %v", name, id, killed)
+		}
+
+		if err := query(context.Background()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		server.mu.Lock()
+		next := server.last
+		server.mu.Unlock()
+		if next == id {
+			t.Errorf("%s: expect killed connection %d discarded, but reused", name, id)
+		}
+	}
+}
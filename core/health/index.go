@@ -0,0 +1,176 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xaxys/maintainman/core/cache"
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/database"
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/storage"
+
+	"github.com/kataras/iris/v12"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded" // only non-critical components are down
+	StatusDisabled = "disabled"
+)
+
+// ErrDisabled is returned by a checker whose component is disabled.
+// The component is reported as disabled and does not fail the check.
+var ErrDisabled = errors.New("component is disabled")
+
+// Checker checks a component and returns an error if it is unhealthy.
+// It should return as soon as ctx is done.
+type Checker func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	checker  Checker
+}
+
+// Component is the health status of a component.
+type Component struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the health status of the service.
+type Report struct {
+	Status     string       `json:"status"` // up, degraded or down
+	Components []*Component `json:"components"`
+}
+
+var (
+	mu     sync.RWMutex
+	checks []*check
+)
+
+func init() {
+	Register("database", true, database.Ping)
+	Register("cache", true, cache.Ping)
+	Register("storage", true, func(ctx context.Context) error {
+		if storage.Storage == nil {
+			return ErrDisabled
+		}
+		return storage.Storage.Ping()
+	})
+}
+
+// Register registers a checker of the component. The service is not ready if a
+// critical component is down, and is degraded if any other component is down.
+// The checker registered before with the same name is replaced.
+func Register(name string, critical bool, checker Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	c := &check{name: name, critical: critical, checker: checker}
+	for i, old := range checks {
+		if old.name == name {
+			checks[i] = c
+			return
+		}
+	}
+	checks = append(checks, c)
+}
+
+// Check runs all checkers concurrently, each within the timeout.
+func Check(timeout time.Duration) *Report {
+	mu.RLock()
+	cs := append([]*check{}, checks...)
+	mu.RUnlock()
+
+	components := make([]*Component, len(cs))
+	wg := sync.WaitGroup{}
+	for i, c := range cs {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			components[i] = run(c, timeout)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusUp, Components: components}
+	for _, c := range components {
+		if c.Status != StatusDown {
+			continue
+		}
+		if c.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+func run(c *check, timeout time.Duration) *Component {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				result <- fmt.Errorf("check panic: %v", err)
+			}
+		}()
+		result <- c.checker(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timeout after %s", timeout)
+	}
+
+	component := &Component{
+		Name:      c.name,
+		Status:    StatusUp,
+		Critical:  c.critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	switch {
+	case errors.Is(err, ErrDisabled):
+		component.Status = StatusDisabled
+	case err != nil:
+		component.Status = StatusDown
+		component.Error = err.Error()
+	}
+	return component
+}
+
+func healthTimeout() time.Duration {
+	return config.AppConfig.GetDuration("app.health_timeout")
+}
+
+// Liveness reports only that the server is serving. Components are not
+// checked, as restarting the service does not help if a dependency is down.
+func Liveness(ctx iris.Context) {
+	ctx.JSON(model.Success(&Report{Status: StatusUp, Components: []*Component{}}, "服务存活"))
+}
+
+// Readiness reports the health of all components.
+// It responds 503 if any critical component is down.
+func Readiness(ctx iris.Context) {
+	report := Check(healthTimeout())
+	if report.Status == StatusDown {
+		ctx.StatusCode(iris.StatusServiceUnavailable)
+		ctx.JSON(model.ApiResponse(iris.StatusServiceUnavailable, false, report, "服务不可用"))
+		return
+	}
+	ctx.JSON(model.Success(report, "服务就绪"))
+}
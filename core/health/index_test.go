@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func findComponent(report *Report, name string) *Component {
+	for _, c := range report.Components {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	defer func(old []*check) { checks = old }(checks)
+	checks = nil
+
+	Register("ok", true, func(ctx context.Context) error { return nil })
+	Register("disabled", true, func(ctx context.Context) error { return ErrDisabled })
+	report := Check(time.Second)
+	if report.Status != StatusUp {
+		t.Errorf("Expect up, but got %s", report.Status)
+	}
+	if c := findComponent(report, "disabled"); c == nil || c.Status != StatusDisabled {
+		t.Errorf("Expect disabled component, but got %+v", c)
+	}
+
+	Register("optional", false, func(ctx context.Context) error { return errors.New("failed") })
+	report = Check(time.Second)
+	if report.Status != StatusDegraded {
+		t.Errorf("Expect degraded, but got %s", report.Status)
+	}
+	if c := findComponent(report, "optional"); c == nil || c.Status != StatusDown || c.Error != "failed" {
+		t.Errorf("Expect failed optional component, but got %+v", c)
+	}
+
+	Register("slow", true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	Register("panic", false, func(ctx context.Context) error { panic("check failed") })
+	start := time.Now()
+	report = Check(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expect checks to run concurrently within timeout, but took %s", elapsed)
+	}
+	if report.Status != StatusDown {
+		t.Errorf("Expect down, but got %s", report.Status)
+	}
+	if c := findComponent(report, "slow"); c == nil || c.Status != StatusDown {
+		t.Errorf("Expect slow component down, but got %+v", c)
+	}
+	if c := findComponent(report, "panic"); c == nil || c.Status != StatusDown {
+		t.Errorf("Expect panic component down, but got %+v", c)
+	}
+
+	// registering the same name replaces the checker
+	Register("slow", true, func(ctx context.Context) error { return nil })
+	report = Check(time.Second)
+	if len(report.Components) != 5 {
+		t.Errorf("Expect 5 components, but got %d", len(report.Components))
+	}
+	if c := findComponent(report, "slow"); c == nil || c.Status != StatusUp {
+		t.Errorf("Expect slow component up, but got %+v", c)
+	}
+}
@@ -1,9 +1,15 @@
 package middleware
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/rate"
+	"github.com/spf13/viper"
 	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/util"
 )
 
 var (
@@ -11,11 +17,110 @@ var (
 )
 
 func init() {
-	if config.AppConfig.GetBool("throttling.enable") {
-		tRate := config.AppConfig.GetInt("throttling.rate")
-		burst := config.AppConfig.GetInt("throttling.burst")
-		purge := config.AppConfig.GetDuration("throttling.purge")
-		expire := config.AppConfig.GetDuration("throttling.expire")
-		RateLimiter = rate.Limit(float64(tRate), burst, rate.PurgeEvery(purge, expire))
+	limiter := NewLimiter(config.AppConfig.Viper(), "throttling")
+	RateLimiter = limiter.Handler
+	config.AddConfigValidator(config.AppConfig, func(v *viper.Viper) error {
+		return ValidateLimiter(v, "throttling")
+	})
+	config.OnConfigChange(config.AppConfig, limiter.Reload)
+}
+
+// Limiter is a rate limiter configured by the keys under prefix,
+// which can be reloaded when the configuration changes.
+type Limiter struct {
+	prefix  string
+	handler util.AtomPtr[iris.Handler]
+
+	mu     sync.Mutex
+	config *limiterConfig
+	stop   chan struct{} // stops the purger of the current rate limiter
+}
+
+type limiterConfig struct {
+	enable        bool
+	rate, burst   int
+	purge, expire time.Duration
+}
+
+func NewLimiter(config *viper.Viper, prefix string) *Limiter {
+	limiter := &Limiter{prefix: prefix}
+	limiter.Reload(config)
+	return limiter
+}
+
+// Reload rebuilds the rate limiter from config if its keys change, which
+// resets the throttling of all clients.
+func (l *Limiter) Reload(config *viper.Viper) {
+	c := &limiterConfig{enable: config.GetBool(l.prefix + ".enable")}
+	if c.enable {
+		c.rate = config.GetInt(l.prefix + ".rate")
+		c.burst = config.GetInt(l.prefix + ".burst")
+		c.purge = config.GetDuration(l.prefix + ".purge")
+		c.expire = config.GetDuration(l.prefix + ".expire")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.config != nil && *l.config == *c {
+		return
+	}
+	l.config = c
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	var handler iris.Handler
+	if c.enable {
+		var limiter *rate.Limiter
+		handler = rate.Limit(float64(c.rate), c.burst, func(rl *rate.Limiter) { limiter = rl })
+		l.stop = make(chan struct{})
+		go purgeLimiter(limiter, c.purge, c.expire, l.stop)
+	}
+	l.handler.Set(&handler)
+}
+
+// purgeLimiter removes clients unseen for expire every purge until stop is
+// closed, unlike rate.PurgeEvery which never stops.
+func purgeLimiter(limiter *rate.Limiter, purge, expire time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(purge)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			limiter.Purge(func(c *rate.Client) bool {
+				return time.Since(c.LastSeen()) > expire
+			})
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Handler limits the request with the current rate limiter.
+func (l *Limiter) Handler(ctx iris.Context) {
+	if handler := l.handler.Get(); handler != nil && *handler != nil {
+		(*handler)(ctx)
+		return
+	}
+	ctx.Next()
+}
+
+// ValidateLimiter checks the rate limiter configuration under prefix.
+func ValidateLimiter(config *viper.Viper, prefix string) error {
+	if !config.GetBool(prefix + ".enable") {
+		return nil
+	}
+	if config.GetInt(prefix+".rate") <= 0 {
+		return fmt.Errorf("%s.rate should be positive", prefix)
+	}
+	if config.GetInt(prefix+".burst") <= 0 {
+		return fmt.Errorf("%s.burst should be positive", prefix)
+	}
+	if config.GetDuration(prefix+".purge") <= 0 {
+		return fmt.Errorf("%s.purge should be a positive duration", prefix)
+	}
+	if config.GetDuration(prefix+".expire") <= 0 {
+		return fmt.Errorf("%s.expire should be a positive duration", prefix)
 	}
+	return nil
 }
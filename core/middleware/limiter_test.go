@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestLimiterReload(t *testing.T) {
+	v := viper.New()
+	v.Set("test.enable", true)
+	v.Set("test.rate", 1)
+	v.Set("test.burst", 1)
+	v.Set("test.purge", "1h")
+	v.Set("test.expire", "1h")
+	goroutines := runtime.NumGoroutine()
+	l := NewLimiter(v, "test")
+	handler, stop := l.handler.Get(), l.stop
+
+	// unrelated keys keep the rate limiter and its clients
+	for i := 0; i < 10; i++ {
+		v.Set("app.loglevel", i)
+		l.Reload(v)
+	}
+	if l.handler.Get() != handler || l.stop != stop {
+		t.Error("Expect rate limiter kept on unrelated changes")
+	}
+
+	// the purger of the replaced rate limiter stops
+	v.Set("test.rate", 2)
+	l.Reload(v)
+	if l.handler.Get() == handler {
+		t.Error("Expect rate limiter rebuilt on changes")
+	}
+	select {
+	case <-stop:
+	default:
+		t.Error("Expect purger of the old rate limiter stopped")
+	}
+	v.Set("test.enable", false)
+	l.Reload(v)
+	if h := l.handler.Get(); h == nil || *h != nil || l.stop != nil {
+		t.Error("Expect rate limiter disabled")
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("Expect no purger left, but got %d goroutines of %d", n, goroutines)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/xaxys/maintainman/core/config"
+
+	"github.com/kataras/iris/v12"
+)
+
+var (
+	DatabaseTimeout iris.Handler
+)
+
+func init() {
+	// DatabaseTimeout sets the deadline of the request context to database.timeout
+	// later, so that queries with the request context are canceled after it.
+	// They are also canceled when the client disconnects.
+	DatabaseTimeout = func(ctx iris.Context) {
+		timeout := config.AppConfig.GetDuration("database.timeout")
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+		c, cancel := context.WithTimeout(ctx.Request().Context(), timeout)
+		defer cancel()
+		ctx.ResetRequest(ctx.Request().WithContext(c))
+		ctx.Next()
+	}
+}
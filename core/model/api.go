@@ -119,3 +119,18 @@ func ErrorNoPermissions(errs ...error) *ApiJson {
 func ErrorInternalServer(errs ...error) *ApiJson {
 	return ApiResponse(500, false, combineError(errs...), "服务器内部错误")
 }
+
+// ErrorConflict 数据冲突
+func ErrorConflict(errs ...error) *ApiJson {
+	return ApiResponse(409, false, combineError(errs...), "数据冲突")
+}
+
+// ErrorStale 数据已被修改 返回数据的当前状态
+func ErrorStale(objects interface{}) *ApiJson {
+	return ApiResponse(409, false, objects, "数据已被修改")
+}
+
+// ErrorServiceUnavailable 服务不可用
+func ErrorServiceUnavailable(errs ...error) *ApiJson {
+	return ApiResponse(503, false, combineError(errs...), "服务不可用")
+}
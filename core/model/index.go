@@ -23,3 +23,12 @@ type AuthInfo struct {
 	IP    string
 	Other map[string]any
 }
+
+// DeletedUnix returns the unix timestamp in seconds the row was soft deleted at,
+// or 0 if it is not deleted.
+func DeletedUnix(deletedAt gorm.DeletedAt) int64 {
+	if !deletedAt.Valid {
+		return 0
+	}
+	return deletedAt.Time.Unix()
+}
@@ -0,0 +1,124 @@
+package module
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xaxys/maintainman/core/config"
+)
+
+const (
+	nodeUnvisited = iota
+	nodeVisiting
+	nodeVisited
+)
+
+// sortModules sorts modules in topological order of ModuleDepends.
+// Modules keep their registration order whenever their dependencies allow.
+// Dependencies which are not registered are ignored here, and reported when loading.
+func sortModules(modules []*Module) ([]*Module, error) {
+	index := make(map[string]*Module, len(modules))
+	for _, m := range modules {
+		if index[m.ModuleName] != nil {
+			return nil, fmt.Errorf("module %s registered more than once", m.ModuleName)
+		}
+		index[m.ModuleName] = m
+	}
+
+	state := make(map[string]int, len(modules))
+	stack := []string{}
+	sorted := make([]*Module, 0, len(modules))
+
+	var visit func(m *Module) error
+	visit = func(m *Module) error {
+		switch state[m.ModuleName] {
+		case nodeVisited:
+			return nil
+		case nodeVisiting:
+			start := 0
+			for i, name := range stack {
+				if name == m.ModuleName {
+					start = i
+					break
+				}
+			}
+			path := append(stack[start:], m.ModuleName)
+			return fmt.Errorf("module dependency cycle detected: %s", strings.Join(path, " -> "))
+		}
+
+		state[m.ModuleName] = nodeVisiting
+		stack = append(stack, m.ModuleName)
+		for _, dep := range m.ModuleDepends {
+			if d := index[dep]; d != nil {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[m.ModuleName] = nodeVisited
+		sorted = append(sorted, m)
+		return nil
+	}
+
+	for _, m := range modules {
+		if err := visit(m); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+const (
+	moduleLoaded   = "loaded"
+	moduleDisabled = "disabled"
+	moduleSkipped  = "skipped"
+)
+
+// resolveStatus returns the status of a module, and the reason if it is
+// skipped. Dependencies of the module should be resolved in status before.
+func resolveStatus(m *Module, status map[string]string) (string, string) {
+	configKey := fmt.Sprintf("module.%s", m.ModuleName)
+	if config.AppConfig.Get(configKey) != nil && !config.AppConfig.GetBool(configKey) {
+		return moduleDisabled, ""
+	}
+	if reason := checkDepends(m, status); reason != "" {
+		return moduleSkipped, reason
+	}
+	return moduleLoaded, ""
+}
+
+// Loadable returns the modules which are loaded by Register in dependency
+// order, excluding modules disabled by configuration or skipped for their
+// dependencies.
+func Loadable(modules ...*Module) ([]*Module, error) {
+	sorted, err := sortModules(modules)
+	if err != nil {
+		return nil, err
+	}
+	status := map[string]string{}
+	loadable := []*Module{}
+	for _, m := range sorted {
+		status[m.ModuleName], _ = resolveStatus(m, status)
+		if status[m.ModuleName] == moduleLoaded {
+			loadable = append(loadable, m)
+		}
+	}
+	return loadable, nil
+}
+
+// checkDepends returns the reason why a module can not be loaded,
+// or an empty string if all its dependencies are loaded.
+func checkDepends(m *Module, status map[string]string) string {
+	for _, dep := range m.ModuleDepends {
+		switch status[dep] {
+		case moduleLoaded:
+			continue
+		case "":
+			return fmt.Sprintf("dependency %s is not registered", dep)
+		default:
+			return fmt.Sprintf("dependency %s is %s", dep, status[dep])
+		}
+	}
+	return ""
+}
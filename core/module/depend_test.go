@@ -0,0 +1,111 @@
+package module
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestModule(name string, depends ...string) *Module {
+	return &Module{
+		ModuleName:    name,
+		ModuleDepends: depends,
+	}
+}
+
+func moduleNames(modules []*Module) string {
+	names := []string{}
+	for _, m := range modules {
+		names = append(names, m.ModuleName)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSortModules(t *testing.T) {
+	sorted, err := sortModules([]*Module{
+		newTestModule("word", "order"),
+		newTestModule("wxnotify", "user", "order"),
+		newTestModule("order", "user"),
+		newTestModule("role"),
+		newTestModule("user"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := moduleNames(sorted); names != "user,order,word,wxnotify,role" {
+		t.Errorf("Expect user,order,word,wxnotify,role, but got %s", names)
+	}
+
+	// registration order is kept when there is no dependency
+	sorted, err = sortModules([]*Module{
+		newTestModule("a"),
+		newTestModule("b"),
+		newTestModule("c"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := moduleNames(sorted); names != "a,b,c" {
+		t.Errorf("Expect a,b,c, but got %s", names)
+	}
+
+	// missing dependency is left to the loader
+	sorted, err = sortModules([]*Module{
+		newTestModule("a", "missing"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := moduleNames(sorted); names != "a" {
+		t.Errorf("Expect a, but got %s", names)
+	}
+}
+
+func TestSortModulesCycle(t *testing.T) {
+	_, err := sortModules([]*Module{
+		newTestModule("a"),
+		newTestModule("b", "c"),
+		newTestModule("c", "d"),
+		newTestModule("d", "b"),
+	})
+	if err == nil {
+		t.Fatal("Expect cycle error")
+	}
+	if !strings.Contains(err.Error(), "b -> c -> d -> b") {
+		t.Errorf("Expect full cycle path, but got %v", err)
+	}
+
+	_, err = sortModules([]*Module{
+		newTestModule("a", "a"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "a -> a") {
+		t.Errorf("Expect self cycle error, but got %v", err)
+	}
+
+	_, err = sortModules([]*Module{
+		newTestModule("a"),
+		newTestModule("a"),
+	})
+	if err == nil {
+		t.Error("Expect duplicate module error")
+	}
+}
+
+func TestCheckDepends(t *testing.T) {
+	status := map[string]string{
+		"user":  moduleLoaded,
+		"order": moduleDisabled,
+		"word":  moduleSkipped,
+	}
+	if reason := checkDepends(newTestModule("a", "user"), status); reason != "" {
+		t.Errorf("Expect no reason, but got %s", reason)
+	}
+	if reason := checkDepends(newTestModule("a", "user", "order"), status); reason != "dependency order is disabled" {
+		t.Errorf("Expect order disabled, but got %s", reason)
+	}
+	if reason := checkDepends(newTestModule("a", "word"), status); reason != "dependency word is skipped" {
+		t.Errorf("Expect word skipped, but got %s", reason)
+	}
+	if reason := checkDepends(newTestModule("a", "image"), status); reason != "dependency image is not registered" {
+		t.Errorf("Expect image not registered, but got %s", reason)
+	}
+}
@@ -1,11 +1,13 @@
 package module
 
 import (
+	"context"
+
 	"github.com/xaxys/maintainman/core/cache"
+	"github.com/xaxys/maintainman/core/config"
 	"github.com/xaxys/maintainman/core/storage"
 
 	"github.com/kataras/iris/v12"
-	"github.com/spf13/viper"
 )
 
 type ModuleContext struct {
@@ -13,24 +15,28 @@ type ModuleContext struct {
 	Route   iris.Party
 	Storage storage.IStorage
 	Cache   cache.ICache
+
+	trashPurge *trashPurge
 }
 
 type IModule interface {
 	Name() string
 	Version() string
-	Export(string) (any, bool)
 }
 
 type Module struct {
 	ModuleName    string
 	ModuleVersion string
-	ModuleConfig  *viper.Viper
+	ModuleConfig  *config.Config
 	ModuleDepends []string       // depend modules
 	ModuleEnv     map[string]any // unexported functions or variables, only accessible to system
-	ModuleExport  map[string]any // exported functions or variables, accessible to all modules
 	ModuleRoute   string         // route prefix
 	ModulePerm    map[string]string
 	EntryPoint    func(mctx *ModuleContext)
+	StartPoint    func(mctx *ModuleContext)       // called when the module is enabled again at runtime
+	StopPoint     func(mctx *ModuleContext)       // called in reverse load order on shutdown, or when the module is disabled at runtime
+	HealthCheck   func(ctx context.Context) error // optional, a failing module degrades the service but does not make it unready
+	CommandPoint  func(mctx *ModuleContext)       // called instead of EntryPoint by a command registry, provides services without serving
 }
 
 func (m *Module) Name() string {
@@ -41,11 +47,6 @@ func (m *Module) Version() string {
 	return m.ModuleVersion
 }
 
-func (m *Module) Export(name string) (any, bool) {
-	v, ok := m.ModuleExport[name]
-	return v, ok
-}
-
 func (m *Module) getOnEvict() func(any) error {
 	evictValue, ok := m.ModuleEnv["cache.evict"]
 	if !ok {
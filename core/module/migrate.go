@@ -0,0 +1,98 @@
+package module
+
+import (
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/database"
+	"github.com/xaxys/maintainman/core/logger"
+
+	"gorm.io/gorm"
+)
+
+// MigrateUp applies pending migrations of modules to db in dependency order.
+func MigrateUp(db *gorm.DB, modules ...*Module) error {
+	sorted, err := sortModules(modules)
+	if err != nil {
+		return err
+	}
+	for _, m := range sorted {
+		migrations, err := m.getMigrations()
+		if err != nil {
+			return err
+		}
+		versions, err := database.MigrateUp(db, m.ModuleName, migrations)
+		for _, v := range versions {
+			logger.Logger.Infof("Module %s migrated to %s", m.ModuleName, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateDown reverts the latest steps applied migrations of the module from db.
+func MigrateDown(db *gorm.DB, m *Module, steps int) error {
+	migrations, err := m.getMigrations()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < steps; i++ {
+		version, err := database.MigrateDown(db, m.ModuleName, migrations)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			logger.Logger.Infof("Module %s has no applied migration", m.ModuleName)
+			return nil
+		}
+		logger.Logger.Infof("Module %s reverted migration %s", m.ModuleName, version)
+	}
+	return nil
+}
+
+// MigrationStatus returns the states of migrations of modules in db in dependency order.
+func MigrationStatus(db *gorm.DB, modules ...*Module) ([]*database.MigrationState, error) {
+	sorted, err := sortModules(modules)
+	if err != nil {
+		return nil, err
+	}
+	states := []*database.MigrationState{}
+	for _, m := range sorted {
+		migrations, err := m.getMigrations()
+		if err != nil {
+			return nil, err
+		}
+		s, err := database.MigrationStatus(db, m.ModuleName, migrations)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s...)
+	}
+	return states, nil
+}
+
+// getMigrations returns the migrations of the module, which are introduced
+// by the module version or earlier ones.
+func (m *Module) getMigrations() ([]*database.Migration, error) {
+	migrationValue, ok := m.ModuleEnv["orm.migration"]
+	if !ok {
+		return nil, nil
+	}
+	var migrations []*database.Migration
+	switch value := migrationValue.(type) {
+	case []*database.Migration:
+		migrations = value
+	case *database.Migration:
+		migrations = []*database.Migration{value}
+	default:
+		return nil, fmt.Errorf("module %s: orm.migration should be []*database.Migration, but got %T", m.ModuleName, migrationValue)
+	}
+	for _, migration := range migrations {
+		if config.VersionCompare(migration.Version, m.ModuleVersion) > 0 {
+			return nil, fmt.Errorf("module %s: migration %s is newer than module version %s", m.ModuleName, migration.Version, m.ModuleVersion)
+		}
+	}
+	return migrations, nil
+}
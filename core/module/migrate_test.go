@@ -0,0 +1,34 @@
+package module
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xaxys/maintainman/core/database"
+)
+
+func TestGetMigrations(t *testing.T) {
+	m := &Module{ModuleName: "test", ModuleVersion: "1.1.0"}
+	if migrations, err := m.getMigrations(); err != nil || migrations != nil {
+		t.Errorf("Expect no migration, but got %v %v", migrations, err)
+	}
+
+	m.ModuleEnv = map[string]any{"orm.migration": &database.Migration{Version: "1.1.0"}}
+	if migrations, err := m.getMigrations(); err != nil || len(migrations) != 1 {
+		t.Errorf("Expect 1 migration, but got %v %v", migrations, err)
+	}
+
+	m.ModuleEnv = map[string]any{"orm.migration": "1.1.0"}
+	if _, err := m.getMigrations(); err == nil || !strings.Contains(err.Error(), "string") {
+		t.Errorf("Expect type error, but got %v", err)
+	}
+	if err := MigrateUp(newTestDatabase(t), m); err == nil {
+		t.Error("Expect migrate error")
+	}
+
+	// migrations are introduced by the module version or earlier ones
+	m.ModuleEnv = map[string]any{"orm.migration": []*database.Migration{{Version: "1.0.2"}, {Version: "1.2.0"}}}
+	if _, err := m.getMigrations(); err == nil || !strings.Contains(err.Error(), "1.2.0") {
+		t.Errorf("Expect version error, but got %v", err)
+	}
+}
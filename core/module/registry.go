@@ -1,11 +1,15 @@
 package module
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sync"
 
 	"github.com/xaxys/maintainman/core/cache"
 	"github.com/xaxys/maintainman/core/config"
 	"github.com/xaxys/maintainman/core/database"
+	"github.com/xaxys/maintainman/core/health"
 	"github.com/xaxys/maintainman/core/logger"
 	"github.com/xaxys/maintainman/core/rbac"
 	"github.com/xaxys/maintainman/core/router"
@@ -14,34 +18,74 @@ import (
 )
 
 type Registry struct {
-	modules map[string]*Module
-	server  *Server
+	sync.RWMutex
+	modules    map[string]*Module
+	contexts   map[string]*ModuleContext
+	services   map[reflect.Type]*service
+	status     map[string]string   // status of registered modules
+	changing   map[string]bool     // modules being stopped or started at runtime
+	routes     map[string][]string // routes registered by loaded modules
+	registered []*Module           // all modules in dependency order
+	loaded     []*Module           // modules in load order
+	loading    string              // name of the module whose EntryPoint or CommandPoint is running
+	command    bool                // whether modules are loaded by CommandPoint
+	server     *Server
 }
 
 func NewRegistry(server *Server) *Registry {
 	registry := &Registry{
-		modules: make(map[string]*Module),
-		server:  server,
+		modules:  make(map[string]*Module),
+		contexts: make(map[string]*ModuleContext),
+		services: make(map[reflect.Type]*service),
+		status:   make(map[string]string),
+		changing: make(map[string]bool),
+		routes:   make(map[string][]string),
+		server:   server,
 	}
 	server.Registry = registry
 	return registry
 }
 
+// NewCommandRegistry creates a registry for commands working without the
+// server. Configurations and the database of modules are resolved as usual,
+// but CommandPoint is called instead of EntryPoint, and configurations are
+// not watched.
+func NewCommandRegistry(server *Server) *Registry {
+	registry := NewRegistry(server)
+	registry.command = true
+	return registry
+}
+
 func (r *Registry) Register(module ...*Module) {
 	model := []any{}
 	disabledModule := []*Module{}
+	skippedModule := []*Module{}
 	enabledModule := []*Module{}
 
+	// sort module by dependencies
+	sorted, err := sortModules(module)
+	if err != nil {
+		logger.Logger.Fatalf("Module registry failed: %v", err)
+	}
+
 	// register module
-	for _, m := range module {
+	r.registered = sorted
+	status := r.status
+	for _, m := range sorted {
 		// register model
 		model = append(model, m.getModel()...)
 
 		// check and register module
-		configKey := fmt.Sprintf("module.%s", m.ModuleName)
-		if config.AppConfig.Get(configKey) != nil && !config.AppConfig.GetBool(configKey) {
+		s, reason := resolveStatus(m, status)
+		status[m.ModuleName] = s
+		switch s {
+		case moduleDisabled:
 			disabledModule = append(disabledModule, m)
 			continue
+		case moduleSkipped:
+			logger.Logger.Warnf("Module %s skipped: %s", m.ModuleName, reason)
+			skippedModule = append(skippedModule, m)
+			continue
 		}
 		enabledModule = append(enabledModule, m)
 		r.modules[m.ModuleName] = m
@@ -50,52 +94,155 @@ func (r *Registry) Register(module ...*Module) {
 		rbac.RegisterPerm(m.ModuleName, m.ModulePerm)
 
 		// read and update config
-		if m.ModuleConfig != nil {
-			m.ModuleConfig.SetConfigName(m.ModuleName)
-			m.ModuleConfig.SetConfigType("yaml")
-			m.ModuleConfig.AddConfigPath(".")
-			m.ModuleConfig.AddConfigPath("./config")
-			m.ModuleConfig.AddConfigPath("/etc/maintainman/")
-			m.ModuleConfig.AddConfigPath("$HOME/.maintainman/")
-			config.ReadAndUpdateConfig(m.ModuleConfig, m.ModuleName, m.ModuleVersion)
+		if err := ReadConfig(m); err != nil {
+			logger.Logger.Fatalf("Module %s failed: %v", m.ModuleName, err)
 		}
 	}
 
+	// apply migrations before syncing models, so that they can rename or drop columns
+	if err := MigrateUp(r.server.Database, enabledModule...); err != nil {
+		logger.Logger.Fatalf("Module migration failed: %v", err)
+	}
+
 	// sync database model
-	database.SyncModel(model...)
+	database.SyncModel(r.server.Database, model...)
 
 	// load module
 	for _, m := range enabledModule {
-		// check dependencies
-		for _, dep := range m.ModuleDepends {
-			if r.modules[dep] == nil {
-				logger.Logger.Fatalf("Module %s dependencies missing: %s", m.ModuleName, dep)
-			}
-		}
-
 		// init module context
 		mctx := &ModuleContext{
 			Server:  r.server,
-			Route:   router.APIRoute.Party(m.ModuleRoute),
-			Storage: storage.InitStorage(m.ModuleConfig),
-			Cache:   cache.InitCache(m.ModuleName, m.ModuleConfig, m.getOnEvict()),
+			Route:   router.APIRoute.Party(m.ModuleRoute, r.guard(m.ModuleName)),
+			Storage: storage.InitStorage(m.ModuleConfig.Viper()),
+			Cache:   cache.InitCache(m.ModuleName, m.ModuleConfig.Viper(), m.getOnEvict()),
 		}
 
 		// start loading
 		logger.Logger.Debugf("Module Loading: %s", m.ModuleName)
 
 		// load module
-		m.EntryPoint(mctx)
+		existed := map[any]bool{}
+		for _, route := range router.Routes() {
+			existed[route] = true
+		}
+		r.loading = m.ModuleName
+		if !r.command {
+			m.EntryPoint(mctx)
+		} else if m.CommandPoint != nil {
+			m.CommandPoint(mctx)
+		}
+		r.loading = ""
+		for _, route := range router.Routes() {
+			if !existed[route] {
+				r.routes[m.ModuleName] = append(r.routes[m.ModuleName], route.String())
+			}
+		}
+		r.contexts[m.ModuleName] = mctx
+		r.loaded = append(r.loaded, m)
+		if m.HealthCheck != nil && !r.command {
+			health.Register(fmt.Sprintf("module.%s", m.ModuleName), false, r.healthCheck(m))
+		}
 
 		// finish loading
 		logger.Logger.Debugf("Module Loaded: %s", m.ModuleName)
 	}
 
+	// watch module config
+	if config.AppConfig.GetBool("app.hot_reload") && !r.command {
+		for _, m := range enabledModule {
+			if m.ModuleConfig == nil {
+				continue
+			}
+			if err := config.WatchConfig(m.ModuleConfig, m.ModuleName); err != nil {
+				logger.Logger.Warnf("Watch %s configuration failed: %v", m.ModuleName, err)
+			}
+		}
+	}
+
 	// module log
 	em := util.TransSlice(enabledModule, func(m *Module) string { return m.ModuleName })
 	dm := util.TransSlice(disabledModule, func(m *Module) string { return m.ModuleName })
+	sm := util.TransSlice(skippedModule, func(m *Module) string { return m.ModuleName })
 	logger.Logger.Infof("%d modules loaded: %v", len(em), em)
 	logger.Logger.Infof("%d modules disabled: %v", len(dm), dm)
+	if len(sm) > 0 {
+		logger.Logger.Warnf("%d modules skipped: %v", len(sm), sm)
+	}
+}
+
+// ReadConfig reads and updates the configuration file of the module.
+func ReadConfig(m *Module) error {
+	if m.ModuleConfig == nil {
+		return nil
+	}
+	m.ModuleConfig.SetConfigName(m.ModuleName)
+	m.ModuleConfig.SetConfigType("yaml")
+	m.ModuleConfig.AddConfigPath(".")
+	m.ModuleConfig.AddConfigPath("./config")
+	m.ModuleConfig.AddConfigPath("/etc/maintainman/")
+	m.ModuleConfig.AddConfigPath("$HOME/.maintainman/")
+	return config.ReadAndUpdateConfig(m.ModuleConfig, m.ModuleName, m.ModuleVersion)
+}
+
+// Shutdown stops loaded modules in reverse load order,
+// so that a module is always stopped before its dependencies.
+// Events being emitted are delivered before, so that listeners of
+// the modules handle them before they stop.
+func (r *Registry) Shutdown() {
+	if r.server != nil {
+		r.server.WaitEvents()
+	}
+	r.Lock()
+	defer r.Unlock()
+	for i := len(r.loaded) - 1; i >= 0; i-- {
+		// modules disabled at runtime are already stopped, and modules
+		// loaded by CommandPoint are never started
+		if r.status[r.loaded[i].ModuleName] != moduleDisabled && !r.command {
+			r.stop(r.loaded[i])
+		}
+		r.removeServices(r.loaded[i])
+	}
+	r.loaded = nil
+}
+
+func (r *Registry) stop(m *Module) {
+	mctx := r.contexts[m.ModuleName]
+	if mctx != nil {
+		mctx.stopTrashPurge()
+	}
+	if m.StopPoint == nil {
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Logger.Errorf("Module %s stop panic: %v", m.ModuleName, err)
+		}
+	}()
+	logger.Logger.Debugf("Module Stopping: %s", m.ModuleName)
+	m.StopPoint(mctx)
+	logger.Logger.Debugf("Module Stopped: %s", m.ModuleName)
+}
+
+// removeServices removes services provided by the module.
+func (r *Registry) removeServices(m *Module) {
+	for typ, s := range r.services {
+		if s.module == m.ModuleName {
+			delete(r.services, typ)
+		}
+	}
+}
+
+// healthCheck reports the module disabled at runtime as disabled.
+func (r *Registry) healthCheck(m *Module) health.Checker {
+	return func(ctx context.Context) error {
+		r.RLock()
+		status := r.status[m.ModuleName]
+		r.RUnlock()
+		if status == moduleDisabled {
+			return health.ErrDisabled
+		}
+		return m.HealthCheck(ctx)
+	}
 }
 
 func (r *Registry) Get(moduleName string) IModule {
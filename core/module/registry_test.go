@@ -0,0 +1,282 @@
+package module
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kataras/golog"
+	"github.com/kataras/iris/v12"
+	"github.com/olebedev/emitter"
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/database"
+	"github.com/xaxys/maintainman/core/logger"
+	"github.com/xaxys/maintainman/core/router"
+	"github.com/xaxys/maintainman/core/util"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	// remove the files created in the package directory by config, database
+	// and storage on initialization, the storage directory only if empty
+	for _, file := range []string{"app.yaml", "maintainman.db", "maintainman.db-wal", "maintainman.db-shm", "files"} {
+		os.Remove(file)
+	}
+	os.Exit(code)
+}
+
+// newTestDatabase opens a sqlite database in a temporary directory.
+func newTestDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	logger.Logger = golog.New()
+	stopped := []string{}
+	stop := func(name string) func(*ModuleContext) {
+		return func(*ModuleContext) {
+			stopped = append(stopped, name)
+		}
+	}
+	r := &Registry{
+		contexts: map[string]*ModuleContext{},
+		loaded: []*Module{
+			{ModuleName: "user", StopPoint: stop("user")},
+			{ModuleName: "image"},
+			{ModuleName: "order", StopPoint: func(*ModuleContext) { panic("stop failed") }},
+			{ModuleName: "word", StopPoint: stop("word")},
+		},
+	}
+	r.Shutdown()
+	if names := strings.Join(stopped, ","); names != "word,user" {
+		t.Errorf("Expect word,user, but got %s", names)
+	}
+
+	// modules are stopped only once
+	r.Shutdown()
+	if len(stopped) != 2 {
+		t.Errorf("Expect 2 modules stopped, but got %d", len(stopped))
+	}
+}
+
+func TestRegistryShutdownEvents(t *testing.T) {
+	logger.Logger = golog.New()
+	bus := &emitter.Emitter{}
+	events := bus.On("test")
+	handled := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range events {
+			time.Sleep(time.Millisecond)
+			handled++
+		}
+	}()
+	r := NewRegistry(&Server{EventBus: bus})
+	r.loaded = []*Module{{
+		ModuleName: "listener",
+		StopPoint: func(*ModuleContext) {
+			bus.Off("test", events)
+			<-done
+		},
+	}}
+	for i := 0; i < 10; i++ {
+		r.server.Emit("test", i)
+	}
+	// events being emitted are handled before the listener stops
+	r.Shutdown()
+	if handled != 10 {
+		t.Errorf("Expect 10 events handled, but got %d", handled)
+	}
+	// events emitted after shutdown are dropped
+	late := bus.OnWithCap("test", 1)
+	r.server.Emit("test", 10)
+	r.server.emits.Wait()
+	if len(late) != 0 {
+		t.Error("Expect event dropped after shutdown")
+	}
+}
+
+type testGreeter interface {
+	Greet() string
+}
+
+type testGreeterImpl struct{}
+
+func (testGreeterImpl) Greet() string { return "hello" }
+
+func TestRegistryService(t *testing.T) {
+	logger.Logger = golog.New()
+	r := NewRegistry(&Server{})
+	if _, ok := Resolve[testGreeter](r); ok {
+		t.Error("Expect no provider")
+	}
+
+	r.loading = "greeter"
+	if err := provide[testGreeter](r, testGreeterImpl{}); err != nil {
+		t.Fatal(err)
+	}
+	r.loading = "other"
+	if err := provide[testGreeter](r, testGreeterImpl{}); err == nil || !strings.Contains(err.Error(), "greeter") {
+		t.Errorf("Expect duplicate provider error, but got %v", err)
+	}
+	r.loading = ""
+
+	greeter, ok := Resolve[testGreeter](r)
+	if !ok {
+		t.Fatal("Expect greeter provided")
+	}
+	if greeter.Greet() != "hello" {
+		t.Errorf("Expect hello, but got %s", greeter.Greet())
+	}
+	// implementation type is not the service type
+	if _, ok := Resolve[testGreeterImpl](r); ok {
+		t.Error("Expect implementation type not resolvable")
+	}
+
+	// services are removed when the provider stops
+	r.loaded = []*Module{{ModuleName: "greeter"}}
+	r.Shutdown()
+	if _, ok := Resolve[testGreeter](r); ok {
+		t.Error("Expect greeter removed after shutdown")
+	}
+}
+
+func TestRegisterMigrate(t *testing.T) {
+	logger.Logger = golog.New()
+	router.Register(iris.New())
+	config.AppConfig.Set("module.migrate_disabled", false)
+	defer config.AppConfig.Set("module.migrate_disabled", nil)
+
+	db := newTestDatabase(t)
+	applied := []string{}
+	newModule := func(name string, depends ...string) *Module {
+		return &Module{
+			ModuleName:    name,
+			ModuleVersion: "1.0.1",
+			ModuleDepends: depends,
+			ModuleEnv: map[string]any{
+				"orm.migration": &database.Migration{
+					Version: "1.0.1",
+					Name:    "test",
+					Up: func(tx *gorm.DB) error {
+						applied = append(applied, name)
+						return nil
+					},
+				},
+			},
+			EntryPoint: func(*ModuleContext) {},
+		}
+	}
+	mods := []*Module{
+		newModule("migrate_loaded"),
+		newModule("migrate_disabled"),
+		newModule("migrate_skipped", "migrate_disabled"),
+	}
+	r := NewRegistry(&Server{Database: db})
+	r.Register(mods...)
+	defer r.Shutdown()
+
+	if s := strings.Join(applied, ","); s != "migrate_loaded" {
+		t.Errorf("Expect migrations of migrate_loaded applied, but got %s", s)
+	}
+	states, err := MigrationStatus(db, mods...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range states {
+		if s.Applied != (s.Module == "migrate_loaded") {
+			t.Errorf("Expect migration of %s applied: %v, but got %v", s.Module, s.Module == "migrate_loaded", s.Applied)
+		}
+	}
+
+	loadable, err := Loadable(mods...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loadable) != 1 || loadable[0] != mods[0] {
+		t.Errorf("Expect only migrate_loaded loadable, but got %v", util.TransSlice(loadable, (*Module).Name))
+	}
+}
+
+func TestRegistryDisableConcurrently(t *testing.T) {
+	logger.Logger = golog.New()
+	stopping := make(chan struct{})
+	release := make(chan struct{})
+	m := &Module{
+		ModuleName: "slow",
+		StopPoint: func(*ModuleContext) {
+			close(stopping)
+			<-release
+		},
+	}
+	r := NewRegistry(&Server{})
+	r.registered = []*Module{m}
+	r.loaded = []*Module{m}
+	r.modules[m.ModuleName] = m
+	r.status[m.ModuleName] = moduleLoaded
+
+	done := make(chan error)
+	go func() { done <- r.Disable("slow") }()
+	<-stopping
+
+	// the registry is not locked while the module is stopping
+	if info, err := r.Info("slow"); err != nil || info.Status != moduleDisabled {
+		t.Errorf("Expect slow disabled, but got %v %v", info, err)
+	}
+	if err := r.Enable("slow"); !errors.Is(err, ErrModuleChanging) {
+		t.Errorf("Expect ErrModuleChanging, but got %v", err)
+	}
+	if err := r.Disable("slow"); !errors.Is(err, ErrModuleChanging) {
+		t.Errorf("Expect ErrModuleChanging, but got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Enable("slow"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := r.Info("slow"); info.Status != moduleLoaded {
+		t.Errorf("Expect slow loaded, but got %s", info.Status)
+	}
+}
+
+func TestCommandRegistry(t *testing.T) {
+	logger.Logger = golog.New()
+	router.Register(iris.New())
+	called := []string{}
+	m := &Module{
+		ModuleName:    "command_test",
+		ModuleVersion: "1.0.0",
+		EntryPoint:    func(*ModuleContext) { called = append(called, "entry") },
+		StopPoint:     func(*ModuleContext) { called = append(called, "stop") },
+		CommandPoint: func(mctx *ModuleContext) {
+			called = append(called, "command")
+			Provide[testGreeter](mctx.Registry, testGreeterImpl{})
+		},
+	}
+	r := NewCommandRegistry(&Server{Database: newTestDatabase(t)})
+	r.Register(m)
+	if _, ok := Resolve[testGreeter](r); !ok {
+		t.Error("Expect greeter provided by CommandPoint")
+	}
+	r.Shutdown()
+	if s := strings.Join(called, ","); s != "command" {
+		t.Errorf("Expect only CommandPoint called, but got %s", s)
+	}
+}
@@ -0,0 +1,193 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/logger"
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/util"
+
+	"github.com/kataras/iris/v12"
+)
+
+var (
+	ErrModuleNotFound  = errors.New("module not found")
+	ErrRestartRequired = errors.New("module is not loaded, restart to enable it")
+	ErrModuleChanging  = errors.New("module is being enabled or disabled")
+)
+
+// ModuleInfo is the information of a registered module.
+type ModuleInfo struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Status      string            `json:"status"` // loaded, disabled or skipped
+	Loaded      bool              `json:"loaded"` // whether the module is loaded since startup
+	Depends     []string          `json:"depends"`
+	Permissions map[string]string `json:"permissions"`
+	Routes      []string          `json:"routes"`
+}
+
+// List returns the information of all registered modules in dependency order.
+func (r *Registry) List() []*ModuleInfo {
+	r.RLock()
+	defer r.RUnlock()
+	return util.TransSlice(r.registered, r.info)
+}
+
+// Info returns the information of the module.
+func (r *Registry) Info(name string) (*ModuleInfo, error) {
+	r.RLock()
+	defer r.RUnlock()
+	m := r.find(name)
+	if m == nil {
+		return nil, ErrModuleNotFound
+	}
+	return r.info(m), nil
+}
+
+func (r *Registry) info(m *Module) *ModuleInfo {
+	return &ModuleInfo{
+		Name:        m.ModuleName,
+		Version:     m.ModuleVersion,
+		Status:      r.status[m.ModuleName],
+		Loaded:      r.modules[m.ModuleName] != nil,
+		Depends:     util.CopySlice(m.ModuleDepends),
+		Permissions: m.ModulePerm,
+		Routes:      util.CopySlice(r.routes[m.ModuleName]),
+	}
+}
+
+func (r *Registry) find(name string) *Module {
+	for _, m := range r.registered {
+		if m.ModuleName == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Disable stops the module and rejects requests to its routes.
+// It fails if any running module depends on it. The choice is persisted to app config.
+func (r *Registry) Disable(name string) error {
+	r.Lock()
+	m := r.find(name)
+	if m == nil {
+		r.Unlock()
+		return ErrModuleNotFound
+	}
+	if r.changing[name] {
+		r.Unlock()
+		return ErrModuleChanging
+	}
+	for _, other := range r.loaded {
+		if (r.status[other.ModuleName] == moduleLoaded || r.changing[other.ModuleName]) && util.In(name, other.ModuleDepends...) {
+			r.Unlock()
+			return fmt.Errorf("module %s is depended by module %s", name, other.ModuleName)
+		}
+	}
+	stopping := r.status[name] == moduleLoaded
+	if stopping {
+		// requests are rejected before the module is stopped
+		r.status[name] = moduleDisabled
+		r.changing[name] = true
+	}
+	r.Unlock()
+
+	if stopping {
+		r.stop(m)
+		r.Lock()
+		delete(r.changing, name)
+		r.Unlock()
+		logger.Logger.Infof("Module %s disabled", name)
+	}
+	return persistModule(name, false)
+}
+
+// Enable restarts the module disabled at runtime.
+// It fails if any dependency is not running. The choice is persisted to app config.
+// ErrRestartRequired is returned if the module is not loaded since startup.
+func (r *Registry) Enable(name string) error {
+	r.Lock()
+	m := r.find(name)
+	if m == nil {
+		r.Unlock()
+		return ErrModuleNotFound
+	}
+	if r.changing[name] {
+		r.Unlock()
+		return ErrModuleChanging
+	}
+	if reason := checkDepends(m, r.status); reason != "" {
+		r.Unlock()
+		return fmt.Errorf("module %s can not be enabled: %s", name, reason)
+	}
+	starting := r.modules[name] != nil && r.status[name] == moduleDisabled
+	if starting {
+		r.changing[name] = true
+	}
+	r.Unlock()
+
+	if err := persistModule(name, true); err != nil {
+		if starting {
+			r.Lock()
+			delete(r.changing, name)
+			r.Unlock()
+		}
+		return err
+	}
+	if r.modules[name] == nil {
+		return ErrRestartRequired
+	}
+	if starting {
+		r.start(m)
+		// requests are accepted after the module is started
+		r.Lock()
+		r.status[name] = moduleLoaded
+		delete(r.changing, name)
+		r.Unlock()
+		logger.Logger.Infof("Module %s enabled", name)
+	}
+	return nil
+}
+
+func (r *Registry) start(m *Module) {
+	mctx := r.contexts[m.ModuleName]
+	if mctx != nil {
+		mctx.startTrashPurge()
+	}
+	if m.StartPoint == nil {
+		return
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Logger.Errorf("Module %s start panic: %v", m.ModuleName, err)
+		}
+	}()
+	m.StartPoint(mctx)
+}
+
+func persistModule(name string, enable bool) error {
+	if err := config.SetConfig(config.AppConfig, fmt.Sprintf("module.%s", name), enable); err != nil {
+		return fmt.Errorf("failed to write app configuration file: %v", err)
+	}
+	return nil
+}
+
+// guard rejects requests to routes of the module disabled at runtime.
+func (r *Registry) guard(name string) iris.Handler {
+	return func(ctx iris.Context) {
+		r.RLock()
+		status := r.status[name]
+		r.RUnlock()
+		if status == moduleDisabled {
+			response := model.ErrorServiceUnavailable(fmt.Errorf("module %s is disabled", name))
+			ctx.StatusCode(response.Code)
+			ctx.JSON(response)
+			ctx.StopExecution()
+			return
+		}
+		ctx.Next()
+	}
+}
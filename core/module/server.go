@@ -1,6 +1,10 @@
 package module
 
 import (
+	"sync"
+
+	"github.com/xaxys/maintainman/core/logger"
+
 	"github.com/go-co-op/gocron"
 	"github.com/go-playground/validator"
 	"github.com/kataras/golog"
@@ -13,6 +17,45 @@ type Server struct {
 	Logger    *golog.Logger
 	Scheduler *gocron.Scheduler
 	Database  *gorm.DB
+	Replica   func() *gorm.DB // returns a read-only replica of Database, nil if there is none
 	EventBus  *emitter.Emitter
 	Registry  *Registry
+
+	emitMu     sync.Mutex
+	emits      sync.WaitGroup // events being emitted by Emit
+	emitClosed bool
+}
+
+// ReadDatabase returns a database for read-only queries which tolerate
+// stale results, such as listing. It may lag behind Database.
+func (s *Server) ReadDatabase() *gorm.DB {
+	if s.Replica == nil {
+		return s.Database
+	}
+	return s.Replica()
+}
+
+// Emit emits the event in background, so that the caller is not blocked by
+// listeners. Events emitted after WaitEvents are dropped.
+func (s *Server) Emit(topic string, args ...any) {
+	s.emitMu.Lock()
+	defer s.emitMu.Unlock()
+	if s.emitClosed {
+		logger.Logger.Warnf("Event %s dropped, as the server is shutting down", topic)
+		return
+	}
+	s.emits.Add(1)
+	go func() {
+		defer s.emits.Done()
+		<-s.EventBus.Emit(topic, args...)
+	}()
+}
+
+// WaitEvents waits for events emitted by Emit to be delivered to their
+// listeners, and stops emitting later ones.
+func (s *Server) WaitEvents() {
+	s.emitMu.Lock()
+	s.emitClosed = true
+	s.emitMu.Unlock()
+	s.emits.Wait()
 }
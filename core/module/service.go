@@ -0,0 +1,57 @@
+package module
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/xaxys/maintainman/core/logger"
+)
+
+type service struct {
+	module string // provider module name
+	value  any
+}
+
+func serviceType[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Provide registers svc as the service of type T, which can be resolved by other modules.
+// T is usually an interface type declared by the provider module.
+// It should be called in EntryPoint, so that modules depending on the provider can resolve it.
+func Provide[T any](r *Registry, svc T) {
+	if err := provide(r, svc); err != nil {
+		logger.Logger.Fatalf("Module registry failed: %v", err)
+	}
+}
+
+func provide[T any](r *Registry, svc T) error {
+	typ := serviceType[T]()
+	if s, ok := r.services[typ]; ok {
+		return fmt.Errorf("service %s is already provided by module %s", typ, s.module)
+	}
+	r.services[typ] = &service{
+		module: r.loading,
+		value:  svc,
+	}
+	return nil
+}
+
+// Resolve returns the service of type T provided by a loaded module.
+func Resolve[T any](r *Registry) (T, bool) {
+	s, ok := r.services[serviceType[T]()]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return s.value.(T), true
+}
+
+// MustResolve is like Resolve, but stops the startup if no module provides T.
+func MustResolve[T any](r *Registry) T {
+	svc, ok := Resolve[T](r)
+	if !ok {
+		logger.Logger.Fatalf("Module %s failed: service %s is not provided by any module", r.loading, serviceType[T]())
+	}
+	return svc
+}
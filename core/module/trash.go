@@ -0,0 +1,56 @@
+package module
+
+import (
+	"context"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+
+	"github.com/go-co-op/gocron"
+)
+
+// trashPurge is the trash purge of a module, scheduled while the module runs.
+type trashPurge struct {
+	purge func(ctx context.Context, before time.Time)
+	job   *gocron.Job
+}
+
+// ScheduleTrashPurge runs purge every database.trash.purge with the time
+// before which soft deleted rows of the module should be purged permanently.
+// purge is not run while database.trash.retention is 0. It is called in
+// EntryPoint, and the job is removed when the module stops and scheduled
+// again when it starts, by the registry.
+func (ctx *ModuleContext) ScheduleTrashPurge(purge func(ctx context.Context, before time.Time)) {
+	ctx.stopTrashPurge()
+	ctx.trashPurge = &trashPurge{purge: purge}
+	ctx.startTrashPurge()
+}
+
+func (ctx *ModuleContext) startTrashPurge() {
+	if ctx.trashPurge == nil || ctx.trashPurge.job != nil {
+		return
+	}
+	purge := ctx.trashPurge.purge
+	interval := config.AppConfig.GetDuration("database.trash.purge")
+	job, err := ctx.Scheduler.Every(interval).SingletonMode().Do(func() {
+		// read on each run, so that changes of retention apply without restart
+		retention := config.AppConfig.GetDuration("database.trash.retention")
+		if retention <= 0 {
+			return
+		}
+		purge(context.Background(), time.Now().Add(-retention))
+	})
+	if err != nil {
+		ctx.Logger.Errorf("Schedule trash purge failed: %v", err)
+		return
+	}
+	ctx.trashPurge.job = job
+}
+
+func (ctx *ModuleContext) stopTrashPurge() {
+	if ctx.trashPurge == nil || ctx.trashPurge.job == nil {
+		return
+	}
+	ctx.Scheduler.RemoveByReference(ctx.trashPurge.job)
+	ctx.trashPurge.job = nil
+}
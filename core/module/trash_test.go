@@ -0,0 +1,45 @@
+package module
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"github.com/kataras/golog"
+	"github.com/xaxys/maintainman/core/logger"
+)
+
+func TestTrashPurgeStopStart(t *testing.T) {
+	logger.Logger = golog.New()
+	scheduler := gocron.NewScheduler(time.Local)
+	m := &Module{ModuleName: "trash"}
+	r := NewRegistry(&Server{Logger: logger.Logger, Scheduler: scheduler})
+	r.registered = []*Module{m}
+	r.loaded = []*Module{m}
+	r.modules[m.ModuleName] = m
+	r.status[m.ModuleName] = moduleLoaded
+	mctx := &ModuleContext{Server: r.server}
+	r.contexts[m.ModuleName] = mctx
+
+	mctx.ScheduleTrashPurge(func(context.Context, time.Time) {})
+	if n := len(scheduler.Jobs()); n != 1 {
+		t.Fatalf("Expect trash purge scheduled, but got %d jobs", n)
+	}
+	if err := r.Disable("trash"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(scheduler.Jobs()); n != 0 {
+		t.Errorf("Expect trash purge removed when the module stops, but got %d jobs", n)
+	}
+	if err := r.Enable("trash"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(scheduler.Jobs()); n != 1 {
+		t.Errorf("Expect trash purge scheduled again when the module starts, but got %d jobs", n)
+	}
+	r.Shutdown()
+	if n := len(scheduler.Jobs()); n != 0 {
+		t.Errorf("Expect trash purge removed on shutdown, but got %d jobs", n)
+	}
+}
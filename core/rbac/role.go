@@ -5,9 +5,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/xaxys/maintainman/core/config"
 	"github.com/xaxys/maintainman/core/util"
-
-	"github.com/spf13/viper"
 )
 
 var (
@@ -16,19 +15,19 @@ var (
 
 type RolePersistence struct {
 	sync.RWMutex
-	data  *viper.Viper
+	data  *config.Config
 	roles []RoleInfo
 	index util.CoPtrMap[string, Role]
 	def   util.AtomPtr[Role] // Default role
 	guest util.AtomPtr[Role] // Guest role
 }
 
-func LoadRole(config *viper.Viper) {
+func LoadRole(c *config.Config) {
 	s := &RolePersistence{
-		data: config,
+		data: c,
 	}
 
-	config.UnmarshalKey("role", &s.roles)
+	c.UnmarshalKey("role", &s.roles)
 	for i := range s.roles {
 		role := &Role{
 			RoleInfo: &s.roles[i],
@@ -63,8 +62,7 @@ func LoadRole(config *viper.Viper) {
 
 func (s *RolePersistence) saveRole() {
 	s.Lock()
-	s.data.Set("role", s.roles)
-	s.data.WriteConfig()
+	config.SetConfig(s.data, "role", s.roles)
 	s.Unlock()
 }
 
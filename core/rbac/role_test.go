@@ -7,12 +7,12 @@ import (
 	"sync"
 	"testing"
 
-	"github.com/spf13/viper"
+	"github.com/xaxys/maintainman/core/config"
 )
 
 func TestRole(t *testing.T) {
-	config := viper.New()
-	config.SetDefault("role", []any{
+	c := config.New()
+	c.SetDefault("role", []any{
 		map[string]any{
 			"name":         "user",
 			"display_name": "普通用户",
@@ -21,7 +21,7 @@ func TestRole(t *testing.T) {
 			"inheritance":  []string{},
 		},
 	})
-	LoadRole(config)
+	LoadRole(c)
 	// test get all roles
 	roles := GetAllRoles()
 	fmt.Printf("all %d roles: %v\n", len(roles), roles)
@@ -128,8 +128,8 @@ func TestRole(t *testing.T) {
 
 func TestRoleConcurrency(t *testing.T) {
 	runtime.GOMAXPROCS(runtime.NumCPU())
-	config := viper.New()
-	config.SetDefault("role", []any{
+	c := config.New()
+	c.SetDefault("role", []any{
 		map[string]any{
 			"name":         "user",
 			"display_name": "普通用户",
@@ -138,7 +138,7 @@ func TestRoleConcurrency(t *testing.T) {
 			"inheritance":  []string{},
 		},
 	})
-	LoadRole(config)
+	LoadRole(c)
 
 	roles := GetAllRoles()
 	fmt.Printf("all %d roles: %v\n", len(roles), roles)
@@ -1,16 +1,28 @@
 package router
 
 import (
+	"github.com/xaxys/maintainman/core/health"
 	"github.com/xaxys/maintainman/core/middleware"
+	"github.com/xaxys/maintainman/core/storage"
 
 	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/core/router"
 	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kataras/iris/v12/middleware/recover"
 )
 
-var APIRoute iris.Party
+var (
+	APIRoute iris.Party
+	app      *iris.Application
+)
+
+// Routes returns all registered routes of the application.
+func Routes() []*router.Route {
+	return app.GetRoutes()
+}
 
-func Register(app *iris.Application) {
+func Register(application *iris.Application) {
+	app = application
 	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(middleware.CORS)
@@ -21,14 +33,14 @@ func Register(app *iris.Application) {
 		home.Get("/", func(ctx iris.Context) {
 			ctx.Redirect("/index.html")
 		})
+		home.Get("/healthz", health.Liveness)
+		home.Get("/readyz", health.Readiness)
 	})
 
 	v1 := app.Party("/v1")
-	v1.Use(middleware.HeaderExtractor, middleware.TokenValidator)
-	if middleware.RateLimiter != nil {
-		v1.Use(middleware.RateLimiter)
-	}
+	v1.Use(middleware.HeaderExtractor, middleware.TokenValidator, middleware.RateLimiter, middleware.DatabaseTimeout)
 	v1.Done(middleware.ResponseHandler)
 	v1.SetExecutionRules(iris.ExecutionRules{Done: iris.ExecutionOptions{Force: true}})
+	v1.Get(storage.SignedRoute, storage.ServeSigned)
 	APIRoute = v1
 }
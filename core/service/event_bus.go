@@ -13,8 +13,10 @@ var (
 func init() {
 	size := config.AppConfig.GetUint("bus_buffer")
 	Bus = emitter.New(size)
+	// events are only logged, and voided so that they are not queued to the
+	// channel nobody reads, which would block Emit once it is full
 	Bus.On("*", func(e *emitter.Event) {
 		logger.Logger.Infof("Event Detected: %s", e.OriginalTopic)
 		logger.Logger.Debugf("Event Data: %#v", *e)
-	})
+	}, emitter.Void)
 }
@@ -2,10 +2,18 @@ package storage
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/xaxys/maintainman/core/config"
 	"github.com/xaxys/maintainman/core/logger"
@@ -30,11 +38,56 @@ type IStorage interface {
 	SaveBytes(id, format string, data []byte) error
 	Delete(id string) error
 	Sub(path string, clean bool) IStorage
+	Ping() error // checks whether the storage is accessible
+	// List lists files directly in the storage whose id has prefix, after
+	// marker in order. It returns at most limit files and the marker of the
+	// next page, which is empty on the last page. ContentType is not set.
+	List(prefix, marker string, limit int) (files []*FileInfo, next string, err error)
+	// Dirs lists names of sub storages directly in the storage.
+	Dirs() ([]string, error)
+	Stat(id string) (*FileInfo, error)
+	// OpenRange opens length bytes of file from offset. Negative length
+	// means to the end of file.
+	OpenRange(id string, offset, length int64) (io.ReadCloser, error)
+	// SignedURL returns an URL, by which the file can be downloaded without
+	// a token before expire.
+	SignedURL(id string, expire time.Duration) (string, error)
+}
+
+// FileInfo describes a file in storage.
+type FileInfo struct {
+	ID          string    `json:"id"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	ContentType string    `json:"content_type,omitempty"`
+}
+
+// Objects of S3 storage larger than the multipart threshold are uploaded in
+// parts. S3 requires parts except the last one to be at least 5 MB.
+const (
+	DefaultMultipartThreshold = 64 << 20
+	DefaultPartSize           = 16 << 20
+	MinPartSize               = 5 << 20
+)
+
+// MaxListLimit is the max number of files returned by List.
+const MaxListLimit = 1000
+
+func listLimit(limit int) int {
+	if limit <= 0 || limit > MaxListLimit {
+		return MaxListLimit
+	}
+	return limit
+}
+
+type rangeReader struct {
+	io.Reader
+	io.Closer
 }
 
 func init() {
-	s3Conn, _ = initS3Conn(config.AppConfig)
-	Storage = InitStorage(config.AppConfig)
+	s3Conn, _ = initS3Conn(config.AppConfig.Viper())
+	Storage = InitStorage(config.AppConfig.Viper())
 }
 
 func InitStorage(config *viper.Viper) (storage IStorage) {
@@ -61,26 +114,105 @@ func InitStorage(config *viper.Viper) (storage IStorage) {
 		bucket := config.GetString("storage.s3.bucket")
 		path := config.GetString("storage.s3.path")
 		clean := config.GetBool("storage.s3.clean")
-		storage = newS3Storage(conn, bucket, path, clean)
+		s := newS3Storage(conn, bucket, path, clean)
+		s.threshold = getMultipartOption(config, "storage.s3.multipart.threshold", DefaultMultipartThreshold)
+		s.partSize = max(getMultipartOption(config, "storage.s3.multipart.part_size", DefaultPartSize), MinPartSize)
+		storage = s
 	default:
 		panic(fmt.Errorf("support local and s3 only"))
 	}
 	return storage
 }
 
+// getMultipartOption gets multipart option from config, or from app config
+// if config does not set it.
+func getMultipartOption(c *viper.Viper, key string, def int64) int64 {
+	switch {
+	case c.IsSet(key):
+		return c.GetInt64(key)
+	case config.AppConfig != nil && config.AppConfig.IsSet(key):
+		return config.AppConfig.GetInt64(key)
+	default:
+		return def
+	}
+}
+
 func initS3Conn(config *viper.Viper) (*s3.S3, error) {
 	accessKey := config.GetString("storage.s3.access_key")
 	secretKey := config.GetString("storage.s3.secret_key")
-	regionInfo := config.GetString("storage.s3.region")
 	auth, err := aws.GetAuth(accessKey, secretKey)
 	if err != nil {
 		return nil, fmt.Errorf("aws cannot get auth: %v", err)
 	}
-	region, ok := aws.Regions[regionInfo]
-	if !ok {
-		return nil, fmt.Errorf("invalid region: %v", regionInfo)
+	region, err := initS3Region(config)
+	if err != nil {
+		return nil, err
+	}
+	conn := s3.New(auth, region)
+	client, err := initS3Client(config)
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		conn.HTTPClient = func() *http.Client { return client }
 	}
-	return s3.New(auth, region), nil
+	return conn, nil
+}
+
+// initS3Region returns the named AWS region, or a custom region if endpoint
+// is set, e.g. of a self-hosted MinIO.
+func initS3Region(config *viper.Viper) (aws.Region, error) {
+	regionInfo := config.GetString("storage.s3.region")
+	endpoint := strings.TrimSuffix(config.GetString("storage.s3.endpoint"), "/")
+	if endpoint == "" {
+		region, ok := aws.Regions[regionInfo]
+		if !ok {
+			return aws.Region{}, fmt.Errorf("invalid region: %v", regionInfo)
+		}
+		return region, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return aws.Region{}, fmt.Errorf("invalid endpoint: %v", endpoint)
+	}
+	region := aws.Region{
+		Name:       regionInfo,
+		S3Endpoint: endpoint,
+		// location constraint of us-east-1 is empty
+		S3LocationConstraint: regionInfo != "" && regionInfo != "us-east-1",
+	}
+	if !config.GetBool("storage.s3.path_style") {
+		region.S3BucketEndpoint = u.Scheme + "://${bucket}." + u.Host
+	}
+	return region, nil
+}
+
+// initS3Client returns the http client with TLS options, or nil if no
+// option is set.
+func initS3Client(config *viper.Viper) (*http.Client, error) {
+	insecure := config.GetBool("storage.s3.tls.insecure_skip_verify")
+	caFile := config.GetString("storage.s3.tls.ca_file")
+	if !insecure && caFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read ca file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in ca file: %v", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
 }
 
 type LocalStorage struct {
@@ -95,9 +227,11 @@ func newLocalStorage(path string, clean bool) *LocalStorage {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		os.MkdirAll(path, 0755)
 	}
-	return &LocalStorage{
+	storage := &LocalStorage{
 		path: path,
 	}
+	registerLocal(storage)
+	return storage
 }
 
 func (s *LocalStorage) Path() string {
@@ -160,6 +294,104 @@ func (s *LocalStorage) Delete(id string) error {
 	return os.Remove(fullPath)
 }
 
+func (s *LocalStorage) Ping() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.path)
+	}
+	return nil
+}
+
+func (s *LocalStorage) List(prefix, marker string, limit int) ([]*FileInfo, string, error) {
+	limit = listLimit(limit)
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, "", err
+	}
+	files := []*FileInfo{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) || name <= marker {
+			continue
+		}
+		if len(files) == limit {
+			return files, files[limit-1].ID, nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, &FileInfo{ID: name, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, "", nil
+}
+
+func (s *LocalStorage) Dirs() ([]string, error) {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	return dirs, nil
+}
+
+func (s *LocalStorage) Stat(id string) (*FileInfo, error) {
+	fullPath := filepath.Join(s.path, id)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a file", id)
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	return &FileInfo{
+		ID:          id,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ContentType: http.DetectContentType(head[:n]),
+	}, nil
+}
+
+func (s *LocalStorage) OpenRange(id string, offset, length int64) (io.ReadCloser, error) {
+	fullPath := filepath.Join(s.path, id)
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if offset < 0 || offset > info.Size() {
+		file.Close()
+		return nil, fmt.Errorf("invalid range of %s: offset %d, size %d", id, offset, info.Size())
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return rangeReader{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
 func (s *LocalStorage) Sub(path string, clean bool) IStorage {
 	subPath := filepath.Join(s.path, path)
 	return newLocalStorage(subPath, clean)
@@ -167,16 +399,20 @@ func (s *LocalStorage) Sub(path string, clean bool) IStorage {
 
 // S3Storage is a storage implementation using Amazon S3
 type S3Storage struct {
-	path   string
-	bucket *s3.Bucket
+	path      string
+	bucket    *s3.Bucket
+	threshold int64 // objects larger than threshold are uploaded in parts
+	partSize  int64
 }
 
 func newS3Storage(conn *s3.S3, bucket, path string, clean bool) *S3Storage {
 	bucketObj := conn.Bucket(bucket)
 	path = filepath.Clean(path)
 	storage := &S3Storage{
-		path:   path,
-		bucket: bucketObj,
+		path:      path,
+		bucket:    bucketObj,
+		threshold: DefaultMultipartThreshold,
+		partSize:  DefaultPartSize,
 	}
 	if clean {
 		storage.Clean()
@@ -197,10 +433,11 @@ func (s *S3Storage) Exist(id string) bool {
 	}
 	if resp == nil {
 		logger.Logger.Error("Error while listing S3 bucket: empty response")
+		return false
 	}
 
 	for _, element := range resp.Contents {
-		if element.Key == id {
+		if element.Key == fullPath {
 			return true
 		}
 	}
@@ -211,10 +448,10 @@ func (s *S3Storage) Exist(id string) bool {
 func (s *S3Storage) Load(id string, fn func(io.Reader) error) (err error) {
 	fullPath := s.path + "/" + id
 	rc, err := s.bucket.GetReader(fullPath)
-	defer rc.Close()
 	if err != nil {
 		return err
 	}
+	defer rc.Close()
 	err = fn(rc)
 	return err
 }
@@ -230,17 +467,90 @@ func (s *S3Storage) LoadBytes(id string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// Save streams the written data, holding up to threshold in memory to
+// decide whether to upload in parts, and a part at a time afterwards.
 func (s *S3Storage) Save(id, format string, fn func(io.Writer) error) error {
-	buffer := bytes.NewBuffer(nil)
-	if err := fn(buffer); err != nil {
-		return err
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("failed to save %s: %v", id, r)
+			}
+			pw.CloseWithError(err)
+			done <- err
+		}()
+		err = fn(pw)
+	}()
+	err := s.upload(s.path+"/"+id, format, pr)
+	// stops fn writing if the upload failed
+	pr.CloseWithError(err)
+	if fnErr := <-done; fnErr != nil {
+		return fnErr
 	}
-	return s.SaveBytes(id, format, buffer.Bytes())
+	return err
 }
 
 func (s *S3Storage) SaveBytes(id, format string, data []byte) error {
 	fullPath := s.path + "/" + id
-	return s.bucket.Put(fullPath, data, format, s3.Private)
+	if int64(len(data)) <= s.threshold {
+		return s.bucket.Put(fullPath, data, format, s3.Private)
+	}
+	return s.putMulti(fullPath, format, bytes.NewReader(data))
+}
+
+func (s *S3Storage) upload(fullPath, format string, r io.Reader) error {
+	head, err := io.ReadAll(io.LimitReader(r, s.threshold+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(head)) <= s.threshold {
+		return s.bucket.Put(fullPath, head, format, s3.Private)
+	}
+	return s.putMulti(fullPath, format, io.MultiReader(bytes.NewReader(head), r))
+}
+
+func (s *S3Storage) putMulti(fullPath, format string, r io.Reader) error {
+	multi, err := s.bucket.InitMulti(fullPath, format, s3.Private)
+	if err != nil {
+		return err
+	}
+	parts, err := putParts(multi, r, s.partSize)
+	if err == nil {
+		err = multi.Complete(parts)
+	}
+	if err != nil {
+		if err := multi.Abort(); err != nil {
+			logger.Logger.Warnf("Failed to abort multipart upload of %s: %v", fullPath, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// putParts uploads parts of partSize read from r in turn, so that only one
+// part is held in memory.
+func putParts(multi *s3.Multi, r io.Reader, partSize int64) ([]s3.Part, error) {
+	buf := make([]byte, partSize)
+	parts := []s3.Part{}
+	for n := 1; ; n++ {
+		size, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		part, perr := multi.PutPart(n, bytes.NewReader(buf[:size]))
+		if perr != nil {
+			return nil, perr
+		}
+		parts = append(parts, part)
+		if err == io.ErrUnexpectedEOF {
+			return parts, nil
+		}
+	}
 }
 
 func (s *S3Storage) Delete(id string) error {
@@ -248,9 +558,139 @@ func (s *S3Storage) Delete(id string) error {
 	return s.bucket.Del(fullPath)
 }
 
+func (s *S3Storage) List(prefix, marker string, limit int) ([]*FileInfo, string, error) {
+	base := s.path + "/"
+	if marker != "" {
+		marker = base + marker
+	}
+	resp, err := s.bucket.List(base+prefix, "/", marker, listLimit(limit))
+	if err != nil {
+		return nil, "", err
+	}
+	files := make([]*FileInfo, 0, len(resp.Contents))
+	for _, key := range resp.Contents {
+		modTime, _ := time.Parse(time.RFC3339, key.LastModified)
+		files = append(files, &FileInfo{
+			ID:      strings.TrimPrefix(key.Key, base),
+			Size:    key.Size,
+			ModTime: modTime,
+		})
+	}
+	if !resp.IsTruncated {
+		return files, "", nil
+	}
+	// common prefixes are counted by S3 as well
+	next := resp.NextMarker
+	keys := append(util.TransSlice(resp.Contents, func(k s3.Key) string { return k.Key }), resp.CommonPrefixes...)
+	if next == "" && len(keys) > 0 {
+		sort.Strings(keys)
+		next = keys[len(keys)-1]
+	}
+	return files, strings.TrimPrefix(next, base), nil
+}
+
+func (s *S3Storage) Dirs() ([]string, error) {
+	base := s.path + "/"
+	dirs := []string{}
+	marker := ""
+	for {
+		resp, err := s.bucket.List(base, "/", marker, MaxListLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, prefix := range resp.CommonPrefixes {
+			dirs = append(dirs, strings.TrimSuffix(strings.TrimPrefix(prefix, base), "/"))
+		}
+		if !resp.IsTruncated {
+			return dirs, nil
+		}
+		marker = resp.NextMarker
+		if marker == "" {
+			keys := append(util.TransSlice(resp.Contents, func(k s3.Key) string { return k.Key }), resp.CommonPrefixes...)
+			sort.Strings(keys)
+			marker = keys[len(keys)-1]
+		}
+	}
+}
+
+func (s *S3Storage) Stat(id string) (*FileInfo, error) {
+	fullPath := s.path + "/" + id
+	resp, err := s.bucket.Head(fullPath)
+	if err != nil {
+		return nil, s3Error(id, err)
+	}
+	resp.Body.Close()
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modTime, _ = time.Parse(time.RFC1123, resp.Header.Get("Last-Modified"))
+	}
+	return &FileInfo{
+		ID:          id,
+		Size:        resp.ContentLength,
+		ModTime:     modTime,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+func (s *S3Storage) OpenRange(id string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid range of %s: offset %d", id, offset)
+	}
+	fullPath := s.path + "/" + id
+	// goamz can not send range requests, so do it with a signed url
+	req, err := http.NewRequest("GET", s.bucket.SignedURL(fullPath, time.Now().Add(time.Minute)), nil)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
+	resp, err := s.bucket.S3.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// range is ignored, e.g. of an empty file or zero length
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil && err != io.EOF {
+			resp.Body.Close()
+			return nil, err
+		}
+		if length < 0 {
+			return resp.Body, nil
+		}
+		return rangeReader{Reader: io.LimitReader(resp.Body, length), Closer: resp.Body}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, id)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to load range of %s: %s", id, resp.Status)
+	}
+}
+
+// s3Error wraps not found errors of S3 with fs.ErrNotExist.
+func s3Error(id string, err error) error {
+	if e, ok := err.(*s3.Error); ok && e.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", fs.ErrNotExist, id)
+	}
+	return err
+}
+
 func (s *S3Storage) Sub(path string, clean bool) IStorage {
 	subPath := s.path + "/" + path
-	return newS3Storage(s.bucket.S3, s.bucket.Name, subPath, clean)
+	sub := newS3Storage(s.bucket.S3, s.bucket.Name, subPath, clean)
+	sub.threshold, sub.partSize = s.threshold, s.partSize
+	return sub
+}
+
+func (s *S3Storage) Ping() error {
+	_, err := s.bucket.List(s.path+"/", "/", "", 1)
+	return err
 }
 
 func (s *S3Storage) Clean() error {
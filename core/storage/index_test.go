@@ -0,0 +1,462 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/mitchellh/goamz/s3/s3test"
+	"github.com/spf13/viper"
+)
+
+// fakeS3 is a fake S3 server. Range requests and multipart uploads, which
+// are rejected by s3test as not implemented, are served by the proxy in
+// front of it, and others are served by s3test. Ranges of objects got from
+// s3test are served by http.ServeContent.
+type fakeS3 struct {
+	*httptest.Server
+	backend string
+	proxy   *httputil.ReverseProxy
+	mu      sync.Mutex
+	uploads map[string]*fakeUpload
+	parts   []int // sizes of uploaded parts
+}
+
+type fakeUpload struct {
+	contentType string
+	parts       map[int][]byte
+}
+
+func newFakeS3(t *testing.T, secure bool) *fakeS3 {
+	srv, err := s3test.NewServer(&s3test.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Quit)
+	backend, _ := url.Parse(srv.URL())
+	fake := &fakeS3{
+		backend: srv.URL(),
+		proxy:   httputil.NewSingleHostReverseProxy(backend),
+		uploads: map[string]*fakeUpload{},
+	}
+	if secure {
+		fake.Server = httptest.NewTLSServer(fake)
+	} else {
+		fake.Server = httptest.NewServer(fake)
+	}
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch {
+	case query.Has("uploads") || query.Has("uploadId"):
+		f.serveMultipart(w, r)
+	case r.Method == "GET" && r.Header.Get("Range") != "":
+		f.serveRange(w, r)
+	default:
+		f.proxy.ServeHTTP(w, r)
+	}
+}
+
+func (f *fakeS3) serveRange(w http.ResponseWriter, r *http.Request) {
+	resp, err := http.Get(f.backend + r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		w.WriteHeader(resp.StatusCode)
+		w.Write(data)
+		return
+	}
+	modTime, _ := time.Parse(time.RFC1123, resp.Header.Get("Last-Modified"))
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+}
+
+// serveMultipart assembles parts on completion, and puts the object to s3test.
+func (f *fakeS3) serveMultipart(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query := r.URL.Query()
+	if query.Has("uploads") {
+		id := fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[id] = &fakeUpload{contentType: r.Header.Get("Content-Type"), parts: map[int][]byte{}}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+		return
+	}
+	id := query.Get("uploadId")
+	upload, ok := f.uploads[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<Error><Code>NoSuchUpload</Code></Error>")
+		return
+	}
+	switch r.Method {
+	case "GET":
+		fmt.Fprint(w, "<ListPartsResult><IsTruncated>false</IsTruncated></ListPartsResult>")
+	case "PUT":
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		upload.parts[n] = data
+		f.parts = append(f.parts, len(data))
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(data)))
+	case "POST":
+		complete := struct {
+			Parts []struct{ PartNumber int } `xml:"Part"`
+		}{}
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data := []byte{}
+		for _, p := range complete.Parts {
+			data = append(data, upload.parts[p.PartNumber]...)
+		}
+		req, _ := http.NewRequest("PUT", f.backend+r.URL.Path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", upload.contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		resp.Body.Close()
+		delete(f.uploads, id)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case "DELETE":
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) conn(t *testing.T) *s3.S3 {
+	conn := s3.New(aws.Auth{AccessKey: "access", SecretKey: "secret"}, aws.Region{Name: "fake", S3Endpoint: f.URL, S3LocationConstraint: true})
+	if err := conn.Bucket("bucket").PutBucket(s3.Private); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, newLocalStorage(t.TempDir(), false))
+}
+
+func TestS3Storage(t *testing.T) {
+	testStorage(t, newS3Storage(newFakeS3(t, false).conn(t), "bucket", "images", false))
+}
+
+func TestS3Endpoint(t *testing.T) {
+	fake := newFakeS3(t, true)
+	c := viper.New()
+	c.Set("storage.driver", "s3")
+	c.Set("storage.s3.access_key", "access")
+	c.Set("storage.s3.secret_key", "secret")
+	c.Set("storage.s3.region", "fake")
+	c.Set("storage.s3.endpoint", fake.URL)
+	c.Set("storage.s3.path_style", true)
+	c.Set("storage.s3.bucket", "bucket")
+	c.Set("storage.s3.path", "images")
+
+	// the certificate of fake server is not trusted
+	conn, err := initS3Conn(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Bucket("bucket").PutBucket(s3.Private); err == nil {
+		t.Error("Expect untrusted certificate rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: fake.Certificate().Raw})
+	if err := os.WriteFile(caFile, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("storage.s3.tls.ca_file", caFile)
+	conn, err = initS3Conn(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Bucket("bucket").PutBucket(s3.Private); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("storage.s3.multipart.threshold", MinPartSize)
+	c.Set("storage.s3.multipart.part_size", 0) // raised to MinPartSize
+	s := InitStorage(c).(*S3Storage)
+	if s.threshold != MinPartSize || s.partSize != MinPartSize {
+		t.Errorf("Expect multipart options set, but got %d, %d", s.threshold, s.partSize)
+	}
+	testStorage(t, s)
+
+	// Exist compares the full key
+	if !s.Exist("a1") || s.Exist("a") || s.Exist("missing") {
+		t.Error("Expect only a1 exist")
+	}
+	if s.Sub("cache", false).Exist("a1") {
+		t.Error("Expect a1 not exist in sub storage")
+	}
+
+	data := make([]byte, 2*MinPartSize+1)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := s.SaveBytes("large", "application/octet-stream", data); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{MinPartSize, MinPartSize, 1}; !slices.Equal(fake.parts, want) {
+		t.Errorf("Expect large file uploaded in parts %v, but got %v", want, fake.parts)
+	}
+	if got, err := s.LoadBytes("large"); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expect large file loaded, but got %d bytes, %v", len(got), err)
+	}
+	if info, err := s.Stat("large"); err != nil || info.Size != int64(len(data)) {
+		t.Errorf("Expect size of large file, but got %+v, %v", info, err)
+	}
+
+	// written data is streamed in parts
+	fake.parts = nil
+	err = s.Save("streamed", "application/octet-stream", func(w io.Writer) error {
+		for i := 0; i < len(data); i += 4096 {
+			if _, err := w.Write(data[i:min(i+4096, len(data))]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{MinPartSize, MinPartSize, 1}; !slices.Equal(fake.parts, want) {
+		t.Errorf("Expect streamed file uploaded in parts %v, but got %v", want, fake.parts)
+	}
+	if got, err := s.LoadBytes("streamed"); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Expect streamed file loaded, but got %d bytes, %v", len(got), err)
+	}
+
+	// the upload is aborted if writing fails after some parts
+	errWrite := errors.New("write failed")
+	err = s.Save("failed", "application/octet-stream", func(w io.Writer) error {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("Expect write error returned, but got %v", err)
+	}
+	if s.Exist("failed") || len(fake.uploads) != 0 {
+		t.Errorf("Expect failed upload aborted, but got %d uploads", len(fake.uploads))
+	}
+	if err := s.Save("small", "text/plain", func(w io.Writer) error {
+		_, err := io.WriteString(w, "small")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.LoadBytes("small"); err != nil || string(got) != "small" {
+		t.Errorf("Expect small file put at once, but got %q, %v", got, err)
+	}
+}
+
+func TestS3Region(t *testing.T) {
+	c := viper.New()
+	c.Set("storage.s3.region", "us-west-2")
+	if region, err := initS3Region(c); err != nil || region.S3Endpoint != aws.USWest2.S3Endpoint {
+		t.Errorf("Expect region us-west-2, but got %+v, %v", region, err)
+	}
+	c.Set("storage.s3.region", "REGION")
+	if _, err := initS3Region(c); err == nil {
+		t.Error("Expect unknown region rejected without endpoint")
+	}
+	c.Set("storage.s3.endpoint", "https://minio.example.com:9000/")
+	region, err := initS3Region(c)
+	if err != nil || region.S3Endpoint != "https://minio.example.com:9000" || region.S3BucketEndpoint != "https://${bucket}.minio.example.com:9000" {
+		t.Errorf("Expect virtual host style region, but got %+v, %v", region, err)
+	}
+	c.Set("storage.s3.path_style", true)
+	if region, err := initS3Region(c); err != nil || region.S3BucketEndpoint != "" {
+		t.Errorf("Expect path style region, but got %+v, %v", region, err)
+	}
+	c.Set("storage.s3.endpoint", "minio:9000")
+	if _, err := initS3Region(c); err == nil {
+		t.Error("Expect endpoint without scheme rejected")
+	}
+}
+
+func testStorage(t *testing.T, s IStorage) {
+	for _, id := range []string{"b1", "a2", "a1"} {
+		if err := s.SaveBytes(id, "text/plain", []byte("hello "+id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files in sub storage are not listed
+	if err := s.Sub("cache", false).SaveBytes("a3", "text/plain", []byte("cached")); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(files []*FileInfo) string {
+		s := []string{}
+		for _, f := range files {
+			s = append(s, f.ID)
+		}
+		return strings.Join(s, ",")
+	}
+	files, next, err := s.List("", "", 0)
+	if err != nil || ids(files) != "a1,a2,b1" || next != "" {
+		t.Errorf("Expect a1,a2,b1 listed, but got %s, %q, %v", ids(files), next, err)
+	}
+	if len(files) > 0 && (files[0].Size != 8 || files[0].ModTime.IsZero()) {
+		t.Errorf("Expect size and modified time listed, but got %+v", files[0])
+	}
+	if files, next, err := s.List("a", "", 0); err != nil || ids(files) != "a1,a2" || next != "" {
+		t.Errorf("Expect a1,a2 listed with prefix, but got %s, %q, %v", ids(files), next, err)
+	}
+	files, next, err = s.List("", "", 2)
+	if err != nil || ids(files) != "a1,a2" || next != "a2" {
+		t.Errorf("Expect a1,a2 on first page, but got %s, %q, %v", ids(files), next, err)
+	}
+	files, next, err = s.List("", next, 2)
+	if err != nil || ids(files) != "b1" || next != "" {
+		t.Errorf("Expect b1 on last page, but got %s, %q, %v", ids(files), next, err)
+	}
+
+	if dirs, err := s.Dirs(); err != nil || strings.Join(dirs, ",") != "cache" {
+		t.Errorf("Expect cache listed in dirs, but got %v, %v", dirs, err)
+	}
+
+	info, err := s.Stat("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "a1" || info.Size != 8 || info.ModTime.IsZero() || !strings.HasPrefix(info.ContentType, "text/plain") {
+		t.Errorf("Expect stat of a1, but got %+v", info)
+	}
+	if _, err := s.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expect missing file not exist, but got %v", err)
+	}
+
+	read := func(offset, length int64) (string, error) {
+		rc, err := s.OpenRange("a1", offset, length)
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		buf := bytes.Buffer{}
+		_, err = io.Copy(&buf, rc)
+		return buf.String(), err
+	}
+	cases := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "hello a1"},
+		{1, 3, "ell"},
+		{6, -1, "a1"},
+		{6, 100, "a1"},
+	}
+	for _, c := range cases {
+		if got, err := read(c.offset, c.length); err != nil || got != c.want {
+			t.Errorf("Expect %q from %d with length %d, but got %q, %v", c.want, c.offset, c.length, got, err)
+		}
+	}
+	if _, err := read(100, 1); err == nil {
+		t.Error("Expect offset out of range rejected")
+	}
+	if _, err := s.OpenRange("missing", 0, -1); err == nil {
+		t.Error("Expect missing file not opened")
+	}
+}
+
+func TestSignedURL(t *testing.T) {
+	local := newLocalStorage(t.TempDir(), false)
+	if err := local.SaveBytes("a1", "text/plain", []byte("hello a1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.SignedURL("a1", 0); err == nil {
+		t.Error("Expect 0 expire rejected")
+	}
+	if _, err := local.SignedURL("a1", 1000*time.Hour); err == nil {
+		t.Error("Expect expire longer than max expire rejected")
+	}
+	signed, err := local.SignedURL("a1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(signed)
+	query := u.Query()
+	if u.Path != "/v1"+SignedRoute || query.Get("id") != "a1" || query.Get("storage") != localName(local.path) {
+		t.Errorf("Expect url of a1, but got %s", signed)
+	}
+	if strings.Contains(signed, url.QueryEscape(filepath.ToSlash(local.path))) {
+		t.Errorf("Expect path of storage not exposed, but got %s", signed)
+	}
+	signature := query.Get("signature")
+	query.Del("signature")
+	if sign(SignedRoute, query) != signature {
+		t.Error("Expect signature verified")
+	}
+	tampered := u.Query()
+	tampered.Del("signature")
+	tampered.Set("id", "a2")
+	if sign(SignedRoute, tampered) == signature || sign("/other", query) == signature {
+		t.Error("Expect signature of other id or route rejected")
+	}
+	if s, ok := getLocal(query.Get("storage")); !ok || s != local {
+		t.Error("Expect local storage registered")
+	}
+
+	// signatures are never tokens signed with token.key
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.GetString("token.key")))
+	fmt.Fprintf(mac, "%s\n%s", SignedRoute, query.Encode())
+	if hex.EncodeToString(mac.Sum(nil)) == signature {
+		t.Error("Expect key of signatures derived from token.key")
+	}
+
+	// presigned url of S3 is downloaded without credentials
+	s := newS3Storage(newFakeS3(t, false).conn(t), "bucket", "images", false)
+	if err := s.SaveBytes("a1", "text/plain", []byte("hello a1")); err != nil {
+		t.Fatal(err)
+	}
+	signed, err = s.SignedURL("a1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(signed, "Signature=") {
+		t.Errorf("Expect presigned url, but got %s", signed)
+	}
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if data, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(data) != "hello a1" {
+		t.Errorf("Expect a1 downloaded, but got %s, %q", resp.Status, data)
+	}
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xaxys/maintainman/core/logger"
+)
+
+// Migration copies every file of Src to Dst, including files of sub
+// storages, so that the layout is kept. Each copy is read back from Dst and
+// verified by its SHA-256 checksum. Files recorded in Journal are skipped,
+// so that an interrupted migration can be resumed.
+type Migration struct {
+	Src     IStorage
+	Dst     IStorage
+	Journal *Journal
+	// Progress is called after each file is copied or skipped.
+	Progress func(id string, size int64, skipped bool)
+}
+
+// MigrateResult is the result of a migration.
+type MigrateResult struct {
+	Copied  int   `json:"copied"`
+	Skipped int   `json:"skipped"` // already copied by an interrupted migration
+	Failed  int   `json:"failed"`
+	Bytes   int64 `json:"bytes"` // bytes copied
+}
+
+// Run runs the migration. Failed files are logged and do not stop the
+// migration, and an error is returned at the end if any file failed.
+func (m *Migration) Run(ctx context.Context) (*MigrateResult, error) {
+	if overlaps(m.Src, m.Dst) {
+		return nil, fmt.Errorf("source and destination overlap: %s, %s", m.Src.Path(), m.Dst.Path())
+	}
+	result := &MigrateResult{}
+	if err := m.migrate(ctx, m.Src, m.Dst, "", result); err != nil {
+		return result, err
+	}
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d files failed to migrate", result.Failed)
+	}
+	return result, nil
+}
+
+func (m *Migration) migrate(ctx context.Context, src, dst IStorage, dir string, result *MigrateResult) error {
+	marker := ""
+	for {
+		files, next, err := src.List("", marker, MaxListLimit)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %v", src.Path(), err)
+		}
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			id := path.Join(dir, file.ID)
+			if m.Journal != nil && m.Journal.Done(id) {
+				result.Skipped++
+				m.progress(id, file.Size, true)
+				continue
+			}
+			sum, size, err := copyFile(src, dst, file.ID)
+			if err == nil && m.Journal != nil {
+				err = m.Journal.Add(id, sum)
+			}
+			if err != nil {
+				logger.Logger.Errorf("Failed to migrate %s: %v", id, err)
+				result.Failed++
+				continue
+			}
+			result.Copied++
+			result.Bytes += size
+			m.progress(id, size, false)
+		}
+		if next == "" {
+			break
+		}
+		marker = next
+	}
+
+	dirs, err := src.Dirs()
+	if err != nil {
+		return fmt.Errorf("failed to list directories of %s: %v", src.Path(), err)
+	}
+	for _, name := range dirs {
+		if err := m.migrate(ctx, src.Sub(name, false), dst.Sub(name, false), path.Join(dir, name), result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlaps reports whether a and b are the same storage, or one is in the other.
+func overlaps(a, b IStorage) bool {
+	within := func(a, b string) bool {
+		return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+	}
+	switch a := a.(type) {
+	case *LocalStorage:
+		b, ok := b.(*LocalStorage)
+		if !ok {
+			return false
+		}
+		pa, errA := filepath.Abs(a.path)
+		pb, errB := filepath.Abs(b.path)
+		return errA == nil && errB == nil && within(filepath.ToSlash(pa), filepath.ToSlash(pb))
+	case *S3Storage:
+		b, ok := b.(*S3Storage)
+		return ok && a.bucket.Region.S3Endpoint == b.bucket.Region.S3Endpoint &&
+			a.bucket.Name == b.bucket.Name && within(a.path, b.path)
+	}
+	return false
+}
+
+func (m *Migration) progress(id string, size int64, skipped bool) {
+	if m.Progress != nil {
+		m.Progress(id, size, skipped)
+	}
+}
+
+// copyFile streams the file from src to dst, and verifies the copy.
+func copyFile(src, dst IStorage, id string) (sum string, size int64, err error) {
+	info, err := src.Stat(id)
+	if err != nil {
+		return "", 0, err
+	}
+	h := sha256.New()
+	if err := src.Load(id, func(r io.Reader) error {
+		return dst.Save(id, info.ContentType, func(w io.Writer) error {
+			size, err = io.Copy(io.MultiWriter(w, h), r)
+			return err
+		})
+	}); err != nil {
+		return "", 0, err
+	}
+	sum = hex.EncodeToString(h.Sum(nil))
+
+	check := sha256.New()
+	copied := int64(0)
+	if err := dst.Load(id, func(r io.Reader) error {
+		copied, err = io.Copy(check, r)
+		return err
+	}); err != nil {
+		return "", 0, fmt.Errorf("failed to verify: %v", err)
+	}
+	if copied != size || hex.EncodeToString(check.Sum(nil)) != sum {
+		return "", 0, fmt.Errorf("checksum mismatch: %d bytes copied of %d", copied, size)
+	}
+	return sum, size, nil
+}
+
+// ErrJournalMismatch is returned by OpenJournal if the journal records
+// a migration between other storages.
+var ErrJournalMismatch = errors.New("journal of another migration")
+
+// Journal records migrated files in the format of sha256sum, one file a
+// line, so that an interrupted migration can be resumed. The first line
+// records the source and destination storages.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+	done map[string]string // id -> checksum
+}
+
+// identity returns the storage recorded in journals, which tells storages
+// of the same path on different buckets or endpoints apart.
+func identity(s IStorage) string {
+	switch s := s.(type) {
+	case *LocalStorage:
+		if p, err := filepath.Abs(s.path); err == nil {
+			return "local:" + filepath.ToSlash(p)
+		}
+		return "local:" + filepath.ToSlash(s.path)
+	case *S3Storage:
+		return fmt.Sprintf("s3:%s/%s/%s", s.bucket.Region.S3Endpoint, s.bucket.Name, s.path)
+	}
+	return fmt.Sprintf("%T:%s", s, s.Path())
+}
+
+func journalHeader(src, dst IStorage) string {
+	return fmt.Sprintf("# migrate %q to %q", identity(src), identity(dst))
+}
+
+// OpenJournal opens the journal file of the migration from src to dst, and
+// creates it if it does not exist. ErrJournalMismatch is returned if it
+// records another migration, whose files are not in dst.
+func OpenJournal(name string, src, dst IStorage) (*Journal, error) {
+	data, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	header := journalHeader(src, dst)
+	if len(lines) > 1 && lines[0] != header {
+		return nil, fmt.Errorf("%w: %s: %s", ErrJournalMismatch, name, lines[0])
+	}
+	// the last line is cut off by interruption if it does not end with a
+	// line break, and is dropped
+	if last := lines[len(lines)-1]; last != "" {
+		if err := os.Truncate(name, int64(len(data)-len(last))); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{file: file, done: map[string]string{}}
+	if len(lines) == 1 {
+		if _, err := fmt.Fprintln(file, header); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return j, nil
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		sum, id, ok := strings.Cut(line, "  ")
+		if ok && len(sum) == sha256.Size*2 && id != "" {
+			j.done[id] = sum
+		}
+	}
+	return j, nil
+}
+
+// Done reports whether the file has been migrated.
+func (j *Journal) Done(id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, ok := j.done[id]
+	return ok
+}
+
+// Len returns the number of migrated files.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.done)
+}
+
+// Add records the file as migrated, and syncs the journal to disk.
+func (j *Journal) Add(id, sum string) error {
+	if strings.ContainsAny(id, "\r\n") {
+		return errors.New("invalid file id with line break")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := fmt.Fprintf(j.file, "%s  %s\n", sum, id); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.done[id] = sum
+	return nil
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
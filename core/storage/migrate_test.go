@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kataras/golog"
+	"github.com/xaxys/maintainman/core/logger"
+)
+
+func TestMigrate(t *testing.T) {
+	logger.Logger = golog.New()
+	src := newLocalStorage(t.TempDir(), false)
+	files := map[string]string{
+		"a1":       "hello a1",
+		"a2":       "hello a2",
+		"cache/c1": "cached c1",
+		"cache/c2": "",
+	}
+	for id, data := range files {
+		dir, name := filepath.Split(id)
+		if err := src.Sub(dir, false).SaveBytes(name, "text/plain", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := newS3Storage(newFakeS3(t, false).conn(t), "bucket", "images", false)
+	journalFile := filepath.Join(t.TempDir(), "migrate.journal")
+	journal, err := OpenJournal(journalFile, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// interrupted after the first file
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Migration{Src: src, Dst: dst, Journal: journal, Progress: func(string, int64, bool) { cancel() }}
+	if result, err := m.Run(ctx); err == nil || result.Copied != 1 {
+		t.Fatalf("Expect migration interrupted after 1 file, but got %+v, %v", result, err)
+	}
+	journal.Close()
+
+	journal, err = OpenJournal(journalFile, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	m = &Migration{Src: src, Dst: dst, Journal: journal}
+	result, err := m.Run(context.Background())
+	if err != nil || result.Copied != 3 || result.Skipped != 1 || result.Bytes != int64(len("hello a2")+len("cached c1")) {
+		t.Errorf("Expect migration resumed, but got %+v, %v", result, err)
+	}
+	if journal.Len() != len(files) {
+		t.Errorf("Expect %d files in journal, but got %d", len(files), journal.Len())
+	}
+	for id, data := range files {
+		dir, name := filepath.Split(id)
+		if got, err := dst.Sub(strings.TrimSuffix(dir, "/"), false).LoadBytes(name); err != nil || string(got) != data {
+			t.Errorf("Expect %s migrated, but got %q, %v", id, got, err)
+		}
+	}
+
+	// and back to another local storage without journal
+	back := newLocalStorage(t.TempDir(), false)
+	if _, err := OpenJournal(journalFile, dst, back); !errors.Is(err, ErrJournalMismatch) {
+		t.Errorf("Expect journal of another migration rejected, but got %v", err)
+	}
+	if result, err := (&Migration{Src: dst, Dst: back}).Run(context.Background()); err != nil || result.Copied != len(files) {
+		t.Errorf("Expect all files migrated back, but got %+v, %v", result, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(back.path, "cache", "c1")); err != nil || string(data) != "cached c1" {
+		t.Errorf("Expect layout kept, but got %q, %v", data, err)
+	}
+
+	if _, err := (&Migration{Src: src, Dst: src.Sub("cache", false)}).Run(context.Background()); err == nil {
+		t.Error("Expect overlapped storages rejected")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "migrate.journal")
+	src, dst := newLocalStorage(t.TempDir(), false), newLocalStorage(t.TempDir(), false)
+	// the header is cut off
+	if err := os.WriteFile(name, []byte("# migrate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := OpenJournal(name, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+	if data, _ := os.ReadFile(name); string(data) != journalHeader(src, dst)+"\n" {
+		t.Errorf("Expect header written, but got %q", data)
+	}
+
+	sum := strings.Repeat("0", 64)
+	// the last line is cut off
+	if err := os.WriteFile(name, []byte(journalHeader(src, dst)+"\n"+sum+"  a1\n"+sum+"  cache/c"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	j, err = OpenJournal(name, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.Done("a1") || j.Done("cache/c") || j.Len() != 1 {
+		t.Errorf("Expect only a1 done, but got %d files", j.Len())
+	}
+	if err := j.Add("cache/c1", sum); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	j, err = OpenJournal(name, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if !j.Done("a1") || !j.Done("cache/c1") || j.Len() != 2 {
+		t.Errorf("Expect a1 and cache/c1 done, but got %d files", j.Len())
+	}
+
+	// journals of other storages, or without header, are never resumed
+	for _, c := range []struct {
+		data     string
+		src, dst IStorage
+	}{
+		{journalHeader(src, dst) + "\n", dst, src},
+		{journalHeader(src, dst) + "\n", src, src.Sub("cache", false)},
+		{sum + "  a1\n", src, dst},
+	} {
+		other := filepath.Join(t.TempDir(), "migrate.journal")
+		if err := os.WriteFile(other, []byte(c.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenJournal(other, c.src, c.dst); !errors.Is(err, ErrJournalMismatch) {
+			t.Errorf("Expect journal of %s to %s rejected, but got %v", c.src.Path(), c.dst.Path(), err)
+		}
+		if data, _ := os.ReadFile(other); string(data) != c.data {
+			t.Errorf("Expect journal of another migration kept, but got %q", data)
+		}
+	}
+}
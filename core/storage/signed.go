@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/model"
+
+	"github.com/kataras/iris/v12"
+)
+
+// SignedRoute is the route under /v1, by which files of local storages are
+// downloaded with signed URLs.
+const SignedRoute = "/storage/signed"
+
+var (
+	localsMu sync.RWMutex
+	locals   = map[string]*LocalStorage{} // local storages by name, served by ServeSigned
+)
+
+// localName returns the name of the local storage at path in signed URLs,
+// which does not expose the path and is kept across restarts.
+func localName(path string) string {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(filepath.Clean(path))))
+	return hex.EncodeToString(sum[:8])
+}
+
+func registerLocal(s *LocalStorage) {
+	localsMu.Lock()
+	defer localsMu.Unlock()
+	locals[localName(s.path)] = s
+}
+
+func getLocal(name string) (*LocalStorage, bool) {
+	localsMu.RLock()
+	defer localsMu.RUnlock()
+	s, ok := locals[name]
+	return s, ok
+}
+
+// checkExpire checks expire of signed URLs is in (0, storage.sign.max_expire].
+func checkExpire(expire time.Duration) error {
+	max := config.AppConfig.GetDuration("storage.sign.max_expire")
+	if expire <= 0 || (max > 0 && expire > max) {
+		return fmt.Errorf("invalid expire of signed url: %v, max: %v", expire, max)
+	}
+	return nil
+}
+
+// signKey derives the key of signatures from storage.sign.key, or token.key
+// if it is not set, so that signatures are never valid as tokens.
+func signKey() []byte {
+	key := config.AppConfig.GetString("storage.sign.key")
+	if key == "" {
+		key = config.AppConfig.GetString("token.key")
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte("maintainman signed url"))
+	return mac.Sum(nil)
+}
+
+// sign signs route with query, which must not contain the signature.
+func sign(route string, query url.Values) string {
+	mac := hmac.New(sha256.New, signKey())
+	fmt.Fprintf(mac, "%s\n%s", route, query.Encode())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL returns an URL of route under /v1 with query, which is verified
+// by VerifySigned until expire. The URL is relative if
+// storage.sign.base_url is not set.
+func SignURL(route string, query url.Values, expire time.Duration) (string, error) {
+	if err := checkExpire(expire); err != nil {
+		return "", err
+	}
+	query.Set("expires", strconv.FormatInt(time.Now().Add(expire).Unix(), 10))
+	query.Set("signature", sign(route, query))
+	base := strings.TrimSuffix(config.AppConfig.GetString("storage.sign.base_url"), "/")
+	return base + "/v1" + route + "?" + query.Encode(), nil
+}
+
+// VerifySigned verifies the request is signed by SignURL for route, and
+// returns the time left before it expires. The response is set if not.
+func VerifySigned(ctx iris.Context, route string) (time.Duration, bool) {
+	query := ctx.Request().URL.Query()
+	signature := query.Get("signature")
+	query.Del("signature")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(sign(route, query)), []byte(signature)) {
+		ctx.Values().Set("response", model.ErrorNoPermissions(fmt.Errorf("签名无效")))
+		return 0, false
+	}
+	remain := time.Until(time.Unix(expires, 0))
+	if remain <= 0 {
+		ctx.Values().Set("response", model.ErrorNoPermissions(fmt.Errorf("链接已过期")))
+		return 0, false
+	}
+	return remain, true
+}
+
+// SignedURL returns an URL served by ServeSigned.
+func (s *LocalStorage) SignedURL(id string, expire time.Duration) (string, error) {
+	query := url.Values{}
+	query.Set("storage", localName(s.path))
+	query.Set("id", id)
+	return SignURL(SignedRoute, query, expire)
+}
+
+// SignedURL returns a presigned URL of S3.
+func (s *S3Storage) SignedURL(id string, expire time.Duration) (string, error) {
+	if err := checkExpire(expire); err != nil {
+		return "", err
+	}
+	fullPath := s.path + "/" + id
+	return s.bucket.SignedURL(fullPath, time.Now().Add(expire)), nil
+}
+
+// ServeSigned serves files of local storages with URLs signed by SignedURL.
+// No token is required.
+func ServeSigned(ctx iris.Context) {
+	remain, ok := VerifySigned(ctx, SignedRoute)
+	if !ok {
+		return
+	}
+	id := ctx.URLParam("id")
+	s, ok := getLocal(ctx.URLParam("storage"))
+	if !ok || id == "" || id != filepath.Base(id) || id == ".." {
+		ctx.Values().Set("response", model.ErrorNotFound(fmt.Errorf("未找到文件: %s", id)))
+		return
+	}
+	file, err := os.Open(filepath.Join(s.path, id))
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorNotFound(fmt.Errorf("未找到文件: %s", id)))
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		ctx.Values().Set("response", model.ErrorNotFound(fmt.Errorf("未找到文件: %s", id)))
+		return
+	}
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int64(remain.Seconds())))
+	ctx.ServeContent(file, id, info.ModTime())
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/kataras/golog"
 	"github.com/kataras/iris/v12"
+	"github.com/spf13/viper"
 
+	"github.com/xaxys/maintainman/core/cache"
 	"github.com/xaxys/maintainman/core/config"
 	"github.com/xaxys/maintainman/core/database"
 	"github.com/xaxys/maintainman/core/logger"
@@ -13,7 +19,10 @@ import (
 	"github.com/xaxys/maintainman/core/service"
 	"github.com/xaxys/maintainman/core/util"
 	"github.com/xaxys/maintainman/modules/announce"
+	"github.com/xaxys/maintainman/modules/audit"
+	"github.com/xaxys/maintainman/modules/cachemgr"
 	"github.com/xaxys/maintainman/modules/imagehost"
+	"github.com/xaxys/maintainman/modules/modmgr"
 	"github.com/xaxys/maintainman/modules/order"
 	"github.com/xaxys/maintainman/modules/role"
 	"github.com/xaxys/maintainman/modules/sysinfo"
@@ -50,36 +59,135 @@ func printBanner() {
 // @version       1.0.0
 // @license.name  MIT With PATENTS
 func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// serve starts the server and blocks until it is shut down.
+func serve() {
 	printBanner()
 	app := newApp()
-	app.Listen(config.AppConfig.GetString("app.listen"))
+	done := make(chan struct{})
+	iris.RegisterOnInterrupt(func() {
+		shutdown(app)
+		close(done)
+	})
+	addr := config.AppConfig.GetString("app.listen")
+	if err := app.Listen(addr, iris.WithoutInterruptHandler, iris.WithoutServerError(iris.ErrServerClosed)); err != nil {
+		logger.Logger.Fatalf("Server stopped: %v", err)
+	}
+	<-done
 }
 
-var logLevel = config.AppConfig.GetString("app.loglevel")
+var (
+	logLevel = config.AppConfig.GetString("app.loglevel")
+	registry *module.Registry
+	modules  = []*module.Module{
+		&role.Module,
+		&user.Module,
+		&imagehost.Module,
+		&announce.Module,
+		&order.Module,
+		&wxnotify.Module,
+		&wordcloud.Module,
+		&sysinfo.Module,
+		&modmgr.Module,
+		&audit.Module,
+		&cachemgr.Module,
+	}
+)
 
 func newApp() *iris.Application {
 	app := iris.New()
 	app.Logger().SetLevel(logLevel)
 	logger.Logger = app.Logger()
+	checkTokenKey()
+	registry = newRegistry(app, modules...)
+	service.Scheduler.StartAsync()
+	if config.AppConfig.GetBool("app.hot_reload") {
+		config.OnConfigChange(config.AppConfig, func(v *viper.Viper) {
+			app.Logger().SetLevel(v.GetString("app.loglevel"))
+		})
+		if err := config.WatchConfig(config.AppConfig, "app"); err != nil {
+			logger.Logger.Warnf("Watch app configuration failed: %v", err)
+		}
+	}
+	return app
+}
+
+// newRegistry registers routes to app and loads the modules.
+func newRegistry(app *iris.Application, mods ...*module.Module) *module.Registry {
+	r := module.NewRegistry(newServer(app))
+	r.Register(mods...)
+	return r
+}
+
+// newServer registers routes to app and returns the server used by modules.
+func newServer(app *iris.Application) *module.Server {
 	router.Register(app)
-	server := module.Server{
+	return &module.Server{
 		Validator: util.Validator,
 		Logger:    app.Logger(),
 		Scheduler: service.Scheduler,
 		EventBus:  service.Bus,
 		Database:  database.DB,
+		Replica:   database.Replica,
 	}
-	registry := module.NewRegistry(&server)
-	registry.Register(
-		&role.Module,
-		&user.Module,
-		&imagehost.Module,
-		&announce.Module,
-		&order.Module,
-		&wxnotify.Module,
-		&wordcloud.Module,
-		&sysinfo.Module,
-	)
-	service.Scheduler.StartAsync()
-	return app
+}
+
+func init() {
+	config.AddConfigValidator(config.AppConfig, validateAppConfig)
+}
+
+func validateAppConfig(v *viper.Viper) error {
+	level := v.GetString("app.loglevel")
+	if golog.ParseLevel(level) == golog.DisableLevel && strings.ToLower(level) != "disable" {
+		return fmt.Errorf("invalid app.loglevel: %s", level)
+	}
+	if mode := strings.ToLower(v.GetString("app.mode")); mode != "development" && mode != "production" {
+		return fmt.Errorf("invalid app.mode: %s", v.GetString("app.mode"))
+	}
+	return nil
+}
+
+// checkTokenKey refuses to start in production mode with the default token key,
+// as anyone knowing it can sign tokens of any user.
+func checkTokenKey() {
+	if config.AppConfig.GetString("token.key") != config.DefaultTokenKey {
+		return
+	}
+	hint := fmt.Sprintf("set token.key in app configuration or %s", config.EnvName("app", "token.key"))
+	if config.IsProduction() {
+		logger.Logger.Fatalf("Default token key is in use, refuse to start in production mode: %s", hint)
+	}
+	logger.Logger.Warnf("Default token key is in use, it is insecure: %s", hint)
+}
+
+// shutdown waits for in-flight requests, then stops modules in reverse
+// load order and releases the resources they share.
+func shutdown(app *iris.Application) {
+	logger.Logger.Info("Shutting down...")
+	timeout := config.AppConfig.GetDuration("app.shutdown_timeout")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != nil {
+		logger.Logger.Warnf("Server shutdown: %v", err)
+	}
+
+	config.StopWatchConfig()
+	// stop scheduled jobs first, so that no event is emitted by them later
+	service.Scheduler.Stop()
+	// events being emitted are delivered and handled by listeners of modules
+	// before the modules stop, then the bus is closed
+	registry.Shutdown()
+	service.Bus.Off("*")
+
+	if err := database.Close(); err != nil {
+		logger.Logger.Warnf("Close database failed: %v", err)
+	}
+	if err := cache.Close(); err != nil {
+		logger.Logger.Warnf("Close redis failed: %v", err)
+	}
+	logger.Logger.Info("Server stopped")
 }
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	"image/png"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,11 +17,13 @@ import (
 
 	"github.com/iris-contrib/httpexpect/v2"
 	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/rbac"
 	"github.com/xaxys/maintainman/core/util"
 	"github.com/xaxys/maintainman/modules/announce"
 	"github.com/xaxys/maintainman/modules/order"
 	"github.com/xaxys/maintainman/modules/user"
 
+	"github.com/google/uuid"
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/httptest"
 	"github.com/spf13/cast"
@@ -835,6 +841,61 @@ func TestCompleteOrderRouter(t *testing.T) {
 	t.Log(responseBody)
 }
 
+func TestStaleOrderRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+	superAdminToken := getSuperAdminToken()
+	tags := ensureTestTags(e)
+	for _, tag := range tags {
+		e.POST("/v1/tag").
+			WithHeader("Authorization", "Bearer "+superAdminToken).
+			WithJSON(tag).
+			Expect().Status(httptest.StatusCreated)
+	}
+	randomNumToString := cast.ToString(rand.Intn(10000))
+
+	testOrder := initOrder("TestStaleOrder "+randomNumToString, "Test", "Earth", "Admin", 5)
+	response := e.POST("/v1/order").WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithJSON(testOrder).Expect().Status(httptest.StatusCreated)
+	id := uint(response.JSON().NotNull().Object().Value("data").Object().Value("id").NotNull().Raw().(float64))
+
+	response = e.GET("/v1/order/"+cast.ToString(id)).
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK)
+	etag := response.Header("ETag").NotEmpty().Raw()
+	version := response.JSON().Object().Value("data").Object().Value("version").Number().Raw()
+	if etag != fmt.Sprintf(`"%v"`, version) {
+		t.Errorf("Expect ETag of version %v, but got %s", version, etag)
+	}
+
+	e.POST("/v1/order/"+cast.ToString(id)+"/selfassign").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithHeader("If-Match", etag).
+		Expect().Status(httptest.StatusNoContent)
+
+	// the order has been assigned since etag, which is stale before the status is checked
+	response = e.POST("/v1/order/"+cast.ToString(id)+"/selfassign").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithHeader("If-Match", etag).
+		Expect().Status(httptest.StatusConflict)
+	t.Log(response.Body().Raw())
+	response.JSON().Object().Value("data").Object().Value("version").Number().Gt(version)
+
+	e.POST("/v1/order/"+cast.ToString(id)+"/release").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithHeader("If-Match", etag).
+		Expect().Status(httptest.StatusConflict)
+
+	etag = e.GET("/v1/order/"+cast.ToString(id)+"/force").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		Header("ETag").NotEmpty().Raw()
+	e.POST("/v1/order/"+cast.ToString(id)+"/release").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithHeader("If-Match", etag).
+		Expect().Status(httptest.StatusNoContent)
+}
+
 func TestCancelOrderRouter(t *testing.T) {
 	// app := newApp()
 	e := httptest.New(t, app)
@@ -1059,6 +1120,43 @@ func TestGetRoleRouter(t *testing.T) {
 	t.Log(responseBody)
 }
 
+func TestUpdateRoleRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+	superAdminToken := getSuperAdminToken()
+	responseBody := e.PUT("/v1/role/banned").
+		WithJSON(rbac.UpdateRoleRequest{DisplayName: "封停用户"}).
+		Expect().Status(httptest.StatusForbidden).
+		Body().Raw()
+	t.Log(responseBody)
+
+	responseBody = e.PUT("/v1/role/banned").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithJSON(rbac.UpdateRoleRequest{DisplayName: "Banned User"}).
+		Expect().Status(httptest.StatusNoContent).
+		Body().Raw()
+	t.Log(responseBody)
+
+	e.GET("/v1/role/banned").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("display_name").String().IsEqual("Banned User")
+
+	responseBody = e.PUT("/v1/role/banned").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithJSON(rbac.UpdateRoleRequest{DisplayName: "封停用户"}).
+		Expect().Status(httptest.StatusNoContent).
+		Body().Raw()
+	t.Log(responseBody)
+
+	responseBody = e.PUT("/v1/role/missing_role").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithJSON(rbac.UpdateRoleRequest{DisplayName: "Missing Role"}).
+		Expect().Status(httptest.StatusNotFound).
+		Body().Raw()
+	t.Log(responseBody)
+}
+
 // TODO: Allow Create Role Router
 //func TestCreateRoleRouter(t *testing.T) {
 //	app := newApp()
@@ -1361,6 +1459,11 @@ func TestCreateCommentRouter(t *testing.T) {
 	t.Log(response.Body().Raw())
 	orderCreated := response.JSON().NotNull().Object().Value("data")
 	orderID := uint(orderCreated.Object().Value("id").NotNull().Raw().(float64))
+	// the order is cached, and deleted from the cache on comments
+	e.GET("/v1/order/"+cast.ToString(orderID)).
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().NotContainsKey("comments")
 
 	responseBody := e.POST("/v1/order/" + cast.ToString(orderID) + "/comment").
 		WithJSON(order.CreateCommentRequest{
@@ -1368,12 +1471,26 @@ func TestCreateCommentRouter(t *testing.T) {
 		}).Expect().Status(httptest.StatusForbidden).Body().Raw()
 	t.Log(responseBody)
 
-	responseBody = e.POST("/v1/order/"+cast.ToString(orderID)+"/comment").
+	response = e.POST("/v1/order/"+cast.ToString(orderID)+"/comment").
 		WithHeader("Authorization", "Bearer "+superAdminToken).
 		WithJSON(order.CreateCommentRequest{
 			Content: "comment " + randomNumToString,
-		}).Expect().Status(httptest.StatusCreated).Body().Raw()
-	t.Log(responseBody)
+		}).Expect().Status(httptest.StatusCreated)
+	t.Log(response.Body().Raw())
+	commentID := uint(response.JSON().Object().Value("data").Object().Value("id").NotNull().Raw().(float64))
+
+	e.GET("/v1/order/"+cast.ToString(orderID)).
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("comments").Array().Length().IsEqual(1)
+
+	e.DELETE("/v1/comment/"+cast.ToString(commentID)+"/force").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusNoContent)
+	e.GET("/v1/order/"+cast.ToString(orderID)).
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().NotContainsKey("comments")
 }
 
 func TestGetCommentsByOrderRouter(t *testing.T) {
@@ -1801,10 +1918,19 @@ func TestHitAnnounceRouter(t *testing.T) {
 		WithHeader("Authorization", "Bearer "+superAdminToken).
 		Expect().Status(http.StatusNoContent).Body().Raw()
 
-	responseBody = e.GET("/v1/announce/"+cast.ToString(id)+"/hit").
-		WithHeader("Authorization", "Bearer "+superAdminToken).
-		Expect().Status(http.StatusOK).Body().Raw()
-	t.Log(responseBody)
+	// the hit is cached asynchronously
+	var again *httpexpect.Response
+	for i := 0; i < 100; i++ {
+		again = e.GET("/v1/announce/"+cast.ToString(id)+"/hit").
+			WithHeader("Authorization", "Bearer "+superAdminToken).
+			Expect()
+		if again.Raw().StatusCode == http.StatusOK {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	again.Status(http.StatusOK)
+	t.Log(again.Body().Raw())
 }
 
 func TestMultiHitAnnounceRouter(t *testing.T) {
@@ -1883,6 +2009,194 @@ func TestMultiHitAnnounceRouter(t *testing.T) {
 	t.Log("all users hit all announces again")
 }
 
+// Test Module Router
+func TestModuleRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+	superAdminToken := getSuperAdminToken()
+
+	e.GET("/v1/module/all").
+		Expect().Status(httptest.StatusForbidden)
+
+	modules := e.GET("/v1/module/all").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Array()
+	modules.NotEmpty()
+
+	e.GET("/v1/module/order").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("routes").Array().NotEmpty()
+
+	e.GET("/v1/module/not_exist").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusNotFound)
+
+	// order depends on user
+	e.POST("/v1/module/user/disable").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusConflict)
+
+	e.POST("/v1/module/sysinfo/disable").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("status").IsEqual("disabled")
+	e.GET("/v1/sysinfo").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusServiceUnavailable)
+
+	e.POST("/v1/module/sysinfo/enable").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("status").IsEqual("loaded")
+	e.GET("/v1/sysinfo").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK)
+}
+
+// Test Cache Router
+func TestCacheRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+	superAdminToken := getSuperAdminToken()
+
+	e.GET("/v1/cache/all").
+		Expect().Status(httptest.StatusForbidden)
+
+	caches := e.GET("/v1/cache/all").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Array()
+	caches.NotEmpty()
+
+	// load the user into the cache
+	e.GET("/v1/user").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK)
+	e.GET("/v1/cache/user").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("driver").IsEqual("local")
+
+	e.GET("/v1/cache/not_exist").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusNotFound)
+
+	e.DELETE("/v1/cache/user").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusNoContent)
+	e.GET("/v1/cache/user").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object().Value("cost").IsEqual(0)
+	e.GET("/v1/user").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK)
+}
+
+func TestSignedURLRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+	superAdminToken := getSuperAdminToken()
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	id := e.POST("/v1/image").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithMultipart().WithFileBytes("image", "test.png", buf.Bytes()).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").String().Raw()
+
+	e.GET("/v1/image/"+id+"/url").WithQuery("param", "origin").
+		Expect().Status(httptest.StatusForbidden)
+	e.GET("/v1/image/"+id+"/url").WithQuery("param", "origin").WithQuery("expire", "1000h").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusBadRequest)
+
+	signed := e.GET("/v1/image/"+id+"/url").WithQuery("param", "origin").WithQuery("expire", "10m").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").String().Raw()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+
+	// no token is required
+	body := e.GET(u.Path).WithQueryString(u.RawQuery).
+		Expect().Status(httptest.StatusOK).
+		Body().Raw()
+	if body != buf.String() {
+		t.Errorf("Expect original image downloaded, but got %d bytes", len(body))
+	}
+
+	query.Set("id", uuid.NewString())
+	e.GET(u.Path).WithQueryString(query.Encode()).
+		Expect().Status(httptest.StatusForbidden)
+	query = u.Query()
+	query.Set("expires", cast.ToString(time.Now().Add(time.Hour).Unix()))
+	e.GET(u.Path).WithQueryString(query.Encode()).
+		Expect().Status(httptest.StatusForbidden)
+
+	// transformed images are transformed on demand, even if evicted from cache
+	signed = e.GET("/v1/image/"+id+"/url").WithQuery("param", "w_8,h_8").WithQuery("expire", "10m").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").String().Raw()
+	u, err = url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		body = e.GET(u.Path).WithQueryString(u.RawQuery).
+			Expect().Status(httptest.StatusOK).
+			Body().Raw()
+		if cfg, _, err := image.DecodeConfig(strings.NewReader(body)); err != nil || cfg.Width != 8 || cfg.Height != 8 {
+			t.Errorf("Expect 8 x 8 image downloaded, but got %+v, %v", cfg, err)
+		}
+		e.DELETE("/v1/cache/image").
+			WithHeader("Authorization", "Bearer "+superAdminToken).
+			Expect().Status(httptest.StatusNoContent)
+	}
+	query = u.Query()
+	query.Set("param", "w_16,h_16")
+	e.GET(u.Path).WithQueryString(query.Encode()).
+		Expect().Status(httptest.StatusForbidden)
+}
+
+func TestHealthRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+
+	health := e.GET("/healthz").
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object()
+	health.Value("status").String().IsEqual("up")
+	health.Value("components").Array().IsEmpty()
+
+	ready := e.GET("/readyz").
+		Expect().Status(httptest.StatusOK).
+		JSON().Object().Value("data").Object()
+	ready.Value("status").String().NotEqual("down")
+	components := ready.Value("components").Array()
+	for _, name := range []string{"database", "cache", "storage", "module.wxnotify"} {
+		found := false
+		for _, c := range components.Iter() {
+			if c.Object().Value("name").String().Raw() == name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expect component %s in readiness report", name)
+		}
+	}
+}
+
 // Test Utils
 func getSuperAdminToken() string {
 	token, _ := util.GetJwtString(1, "fake super admin", "super_admin")
@@ -2113,3 +2427,53 @@ func getMyIPV6() string {
 	}
 	return ""
 }
+
+func TestAuditRouter(t *testing.T) {
+	// app := newApp()
+	e := httptest.New(t, app)
+	superAdminToken := getSuperAdminToken()
+	title := "Audit " + cast.ToString(rand.Intn(10000))
+	start := time.Now().Unix()
+
+	id := e.POST("/v1/announce").
+		WithHeader("Authorization", "Bearer "+superAdminToken).
+		WithJSON(announce.CreateAnnounceRequest{Title: title, Content: "v1", StartTime: start, EndTime: start + 10000}).
+		Expect().Status(http.StatusCreated).
+		JSON().Object().Value("data").Object().Value("id").Number().Raw()
+	for _, content := range []string{"v2", "v3"} {
+		e.PUT("/v1/announce/"+cast.ToString(id)).
+			WithHeader("Authorization", "Bearer "+superAdminToken).
+			WithJSON(announce.UpdateAnnounceRequest{Title: title, Content: content, StartTime: start, EndTime: start + 10000}).
+			Expect().Status(httptest.StatusNoContent)
+	}
+
+	e.GET("/v1/audit").
+		Expect().Status(httptest.StatusForbidden)
+
+	// logs are saved in the background by the audit module
+	var response *httpexpect.Response
+	for i := 0; i < 100; i++ {
+		response = e.GET("/v1/audit").
+			WithHeader("Authorization", "Bearer "+superAdminToken).
+			WithQuery("resource", "announce").
+			WithQuery("resource_id", id).
+			Expect().Status(httptest.StatusOK)
+		if response.JSON().Object().Value("data").Object().Value("total").Number().Raw() == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Log(response.Body().Raw())
+
+	// the latest first, each update with only the changed fields
+	entries := response.JSON().Object().Value("data").Object().Value("entries").Array()
+	entries.Length().IsEqual(3)
+	entries.Value(0).Object().Value("action").IsEqual("update")
+	entries.Value(0).Object().Value("user_name").IsEqual("fake super admin")
+	entries.Value(0).Object().Value("changes").Object().Value("content").IsEqual(map[string]any{"before": "v2", "after": "v3"})
+	entries.Value(0).Object().Value("changes").Object().NotContainsKey("title")
+	entries.Value(1).Object().Value("action").IsEqual("update")
+	entries.Value(1).Object().Value("changes").Object().Value("content").IsEqual(map[string]any{"before": "v1", "after": "v2"})
+	entries.Value(2).Object().Value("action").IsEqual("create")
+	entries.Value(2).Object().Value("changes").Object().Value("title").IsEqual(map[string]any{"before": nil, "after": title})
+}
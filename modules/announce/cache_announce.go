@@ -0,0 +1,55 @@
+package announce
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/cache"
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/util"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+var (
+	announceLoader *cache.Loader[Announce]
+	// hitLoader records hits of users, which are counted once in hit_expire.
+	hitLoader util.AtomPtr[cache.Loader[bool]]
+)
+
+func initAnnounceCache() {
+	announceLoader = cache.NewLoader[Announce](
+		mctx.Cache,
+		announceConfig.GetDuration("cache.ttl"),
+		announceConfig.GetDuration("cache.jitter"),
+		announceConfig.GetDuration("cache.negative_ttl"),
+		gorm.ErrRecordNotFound,
+	)
+	hitLoader.Set(newHitLoader(announceConfig.Viper()))
+	config.OnConfigChange(announceConfig, func(v *viper.Viper) {
+		hitLoader.Set(newHitLoader(v))
+	})
+}
+
+func newHitLoader(v *viper.Viper) *cache.Loader[bool] {
+	return cache.NewLoader[bool](mctx.Cache, v.GetDuration("hit_expire"), 0, 0, nil)
+}
+
+func cacheGetAnnounceByID(ctx context.Context, id uint, load func(ctx context.Context) (Announce, error)) (*Announce, error) {
+	announce, err := announceLoader.Get(ctx, fmt.Sprintf("announce:%d", id), load)
+	if err != nil {
+		return nil, err
+	}
+	return &announce, nil
+}
+
+func cacheDeleteAnnounce(id uint) {
+	announceLoader.Del(fmt.Sprintf("announce:%d", id))
+}
+
+// cacheHitAnnounce calls hit unless the user has hit the announce in hit_expire.
+func cacheHitAnnounce(ctx context.Context, id, uid uint, hit func(ctx context.Context) (bool, error)) error {
+	_, err := hitLoader.Get().Get(ctx, fmt.Sprintf("%d:%d", id, uid), hit)
+	return err
+}
@@ -1,12 +1,34 @@
 package announce
 
-import "github.com/spf13/viper"
+import (
+	"time"
 
-var announceConfig = viper.New()
+	"github.com/xaxys/maintainman/core/config"
+)
+
+var announceConfig = config.New()
 
 func init() {
 	announceConfig.SetDefault("hit_expire", "12h")
 
 	announceConfig.SetDefault("cache.driver", "local")
 	announceConfig.SetDefault("cache.limit", 268435456) // 256MB
+	announceConfig.SetDefault("cache.codec", "json")
+	announceConfig.SetDefault("cache.sync", false)
+	announceConfig.SetDefault("cache.local_ttl", "1m")
+	announceConfig.SetDefault("cache.ttl", "10m")
+	announceConfig.SetDefault("cache.jitter", "1m")
+	announceConfig.SetDefault("cache.negative_ttl", "1m")
+
+	config.SetConfigSchema(announceConfig, config.Schema{
+		"hit_expire":         config.DurationMin(time.Second),
+		"cache.driver":       config.OneOf("local", "redis", "tiered"),
+		"cache.limit":        config.IntMin(0),
+		"cache.codec":        config.OneOf("json", "gob", "msgpack"),
+		"cache.sync":         config.Bool(),
+		"cache.local_ttl":    config.DurationMin(0),
+		"cache.ttl":          config.DurationMin(0),
+		"cache.jitter":       config.DurationMin(0),
+		"cache.negative_ttl": config.DurationMin(0),
+	})
 }
@@ -25,7 +25,7 @@ import (
 func getAnnounce(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAnnounceService(id, auth)
+	response := getAnnounceService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -56,7 +56,7 @@ func getAllAnnounces(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAllAnnouncesService(aul, auth)
+	response := getAllAnnouncesService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -78,7 +78,7 @@ func getAllAnnounces(ctx iris.Context) {
 func getLatestAnnounces(ctx iris.Context) {
 	param := controller.ExtractPageParam(ctx)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getLatestAnnouncesService(param, auth)
+	response := getLatestAnnouncesService(ctx.Request().Context(), param, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -104,7 +104,7 @@ func createAnnounce(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createAnnounceService(aul, auth)
+	response := createAnnounceService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -132,7 +132,7 @@ func updateAnnounce(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := updateAnnounceService(id, aul, auth)
+	response := updateAnnounceService(ctx.Request().Context(), id, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -154,7 +154,7 @@ func updateAnnounce(ctx iris.Context) {
 func deleteAnnounce(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := deleteAnnounceService(id, auth)
+	response := deleteAnnounceService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -175,6 +175,72 @@ func deleteAnnounce(ctx iris.Context) {
 func hitAnnounce(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := hitAnnounceService(id, auth)
+	response := hitAnnounceService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// getTrashAnnounces godoc
+// @Summary      获取已删除的公告
+// @Description  获取已删除的公告 分页 默认按删除时间倒序
+// @Tags         announce
+// @Produce      json
+// @Param        order_by  query     string  false  "排序字段"
+// @Param        offset    query     uint    false  "偏移量"
+// @Param        limit     query     uint    false  "每页数据量"
+// @Success      200       {object}  model.ApiJson{data=model.Page{entries=[]AnnounceJson}}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
+// @Router       /v1/announce/trash [get]
+func getTrashAnnounces(ctx iris.Context) {
+	param := controller.ExtractPageParam(ctx)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getTrashAnnouncesService(ctx.Request().Context(), param, auth)
+	ctx.Values().Set("response", response)
+}
+
+// restoreAnnounce godoc
+// @Summary      恢复已删除的公告
+// @Description  通过ID恢复已删除的公告 标题与现有公告重复时失败
+// @Tags         announce
+// @Produce      json
+// @Param        id   path      uint  true  "公告ID"
+// @Success      204  {object}  model.ApiJson{data=AnnounceJson}
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      409  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/announce/trash/{id}/restore [post]
+func restoreAnnounce(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := restoreAnnounceService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// purgeAnnounce godoc
+// @Summary      彻底删除公告
+// @Description  通过ID彻底删除已删除的公告 不可恢复
+// @Tags         announce
+// @Produce      json
+// @Param        id   path      uint  true  "公告ID"
+// @Success      204  {object}  model.ApiJson
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/announce/trash/{id} [delete]
+func purgeAnnounce(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := purgeAnnounceService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
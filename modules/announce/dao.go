@@ -1,15 +1,18 @@
 package announce
 
 import (
+	"context"
 	"time"
 
 	"github.com/xaxys/maintainman/core/dao"
+	"github.com/xaxys/maintainman/core/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func dbGetAnnounceCount() (count uint, err error) {
-	return txGetAnnounceCount(mctx.Database)
+func dbGetAnnounceCount(ctx context.Context) (count uint, err error) {
+	return txGetAnnounceCount(mctx.Database.WithContext(ctx))
 }
 
 func txGetAnnounceCount(tx *gorm.DB) (uint, error) {
@@ -21,8 +24,20 @@ func txGetAnnounceCount(tx *gorm.DB) (uint, error) {
 	return uint(count), nil
 }
 
-func dbGetAnnounceByID(id uint) (announce *Announce, err error) {
-	return txGetAnnounceByID(mctx.Database, id)
+func dbGetAnnounceByID(ctx context.Context, id uint) (announce *Announce, err error) {
+	return txGetAnnounceByID(mctx.Database.WithContext(ctx), id)
+}
+
+// dbGetCachedAnnounceByID returns the announce from the cache, in which hits
+// may be outdated.
+func dbGetCachedAnnounceByID(ctx context.Context, id uint) (*Announce, error) {
+	return cacheGetAnnounceByID(ctx, id, func(ctx context.Context) (Announce, error) {
+		announce, err := txGetAnnounceByID(mctx.Database.WithContext(ctx), id)
+		if err != nil {
+			return Announce{}, err
+		}
+		return *announce, nil
+	})
 }
 
 func txGetAnnounceByID(tx *gorm.DB, id uint) (*Announce, error) {
@@ -34,8 +49,8 @@ func txGetAnnounceByID(tx *gorm.DB, id uint) (*Announce, error) {
 	return announce, nil
 }
 
-func dbGetAnnounceByTitle(title string) (announce *Announce, err error) {
-	return txGetAnnounceByTitle(mctx.Database, title)
+func dbGetAnnounceByTitle(ctx context.Context, title string) (announce *Announce, err error) {
+	return txGetAnnounceByTitle(mctx.Database.WithContext(ctx), title)
 }
 
 func txGetAnnounceByTitle(tx *gorm.DB, title string) (*Announce, error) {
@@ -47,8 +62,8 @@ func txGetAnnounceByTitle(tx *gorm.DB, title string) (*Announce, error) {
 	return announce, nil
 }
 
-func dbGetAllAnnouncesWithParam(aul *AllAnnounceRequest) (announces []*Announce, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetAllAnnouncesWithParam(ctx context.Context, aul *AllAnnounceRequest) (announces []*Announce, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if announces, count, err = txGetAllAnnouncesWithParam(tx, aul); err != nil {
 			mctx.Logger.Warnf("GetAllAnnouncesWithParamErr: %v\n", err)
 		}
@@ -60,7 +75,7 @@ func dbGetAllAnnouncesWithParam(aul *AllAnnounceRequest) (announces []*Announce,
 func txGetAllAnnouncesWithParam(tx *gorm.DB, aul *AllAnnounceRequest) (announces []*Announce, count uint, err error) {
 	tx = dao.TxFilter(tx, aul.OrderBy, aul.Offset, aul.Limit)
 	if aul.Title != "" {
-		tx = tx.Where("title like ?", aul.Title)
+		tx = dao.TxLike(tx, "title", aul.Title)
 	}
 
 	if aul.StartTime != -1 {
@@ -92,8 +107,13 @@ func txGetAllAnnouncesWithParam(tx *gorm.DB, aul *AllAnnounceRequest) (announces
 	return
 }
 
-func dbCreateAnnounce(json *ModifyAnnounceRequest, operator uint) (*Announce, error) {
-	return txCreateAnnounce(mctx.Database, json, operator)
+func dbCreateAnnounce(ctx context.Context, json *ModifyAnnounceRequest, operator uint) (*Announce, error) {
+	announce, err := txCreateAnnounce(mctx.Database.WithContext(ctx), json, operator)
+	if err != nil {
+		return nil, err
+	}
+	cacheDeleteAnnounce(announce.ID)
+	return announce, nil
 }
 
 func txCreateAnnounce(tx *gorm.DB, json *ModifyAnnounceRequest, operator uint) (*Announce, error) {
@@ -115,8 +135,13 @@ func txCreateAnnounce(tx *gorm.DB, json *ModifyAnnounceRequest, operator uint) (
 	return announce, nil
 }
 
-func dbUpdateAnnounce(id uint, json *ModifyAnnounceRequest, operator uint) (*Announce, error) {
-	return txUpdateAnnounce(mctx.Database, id, json, operator)
+func dbUpdateAnnounce(ctx context.Context, id uint, json *ModifyAnnounceRequest, operator uint) (*Announce, error) {
+	announce, err := txUpdateAnnounce(mctx.Database.WithContext(ctx), id, json, operator)
+	if err != nil {
+		return nil, err
+	}
+	cacheDeleteAnnounce(id)
+	return announce, nil
 }
 
 func txUpdateAnnounce(tx *gorm.DB, id uint, json *ModifyAnnounceRequest, operator uint) (*Announce, error) {
@@ -131,8 +156,12 @@ func txUpdateAnnounce(tx *gorm.DB, id uint, json *ModifyAnnounceRequest, operato
 	return announce, nil
 }
 
-func dbDeleteAnnounce(id uint) error {
-	return txDeleteAnnounce(mctx.Database, id)
+func dbDeleteAnnounce(ctx context.Context, id uint) error {
+	if err := txDeleteAnnounce(mctx.Database.WithContext(ctx), id); err != nil {
+		return err
+	}
+	cacheDeleteAnnounce(id)
+	return nil
 }
 
 func txDeleteAnnounce(tx *gorm.DB, id uint) error {
@@ -143,14 +172,14 @@ func txDeleteAnnounce(tx *gorm.DB, id uint) error {
 	return nil
 }
 
-func dbHitAnnounce(id uint) error {
-	return txHitAnnounce(mctx.Database, id)
+func dbHitAnnounce(ctx context.Context, id uint) error {
+	return txHitAnnounce(mctx.Database.WithContext(ctx), id)
 }
 
 func txHitAnnounce(tx *gorm.DB, id uint) error {
 	announce := &Announce{}
 	announce.ID = id
-	if err := tx.Model(announce).Update("hits", gorm.Expr("hits + ?", 1)).Error; err != nil {
+	if err := tx.Model(announce).Update("hits", gorm.Expr("? + ?", clause.Column{Name: "hits"}, 1)).Error; err != nil {
 		mctx.Logger.Warnf("HitAnnounceErr: %v\n", err)
 		return err
 	}
@@ -172,3 +201,42 @@ func jsonToAnnounce(json *ModifyAnnounceRequest) (ret *Announce) {
 	}
 	return
 }
+
+func dbGetTrashAnnounces(ctx context.Context, param *model.PageParam) (announces []*Announce, count uint, err error) {
+	if announces, count, err = dao.TxGetTrash[Announce](mctx.ReadDatabase().WithContext(ctx), param); err != nil {
+		mctx.Logger.Warnf("GetTrashAnnouncesErr: %v\n", err)
+	}
+	return
+}
+
+func dbRestoreAnnounce(ctx context.Context, id uint) (announce *Announce, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if announce, err = dao.TxRestore[Announce](tx, id); err != nil {
+			mctx.Logger.Warnf("RestoreAnnounceErr: %v\n", err)
+		}
+		return err
+	})
+	if err == nil {
+		cacheDeleteAnnounce(id)
+	}
+	return
+}
+
+func dbPurgeAnnounce(ctx context.Context, id uint) error {
+	if err := dao.TxPurge[Announce](mctx.Database.WithContext(ctx), id); err != nil {
+		mctx.Logger.Warnf("PurgeAnnounceErr: %v\n", err)
+		return err
+	}
+	cacheDeleteAnnounce(id)
+	return nil
+}
+
+func dbPurgeTrashAnnounces(ctx context.Context, before time.Time) {
+	n, err := dao.TxPurgeBefore[Announce](mctx.Database.WithContext(ctx), before)
+	if err != nil {
+		mctx.Logger.Warnf("PurgeTrashAnnouncesErr: %v\n", err)
+	}
+	if n > 0 {
+		mctx.Logger.Infof("Purged %d deleted announces", n)
+	}
+}
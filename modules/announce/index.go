@@ -9,7 +9,7 @@ import (
 
 var Module = module.Module{
 	ModuleName:    "announce",
-	ModuleVersion: "1.0.0",
+	ModuleVersion: "1.0.3",
 	ModuleConfig:  announceConfig,
 	ModuleDepends: []string{},
 	ModuleEnv: map[string]any{
@@ -17,7 +17,6 @@ var Module = module.Module{
 			&Announce{},
 		},
 	},
-	ModuleExport: map[string]any{},
 	ModulePerm: map[string]string{
 		"announce.view":    "查看公告",
 		"announce.hit":     "点击公告",
@@ -25,6 +24,9 @@ var Module = module.Module{
 		"announce.update":  "更新公告",
 		"announce.delete":  "删除公告",
 		"announce.viewall": "查看所有公告",
+		"announce.trash":   "查看已删除的公告",
+		"announce.restore": "恢复已删除的公告",
+		"announce.purge":   "彻底删除公告",
 	},
 	EntryPoint: entry,
 }
@@ -33,6 +35,8 @@ var mctx *module.ModuleContext
 
 func entry(ctx *module.ModuleContext) {
 	mctx = ctx
+	initAnnounceCache()
+	mctx.ScheduleTrashPurge(dbPurgeTrashAnnounces)
 	ctx.Route.PartyFunc("/announce", func(announce iris.Party) {
 		announce.Get("/", rbac.PermInterceptor("announce.view"), getLatestAnnounces)
 		announce.Get("/all", rbac.PermInterceptor("announce.viewall"), getAllAnnounces)
@@ -41,5 +45,8 @@ func entry(ctx *module.ModuleContext) {
 		announce.Put("/{id:uint}", rbac.PermInterceptor("announce.update"), updateAnnounce)
 		announce.Delete("/{id:uint}", rbac.PermInterceptor("announce.delete"), deleteAnnounce)
 		announce.Get("/{id:uint}/hit", rbac.PermInterceptor("announce.hit"), hitAnnounce)
+		announce.Get("/trash", rbac.PermInterceptor("announce.trash"), getTrashAnnounces)
+		announce.Post("/trash/{id:uint}/restore", rbac.PermInterceptor("announce.restore"), restoreAnnounce)
+		announce.Delete("/trash/{id:uint}", rbac.PermInterceptor("announce.purge"), purgeAnnounce)
 	})
 }
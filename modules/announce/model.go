@@ -48,9 +48,10 @@ type AnnounceJson struct {
 	ID        uint   `json:"id"`
 	Title     string `json:"title"`
 	Content   string `json:"content"`
-	StartTime int64  `json:"start_time"` // unix timestamp in seconds (UTC)
-	EndTime   int64  `json:"end_time"`   // unix timestamp in seconds (UTC)
-	Hits      uint   `json:"hits"`       // 点击数
-	CreatedAt int64  `json:"created_at"` // unix timestamp in seconds (UTC)
-	UpdatedAt int64  `json:"updated_at"` // unix timestamp in seconds (UTC)
+	StartTime int64  `json:"start_time"`           // unix timestamp in seconds (UTC)
+	EndTime   int64  `json:"end_time"`             // unix timestamp in seconds (UTC)
+	Hits      uint   `json:"hits"`                 // 点击数
+	CreatedAt int64  `json:"created_at"`           // unix timestamp in seconds (UTC)
+	UpdatedAt int64  `json:"updated_at"`           // unix timestamp in seconds (UTC)
+	DeletedAt int64  `json:"deleted_at,omitempty"` // unix timestamp in seconds (UTC), 仅回收站中的记录有
 }
@@ -1,18 +1,22 @@
 package announce
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"time"
 
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
 	"gorm.io/gorm"
 )
 
-func getAnnounceService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	announce, err := dbGetAnnounceByID(id)
+var errNotInPeriod = errors.New("不在公告期间")
+
+func getAnnounceService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	announce, err := dbGetAnnounceByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -22,8 +26,8 @@ func getAnnounceService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(announceToJson(announce), "获取成功")
 }
 
-func getAnounceByTitleService(title string, auth *model.AuthInfo) *model.ApiJson {
-	announce, err := dbGetAnnounceByTitle(title)
+func getAnounceByTitleService(ctx context.Context, title string, auth *model.AuthInfo) *model.ApiJson {
+	announce, err := dbGetAnnounceByTitle(ctx, title)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -33,11 +37,11 @@ func getAnounceByTitleService(title string, auth *model.AuthInfo) *model.ApiJson
 	return model.Success(announceToJson(announce), "获取成功")
 }
 
-func getAllAnnouncesService(aul *AllAnnounceRequest, auth *model.AuthInfo) *model.ApiJson {
+func getAllAnnouncesService(ctx context.Context, aul *AllAnnounceRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	announces, count, err := dbGetAllAnnouncesWithParam(aul)
+	announces, count, err := dbGetAllAnnouncesWithParam(ctx, aul)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -48,7 +52,7 @@ func getAllAnnouncesService(aul *AllAnnounceRequest, auth *model.AuthInfo) *mode
 	return model.SuccessPaged(as, count, "获取成功")
 }
 
-func getLatestAnnouncesService(param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+func getLatestAnnouncesService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
 	now := time.Now().Unix()
 	aul := &AllAnnounceRequest{
 		StartTime: now,
@@ -60,72 +64,126 @@ func getLatestAnnouncesService(param *model.PageParam, auth *model.AuthInfo) *mo
 			Limit:   param.Limit,
 		},
 	}
-	return getAllAnnouncesService(aul, auth)
+	return getAllAnnouncesService(ctx, aul, auth)
 }
 
-func createAnnounceService(aul *CreateAnnounceRequest, auth *model.AuthInfo) *model.ApiJson {
+func createAnnounceService(ctx context.Context, aul *CreateAnnounceRequest, auth *model.AuthInfo) *model.ApiJson {
 	// TODO: Localize error info: https://blog.xizhibei.me/2019/06/16/an-introduction-to-golang-validator/
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
 	req := ModifyAnnounceRequest(*aul)
-	announce, err := dbCreateAnnounce(&req, auth.User)
+	announce, err := dbCreateAnnounce(ctx, &req, auth.User)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "announce", announce.ID, audit.ActionCreate, nil, announceToJson(announce))
 	return model.SuccessCreate(announceToJson(announce), "创建成功")
 }
 
-func updateAnnounceService(id uint, aul *UpdateAnnounceRequest, auth *model.AuthInfo) *model.ApiJson {
+func updateAnnounceService(ctx context.Context, id uint, aul *UpdateAnnounceRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
+	before, err := dbGetAnnounceByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorQueryDatabase(err)
+	}
 	req := ModifyAnnounceRequest(*aul)
-	announce, err := dbUpdateAnnounce(id, &req, auth.User)
+	announce, err := dbUpdateAnnounce(ctx, id, &req, auth.User)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorUpdateDatabase(err)
 	}
+	if after, err := dbGetAnnounceByID(ctx, id); err == nil {
+		audit.Record(mctx.EventBus, auth, "announce", id, audit.ActionUpdate, announceToJson(before), announceToJson(after))
+	}
 	return model.SuccessUpdate(announceToJson(announce), "更新成功")
 }
 
-func deleteAnnounceService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	err := dbDeleteAnnounce(id)
+func deleteAnnounceService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	announce, err := dbGetAnnounceByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
+		return model.ErrorQueryDatabase(err)
+	}
+	if err := dbDeleteAnnounce(ctx, id); err != nil {
 		return model.ErrorDeleteDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "announce", id, audit.ActionDelete, announceToJson(announce), nil)
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
-func hitAnnounceService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	key := fmt.Sprintf("%d:%d", id, auth.User)
-	if _, ok := mctx.Cache.Get(key); ok {
+func hitAnnounceService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	hit := false
+	err := cacheHitAnnounce(ctx, id, auth.User, func(ctx context.Context) (bool, error) {
+		announce, err := dbGetCachedAnnounceByID(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if time.Now().Before(*announce.StartTime) || time.Now().After(*announce.EndTime) {
+			return false, errNotInPeriod
+		}
+		if err := dbHitAnnounce(ctx, id); err != nil {
+			return false, err
+		}
+		hit = true
+		return true, nil
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, errNotInPeriod):
+		return model.ErrorNotFound(err)
+	case err != nil:
+		return model.ErrorUpdateDatabase(err)
+	case !hit:
 		return model.Success(nil, "浏览过了")
 	}
-	expire, err := time.ParseDuration(announceConfig.GetString("hit_expire"))
+	return model.SuccessUpdate(nil, "浏览成功")
+}
+
+func getTrashAnnouncesService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+	if err := util.Validator.Struct(param); err != nil {
+		return model.ErrorValidation(err)
+	}
+	announces, count, err := dbGetTrashAnnounces(ctx, param)
 	if err != nil {
-		return model.ErrorInternalServer(err)
+		return model.ErrorQueryDatabase(err)
 	}
-	announce, err := dbGetAnnounceByID(id)
+	as := util.TransSlice(announces, announceToJson)
+	return model.SuccessPaged(as, count, "获取成功")
+}
+
+func restoreAnnounceService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	announce, err := dbRestoreAnnounce(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
-		return model.ErrorQueryDatabase(err)
-	}
-	if time.Now().Before(*announce.StartTime) || time.Now().After(*announce.EndTime) {
-		return model.ErrorNotFound(errors.New("不在公告期间"))
-	}
-	mctx.Cache.Set(key, nil, expire)
-	if err := dbHitAnnounce(id); err != nil {
+		if errors.Is(err, dao.ErrConflict) {
+			return model.ErrorConflict(err)
+		}
 		return model.ErrorUpdateDatabase(err)
 	}
-	return model.SuccessUpdate(nil, "浏览成功")
+	audit.Record(mctx.EventBus, auth, "announce", id, audit.ActionRestore, nil, announceToJson(announce))
+	return model.SuccessUpdate(announceToJson(announce), "恢复成功")
+}
+
+func purgeAnnounceService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	if err := dbPurgeAnnounce(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorDeleteDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "announce", id, audit.ActionPurge, nil, nil)
+	return model.SuccessUpdate(nil, "彻底删除成功")
 }
 
 func announceToJson(announce *Announce) *AnnounceJson {
@@ -141,6 +199,7 @@ func announceToJson(announce *Announce) *AnnounceJson {
 			Hits:      announce.Hits,
 			CreatedAt: announce.CreatedAt.Unix(),
 			UpdatedAt: announce.UpdatedAt.Unix(),
+			DeletedAt: model.DeletedUnix(announce.DeletedAt),
 		}
 	}
 }
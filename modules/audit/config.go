@@ -0,0 +1,19 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
+)
+
+var auditConfig = config.New()
+
+func init() {
+	auditConfig.SetDefault("retention", "2160h") // 90 days
+	auditConfig.SetDefault("purge", "1h")
+
+	config.SetConfigSchema(auditConfig, config.Schema{
+		"retention": config.DurationMin(0),
+		"purge":     config.DurationMin(time.Second),
+	})
+}
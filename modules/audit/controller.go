@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/util"
+
+	"github.com/kataras/iris/v12"
+)
+
+// getAllAudits godoc
+// @Summary      获取审计日志
+// @Description  获取审计日志 分页 默认按ID逆序 可按操作用户 资源 操作 时间筛选
+// @Tags         audit
+// @Produce      json
+// @Param        user_id      query     uint    false  "操作用户ID"
+// @Param        resource     query     string  false  "资源类型"
+// @Param        resource_id  query     string  false  "资源ID"
+// @Param        action       query     string  false  "操作"  Enums(create, update, delete, restore, purge)
+// @Param        start_time   query     int64   false  "开始时间 unix timestamp in seconds (UTC)"
+// @Param        end_time     query     int64   false  "结束时间 unix timestamp in seconds (UTC)"
+// @Param        order_by     query     string  false  "排序字段"
+// @Param        offset       query     uint    false  "偏移量"
+// @Param        limit        query     uint    false  "每页数据量"
+// @Success      200          {object}  model.ApiJson{data=model.Page{entries=[]AuditJson}}
+// @Failure      400          {object}  model.ApiJson{data=[]string}
+// @Failure      401          {object}  model.ApiJson{data=[]string}
+// @Failure      403          {object}  model.ApiJson{data=[]string}
+// @Failure      422          {object}  model.ApiJson{data=[]string}
+// @Failure      500          {object}  model.ApiJson{data=[]string}
+// @Router       /v1/audit [get]
+func getAllAudits(ctx iris.Context) {
+	aul := &AllAuditRequest{}
+	if err := ctx.ReadQuery(aul); err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getAllAuditsService(ctx.Request().Context(), aul, auth)
+	ctx.Values().Set("response", response)
+}
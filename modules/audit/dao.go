@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/dao"
+
+	"gorm.io/gorm"
+)
+
+func dbCreateAudit(ctx context.Context, log *audit.Log) error {
+	if err := mctx.Database.WithContext(ctx).Create(log).Error; err != nil {
+		mctx.Logger.Warnf("CreateAuditErr: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func dbGetAllAudits(ctx context.Context, aul *AllAuditRequest) (logs []*audit.Log, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if logs, count, err = txGetAllAudits(tx, aul); err != nil {
+			mctx.Logger.Warnf("GetAllAuditsErr: %v\n", err)
+		}
+		return err
+	})
+	return
+}
+
+func txGetAllAudits(tx *gorm.DB, aul *AllAuditRequest) (logs []*audit.Log, count uint, err error) {
+	tx = dao.TxPageFilter(tx.Model(&audit.Log{}), &aul.PageParam)
+	if aul.UserID != 0 {
+		tx = tx.Where("user_id = ?", aul.UserID)
+	}
+	if aul.Resource != "" {
+		tx = tx.Where("resource = ?", aul.Resource)
+	}
+	if aul.ResourceID != "" {
+		tx = tx.Where("resource_id = ?", aul.ResourceID)
+	}
+	if aul.Action != "" {
+		tx = tx.Where("action = ?", aul.Action)
+	}
+	if aul.StartTime != 0 {
+		tx = tx.Where("created_at >= ?", time.Unix(aul.StartTime, 0))
+	}
+	if aul.EndTime != 0 {
+		tx = tx.Where("created_at <= ?", time.Unix(aul.EndTime, 0))
+	}
+	if err = tx.Find(&logs).Error; err != nil {
+		return
+	}
+	cnt := int64(0)
+	if err = tx.Offset(-1).Limit(-1).Count(&cnt).Error; err != nil {
+		return
+	}
+	count = uint(cnt)
+	return
+}
+
+func dbPurgeAudits(ctx context.Context, before time.Time) {
+	result := mctx.Database.WithContext(ctx).Where("created_at < ?", before).Delete(&audit.Log{})
+	if result.Error != nil {
+		mctx.Logger.Warnf("PurgeAuditsErr: %v\n", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		mctx.Logger.Infof("Purged %d audit logs", result.RowsAffected)
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/module"
+	"github.com/xaxys/maintainman/core/rbac"
+
+	"github.com/go-co-op/gocron"
+)
+
+var Module = module.Module{
+	ModuleName:    "audit",
+	ModuleVersion: "1.0.0",
+	ModuleConfig:  auditConfig,
+	ModuleDepends: []string{},
+	ModuleEnv: map[string]any{
+		"orm.model": []any{
+			&audit.Log{},
+		},
+	},
+	ModulePerm: map[string]string{
+		"audit.viewall": "查看审计日志",
+	},
+	EntryPoint: entry,
+	StartPoint: start,
+	StopPoint:  stop,
+}
+
+var (
+	mctx     *module.ModuleContext
+	purgeJob *gocron.Job
+)
+
+func entry(ctx *module.ModuleContext) {
+	mctx = ctx
+	mctx.Route.Get("/audit", rbac.PermInterceptor("audit.viewall"), getAllAudits)
+	start(ctx)
+}
+
+func start(ctx *module.ModuleContext) {
+	subscribe()
+
+	job, err := mctx.Scheduler.Every(auditConfig.GetDuration("purge")).SingletonMode().Do(purgeAuditsService)
+	if err != nil {
+		mctx.Logger.Errorf("Schedule audit purge failed: %v", err)
+		return
+	}
+	purgeJob = job
+}
+
+func stop(ctx *module.ModuleContext) {
+	if purgeJob != nil {
+		mctx.Scheduler.RemoveByReference(purgeJob)
+		purgeJob = nil
+	}
+	unsubscribe()
+}
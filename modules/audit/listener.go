@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/xaxys/maintainman/core/audit"
+
+	"github.com/olebedev/emitter"
+)
+
+// logQueue is the number of logs queued for saving. audit.Record blocks
+// only if the queue is full.
+const logQueue = 1024
+
+var (
+	recordEvents <-chan emitter.Event
+	workerDone   chan struct{}
+)
+
+// subscribe queues logs in the order they are recorded, and saves them one
+// by one in worker. The subscription is synchronous only to keep the order,
+// which pushes the log to the queue, so Emit never waits for the database.
+func subscribe() {
+	recordEvents = mctx.EventBus.OnWithCap(audit.Topic, logQueue, emitter.Sync)
+	workerDone = make(chan struct{})
+	go worker(recordEvents, workerDone)
+}
+
+// unsubscribe closes the queue, and waits for the worker to save the logs
+// left in it, so that none is lost when the module stops.
+func unsubscribe() {
+	mctx.EventBus.Off(audit.Topic, recordEvents)
+	<-workerDone
+}
+
+func worker(events <-chan emitter.Event, done chan struct{}) {
+	defer close(done)
+	for event := range events {
+		saveLog(event.Args[0])
+	}
+}
+
+func saveLog(arg any) {
+	defer func() {
+		if err := recover(); err != nil {
+			mctx.Logger.Errorf("Save audit log panic: %s", err)
+		}
+	}()
+	log, ok := arg.(*audit.Log)
+	if !ok {
+		mctx.Logger.Warnf("Unknown audit log: %#v", arg)
+		return
+	}
+	_ = dbCreateAudit(context.Background(), log)
+}
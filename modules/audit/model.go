@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/model"
+)
+
+type AllAuditRequest struct {
+	UserID     uint   `json:"user_id" url:"user_id"`                            // 操作用户ID
+	Resource   string `json:"resource" url:"resource" validate:"lte=50"`        // 资源类型 (e.g. `user` `order` `item` `tag` `announce` `role`)
+	ResourceID string `json:"resource_id" url:"resource_id" validate:"lte=191"` // 资源ID
+	Action     string `json:"action" url:"action" validate:"omitempty,oneof=create update delete restore purge"`
+	StartTime  int64  `json:"start_time" url:"start_time" validate:"gte=0,lte=253370764799"` // unix timestamp in seconds (UTC); 0代表不限; 含本数
+	EndTime    int64  `json:"end_time" url:"end_time" validate:"gte=0,lte=253370764799"`     // unix timestamp in seconds (UTC); 0代表不限; 含本数
+	model.PageParam
+}
+
+type AuditJson struct {
+	ID         uint                     `json:"id"`
+	UserID     uint                     `json:"user_id"` // 操作用户ID 0:系统
+	UserName   string                   `json:"user_name"`
+	IP         string                   `json:"ip"`
+	Resource   string                   `json:"resource"`
+	ResourceID string                   `json:"resource_id"`
+	Action     string                   `json:"action"`
+	Changes    map[string]*audit.Change `json:"changes"`    // 字段名 -> 变化前后的值
+	CreatedAt  int64                    `json:"created_at"` // unix timestamp in seconds (UTC)
+}
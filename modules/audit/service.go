@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/util"
+)
+
+func getAllAuditsService(ctx context.Context, aul *AllAuditRequest, auth *model.AuthInfo) *model.ApiJson {
+	aul.OrderBy = util.NotEmpty(aul.OrderBy, "id desc")
+	if err := util.Validator.Struct(aul); err != nil {
+		return model.ErrorValidation(err)
+	}
+	logs, count, err := dbGetAllAudits(ctx, aul)
+	if err != nil {
+		return model.ErrorQueryDatabase(err)
+	}
+	ls := util.TransSlice(logs, auditToJson)
+	return model.SuccessPaged(ls, count, "获取成功")
+}
+
+func purgeAuditsService() {
+	retention := auditConfig.GetDuration("retention")
+	if retention <= 0 {
+		return
+	}
+	dbPurgeAudits(context.Background(), time.Now().Add(-retention))
+}
+
+func auditToJson(log *audit.Log) *AuditJson {
+	if log == nil {
+		return nil
+	} else {
+		changes := map[string]*audit.Change{}
+		if err := json.Unmarshal([]byte(log.Changes), &changes); err != nil {
+			mctx.Logger.Warnf("解析审计日志%d的字段变化失败: %v", log.ID, err)
+		}
+		return &AuditJson{
+			ID:         log.ID,
+			UserID:     log.UserID,
+			UserName:   log.UserName,
+			IP:         log.IP,
+			Resource:   log.Resource,
+			ResourceID: log.ResourceID,
+			Action:     log.Action,
+			Changes:    changes,
+			CreatedAt:  log.CreatedAt.Unix(),
+		}
+	}
+}
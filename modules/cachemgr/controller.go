@@ -0,0 +1,59 @@
+package cachemgr
+
+import (
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/util"
+
+	"github.com/kataras/iris/v12"
+)
+
+// getAllCaches godoc
+// @Summary      获取所有缓存信息
+// @Description  获取应用及各模块缓存的命中率、条目数和占用
+// @Tags         cache
+// @Produce      json
+// @Success      200  {object}  model.ApiJson{data=[]CacheJson}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/cache/all [get]
+func getAllCaches(ctx iris.Context) {
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getAllCachesService(auth)
+	ctx.Values().Set("response", response)
+}
+
+// getCache godoc
+// @Summary      获取某缓存信息
+// @Description  通过名称获取缓存的命中率、条目数和占用 应用缓存名称为app 模块缓存名称为模块名
+// @Tags         cache
+// @Produce      json
+// @Param        name  path      string  true  "缓存名称"
+// @Success      200   {object}  model.ApiJson{data=CacheJson}
+// @Failure      401   {object}  model.ApiJson{data=[]string}
+// @Failure      403   {object}  model.ApiJson{data=[]string}
+// @Failure      404   {object}  model.ApiJson{data=[]string}
+// @Router       /v1/cache/{name} [get]
+func getCache(ctx iris.Context) {
+	name := ctx.Params().GetString("name")
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getCacheService(name, auth)
+	ctx.Values().Set("response", response)
+}
+
+// purgeCache godoc
+// @Summary      清空缓存
+// @Description  清空某缓存的所有条目 其他实例的本地缓存同时被清空 图片缓存清空时缓存的图片同时被删除
+// @Tags         cache
+// @Produce      json
+// @Param        name  path      string  true  "缓存名称"
+// @Success      204   {object}  model.ApiJson{data=[]string}
+// @Failure      401   {object}  model.ApiJson{data=[]string}
+// @Failure      403   {object}  model.ApiJson{data=[]string}
+// @Failure      404   {object}  model.ApiJson{data=[]string}
+// @Router       /v1/cache/{name} [delete]
+func purgeCache(ctx iris.Context) {
+	name := ctx.Params().GetString("name")
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := purgeCacheService(name, auth)
+	ctx.Values().Set("response", response)
+}
@@ -0,0 +1,34 @@
+package cachemgr
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/xaxys/maintainman/core/module"
+	"github.com/xaxys/maintainman/core/rbac"
+)
+
+var Module module.Module
+
+func init() {
+	Module = module.Module{
+		ModuleName:    "cache",
+		ModuleVersion: "1.0.0",
+		ModuleDepends: []string{},
+		ModuleEnv:     map[string]any{},
+		ModulePerm: map[string]string{
+			"cache.viewall": "查看所有缓存",
+			"cache.purge":   "清空缓存",
+		},
+		EntryPoint: entry,
+	}
+}
+
+var mctx *module.ModuleContext
+
+func entry(ctx *module.ModuleContext) {
+	mctx = ctx
+	mctx.Route.PartyFunc("/cache", func(c iris.Party) {
+		c.Get("/all", rbac.PermInterceptor("cache.viewall"), getAllCaches)
+		c.Get("/{name:string}", rbac.PermInterceptor("cache.viewall"), getCache)
+		c.Delete("/{name:string}", rbac.PermInterceptor("cache.purge"), purgeCache)
+	})
+}
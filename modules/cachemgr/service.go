@@ -0,0 +1,42 @@
+package cachemgr
+
+import (
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/cache"
+	"github.com/xaxys/maintainman/core/model"
+)
+
+type CacheJson struct {
+	Name string `json:"name"`
+	cache.Stats
+}
+
+func getAllCachesService(auth *model.AuthInfo) *model.ApiJson {
+	caches := []*CacheJson{}
+	for _, name := range cache.Names() {
+		c, _ := cache.GetCache(name)
+		caches = append(caches, &CacheJson{Name: name, Stats: c.Stats()})
+	}
+	return model.Success(caches, "获取成功")
+}
+
+func getCacheService(name string, auth *model.AuthInfo) *model.ApiJson {
+	c, ok := cache.GetCache(name)
+	if !ok {
+		return model.ErrorNotFound(fmt.Errorf("未找到缓存: %s", name))
+	}
+	return model.Success(&CacheJson{Name: name, Stats: c.Stats()}, "获取成功")
+}
+
+func purgeCacheService(name string, auth *model.AuthInfo) *model.ApiJson {
+	c, ok := cache.GetCache(name)
+	if !ok {
+		return model.ErrorNotFound(fmt.Errorf("未找到缓存: %s", name))
+	}
+	before := c.Stats()
+	c.Purge()
+	audit.Record(mctx.EventBus, auth, "cache", name, audit.ActionPurge, &CacheJson{Name: name, Stats: before}, nil)
+	return model.SuccessUpdate(nil, "清空成功")
+}
@@ -1,11 +1,13 @@
 package imagehost
 
 import (
-	"github.com/spf13/viper"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
 )
 
 var (
-	imageConfig = viper.New()
+	imageConfig = config.New()
 )
 
 func init() {
@@ -25,12 +27,17 @@ func init() {
 
 	imageConfig.SetDefault("cache.driver", "local")
 	imageConfig.SetDefault("cache.limit", 1073741824) // 1GB
+	imageConfig.SetDefault("cache.codec", "json")
+	imageConfig.SetDefault("cache.sync", false)
+	imageConfig.SetDefault("cache.local_ttl", "1m")
 
 	imageConfig.SetDefault("storage.driver", "local")
 	imageConfig.SetDefault("storage.local.path", "./images")
 	imageConfig.SetDefault("storage.s3.bucket", "BUCKET")
 	imageConfig.SetDefault("storage.cache.clean", true)
 
+	imageConfig.SetDefault("signed_url.expire", "1h")
+
 	imageConfig.SetDefault("transformations", []map[string]any{
 		{
 			"name":   "square",
@@ -54,4 +61,33 @@ func init() {
 			},
 		},
 	})
+
+	config.SetConfigSchema(imageConfig, config.Schema{
+		"jpeg_quality":   config.Int(1, 100),
+		"gif_num_colors": config.Int(1, 256),
+		"cache_as_jpeg":  config.Bool(),
+		"save_as_jpeg":   config.Bool(),
+
+		"upload.async":             config.Bool(),
+		"upload.throttling.enable": config.Bool(),
+		"upload.throttling.burst":  config.IntMin(0),
+		"upload.throttling.rate":   config.IntMin(0),
+		"upload.throttling.purge":  config.DurationMin(0),
+		"upload.throttling.expire": config.DurationMin(0),
+		"upload.max_file_size":     config.IntMin(1),
+		"upload.max_pixels":        config.IntMin(1),
+
+		"cache.driver":        config.OneOf("local", "redis", "tiered"),
+		"cache.limit":         config.IntMin(0),
+		"cache.codec":         config.OneOf("json", "gob", "msgpack"),
+		"cache.sync":          config.Bool(),
+		"cache.local_ttl":     config.DurationMin(0),
+		"storage.driver":      config.OneOf("local", "s3"),
+		"storage.local.path":  config.NonEmpty(),
+		"storage.s3.bucket":   config.String(),
+		"storage.cache.clean": config.Bool(),
+		"signed_url.expire":   config.DurationMin(time.Second),
+
+		"transformations": config.List(),
+	})
 }
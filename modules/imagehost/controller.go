@@ -1,7 +1,11 @@
 package imagehost
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/storage"
 	"github.com/xaxys/maintainman/core/util"
 
 	"github.com/kataras/iris/v12"
@@ -25,11 +29,70 @@ func getImage(ctx iris.Context) {
 	id := ctx.Params().GetString("id")
 	param := ctx.URLParam("param")
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getImageService(id, param, auth)
+	response := getImageService(ctx.Request().Context(), id, param, auth)
+	if response.ApiRes != nil {
+		ctx.Values().Set("response", response.ApiRes)
+		return
+	}
+	ctx.ContentType(response.Format)
+	ctx.StatusCode(iris.StatusOK)
+	ctx.Write(response.Data)
+}
+
+// getImageURL godoc
+// @Summary      获取图片临时链接
+// @Description  根据图片UUID 获取无需登录即可访问的签名链接 可以自定义图片变化
+// @Tags         image
+// @Produce      json
+// @Param        id      path      string                        true   "Image UUID"
+// @Param        param   query     string                        false  "Transformation parameters"
+// @Param        expire  query     string                        false  "Expire duration, e.g. 30m"
+// @Success      200     {object}  model.ApiJson{data=string}    "Signed URL"
+// @Failure      400     {object}  model.ApiJson{data=[]string}  "Error message"
+// @Failure      403     {object}  model.ApiJson{data=[]string}  "Error message"
+// @Failure      404     {object}  model.ApiJson{data=[]string}  "Error message"
+// @Failure      500     {object}  model.ApiJson{data=[]string}  "Error message"
+// @Router       /v1/image/{id}/url [get]
+func getImageURL(ctx iris.Context) {
+	id := ctx.Params().GetString("id")
+	param := ctx.URLParam("param")
+	expire, err := time.ParseDuration(ctx.URLParamDefault("expire", "0s"))
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getImageURLService(ctx.Request().Context(), id, param, expire, auth)
+	ctx.Values().Set("response", response)
+}
+
+// getSignedImage godoc
+// @Summary      获取签名图片
+// @Description  根据签名链接获取变化后的图片 无需登录
+// @Tags         image
+// @Produce      json
+// @Produce      image/*
+// @Param        id         query     string                        true  "Image UUID"
+// @Param        param      query     string                        true  "Transformation parameters"
+// @Param        expires    query     int                           true  "Expire time"
+// @Param        signature  query     string                        true  "Signature"
+// @Success      200        {object}  string                        "Image data"
+// @Failure      400        {object}  model.ApiJson{data=[]string}  "Error message"
+// @Failure      403        {object}  model.ApiJson{data=[]string}  "Error message"
+// @Failure      404        {object}  model.ApiJson{data=[]string}  "Error message"
+// @Failure      500        {object}  model.ApiJson{data=[]string}  "Error message"
+// @Router       /v1/image/signed [get]
+func getSignedImage(ctx iris.Context) {
+	remain, ok := storage.VerifySigned(ctx, signedImageRoute)
+	if !ok {
+		return
+	}
+	response := getSignedImageService(ctx.Request().Context(), ctx.URLParam("id"), ctx.URLParam("param"))
 	if response.ApiRes != nil {
 		ctx.Values().Set("response", response.ApiRes)
 		return
 	}
+	ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int64(remain.Seconds())))
 	ctx.ContentType(response.Format)
 	ctx.StatusCode(iris.StatusOK)
 	ctx.Write(response.Data)
@@ -53,6 +116,6 @@ func uploadImage(ctx iris.Context) {
 	if err != nil {
 		ctx.Values().Set("response", model.ErrorInvalidData(err))
 	}
-	response := uploadImageService(file, auth)
+	response := uploadImageService(ctx.Request().Context(), file, auth)
 	ctx.Values().Set("response", response)
 }
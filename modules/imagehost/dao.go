@@ -2,12 +2,16 @@ package imagehost
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"net/url"
+	"time"
 
+	"github.com/xaxys/maintainman/core/cache"
 	"github.com/xaxys/maintainman/core/storage"
 	"github.com/xaxys/maintainman/core/util"
 
@@ -16,7 +20,7 @@ import (
 )
 
 var (
-	transformationPO *transformationPersistence
+	transformationPO util.AtomPtr[transformationPersistence]
 )
 
 type transformationPersistence struct {
@@ -56,19 +60,30 @@ func newTransformationPersistence(config *viper.Viper) (s *transformationPersist
 	return
 }
 
+// loadTransformationPersistence is like newTransformationPersistence, but returns the error instead of panic.
+func loadTransformationPersistence(config *viper.Viper) (s *transformationPersistence, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("invalid transformations: %v", e)
+		}
+	}()
+	return newTransformationPersistence(config), nil
+}
+
 func getTransformation(name string) (*Transformation, bool) {
+	po := transformationPO.Get()
 	if name == "" {
-		return transformationPO.def, true
+		return po.def, true
 	}
 	if name == "origin" {
 		return nil, true
 	}
-	trans, ok := transformationPO.index[name]
+	trans, ok := po.index[name]
 	return trans, ok
 }
 
 func getEagerTransformation() []*Transformation {
-	return transformationPO.eager
+	return transformationPO.Get().eager
 }
 
 // Storage API
@@ -76,10 +91,16 @@ func getEagerTransformation() []*Transformation {
 var (
 	imageStorage      storage.IStorage
 	imageCacheStorage storage.IStorage
+	// imageLoader maps original image id with transformation hash to
+	// transformed image id in cache storage.
+	imageLoader *cache.Loader[string]
+
+	errImageNotFound = errors.New("未找到图片")
+	errImageSave     = errors.New("保存图片失败")
 )
 
 func onEvict(a any) error {
-	if id, ok := a.(string); ok {
+	if id, ok := cache.Decode[string](mctx.Cache, a); ok {
 		return deleteImage(id, true)
 	}
 	return nil
@@ -110,6 +131,20 @@ func deleteImage(id string, cached bool) error {
 	return stDeleteImage(store, id)
 }
 
+func signImage(id string, expire time.Duration) (string, error) {
+	return imageStorage.SignedURL(id, expire)
+}
+
+// signTransformedImage signs an URL served by getSignedImage, which
+// transforms the image on demand, as the transformed image in cache storage
+// may be evicted before the URL expires.
+func signTransformedImage(id, param string, expire time.Duration) (string, error) {
+	query := url.Values{}
+	query.Set("id", id)
+	query.Set("param", param)
+	return storage.SignURL(signedImageRoute, query, expire)
+}
+
 // St Functions
 
 func stExistImage(store storage.IStorage, id string) bool {
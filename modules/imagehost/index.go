@@ -1,15 +1,19 @@
 package imagehost
 
 import (
+	"github.com/xaxys/maintainman/core/cache"
+	"github.com/xaxys/maintainman/core/config"
+	"github.com/xaxys/maintainman/core/middleware"
 	"github.com/xaxys/maintainman/core/module"
 	"github.com/xaxys/maintainman/core/rbac"
 
 	"github.com/kataras/iris/v12"
+	"github.com/spf13/viper"
 )
 
 var Module = module.Module{
 	ModuleName:    "image",
-	ModuleVersion: "1.1.0",
+	ModuleVersion: "1.1.3",
 	ModuleConfig:  imageConfig,
 	ModuleDepends: []string{
 		"user",
@@ -17,7 +21,6 @@ var Module = module.Module{
 	ModuleEnv: map[string]any{
 		"cache.evict": onEvict,
 	},
-	ModuleExport: map[string]any{},
 	ModulePerm: map[string]string{
 		"image.upload": "上传图片",
 		"image.view":   "查看图片",
@@ -28,19 +31,36 @@ var Module = module.Module{
 
 var mctx *module.ModuleContext
 
+// signedImageRoute serves transformed images with signed URLs.
+const signedImageRoute = "/image/signed"
+
 func entry(ctx *module.ModuleContext) {
 	mctx = ctx
 	initLimiter()
 	ctx.Route.PartyFunc("/image", func(image iris.Party) {
-		if rateLimiter != nil {
-			image.Post("/", rbac.PermInterceptor("image.upload"), rateLimiter, uploadImage)
-		} else {
-			image.Post("/", rbac.PermInterceptor("image.upload"), uploadImage)
-		}
+		image.Post("/", rbac.PermInterceptor("image.upload"), rateLimiter.Handler, uploadImage)
 		image.Get("/{id:uuid}", rbac.PermInterceptor("image.view"), getImage)
+		image.Get("/{id:uuid}/url", rbac.PermInterceptor("image.view"), getImageURL)
+		image.Get("/signed", getSignedImage)
 	})
 
-	transformationPO = newTransformationPersistence(imageConfig)
+	transformationPO.Set(newTransformationPersistence(imageConfig.Viper()))
 	imageStorage = ctx.Storage
 	imageCacheStorage = ctx.Storage.Sub("cache", imageConfig.GetBool("storage.cache.clean"))
+	// cached images are deleted on evict, so they never expire
+	imageLoader = cache.NewLoader[string](ctx.Cache, 0, 0, 0, nil)
+
+	config.AddConfigValidator(imageConfig, validateConfig)
+	config.OnConfigChange(imageConfig, func(v *viper.Viper) {
+		rateLimiter.Reload(v)
+		transformationPO.Set(newTransformationPersistence(v))
+	})
+}
+
+func validateConfig(v *viper.Viper) error {
+	if err := middleware.ValidateLimiter(v, "upload.throttling"); err != nil {
+		return err
+	}
+	_, err := loadTransformationPersistence(v)
+	return err
 }
@@ -1,20 +1,13 @@
 package imagehost
 
 import (
-	"github.com/kataras/iris/v12"
-	"github.com/kataras/iris/v12/middleware/rate"
+	"github.com/xaxys/maintainman/core/middleware"
 )
 
 var (
-	rateLimiter iris.Handler
+	rateLimiter *middleware.Limiter
 )
 
 func initLimiter() {
-	if imageConfig.GetBool("upload.throttling.enable") {
-		tRate := imageConfig.GetInt("upload.throttling.rate")
-		burst := imageConfig.GetInt("upload.throttling.burst")
-		purge := imageConfig.GetDuration("upload.throttling.purge")
-		expire := imageConfig.GetDuration("upload.throttling.expire")
-		rateLimiter = rate.Limit(float64(tRate), burst, rate.PurgeEvery(purge, expire))
-	}
+	rateLimiter = middleware.NewLimiter(imageConfig.Viper(), "upload.throttling")
 }
@@ -2,13 +2,17 @@ package imagehost
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"time"
 
+	"github.com/xaxys/maintainman/core/cache"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/rbac"
 	"github.com/xaxys/maintainman/core/util"
@@ -23,43 +27,136 @@ type imageResponse struct {
 	ApiRes *model.ApiJson
 }
 
-func getImageService(id, param string, auth *model.AuthInfo) *imageResponse {
-	// parse param to transformation
-	trans, ok := getTransformation(param)
-	if !ok {
-		if err := rbac.CheckPermission(auth.Role, "image.custom"); err != nil {
-			return &imageResponse{ApiRes: model.ErrorNoPermissions(err)}
+func getImageService(ctx context.Context, id, param string, auth *model.AuthInfo) *imageResponse {
+	trans, apiRes := resolveTransformation(param, auth)
+	if apiRes != nil {
+		return &imageResponse{ApiRes: apiRes}
+	}
+	return loadImageService(ctx, id, trans)
+}
+
+// getSignedImageService returns the image of URL signed by
+// getImageURLService, whose transformation has been checked on signing.
+// Transformed images are loaded, or transformed again if evicted from
+// cache, on demand.
+func getSignedImageService(ctx context.Context, id, param string) *imageResponse {
+	trans, err := parseTransformation(param)
+	if err != nil {
+		return &imageResponse{ApiRes: model.ErrorInvalidData(err)}
+	}
+	return loadImageService(ctx, id, trans)
+}
+
+func loadImageService(ctx context.Context, id string, trans *Transformation) *imageResponse {
+	if trans == nil {
+		if !existImage(id, false) {
+			return &imageResponse{ApiRes: model.ErrorNotFound(fmt.Errorf("未找到图片: id: %s", id))}
 		}
-		transParam, err := parseParameters(param)
+		_, data, format, err := loadImage(id, false)
 		if err != nil {
-			return &imageResponse{ApiRes: model.ErrorInvalidData(err)}
+			return &imageResponse{ApiRes: model.ErrorQueryDatabase(err)}
+		}
+		return &imageResponse{
+			Data:   data,
+			Format: "image/" + format,
 		}
-		trans = newTransformation(&transParam)
 	}
 
-	key := id
-	if trans != nil {
-		key += trans.Hash
+	tid, data, format, apiRes := transformImage(ctx, id, trans)
+	if apiRes != nil {
+		return &imageResponse{ApiRes: apiRes}
+	}
+
+	// transformed by others, or cached
+	if data == nil {
+		if !existImage(tid, true) {
+			return &imageResponse{ApiRes: model.ErrorNotFound(fmt.Errorf("未找到图片: cached: true, id: %s", tid))}
+		}
+		var err error
+		_, data, format, err = loadImage(tid, true)
+		if err != nil {
+			return &imageResponse{ApiRes: model.ErrorQueryDatabase(err)}
+		}
+	}
+
+	return &imageResponse{
+		Data:   data,
+		Format: "image/" + format,
+	}
+}
+
+// getImageURLService returns a signed URL of the image with transformation,
+// which can be downloaded without a token before expire.
+func getImageURLService(ctx context.Context, id, param string, expire time.Duration, auth *model.AuthInfo) *model.ApiJson {
+	if expire == 0 {
+		expire = imageConfig.GetDuration("signed_url.expire")
+	}
+	trans, apiRes := resolveTransformation(param, auth)
+	if apiRes != nil {
+		return apiRes
+	}
+	if !existImage(id, false) {
+		return model.ErrorNotFound(fmt.Errorf("未找到图片: id: %s", id))
 	}
 
-	cid, cached := mctx.Cache.Get(key)
-	if cached {
-		id = cid.(string)
+	var (
+		url string
+		err error
+	)
+	if trans == nil {
+		url, err = signImage(id, expire)
+	} else {
+		url, err = signTransformedImage(id, param, expire)
+	}
+	if err != nil {
+		return model.ErrorInvalidData(err)
 	}
+	return model.Success(url, "获取成功")
+}
 
-	if !existImage(id, cached) {
-		return &imageResponse{ApiRes: model.ErrorNotFound(fmt.Errorf("未找到图片: cached: %v, id: %s", cached, id))}
+// resolveTransformation parses param to transformation. nil transformation
+// means the original image.
+func resolveTransformation(param string, auth *model.AuthInfo) (*Transformation, *model.ApiJson) {
+	if trans, ok := getTransformation(param); ok {
+		return trans, nil
+	}
+	if err := rbac.CheckPermission(auth.Role, "image.custom"); err != nil {
+		return nil, model.ErrorNoPermissions(err)
 	}
+	trans, err := parseTransformation(param)
+	if err != nil {
+		return nil, model.ErrorInvalidData(err)
+	}
+	return trans, nil
+}
 
-	image, data, format, err := loadImage(id, cached)
+// parseTransformation parses param to transformation without checking
+// permissions.
+func parseTransformation(param string) (*Transformation, error) {
+	if trans, ok := getTransformation(param); ok {
+		return trans, nil
+	}
+	transParam, err := parseParameters(param)
 	if err != nil {
-		return &imageResponse{ApiRes: model.ErrorQueryDatabase(err)}
+		return nil, err
 	}
+	return newTransformation(&transParam), nil
+}
 
-	// do transformation
-	if trans != nil && !cached {
+// transformImage returns id of the transformed image in cache storage. data
+// is returned only if it is transformed by this call.
+func transformImage(ctx context.Context, id string, trans *Transformation) (tid string, data []byte, format string, apiRes *model.ApiJson) {
+	// do transformation, which is shared by concurrent requests
+	tid, err := imageLoader.GetWithCost(ctx, id+trans.Hash, func(ctx context.Context) (string, int64, error) {
+		if !existImage(id, false) {
+			return "", 0, fmt.Errorf("%w: id: %s", errImageNotFound, id)
+		}
+		image, _, f, err := loadImage(id, false)
+		if err != nil {
+			return "", 0, err
+		}
 		uid := parseUUID(id)
-		user, err := user.GetUserByID(uid)
+		user, err := user.GetUserByID(ctx, uid)
 		newAuth := model.AuthInfo{User: uid}
 		if err != nil {
 			mctx.Logger.Warn(err)
@@ -68,22 +165,26 @@ func getImageService(id, param string, auth *model.AuthInfo) *imageResponse {
 		}
 		image = transformCropAndResize(image, trans, newAuth)
 		tid := genUUID(uid)
-		format = util.Tenary(imageConfig.GetBool("cache_as_jpeg"), "jpeg", format)
-		bytes, err := saveImage(tid, format, image, true)
+		f = util.Tenary(imageConfig.GetBool("cache_as_jpeg"), "jpeg", f)
+		bytes, err := saveImage(tid, f, image, true)
 		if err != nil {
-			return &imageResponse{ApiRes: model.ErrorInsertDatabase(err)}
+			return "", 0, fmt.Errorf("%w: %v", errImageSave, err)
 		}
-		mctx.Cache.SetWithCost(key, tid, int64(len(bytes)), 0)
-		data = bytes
-	}
-
-	return &imageResponse{
-		Data:   data,
-		Format: "image/" + format,
+		data, format = bytes, f
+		return tid, int64(len(bytes)), nil
+	})
+	switch {
+	case errors.Is(err, errImageNotFound):
+		return "", nil, "", model.ErrorNotFound(err)
+	case errors.Is(err, errImageSave):
+		return "", nil, "", model.ErrorInsertDatabase(err)
+	case err != nil:
+		return "", nil, "", model.ErrorQueryDatabase(err)
 	}
+	return tid, data, format, nil
 }
 
-func uploadImageService(file multipart.File, auth *model.AuthInfo) *model.ApiJson {
+func uploadImageService(ctx context.Context, file multipart.File, auth *model.AuthInfo) *model.ApiJson {
 	c, format, err := image.DecodeConfig(file)
 	if err != nil {
 		return model.ErrorValidation(err)
@@ -134,7 +235,7 @@ func uploadImageService(file multipart.File, auth *model.AuthInfo) *model.ApiJso
 				format = util.Tenary(imageConfig.GetBool("cache_as_jpeg"), "jpeg", format)
 				bytes, err := saveImage(tid, format, imgNew, true)
 				if err == nil {
-					mctx.Cache.SetWithCost(key, tid, int64(len(bytes)), 0)
+					cache.SetAsWithCost(mctx.Cache, key, tid, int64(len(bytes)), 0)
 				}
 			}
 		}()
@@ -0,0 +1,79 @@
+package modmgr
+
+import (
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/util"
+
+	"github.com/kataras/iris/v12"
+)
+
+// getAllModules godoc
+// @Summary      获取所有模块信息
+// @Description  获取所有已注册模块的版本、状态、依赖、权限和路由
+// @Tags         module
+// @Produce      json
+// @Success      200  {object}  model.ApiJson{data=[]module.ModuleInfo}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/module/all [get]
+func getAllModules(ctx iris.Context) {
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getAllModulesService(auth)
+	ctx.Values().Set("response", response)
+}
+
+// getModule godoc
+// @Summary      获取某模块信息
+// @Description  通过模块名获取模块的版本、状态、依赖、权限和路由
+// @Tags         module
+// @Produce      json
+// @Param        name  path      string  true  "模块名"
+// @Success      200   {object}  model.ApiJson{data=module.ModuleInfo}
+// @Failure      401   {object}  model.ApiJson{data=[]string}
+// @Failure      403   {object}  model.ApiJson{data=[]string}
+// @Failure      404   {object}  model.ApiJson{data=[]string}
+// @Router       /v1/module/{name} [get]
+func getModule(ctx iris.Context) {
+	name := ctx.Params().GetString("name")
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getModuleService(name, auth)
+	ctx.Values().Set("response", response)
+}
+
+// enableModule godoc
+// @Summary      启用模块
+// @Description  启用运行时被禁用的模块，并写入配置文件。启动时未加载的模块需重启后生效
+// @Tags         module
+// @Produce      json
+// @Param        name  path      string  true  "模块名"
+// @Success      200   {object}  model.ApiJson{data=module.ModuleInfo}
+// @Failure      401   {object}  model.ApiJson{data=[]string}
+// @Failure      403   {object}  model.ApiJson{data=[]string}
+// @Failure      404   {object}  model.ApiJson{data=[]string}
+// @Failure      409   {object}  model.ApiJson{data=[]string}
+// @Router       /v1/module/{name}/enable [post]
+func enableModule(ctx iris.Context) {
+	name := ctx.Params().GetString("name")
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := enableModuleService(name, auth)
+	ctx.Values().Set("response", response)
+}
+
+// disableModule godoc
+// @Summary      禁用模块
+// @Description  停止模块的监听器并拒绝其路由的请求，并写入配置文件。被其他模块依赖时无法禁用
+// @Tags         module
+// @Produce      json
+// @Param        name  path      string  true  "模块名"
+// @Success      200   {object}  model.ApiJson{data=module.ModuleInfo}
+// @Failure      401   {object}  model.ApiJson{data=[]string}
+// @Failure      403   {object}  model.ApiJson{data=[]string}
+// @Failure      404   {object}  model.ApiJson{data=[]string}
+// @Failure      409   {object}  model.ApiJson{data=[]string}
+// @Router       /v1/module/{name}/disable [post]
+func disableModule(ctx iris.Context) {
+	name := ctx.Params().GetString("name")
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := disableModuleService(name, auth)
+	ctx.Values().Set("response", response)
+}
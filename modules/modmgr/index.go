@@ -0,0 +1,35 @@
+package modmgr
+
+import (
+	"github.com/kataras/iris/v12"
+	"github.com/xaxys/maintainman/core/module"
+	"github.com/xaxys/maintainman/core/rbac"
+)
+
+var Module module.Module
+
+func init() {
+	Module = module.Module{
+		ModuleName:    "module",
+		ModuleVersion: "1.0.0",
+		ModuleDepends: []string{},
+		ModuleEnv:     map[string]any{},
+		ModulePerm: map[string]string{
+			"module.viewall": "查看所有模块",
+			"module.update":  "启用或禁用模块",
+		},
+		EntryPoint: entry,
+	}
+}
+
+var mctx *module.ModuleContext
+
+func entry(ctx *module.ModuleContext) {
+	mctx = ctx
+	mctx.Route.PartyFunc("/module", func(mod iris.Party) {
+		mod.Get("/all", rbac.PermInterceptor("module.viewall"), getAllModules)
+		mod.Get("/{name:string}", rbac.PermInterceptor("module.viewall"), getModule)
+		mod.Post("/{name:string}/enable", rbac.PermInterceptor("module.update"), enableModule)
+		mod.Post("/{name:string}/disable", rbac.PermInterceptor("module.update"), disableModule)
+	})
+}
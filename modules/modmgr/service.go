@@ -0,0 +1,53 @@
+package modmgr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/model"
+	"github.com/xaxys/maintainman/core/module"
+)
+
+func getAllModulesService(auth *model.AuthInfo) *model.ApiJson {
+	modules := mctx.Registry.List()
+	return model.Success(modules, "获取成功")
+}
+
+func getModuleService(name string, auth *model.AuthInfo) *model.ApiJson {
+	info, err := mctx.Registry.Info(name)
+	if err != nil {
+		return model.ErrorNotFound(err)
+	}
+	return model.Success(info, "获取成功")
+}
+
+func enableModuleService(name string, auth *model.AuthInfo) *model.ApiJson {
+	err := mctx.Registry.Enable(name)
+	if errors.Is(err, module.ErrModuleNotFound) {
+		return model.ErrorNotFound(err)
+	}
+	if errors.Is(err, module.ErrRestartRequired) {
+		info, _ := mctx.Registry.Info(name)
+		return model.Success(info, "已启用，重启后生效")
+	}
+	if err != nil {
+		return model.ErrorConflict(err)
+	}
+	info, _ := mctx.Registry.Info(name)
+	return model.Success(info, "启用成功")
+}
+
+func disableModuleService(name string, auth *model.AuthInfo) *model.ApiJson {
+	if name == Module.ModuleName {
+		return model.ErrorConflict(fmt.Errorf("module %s can not disable itself", name))
+	}
+	err := mctx.Registry.Disable(name)
+	if errors.Is(err, module.ErrModuleNotFound) {
+		return model.ErrorNotFound(err)
+	}
+	if err != nil {
+		return model.ErrorConflict(err)
+	}
+	info, _ := mctx.Registry.Info(name)
+	return model.Success(info, "禁用成功")
+}
@@ -1,21 +1,94 @@
 package order
 
+import "context"
+
 // GetSimpleOrderByID returns the order with the given ID.
-func GetSimpleOrderByID(id uint) (*Order, error) {
-	return dbGetSimpleOrderByID(id)
+func GetSimpleOrderByID(ctx context.Context, id uint) (*Order, error) {
+	return dbGetSimpleOrderByID(ctx, id)
 }
 
 // GetOrderByID returns the order with the given ID and Tags and Comments.
-func GetOrderByID(id uint) (*Order, error) {
-	return dbGetOrderByID(id)
+func GetOrderByID(ctx context.Context, id uint) (*Order, error) {
+	return dbGetCachedOrderByID(ctx, id)
 }
 
 // GetOrderWithLastStatus returns the order with the given ID and the last status.
-func GetOrderWithLastStatus(id uint) (*Order, error) {
-	return dbGetOrderWithLastStatus(id)
+func GetOrderWithLastStatus(ctx context.Context, id uint) (*Order, error) {
+	return dbGetOrderWithLastStatus(ctx, id)
 }
 
 // GetCommentByID returns the comment with the given ID.
-func GetCommentByID(id uint) (*Comment, error) {
-	return dbGetCommentByID(id)
+func GetCommentByID(ctx context.Context, id uint) (*Comment, error) {
+	return dbGetCommentByID(ctx, id)
+}
+
+// OrderReader is provided by order module for other modules to read orders and comments.
+type OrderReader interface {
+	GetOrderByID(ctx context.Context, id uint) (*Order, error)
+	GetOrderWithLastStatus(ctx context.Context, id uint) (*Order, error)
+	GetCommentByID(ctx context.Context, id uint) (*Comment, error)
+}
+
+// WechatStatusTemplate is the wechat template of order status notification.
+// Except ID, fields are the field names in the template, empty if not used.
+type WechatStatusTemplate struct {
+	ID     string
+	Order  string
+	Title  string
+	Status string
+	Time   string
+	Other  string
+}
+
+// WechatCommentTemplate is the wechat template of order comment notification.
+// Except ID, fields are the field names in the template, empty if not used.
+type WechatCommentTemplate struct {
+	ID      string
+	Title   string
+	Name    string
+	Message string
+	Time    string
+}
+
+// WechatTemplates is provided by order module for wechat notification.
+type WechatTemplates interface {
+	StatusTemplate() *WechatStatusTemplate
+	CommentTemplate() *WechatCommentTemplate
+}
+
+type orderReader struct{}
+
+func (orderReader) GetOrderByID(ctx context.Context, id uint) (*Order, error) {
+	return GetOrderByID(ctx, id)
+}
+
+func (orderReader) GetOrderWithLastStatus(ctx context.Context, id uint) (*Order, error) {
+	return GetOrderWithLastStatus(ctx, id)
+}
+
+func (orderReader) GetCommentByID(ctx context.Context, id uint) (*Comment, error) {
+	return GetCommentByID(ctx, id)
+}
+
+type wechatTemplates struct{}
+
+func (wechatTemplates) StatusTemplate() *WechatStatusTemplate {
+	return &WechatStatusTemplate{
+		ID:     orderConfig.GetString("notify.wechat.status.tmpl"),
+		Order:  orderConfig.GetString("notify.wechat.status.order"),
+		Title:  orderConfig.GetString("notify.wechat.status.title"),
+		Status: orderConfig.GetString("notify.wechat.status.status"),
+		Time:   orderConfig.GetString("notify.wechat.status.time"),
+		Other:  orderConfig.GetString("notify.wechat.status.other"),
+	}
+}
+
+func (wechatTemplates) CommentTemplate() *WechatCommentTemplate {
+	return &WechatCommentTemplate{
+		ID:      orderConfig.GetString("notify.wechat.comment.tmpl"),
+		Title:   orderConfig.GetString("notify.wechat.comment.title"),
+		Name:    orderConfig.GetString("notify.wechat.comment.name"),
+		Message: orderConfig.GetString("notify.wechat.comment.message"),
+		Time:    orderConfig.GetString("notify.wechat.comment.time"),
+	}
 }
@@ -0,0 +1,36 @@
+package order
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/cache"
+
+	"gorm.io/gorm"
+)
+
+var orderLoader *cache.Loader[Order]
+
+func initOrderCache() {
+	orderLoader = cache.NewLoader[Order](
+		mctx.Cache,
+		orderConfig.GetDuration("cache.ttl"),
+		orderConfig.GetDuration("cache.jitter"),
+		orderConfig.GetDuration("cache.negative_ttl"),
+		gorm.ErrRecordNotFound,
+	)
+}
+
+func cacheGetOrderByID(ctx context.Context, id uint, load func(ctx context.Context) (Order, error)) (*Order, error) {
+	order, err := orderLoader.Get(ctx, fmt.Sprintf("order:%d", id), load)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func cacheDeleteOrder(ids ...uint) {
+	for _, id := range ids {
+		orderLoader.Del(fmt.Sprintf("order:%d", id))
+	}
+}
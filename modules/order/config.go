@@ -1,8 +1,12 @@
 package order
 
-import "github.com/spf13/viper"
+import (
+	"time"
 
-var orderConfig = viper.New()
+	"github.com/xaxys/maintainman/core/config"
+)
+
+var orderConfig = config.New()
 
 func init() {
 	orderConfig.SetDefault("item_can_negative", true)
@@ -11,6 +15,15 @@ func init() {
 	orderConfig.SetDefault("appraise.purge", "1m")
 	orderConfig.SetDefault("appraise.default", 5)
 
+	orderConfig.SetDefault("cache.driver", "local")
+	orderConfig.SetDefault("cache.limit", 268435456) // 256MB
+	orderConfig.SetDefault("cache.codec", "json")
+	orderConfig.SetDefault("cache.sync", false)
+	orderConfig.SetDefault("cache.local_ttl", "1m")
+	orderConfig.SetDefault("cache.ttl", "10m")
+	orderConfig.SetDefault("cache.jitter", "1m")
+	orderConfig.SetDefault("cache.negative_ttl", "1m")
+
 	orderConfig.SetDefault("notify.wechat.status.tmpl", "订阅消息模板id")
 	orderConfig.SetDefault("notify.wechat.status.order", "模板中 订单编号 字段名")
 	orderConfig.SetDefault("notify.wechat.status.title", "模板中 订单标题 字段名")
@@ -21,6 +34,42 @@ func init() {
 	orderConfig.SetDefault("notify.wechat.comment.tmpl", "微信留言消息模板id")
 	orderConfig.SetDefault("notify.wechat.comment.title", "模板中 订单标题 字段名")
 	orderConfig.SetDefault("notify.wechat.comment.name", "模板中 留言人 字段名")
-	orderConfig.SetDefault("notify.wechat.comment.messgae", "模板中 留言内容 字段名")
+	orderConfig.SetDefault("notify.wechat.comment.message", "模板中 留言内容 字段名")
 	orderConfig.SetDefault("notify.wechat.comment.time", "模板中 留言时间 字段名")
+
+	config.SetConfigSchema(orderConfig, config.Schema{
+		"item_can_negative": config.Bool(),
+		"appraise.timeout":  config.DurationMin(time.Second),
+		"appraise.purge":    config.DurationMin(time.Second),
+		"appraise.default":  config.Int(1, 5),
+
+		"cache.driver":       config.OneOf("local", "redis", "tiered"),
+		"cache.limit":        config.IntMin(0),
+		"cache.codec":        config.OneOf("json", "gob", "msgpack"),
+		"cache.sync":         config.Bool(),
+		"cache.local_ttl":    config.DurationMin(0),
+		"cache.ttl":          config.DurationMin(0),
+		"cache.jitter":       config.DurationMin(0),
+		"cache.negative_ttl": config.DurationMin(0),
+
+		"notify.wechat.status.tmpl":     config.String(),
+		"notify.wechat.status.order":    config.String(),
+		"notify.wechat.status.title":    config.String(),
+		"notify.wechat.status.status":   config.String(),
+		"notify.wechat.status.time":     config.String(),
+		"notify.wechat.status.other":    config.String(),
+		"notify.wechat.comment.tmpl":    config.String(),
+		"notify.wechat.comment.title":   config.String(),
+		"notify.wechat.comment.name":    config.String(),
+		"notify.wechat.comment.message": config.String(),
+		"notify.wechat.comment.time":    config.String(),
+	})
+
+	config.AddConfigMigration(orderConfig, &config.ConfigMigration{
+		Version: "1.2.0",
+		Changes: []config.ConfigChange{
+			// configuration files written by older versions use the misspelled key
+			config.RenameKey("notify.wechat.comment.messgae", "message"),
+		},
+	})
 }
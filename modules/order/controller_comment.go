@@ -29,7 +29,7 @@ func getCommentsByOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	param := controller.ExtractPageParam(ctx)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getCommentsByOrderService(id, param, auth)
+	response := getCommentsByOrderService(ctx.Request().Context(), id, param, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -54,7 +54,7 @@ func forceGetCommentsByOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	param := controller.ExtractPageParam(ctx)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := forceGetCommentsByOrderService(id, param, auth)
+	response := forceGetCommentsByOrderService(ctx.Request().Context(), id, param, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -82,7 +82,7 @@ func forceCreateComment(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := forceCreateCommentService(id, aul, auth)
+	response := forceCreateCommentService(ctx.Request().Context(), id, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -110,7 +110,7 @@ func createComment(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createCommentService(id, aul, auth)
+	response := createCommentService(ctx.Request().Context(), id, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -132,7 +132,7 @@ func createComment(ctx iris.Context) {
 func deleteComment(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := forceDeleteCommentService(id, auth)
+	response := forceDeleteCommentService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -154,6 +154,71 @@ func deleteComment(ctx iris.Context) {
 func forceDeleteComment(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := DeleteCommentService(id, auth)
+	response := DeleteCommentService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// getTrashComments godoc
+// @Summary      获取已删除的评论
+// @Description  获取已删除的评论 分页 默认按删除时间倒序
+// @Tags         comment
+// @Produce      json
+// @Param        order_by  query     string  false  "排序字段"
+// @Param        offset    query     uint    false  "偏移量"
+// @Param        limit     query     uint    false  "每页数据量"
+// @Success      200       {object}  model.ApiJson{data=model.Page{entries=[]CommentJson}}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
+// @Router       /v1/comment/trash [get]
+func getTrashComments(ctx iris.Context) {
+	param := controller.ExtractPageParam(ctx)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getTrashCommentsService(ctx.Request().Context(), param, auth)
+	ctx.Values().Set("response", response)
+}
+
+// restoreComment godoc
+// @Summary      恢复已删除的评论
+// @Description  通过ID恢复已删除的评论
+// @Tags         comment
+// @Produce      json
+// @Param        id   path      uint  true  "评论ID"
+// @Success      204  {object}  model.ApiJson{data=CommentJson}
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/comment/trash/{id}/restore [post]
+func restoreComment(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := restoreCommentService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// purgeComment godoc
+// @Summary      彻底删除评论
+// @Description  通过ID彻底删除已删除的评论 不可恢复
+// @Tags         comment
+// @Produce      json
+// @Param        id   path      uint  true  "评论ID"
+// @Success      204  {object}  model.ApiJson
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/comment/trash/{id} [delete]
+func purgeComment(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := purgeCommentService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
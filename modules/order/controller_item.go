@@ -15,6 +15,7 @@ import (
 // @Produce      json
 // @Param        id   path      uint  true  "物品ID"
 // @Success      200  {object}  model.ApiJson{data=ItemJson}
+// @Header       200  {string}  ETag  "物品版本号 用于 If-Match"
 // @Failure      400  {object}  model.ApiJson{data=[]string}
 // @Failure      401  {object}  model.ApiJson{data=[]string}
 // @Failure      403  {object}  model.ApiJson{data=[]string}
@@ -25,7 +26,10 @@ import (
 func getItemByID(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getItemByIDService(id, auth)
+	response := getItemByIDService(ctx.Request().Context(), id, auth)
+	if item, ok := response.Data.(*ItemJson); ok && item != nil {
+		controller.SetETag(ctx, item.Version)
+	}
 	ctx.Values().Set("response", response)
 }
 
@@ -36,6 +40,7 @@ func getItemByID(ctx iris.Context) {
 // @Produce      json
 // @Param        name  path      string  true  "物品名称"
 // @Success      200   {object}  model.ApiJson{data=ItemJson}
+// @Header       200   {string}  ETag  "物品版本号 用于 If-Match"
 // @Failure      400   {object}  model.ApiJson{data=[]string}
 // @Failure      401   {object}  model.ApiJson{data=[]string}
 // @Failure      403   {object}  model.ApiJson{data=[]string}
@@ -46,7 +51,10 @@ func getItemByID(ctx iris.Context) {
 func getItemByName(ctx iris.Context) {
 	name := ctx.Params().Get("name")
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getItemByNameService(name, auth)
+	response := getItemByNameService(ctx.Request().Context(), name, auth)
+	if item, ok := response.Data.(*ItemJson); ok && item != nil {
+		controller.SetETag(ctx, item.Version)
+	}
 	ctx.Values().Set("response", response)
 }
 
@@ -67,7 +75,7 @@ func getItemByName(ctx iris.Context) {
 func getItemsByFuzzyName(ctx iris.Context) {
 	name := ctx.Params().Get("name")
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getItemsByFuzzyNameService(name, auth)
+	response := getItemsByFuzzyNameService(ctx.Request().Context(), name, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -90,7 +98,7 @@ func getItemsByFuzzyName(ctx iris.Context) {
 func getAllItems(ctx iris.Context) {
 	param := controller.ExtractPageParam(ctx)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAllItemsService(param, auth)
+	response := getAllItemsService(ctx.Request().Context(), param, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -116,7 +124,7 @@ func createItem(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createItemService(aul, auth)
+	response := createItemService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -138,7 +146,7 @@ func createItem(ctx iris.Context) {
 func deleteItem(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := deleteItemService(id, auth)
+	response := deleteItemService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -148,15 +156,17 @@ func deleteItem(ctx iris.Context) {
 // @Tags         item
 // @Accept       json
 // @Produce      json
-// @Param        id    path      uint            true  "物品ID"
-// @Param        body  body      AddItemRequest  true  "物品数量"
-// @Success      204   {object}  model.ApiJson{data=ItemJson}
-// @Failure      400   {object}  model.ApiJson{data=[]string}
-// @Failure      401   {object}  model.ApiJson{data=[]string}
-// @Failure      403   {object}  model.ApiJson{data=[]string}
-// @Failure      404   {object}  model.ApiJson{data=[]string}
-// @Failure      422   {object}  model.ApiJson{data=[]string}
-// @Failure      500   {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                              true   "物品ID"
+// @Param        body      body      AddItemRequest                    true   "物品数量"
+// @Param        If-Match  header    string                            false  "物品版本号 物品已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=ItemJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=ItemInfoJson}  "物品已被修改 返回当前物品"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/item/{id} [post]
 func addItem(ctx iris.Context) {
 	aul := &AddItemRequest{}
@@ -166,7 +176,12 @@ func addItem(ctx iris.Context) {
 	}
 	aul.ItemID = ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := addItemService(aul, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := addItemService(ctx.Request().Context(), aul, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -176,15 +191,17 @@ func addItem(ctx iris.Context) {
 // @Tags         item
 // @Accept       json
 // @Produce      json
-// @Param        id    path      uint                true  "订单ID"
-// @Param        body  body      ConsumeItemRequest  true  "物品数量"
-// @Success      204   {object}  model.ApiJson{data=ItemJson}
-// @Failure      400   {object}  model.ApiJson{data=[]string}
-// @Failure      401   {object}  model.ApiJson{data=[]string}
-// @Failure      403   {object}  model.ApiJson{data=[]string}
-// @Failure      404   {object}  model.ApiJson{data=[]string}
-// @Failure      422   {object}  model.ApiJson{data=[]string}
-// @Failure      500   {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                              true   "订单ID"
+// @Param        body      body      ConsumeItemRequest                true   "物品数量"
+// @Param        If-Match  header    string                            false  "物品版本号 物品已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=ItemJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=ItemInfoJson}  "物品已被修改 返回当前物品"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/consume [post]
 func consumeItem(ctx iris.Context) {
 	aul := &ConsumeItemRequest{}
@@ -194,6 +211,77 @@ func consumeItem(ctx iris.Context) {
 	}
 	aul.OrderID = ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := consumeItemService(aul, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := consumeItemService(ctx.Request().Context(), aul, version, auth)
+	ctx.Values().Set("response", response)
+}
+
+// getTrashItems godoc
+// @Summary      获取已删除的物品
+// @Description  获取已删除的物品 分页 默认按删除时间倒序
+// @Tags         item
+// @Produce      json
+// @Param        order_by  query     string  false  "排序字段"
+// @Param        offset    query     uint    false  "偏移量"
+// @Param        limit     query     uint    false  "每页数据量"
+// @Success      200       {object}  model.ApiJson{data=model.Page{entries=[]ItemInfoJson}}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
+// @Router       /v1/item/trash [get]
+func getTrashItems(ctx iris.Context) {
+	param := controller.ExtractPageParam(ctx)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getTrashItemsService(ctx.Request().Context(), param, auth)
+	ctx.Values().Set("response", response)
+}
+
+// restoreItem godoc
+// @Summary      恢复已删除的物品
+// @Description  通过ID恢复已删除的物品 名称与现有物品重复时失败
+// @Tags         item
+// @Produce      json
+// @Param        id   path      uint  true  "物品ID"
+// @Success      204  {object}  model.ApiJson{data=ItemInfoJson}
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      409  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/item/trash/{id}/restore [post]
+func restoreItem(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := restoreItemService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// purgeItem godoc
+// @Summary      彻底删除物品
+// @Description  通过ID彻底删除已删除的物品 不可恢复
+// @Tags         item
+// @Produce      json
+// @Param        id   path      uint  true  "物品ID"
+// @Success      204  {object}  model.ApiJson
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/item/trash/{id} [delete]
+func purgeItem(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := purgeItemService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
@@ -1,6 +1,7 @@
 package order
 
 import (
+	"github.com/xaxys/maintainman/core/controller"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
@@ -34,7 +35,7 @@ func getUserOrders(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getOrderByUserService(req, auth)
+	response := getOrderByUserService(ctx.Request().Context(), req, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -65,7 +66,7 @@ func getRepairerOrders(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getOrderByRepairerService(auth.User, req, auth)
+	response := getOrderByRepairerService(ctx.Request().Context(), auth.User, req, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -98,7 +99,7 @@ func forceGetRepairerOrders(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getOrderByRepairerService(id, req, auth)
+	response := getOrderByRepairerService(ctx.Request().Context(), id, req, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -131,7 +132,7 @@ func getAllOrders(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAllOrdersService(req, auth)
+	response := getAllOrdersService(ctx.Request().Context(), req, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -142,6 +143,7 @@ func getAllOrders(ctx iris.Context) {
 // @Produce      json
 // @Param        id   path      uint                              true  "订单ID"
 // @Success      200  {object}  model.ApiJson{data=OrderJson}  "返回结果 带Tag 带Comment 带Repairer"
+// @Header       200  {string}  ETag                           "订单版本号 用于 If-Match"
 // @Failure      400  {object}  model.ApiJson{data=[]string}
 // @Failure      401  {object}  model.ApiJson{data=[]string}
 // @Failure      403  {object}  model.ApiJson{data=[]string}
@@ -152,7 +154,10 @@ func getAllOrders(ctx iris.Context) {
 func getOrderByID(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getOrderByIDService(id, auth)
+	response := getOrderByIDService(ctx.Request().Context(), id, auth)
+	if order, ok := response.Data.(*OrderJson); ok && order != nil {
+		controller.SetETag(ctx, order.Version)
+	}
 	ctx.Values().Set("response", response)
 }
 
@@ -163,6 +168,7 @@ func getOrderByID(ctx iris.Context) {
 // @Produce      json
 // @Param        id   path      uint                              true  "订单ID"
 // @Success      200  {object}  model.ApiJson{data=OrderJson}  "返回结果 带Tag 带Comment 带Repairer"
+// @Header       200  {string}  ETag                           "订单版本号 用于 If-Match"
 // @Failure      400  {object}  model.ApiJson{data=[]string}
 // @Failure      401  {object}  model.ApiJson{data=[]string}
 // @Failure      403  {object}  model.ApiJson{data=[]string}
@@ -173,7 +179,10 @@ func getOrderByID(ctx iris.Context) {
 func forceGetOrderByID(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := forceGetOrderByIDService(id, auth)
+	response := forceGetOrderByIDService(ctx.Request().Context(), id, auth)
+	if order, ok := response.Data.(*OrderJson); ok && order != nil {
+		controller.SetETag(ctx, order.Version)
+	}
 	ctx.Values().Set("response", response)
 }
 
@@ -194,7 +203,7 @@ func forceGetOrderByID(ctx iris.Context) {
 func getOrderStatus(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getOrderStatusService(id, auth)
+	response := getOrderStatusService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -215,7 +224,7 @@ func getOrderStatus(ctx iris.Context) {
 func forceGetOrderStatus(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := forceGetOrderStatusService(id, auth)
+	response := forceGetOrderStatusService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -241,7 +250,7 @@ func createOrder(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createOrderService(aul, auth)
+	response := createOrderService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -251,15 +260,17 @@ func createOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id    path      uint                true  "订单ID"
-// @Param        body  body      UpdateOrderRequest  true  "请求参数"
-// @Success      204   {object}  model.ApiJson{data=OrderJson}
-// @Failure      400   {object}  model.ApiJson{data=[]string}
-// @Failure      401   {object}  model.ApiJson{data=[]string}
-// @Failure      403   {object}  model.ApiJson{data=[]string}
-// @Failure      404   {object}  model.ApiJson{data=[]string}
-// @Failure      422   {object}  model.ApiJson{data=[]string}
-// @Failure      500   {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        body      body      UpdateOrderRequest             true   "请求参数"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id} [put]
 func updateOrder(ctx iris.Context) {
 	aul := &UpdateOrderRequest{}
@@ -269,7 +280,12 @@ func updateOrder(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := updateOrderService(id, aul, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := updateOrderService(ctx.Request().Context(), id, version, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -279,15 +295,17 @@ func updateOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id    path      uint                true  "订单ID"
-// @Param        body  body      UpdateOrderRequest  true  "请求参数"
-// @Success      204   {object}  model.ApiJson{data=OrderJson}
-// @Failure      400   {object}  model.ApiJson{data=[]string}
-// @Failure      401   {object}  model.ApiJson{data=[]string}
-// @Failure      403   {object}  model.ApiJson{data=[]string}
-// @Failure      404   {object}  model.ApiJson{data=[]string}
-// @Failure      422   {object}  model.ApiJson{data=[]string}
-// @Failure      500   {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        body      body      UpdateOrderRequest             true   "请求参数"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/force [put]
 func forceUpdateOrder(ctx iris.Context) {
 	aul := &UpdateOrderRequest{}
@@ -297,7 +315,12 @@ func forceUpdateOrder(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := forceUpdateOrderService(id, aul, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := forceUpdateOrderService(ctx.Request().Context(), id, version, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -309,19 +332,26 @@ func forceUpdateOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/release [post]
 func releaseOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := releaseOrderService(id, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := releaseOrderService(ctx.Request().Context(), id, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -331,13 +361,15 @@ func releaseOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id        path      uint  true  "订单ID"
-// @Param        repairer  query     uint  true  "维修工ID"
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        repairer  query     uint                           true   "维修工ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
 // @Success      204       {object}  model.ApiJson{data=OrderJson}
 // @Failure      400       {object}  model.ApiJson{data=[]string}
 // @Failure      401       {object}  model.ApiJson{data=[]string}
 // @Failure      403       {object}  model.ApiJson{data=[]string}
 // @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
 // @Failure      422       {object}  model.ApiJson{data=[]string}
 // @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/assign [post]
@@ -345,7 +377,12 @@ func assignOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	repairer := util.ToUint(ctx.URLParamIntDefault("repairer", 0))
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := assignOrderService(id, repairer, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := assignOrderService(ctx.Request().Context(), id, version, repairer, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -355,19 +392,26 @@ func assignOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/selfassign [post]
 func selfAssignOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := assignOrderService(id, auth.User, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := assignOrderService(ctx.Request().Context(), id, version, auth.User, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -377,19 +421,26 @@ func selfAssignOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/complete [post]
 func completeOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := completeOrderService(id, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := completeOrderService(ctx.Request().Context(), id, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -399,19 +450,26 @@ func completeOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/cancel [post]
 func cancelOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := cancelOrderService(id, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := cancelOrderService(ctx.Request().Context(), id, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -421,19 +479,26 @@ func cancelOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/reject [post]
 func rejectOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := rejectOrderService(id, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := rejectOrderService(ctx.Request().Context(), id, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -443,19 +508,26 @@ func rejectOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/report [post]
 func reportOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := reportOrderService(id, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := reportOrderService(ctx.Request().Context(), id, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -465,19 +537,26 @@ func reportOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id   path      uint  true  "订单ID"
-// @Success      204  {object}  model.ApiJson{data=OrderJson}
-// @Failure      400  {object}  model.ApiJson{data=[]string}
-// @Failure      401  {object}  model.ApiJson{data=[]string}
-// @Failure      403  {object}  model.ApiJson{data=[]string}
-// @Failure      404  {object}  model.ApiJson{data=[]string}
-// @Failure      422  {object}  model.ApiJson{data=[]string}
-// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Param        id        path      uint                           true   "订单ID"
+// @Param        If-Match  header    string                         false  "订单版本号 订单已被修改时返回409"
+// @Success      204       {object}  model.ApiJson{data=OrderJson}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      409       {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/hold [post]
 func holdOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := holdOrderService(id, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := holdOrderService(ctx.Request().Context(), id, version, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -487,13 +566,15 @@ func holdOrder(ctx iris.Context) {
 // @Tags         order
 // @Accept       json
 // @Produce      json
-// @Param        id         path      uint  true  "订单ID"
-// @Param        appraisal  query     uint  true  "评价分数"
+// @Param        id         path      uint                           true   "订单ID"
+// @Param        appraisal  query     uint                           true   "评价分数"
+// @Param        If-Match   header    string                         false  "订单版本号 订单已被修改时返回409"
 // @Success      204        {object}  model.ApiJson{data=OrderJson}
 // @Failure      400        {object}  model.ApiJson{data=[]string}
 // @Failure      401        {object}  model.ApiJson{data=[]string}
 // @Failure      403        {object}  model.ApiJson{data=[]string}
 // @Failure      404        {object}  model.ApiJson{data=[]string}
+// @Failure      409        {object}  model.ApiJson{data=OrderJson}  "订单已被修改 返回当前订单"
 // @Failure      422        {object}  model.ApiJson{data=[]string}
 // @Failure      500        {object}  model.ApiJson{data=[]string}
 // @Router       /v1/order/{id}/appraise [post]
@@ -501,6 +582,11 @@ func appraiseOrder(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	appraisal := util.ToUint(ctx.URLParamIntDefault("appraisal", 0))
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := appraiseOrderService(id, appraisal, auth)
+	version, err := controller.ExtractIfMatch(ctx)
+	if err != nil {
+		ctx.Values().Set("response", model.ErrorInvalidData(err))
+		return
+	}
+	response := appraiseOrderService(ctx.Request().Context(), id, version, appraisal, auth)
 	ctx.Values().Set("response", response)
 }
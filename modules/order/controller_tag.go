@@ -1,6 +1,7 @@
 package order
 
 import (
+	"github.com/xaxys/maintainman/core/controller"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
@@ -24,7 +25,7 @@ import (
 func getTagByID(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getTagByIDService(id, auth)
+	response := getTagByIDService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -43,7 +44,7 @@ func getTagByID(ctx iris.Context) {
 // @Router       /v1/tag/sort [get]
 func getAllTagSorts(ctx iris.Context) {
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAllTagSortsService(auth)
+	response := getAllTagSortsService(ctx.Request().Context(), auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -64,7 +65,7 @@ func getAllTagSorts(ctx iris.Context) {
 func getAllTagsBySort(ctx iris.Context) {
 	name := ctx.Params().GetString("name")
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAllTagsBySortService(name, auth)
+	response := getAllTagsBySortService(ctx.Request().Context(), name, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -90,7 +91,7 @@ func createTag(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createTagService(aul, auth)
+	response := createTagService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -112,6 +113,71 @@ func createTag(ctx iris.Context) {
 func deleteTag(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := deleteTagService(id, auth)
+	response := deleteTagService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// getTrashTags godoc
+// @Summary      获取已删除的标签
+// @Description  获取已删除的标签 分页 默认按删除时间倒序
+// @Tags         tag
+// @Produce      json
+// @Param        order_by  query     string  false  "排序字段"
+// @Param        offset    query     uint    false  "偏移量"
+// @Param        limit     query     uint    false  "每页数据量"
+// @Success      200       {object}  model.ApiJson{data=model.Page{entries=[]TagJson}}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
+// @Router       /v1/tag/trash [get]
+func getTrashTags(ctx iris.Context) {
+	param := controller.ExtractPageParam(ctx)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getTrashTagsService(ctx.Request().Context(), param, auth)
+	ctx.Values().Set("response", response)
+}
+
+// restoreTag godoc
+// @Summary      恢复已删除的标签
+// @Description  通过ID恢复已删除的标签 不会恢复标签与订单的关联
+// @Tags         tag
+// @Produce      json
+// @Param        id   path      uint  true  "标签ID"
+// @Success      204  {object}  model.ApiJson{data=TagJson}
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/tag/trash/{id}/restore [post]
+func restoreTag(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := restoreTagService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// purgeTag godoc
+// @Summary      彻底删除标签
+// @Description  通过ID彻底删除已删除的标签 不可恢复
+// @Tags         tag
+// @Produce      json
+// @Param        id   path      uint  true  "标签ID"
+// @Success      204  {object}  model.ApiJson
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/tag/trash/{id} [delete]
+func purgeTag(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := purgeTagService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
@@ -1,7 +1,9 @@
 package order
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
@@ -9,8 +11,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func dbGetCommentCountByOrder(id uint) (uint, error) {
-	return txGetCommentCountByOrder(mctx.Database, id)
+func dbGetCommentCountByOrder(ctx context.Context, id uint) (uint, error) {
+	return txGetCommentCountByOrder(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetCommentCountByOrder(tx *gorm.DB, id uint) (uint, error) {
@@ -23,8 +25,8 @@ func txGetCommentCountByOrder(tx *gorm.DB, id uint) (uint, error) {
 	return uint(count), nil
 }
 
-func dbGetCommentByID(id uint) (*Comment, error) {
-	return txGetCommentByID(mctx.Database, id)
+func dbGetCommentByID(ctx context.Context, id uint) (*Comment, error) {
+	return txGetCommentByID(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetCommentByID(tx *gorm.DB, id uint) (*Comment, error) {
@@ -36,8 +38,8 @@ func txGetCommentByID(tx *gorm.DB, id uint) (*Comment, error) {
 	return comment, nil
 }
 
-func dbGetCommentsByOrder(id uint, param *model.PageParam) (comments []*Comment, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetCommentsByOrder(ctx context.Context, id uint, param *model.PageParam) (comments []*Comment, count uint, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if comments, count, err = txGetCommentsByOrder(tx, id, param); err != nil {
 			mctx.Logger.Warnf("GetCommentsByOrder: %v\n", err)
 		}
@@ -60,13 +62,16 @@ func txGetCommentsByOrder(tx *gorm.DB, oid uint, param *model.PageParam) (commen
 	return
 }
 
-func dbCreateComment(oid, uid uint, name string, aul *CreateCommentRequest) (comment *Comment, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbCreateComment(ctx context.Context, oid, uid uint, name string, aul *CreateCommentRequest) (comment *Comment, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if comment, err = txCreateComment(tx, oid, uid, name, aul); err != nil {
 			mctx.Logger.Warnf("CreateCommentErr: %v\n", err)
 		}
 		return err
 	})
+	if err == nil {
+		cacheDeleteOrder(oid)
+	}
 	return
 }
 
@@ -95,8 +100,18 @@ func txCreateComment(tx *gorm.DB, oid, uid uint, name string, aul *CreateComment
 	return
 }
 
-func dbDeleteComment(id uint) error {
-	return txDeleteComment(mctx.Database, id)
+func dbDeleteComment(ctx context.Context, id uint) error {
+	tx := mctx.Database.WithContext(ctx)
+	oids := []uint{}
+	if err := tx.Model(&Comment{}).Where("id = ?", id).Pluck("order_id", &oids).Error; err != nil {
+		mctx.Logger.Warnf("DeleteCommentErr: %v\n", err)
+		return err
+	}
+	if err := txDeleteComment(tx, id); err != nil {
+		return err
+	}
+	cacheDeleteOrder(oids...)
+	return nil
 }
 
 func txDeleteComment(tx *gorm.DB, id uint) error {
@@ -106,3 +121,51 @@ func txDeleteComment(tx *gorm.DB, id uint) error {
 	}
 	return nil
 }
+
+func dbGetTrashComments(ctx context.Context, param *model.PageParam) (comments []*Comment, count uint, err error) {
+	if comments, count, err = dao.TxGetTrash[Comment](mctx.ReadDatabase().WithContext(ctx), param); err != nil {
+		mctx.Logger.Warnf("GetTrashCommentsErr: %v\n", err)
+	}
+	return
+}
+
+func dbRestoreComment(ctx context.Context, id uint) (comment *Comment, err error) {
+	if comment, err = dao.TxRestore[Comment](mctx.Database.WithContext(ctx), id); err != nil {
+		mctx.Logger.Warnf("RestoreCommentErr: %v\n", err)
+		return
+	}
+	cacheDeleteOrder(comment.OrderID)
+	return
+}
+
+func dbPurgeComment(ctx context.Context, id uint) error {
+	tx := mctx.Database.WithContext(ctx)
+	oids := []uint{}
+	if err := tx.Unscoped().Model(&Comment{}).Where("id = ?", id).Pluck("order_id", &oids).Error; err != nil {
+		mctx.Logger.Warnf("PurgeCommentErr: %v\n", err)
+		return err
+	}
+	if err := dao.TxPurge[Comment](tx, id); err != nil {
+		mctx.Logger.Warnf("PurgeCommentErr: %v\n", err)
+		return err
+	}
+	cacheDeleteOrder(oids...)
+	return nil
+}
+
+func dbPurgeTrashComments(ctx context.Context, before time.Time) {
+	tx := mctx.Database.WithContext(ctx)
+	oids := []uint{}
+	if err := tx.Unscoped().Model(&Comment{}).Where("deleted_at < ?", before).Pluck("order_id", &oids).Error; err != nil {
+		mctx.Logger.Warnf("PurgeTrashCommentsErr: %v\n", err)
+		return
+	}
+	n, err := dao.TxPurgeBefore[Comment](tx, before)
+	if err != nil {
+		mctx.Logger.Warnf("PurgeTrashCommentsErr: %v\n", err)
+	}
+	cacheDeleteOrder(oids...)
+	if n > 0 {
+		mctx.Logger.Infof("Purged %d deleted comments", n)
+	}
+}
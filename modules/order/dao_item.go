@@ -1,7 +1,9 @@
 package order
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
@@ -9,8 +11,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func dbGetItemCount() (uint, error) {
-	return txGetItemCount(mctx.Database)
+func dbGetItemCount(ctx context.Context) (uint, error) {
+	return txGetItemCount(mctx.Database.WithContext(ctx))
 }
 
 func txGetItemCount(tx *gorm.DB) (uint, error) {
@@ -22,8 +24,8 @@ func txGetItemCount(tx *gorm.DB) (uint, error) {
 	return uint(count), nil
 }
 
-func dbGetItemByID(id uint) (*Item, error) {
-	return txGetItemByID(mctx.Database, id)
+func dbGetItemByID(ctx context.Context, id uint) (*Item, error) {
+	return txGetItemByID(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetItemByID(tx *gorm.DB, id uint) (*Item, error) {
@@ -35,8 +37,8 @@ func txGetItemByID(tx *gorm.DB, id uint) (*Item, error) {
 	return item, nil
 }
 
-func dbGetItemByName(name string) (*Item, error) {
-	return txGetItemByName(mctx.Database, name)
+func dbGetItemByName(ctx context.Context, name string) (*Item, error) {
+	return txGetItemByName(mctx.Database.WithContext(ctx), name)
 }
 
 func txGetItemByName(tx *gorm.DB, name string) (*Item, error) {
@@ -48,20 +50,20 @@ func txGetItemByName(tx *gorm.DB, name string) (*Item, error) {
 	return item, nil
 }
 
-func dbGetItemsByFuzzyName(name string) (items []*Item, err error) {
-	return TxGetItemsByFuzzyName(mctx.Database, name)
+func dbGetItemsByFuzzyName(ctx context.Context, name string) (items []*Item, err error) {
+	return TxGetItemsByFuzzyName(mctx.Database.WithContext(ctx), name)
 }
 
 func TxGetItemsByFuzzyName(tx *gorm.DB, name string) (items []*Item, err error) {
-	if err = dao.TxFilter(tx, "", 0, 0).Where("name like (?)", name).Find(&items).Error; err != nil {
+	if err = dao.TxLike(dao.TxFilter(tx, "", 0, 0), "name", name).Find(&items).Error; err != nil {
 		mctx.Logger.Warnf("GetItemByNameErr: %v\n", err)
 		return nil, err
 	}
 	return
 }
 
-func dbGetAllItems(param *model.PageParam) (items []*Item, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetAllItems(ctx context.Context, param *model.PageParam) (items []*Item, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if items, count, err = txGetAllItems(tx, param); err != nil {
 			mctx.Logger.Warnf("GetAllItemsErr: %v\n", err)
 		}
@@ -83,8 +85,8 @@ func txGetAllItems(tx *gorm.DB, param *model.PageParam) (items []*Item, count ui
 	return
 }
 
-func dbCreateItem(aul *CreateItemRequest, operator uint) (*Item, error) {
-	return TxCreateItem(mctx.Database, aul, operator)
+func dbCreateItem(ctx context.Context, aul *CreateItemRequest, operator uint) (*Item, error) {
+	return TxCreateItem(mctx.Database.WithContext(ctx), aul, operator)
 }
 
 func TxCreateItem(tx *gorm.DB, aul *CreateItemRequest, operator uint) (*Item, error) {
@@ -97,8 +99,8 @@ func TxCreateItem(tx *gorm.DB, aul *CreateItemRequest, operator uint) (*Item, er
 	return item, nil
 }
 
-func dbDeleteItem(id uint) error {
-	return TxDeleteItem(mctx.Database, id)
+func dbDeleteItem(ctx context.Context, id uint) error {
+	return TxDeleteItem(mctx.Database.WithContext(ctx), id)
 }
 
 func TxDeleteItem(tx *gorm.DB, id uint) error {
@@ -109,9 +111,9 @@ func TxDeleteItem(tx *gorm.DB, id uint) error {
 	return nil
 }
 
-func dbAddItem(itemlog *ItemLog, operator uint) (item *Item, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
-		if item, err = txAddItem(tx, itemlog, operator); err != nil {
+func dbAddItem(ctx context.Context, itemlog *ItemLog, version, operator uint) (item *Item, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if item, err = txAddItem(tx, itemlog, version, operator); err != nil {
 			mctx.Logger.Warnf("AddItemErr: %v\n", err)
 		}
 		return err
@@ -119,9 +121,14 @@ func dbAddItem(itemlog *ItemLog, operator uint) (item *Item, err error) {
 	return
 }
 
-func txAddItem(tx *gorm.DB, itemlog *ItemLog, operator uint) (item *Item, err error) {
+// txAddItem adds to the item if its version is still version, or the version
+// read, so that concurrent changes of the count are not lost.
+func txAddItem(tx *gorm.DB, itemlog *ItemLog, version, operator uint) (item *Item, err error) {
 	itemlog.CreatedBy = operator
-	if item, err = dbGetItemByID(itemlog.ItemID); err != nil {
+	if item, err = txGetItemByID(tx, itemlog.ItemID); err != nil {
+		return
+	}
+	if err = txBumpItemVersion(tx, item, version); err != nil {
 		return
 	}
 	item.Count += itemlog.ChangeNum
@@ -136,9 +143,9 @@ func txAddItem(tx *gorm.DB, itemlog *ItemLog, operator uint) (item *Item, err er
 	return
 }
 
-func dbConsumeItem(itemlog *ItemLog, operator uint) (item *Item, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
-		if item, err = txConsumeItem(tx, itemlog, operator); err != nil {
+func dbConsumeItem(ctx context.Context, itemlog *ItemLog, version, operator uint) (item *Item, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if item, err = txConsumeItem(tx, itemlog, version, operator); err != nil {
 			mctx.Logger.Warnf("ConsumeItemErr: %v\n", err)
 		}
 		return err
@@ -146,9 +153,14 @@ func dbConsumeItem(itemlog *ItemLog, operator uint) (item *Item, err error) {
 	return
 }
 
-func txConsumeItem(tx *gorm.DB, itemlog *ItemLog, operator uint) (item *Item, err error) {
+// txConsumeItem consumes the item if its version is still version, or the
+// version read, so that the count checked is not stale.
+func txConsumeItem(tx *gorm.DB, itemlog *ItemLog, version, operator uint) (item *Item, err error) {
 	itemlog.CreatedBy = operator
-	if item, err = dbGetItemByID(itemlog.ItemID); err != nil {
+	if item, err = txGetItemByID(tx, itemlog.ItemID); err != nil {
+		return
+	}
+	if err = txBumpItemVersion(tx, item, version); err != nil {
 		return
 	}
 	if item.Count < itemlog.ChangeNum && !orderConfig.GetBool("item_can_negative") {
@@ -166,9 +178,57 @@ func txConsumeItem(tx *gorm.DB, itemlog *ItemLog, operator uint) (item *Item, er
 	return
 }
 
+// txBumpItemVersion bumps the version of the item read, which must be
+// version if it is not 0, and keeps the version of item in step.
+func txBumpItemVersion(tx *gorm.DB, item *Item, version uint) error {
+	if err := dao.CheckVersion(version, item.Version); err != nil {
+		return err
+	}
+	if err := dao.TxBumpVersion[Item](tx, item.ID, item.Version); err != nil {
+		return err
+	}
+	item.Version++
+	return nil
+}
+
 func jsonToItem(item *CreateItemRequest) *Item {
 	return &Item{
 		Name:        item.Name,
 		Description: item.Discription,
 	}
 }
+
+func dbGetTrashItems(ctx context.Context, param *model.PageParam) (items []*Item, count uint, err error) {
+	if items, count, err = dao.TxGetTrash[Item](mctx.ReadDatabase().WithContext(ctx), param); err != nil {
+		mctx.Logger.Warnf("GetTrashItemsErr: %v\n", err)
+	}
+	return
+}
+
+func dbRestoreItem(ctx context.Context, id uint) (item *Item, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if item, err = dao.TxRestore[Item](tx, id); err != nil {
+			mctx.Logger.Warnf("RestoreItemErr: %v\n", err)
+		}
+		return err
+	})
+	return
+}
+
+func dbPurgeItem(ctx context.Context, id uint) error {
+	if err := dao.TxPurge[Item](mctx.Database.WithContext(ctx), id); err != nil {
+		mctx.Logger.Warnf("PurgeItemErr: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+func dbPurgeTrashItems(ctx context.Context, before time.Time) {
+	n, err := dao.TxPurgeBefore[Item](mctx.Database.WithContext(ctx), before)
+	if err != nil {
+		mctx.Logger.Warnf("PurgeTrashItemsErr: %v\n", err)
+	}
+	if n > 0 {
+		mctx.Logger.Infof("Purged %d deleted items", n)
+	}
+}
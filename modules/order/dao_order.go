@@ -1,6 +1,7 @@
 package order
 
 import (
+	"context"
 	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/util"
 
@@ -8,8 +9,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func dbGetOrderCount() (count uint, err error) {
-	return txGetOrderCount(mctx.Database)
+func dbGetOrderCount(ctx context.Context) (count uint, err error) {
+	return txGetOrderCount(mctx.Database.WithContext(ctx))
 }
 
 func txGetOrderCount(tx *gorm.DB) (uint, error) {
@@ -22,8 +23,8 @@ func txGetOrderCount(tx *gorm.DB) (uint, error) {
 }
 
 // GetSimpleOrderByID return no relative info
-func dbGetSimpleOrderByID(id uint) (*Order, error) {
-	return txGetSimpleOrderByID(mctx.Database, id)
+func dbGetSimpleOrderByID(ctx context.Context, id uint) (*Order, error) {
+	return txGetSimpleOrderByID(mctx.Database.WithContext(ctx), id)
 }
 
 // txGetSimpleOrderByID return no relative info
@@ -36,21 +37,34 @@ func txGetSimpleOrderByID(tx *gorm.DB, id uint) (*Order, error) {
 	return order, nil
 }
 
-func dbGetOrderByID(id uint) (*Order, error) {
-	return txGetOrderByID(mctx.Database, id)
+func dbGetOrderByID(ctx context.Context, id uint) (*Order, error) {
+	return txGetOrderByID(mctx.Database.WithContext(ctx), id)
+}
+
+// dbGetCachedOrderByID returns the order from the cache, which is deleted
+// on changes of the order, its comments and tags. Reads checking the
+// version before changes should use dbGetOrderByID.
+func dbGetCachedOrderByID(ctx context.Context, id uint) (*Order, error) {
+	return cacheGetOrderByID(ctx, id, func(ctx context.Context) (Order, error) {
+		order, err := txGetOrderByID(mctx.Database.WithContext(ctx), id)
+		if err != nil {
+			return Order{}, err
+		}
+		return *order, nil
+	})
 }
 
 func txGetOrderByID(tx *gorm.DB, id uint) (*Order, error) {
 	order := &Order{}
-	if err := tx.Preload("Tags").Preload("Comments").Preload("StatusList", "current = true").First(order, id).Error; err != nil {
+	if err := tx.Preload("Tags").Preload("Comments").Preload("StatusList", "current = ?", true).First(order, id).Error; err != nil {
 		mctx.Logger.Warnf("TxGetOrderByIDErr: %v\n", err)
 		return nil, err
 	}
 	return order, nil
 }
 
-func dbGetAllOrdersWithParam(aul *AllOrderRequest) (orders []*Order, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetAllOrdersWithParam(ctx context.Context, aul *AllOrderRequest) (orders []*Order, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if orders, count, err = txGetAllOrdersWithParam(tx, aul); err != nil {
 			mctx.Logger.Warnf("GetAllOrdersWithParam: %v\n", err)
 		}
@@ -75,7 +89,7 @@ func txGetAllOrdersWithParam(tx *gorm.DB, aul *AllOrderRequest) (orders []*Order
 		}
 	}
 	if aul.Title != "" {
-		tx = tx.Where("title LIKE ?", aul.Title)
+		tx = dao.TxLike(tx, "title", aul.Title)
 	}
 	cnt := int64(0)
 	if err = tx.Count(&cnt).Error; err != nil || cnt == 0 {
@@ -88,26 +102,29 @@ func txGetAllOrdersWithParam(tx *gorm.DB, aul *AllOrderRequest) (orders []*Order
 	return
 }
 
-func dbGetOrderWithLastStatus(id uint) (*Order, error) {
-	return txGetOrderWithLastStatus(mctx.Database, id)
+func dbGetOrderWithLastStatus(ctx context.Context, id uint) (*Order, error) {
+	return txGetOrderWithLastStatus(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetOrderWithLastStatus(tx *gorm.DB, id uint) (*Order, error) {
 	order := &Order{}
-	if err := tx.Preload("StatusList", "current = true").Model(order).Find(order, id).Error; err != nil {
+	if err := tx.Preload("StatusList", "current = ?", true).Model(order).Find(order, id).Error; err != nil {
 		mctx.Logger.Warnf("GetOrderWithLastStatusErr: %v\n", err)
 		return nil, err
 	}
 	return order, nil
 }
 
-func dbCreateOrder(aul *CreateOrderRequest, operator uint) (order *Order, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbCreateOrder(ctx context.Context, aul *CreateOrderRequest, operator uint) (order *Order, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if order, err = txCreateOrder(tx, aul, operator); err != nil {
 			mctx.Logger.Warnf("CreateOrderErr: %v\n", err)
 		}
 		return err
 	})
+	if err == nil {
+		cacheDeleteOrder(order.ID)
+	}
 	return
 }
 
@@ -122,7 +139,7 @@ func txCreateOrder(tx *gorm.DB, aul *CreateOrderRequest, operator uint) (order *
 	if err != nil {
 		return
 	}
-	if err = dbCheckTagsCongener(tags); err != nil {
+	if err = dbCheckTagsCongener(tx.Statement.Context, tags); err != nil {
 		return
 	}
 	if err = tx.Create(order).Error; err != nil {
@@ -139,17 +156,24 @@ func txCreateOrder(tx *gorm.DB, aul *CreateOrderRequest, operator uint) (order *
 	return
 }
 
-func dbUpdateOrder(id uint, aul *UpdateOrderRequest, operator uint) (order *Order, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
-		if order, err = TxUpdateOrder(tx, id, aul, operator); err != nil {
+func dbUpdateOrder(ctx context.Context, id, version uint, aul *UpdateOrderRequest, operator uint) (order *Order, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if order, err = TxUpdateOrder(tx, id, version, aul, operator); err != nil {
 			mctx.Logger.Warnf("UpdateOrderErr: %v\n", err)
 		}
 		return err
 	})
+	if err == nil {
+		cacheDeleteOrder(id)
+	}
 	return
 }
 
-func TxUpdateOrder(tx *gorm.DB, id uint, aul *UpdateOrderRequest, operator uint) (order *Order, err error) {
+// TxUpdateOrder updates the order if its version is still version, see dao.TxBumpVersion.
+func TxUpdateOrder(tx *gorm.DB, id, version uint, aul *UpdateOrderRequest, operator uint) (order *Order, err error) {
+	if err = dao.TxBumpVersion[Order](tx, id, version); err != nil {
+		return
+	}
 	order = &Order{}
 	copier.Copy(order, aul)
 	order.ID = id
@@ -176,14 +200,18 @@ func TxUpdateOrder(tx *gorm.DB, id uint, aul *UpdateOrderRequest, operator uint)
 	if err = tx.Preload("Tags").First(order, id).Error; err != nil {
 		return
 	}
-	if err = dbCheckTagsCongener(order.Tags); err != nil {
+	if err = dbCheckTagsCongener(tx.Statement.Context, order.Tags); err != nil {
 		return
 	}
 	return
 }
 
-func dbDeleteOrder(id uint) error {
-	return txDeleteOrder(mctx.Database, id)
+func dbDeleteOrder(ctx context.Context, id uint) error {
+	if err := txDeleteOrder(mctx.Database.WithContext(ctx), id); err != nil {
+		return err
+	}
+	cacheDeleteOrder(id)
+	return nil
 }
 
 func txDeleteOrder(tx *gorm.DB, id uint) error {
@@ -194,17 +222,25 @@ func txDeleteOrder(tx *gorm.DB, id uint) error {
 	return nil
 }
 
-func dbChangeOrderStatus(id uint, status *Status) (err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
-		if err = txChangeOrderStatus(tx, id, status); err != nil {
+func dbChangeOrderStatus(ctx context.Context, id, version uint, status *Status) (err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err = txChangeOrderStatus(tx, id, version, status); err != nil {
 			mctx.Logger.Warnf("ChangeOrderStatusErr: %v\n", err)
 		}
 		return err
 	})
+	if err == nil {
+		cacheDeleteOrder(id)
+	}
 	return
 }
 
-func txChangeOrderStatus(tx *gorm.DB, id uint, status *Status) error {
+// txChangeOrderStatus changes the status of the order if its version is
+// still version, so that the status checked before is not stale.
+func txChangeOrderStatus(tx *gorm.DB, id, version uint, status *Status) error {
+	if err := dao.TxBumpVersion[Order](tx, id, version); err != nil {
+		return err
+	}
 	order := &Order{}
 	order.ID = id
 	order.Status = status.Status
@@ -235,8 +271,12 @@ func txChangeOrderStatus(tx *gorm.DB, id uint, status *Status) error {
 	return nil
 }
 
-func dbChangeOrderAllowComment(id uint, allow bool) error {
-	return txChangeOrderAllowComment(mctx.Database, id, allow)
+func dbChangeOrderAllowComment(ctx context.Context, id uint, allow bool) error {
+	if err := txChangeOrderAllowComment(mctx.Database.WithContext(ctx), id, allow); err != nil {
+		return err
+	}
+	cacheDeleteOrder(id)
+	return nil
 }
 
 func txChangeOrderAllowComment(tx *gorm.DB, id uint, allow bool) error {
@@ -250,17 +290,20 @@ func txChangeOrderAllowComment(tx *gorm.DB, id uint, allow bool) error {
 	return nil
 }
 
-func dbAppraiseOrder(id, appraisal, operator uint) (err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
-		if err := txAppraiseOrder(tx, id, appraisal, operator); err != nil {
+func dbAppraiseOrder(ctx context.Context, id, version, appraisal, operator uint) (err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err = txAppraiseOrder(tx, id, version, appraisal, operator); err != nil {
 			mctx.Logger.Warnf("AppraiseOrderErr: %v\n", err)
 		}
 		return err
 	})
+	if err == nil {
+		cacheDeleteOrder(id)
+	}
 	return
 }
 
-func txAppraiseOrder(tx *gorm.DB, id, appraisal, operator uint) (err error) {
+func txAppraiseOrder(tx *gorm.DB, id, version, appraisal, operator uint) (err error) {
 	order := &Order{}
 	order.ID = id
 	order.Appraisal = appraisal
@@ -271,7 +314,7 @@ func txAppraiseOrder(tx *gorm.DB, id, appraisal, operator uint) (err error) {
 		return
 	}
 	status := NewStatusAppraised(operator)
-	if err = txChangeOrderStatus(tx, id, status); err != nil {
+	if err = txChangeOrderStatus(tx, id, version, status); err != nil {
 		return
 	}
 	return
@@ -1,6 +1,7 @@
 package order
 
 import (
+	"context"
 	"time"
 
 	"github.com/xaxys/maintainman/core/dao"
@@ -10,8 +11,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func dbGetOrderByRepairer(id uint, json *RepairerOrderRequest) (orders []*Order, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetOrderByRepairer(ctx context.Context, id uint, json *RepairerOrderRequest) (orders []*Order, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if orders, count, err = txGetOrderByRepairer(tx, id, json); err != nil {
 			mctx.Logger.Warnf("GetOrderByRepairer: %v\n", err)
 		}
@@ -32,7 +33,7 @@ func txGetOrderByRepairer(tx *gorm.DB, id uint, json *RepairerOrderRequest) (ord
 	}
 	if len(json.Tags) > 0 {
 		if json.Disjunctive {
-			tx = tx.Where("id IN (?)", mctx.Database.Table("order_tags").Select("order_id").Where("tag_id IN (?)", json.Tags))
+			tx = tx.Where("statuses.order_id IN (?)", mctx.Database.Table("order_tags").Select("order_id").Where("tag_id IN (?)", json.Tags))
 		} else {
 			for _, tag := range json.Tags {
 				tx = tx.Where("EXISTS (?)", mctx.Database.Table("order_tags").Select("order_id").Where("tag_id = (?)", tag).Where("order_id = statuses.order_id"))
@@ -51,8 +52,8 @@ func txGetOrderByRepairer(tx *gorm.DB, id uint, json *RepairerOrderRequest) (ord
 	return
 }
 
-func dbGetStatusByOrder(id uint) (statuses []*Status, err error) {
-	return txGetStatusByOrder(mctx.Database, id)
+func dbGetStatusByOrder(ctx context.Context, id uint) (statuses []*Status, err error) {
+	return txGetStatusByOrder(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetStatusByOrder(tx *gorm.DB, id uint) (statuses []*Status, err error) {
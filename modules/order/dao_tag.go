@@ -1,14 +1,19 @@
 package order
 
 import (
+	"context"
 	"fmt"
+	"time"
+
+	"github.com/xaxys/maintainman/core/dao"
+	"github.com/xaxys/maintainman/core/model"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func dbGetTagByID(id uint) (*Tag, error) {
-	return txGetTagByID(mctx.Database, id)
+func dbGetTagByID(ctx context.Context, id uint) (*Tag, error) {
+	return txGetTagByID(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetTagByID(tx *gorm.DB, id uint) (*Tag, error) {
@@ -20,8 +25,8 @@ func txGetTagByID(tx *gorm.DB, id uint) (*Tag, error) {
 	return tag, nil
 }
 
-func dbGetTagsByIDs(ids []uint) (tags []*Tag, err error) {
-	return txGetTagsByIDs(mctx.Database, ids)
+func dbGetTagsByIDs(ctx context.Context, ids []uint) (tags []*Tag, err error) {
+	return txGetTagsByIDs(mctx.Database.WithContext(ctx), ids)
 }
 
 func txGetTagsByIDs(tx *gorm.DB, ids []uint) (tags []*Tag, err error) {
@@ -35,8 +40,8 @@ func txGetTagsByIDs(tx *gorm.DB, ids []uint) (tags []*Tag, err error) {
 	return tags, nil
 }
 
-func dbGetAllTagSorts() ([]string, error) {
-	return txGetAllTagSorts(mctx.Database)
+func dbGetAllTagSorts(ctx context.Context) ([]string, error) {
+	return txGetAllTagSorts(mctx.Database.WithContext(ctx))
 }
 
 func txGetAllTagSorts(tx *gorm.DB) (sorts []string, err error) {
@@ -46,8 +51,8 @@ func txGetAllTagSorts(tx *gorm.DB) (sorts []string, err error) {
 	return
 }
 
-func dbGetAllTagsBySort(sort string) ([]*Tag, error) {
-	return txGetAllTagsBySort(mctx.Database, sort)
+func dbGetAllTagsBySort(ctx context.Context, sort string) ([]*Tag, error) {
+	return txGetAllTagsBySort(mctx.Database.WithContext(ctx), sort)
 }
 
 func txGetAllTagsBySort(tx *gorm.DB, sort string) (tags []*Tag, err error) {
@@ -60,8 +65,8 @@ func txGetAllTagsBySort(tx *gorm.DB, sort string) (tags []*Tag, err error) {
 	return
 }
 
-func dbCreateTag(aul *CreateTagRequest, operator uint) (*Tag, error) {
-	return txCreateTag(mctx.Database, aul, operator)
+func dbCreateTag(ctx context.Context, aul *CreateTagRequest, operator uint) (*Tag, error) {
+	return txCreateTag(mctx.Database.WithContext(ctx), aul, operator)
 }
 
 func txCreateTag(tx *gorm.DB, aul *CreateTagRequest, operator uint) (tag *Tag, err error) {
@@ -77,8 +82,18 @@ func txCreateTag(tx *gorm.DB, aul *CreateTagRequest, operator uint) (tag *Tag, e
 	return
 }
 
-func dbUpdateTag(id uint, aul *CreateTagRequest, operator uint) (*Tag, error) {
-	return txUpdateTag(mctx.Database, id, aul, operator)
+func dbUpdateTag(ctx context.Context, id uint, aul *CreateTagRequest, operator uint) (*Tag, error) {
+	tx := mctx.Database.WithContext(ctx)
+	oids, err := txGetOrderIDsByTag(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := txUpdateTag(tx, id, aul, operator)
+	if err != nil {
+		return nil, err
+	}
+	cacheDeleteOrder(oids...)
+	return tag, nil
 }
 
 func txUpdateTag(tx *gorm.DB, id uint, aul *CreateTagRequest, operator uint) (tag *Tag, err error) {
@@ -91,8 +106,25 @@ func txUpdateTag(tx *gorm.DB, id uint, aul *CreateTagRequest, operator uint) (ta
 	return
 }
 
-func dbDeleteTag(id uint) error {
-	return txDeleteTag(mctx.Database, id)
+func dbDeleteTag(ctx context.Context, id uint) error {
+	tx := mctx.Database.WithContext(ctx)
+	oids, err := txGetOrderIDsByTag(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := txDeleteTag(tx, id); err != nil {
+		return err
+	}
+	cacheDeleteOrder(oids...)
+	return nil
+}
+
+// txGetOrderIDsByTag returns the orders with the tags, whose cache is deleted on changes of the tags.
+func txGetOrderIDsByTag(tx *gorm.DB, tids ...uint) (ids []uint, err error) {
+	if err = tx.Table("order_tags").Where("tag_id IN ?", tids).Pluck("order_id", &ids).Error; err != nil {
+		mctx.Logger.Warnf("GetOrderIDsByTagErr: %v\n", err)
+	}
+	return
 }
 
 func txDeleteTag(tx *gorm.DB, id uint) (err error) {
@@ -102,7 +134,7 @@ func txDeleteTag(tx *gorm.DB, id uint) (err error) {
 	return
 }
 
-func dbCheckTagsCongener(tags []*Tag) error {
+func dbCheckTagsCongener(ctx context.Context, tags []*Tag) error {
 	count := map[string]uint{}
 	min := map[string]uint{}
 	for _, t := range tags {
@@ -123,3 +155,60 @@ func jsonToTag(aul *CreateTagRequest) *Tag {
 		Congener: aul.Congener,
 	}
 }
+
+func dbGetTrashTags(ctx context.Context, param *model.PageParam) (tags []*Tag, count uint, err error) {
+	if tags, count, err = dao.TxGetTrash[Tag](mctx.ReadDatabase().WithContext(ctx), param); err != nil {
+		mctx.Logger.Warnf("GetTrashTagsErr: %v\n", err)
+	}
+	return
+}
+
+// dbRestoreTag restores the tag only, as it was removed from orders on deletion.
+func dbRestoreTag(ctx context.Context, id uint) (tag *Tag, err error) {
+	tx := mctx.Database.WithContext(ctx)
+	if tag, err = dao.TxRestore[Tag](tx, id); err != nil {
+		mctx.Logger.Warnf("RestoreTagErr: %v\n", err)
+		return
+	}
+	oids, err := txGetOrderIDsByTag(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	cacheDeleteOrder(oids...)
+	return
+}
+
+func dbPurgeTag(ctx context.Context, id uint) error {
+	tx := mctx.Database.WithContext(ctx)
+	oids, err := txGetOrderIDsByTag(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := dao.TxPurge[Tag](tx, id, clause.Associations); err != nil {
+		mctx.Logger.Warnf("PurgeTagErr: %v\n", err)
+		return err
+	}
+	cacheDeleteOrder(oids...)
+	return nil
+}
+
+func dbPurgeTrashTags(ctx context.Context, before time.Time) {
+	tx := mctx.Database.WithContext(ctx)
+	tids := []uint{}
+	if err := tx.Unscoped().Model(&Tag{}).Where("deleted_at < ?", before).Pluck("id", &tids).Error; err != nil {
+		mctx.Logger.Warnf("PurgeTrashTagsErr: %v\n", err)
+		return
+	}
+	oids, err := txGetOrderIDsByTag(tx, tids...)
+	if err != nil {
+		return
+	}
+	n, err := dao.TxPurgeBefore[Tag](tx, before, clause.Associations)
+	if err != nil {
+		mctx.Logger.Warnf("PurgeTrashTagsErr: %v\n", err)
+	}
+	cacheDeleteOrder(oids...)
+	if n > 0 {
+		mctx.Logger.Infof("Purged %d deleted tags", n)
+	}
+}
@@ -1,11 +1,17 @@
 package order
 
 import (
+	"context"
+	"time"
+
+	"github.com/xaxys/maintainman/core/config"
 	"github.com/xaxys/maintainman/core/middleware"
 	"github.com/xaxys/maintainman/core/module"
 	"github.com/xaxys/maintainman/core/rbac"
 
+	"github.com/go-co-op/gocron"
 	"github.com/kataras/iris/v12"
+	"github.com/spf13/viper"
 )
 
 var Module module.Module
@@ -13,7 +19,7 @@ var Module module.Module
 func init() {
 	Module = module.Module{
 		ModuleName:    "order",
-		ModuleVersion: "1.1.0",
+		ModuleVersion: "1.3.0",
 		ModuleConfig:  orderConfig,
 		ModuleDepends: []string{
 			"user",
@@ -27,19 +33,7 @@ func init() {
 				&Item{},
 				&ItemLog{},
 			},
-		},
-		ModuleExport: map[string]any{
-			"wechat.status.tmpl":     "",
-			"wechat.status.order":    "",
-			"wechat.status.title":    "",
-			"wechat.status.status":   "",
-			"wechat.status.time":     "",
-			"wechat.status.other":    "",
-			"wechat.comment.tmpl":    "",
-			"wechat.comment.title":   "",
-			"wechat.comment.name":    "",
-			"wechat.comment.messgae": "",
-			"wechat.comment.time":    "",
+			"orm.migration": migrations,
 		},
 		ModulePerm: map[string]string{
 			"order.view":        "查看我的订单",
@@ -63,39 +57,56 @@ func init() {
 			"comment.viewall":   "查看所有评论",
 			"comment.createall": "创建所有评论",
 			"comment.deleteall": "删除所有评论",
+			"comment.trash":     "查看已删除的评论",
+			"comment.restore":   "恢复已删除的评论",
+			"comment.purge":     "彻底删除评论",
 			"tag.create":        "创建标签",
 			"tag.delete":        "删除标签",
 			"tag.view":          "查看标签",
 			"tag.add":           "添加标签",
+			"tag.trash":         "查看已删除的标签",
+			"tag.restore":       "恢复已删除的标签",
+			"tag.purge":         "彻底删除标签",
 			"item.create":       "创建零件",
 			"item.delete":       "删除零件",
 			"item.viewall":      "查看所有零件",
 			"item.update":       "更新零件",
 			"item.consume":      "消耗零件",
+			"item.trash":        "查看已删除的零件",
+			"item.restore":      "恢复已删除的零件",
+			"item.purge":        "彻底删除零件",
 		},
 		EntryPoint: entry,
+		StartPoint: start,
+		StopPoint:  stop,
 	}
 }
 
-var mctx *module.ModuleContext
+var (
+	mctx              *module.ModuleContext
+	autoAppraiseJob   *gocron.Job
+	autoAppraisePurge string
+)
 
 func entry(ctx *module.ModuleContext) {
 	mctx = ctx
+	initOrderCache()
 
-	Module.ModuleExport["wechat.status.tmpl"] = orderConfig.GetString("notify.wechat.status.tmpl")
-	Module.ModuleExport["wechat.status.order"] = orderConfig.GetString("notify.wechat.status.order")
-	Module.ModuleExport["wechat.status.title"] = orderConfig.GetString("notify.wechat.status.title")
-	Module.ModuleExport["wechat.status.status"] = orderConfig.GetString("notify.wechat.status.status")
-	Module.ModuleExport["wechat.status.time"] = orderConfig.GetString("notify.wechat.status.time")
-	Module.ModuleExport["wechat.status.other"] = orderConfig.GetString("notify.wechat.status.other")
-
-	Module.ModuleExport["wechat.comment.tmpl"] = orderConfig.GetString("notify.wechat.comment.tmpl")
-	Module.ModuleExport["wechat.comment.title"] = orderConfig.GetString("notify.wechat.comment.title")
-	Module.ModuleExport["wechat.comment.name"] = orderConfig.GetString("notify.wechat.comment.name")
-	Module.ModuleExport["wechat.comment.message"] = orderConfig.GetString("notify.wechat.comment.message")
-	Module.ModuleExport["wechat.comment.time"] = orderConfig.GetString("notify.wechat.comment.time")
+	module.Provide[OrderReader](mctx.Registry, orderReader{})
+	module.Provide[WechatTemplates](mctx.Registry, wechatTemplates{})
 
-	mctx.Scheduler.Every(orderConfig.GetString("appraise.purge")).SingletonMode().Do(autoAppraiseOrderService)
+	scheduleAutoAppraise(orderConfig.Viper())
+	mctx.ScheduleTrashPurge(func(ctx context.Context, before time.Time) {
+		dbPurgeTrashComments(ctx, before)
+		dbPurgeTrashTags(ctx, before)
+		dbPurgeTrashItems(ctx, before)
+	})
+	config.OnConfigChange(orderConfig, func(v *viper.Viper) {
+		// not scheduled when the module is disabled at runtime
+		if autoAppraiseJob != nil {
+			scheduleAutoAppraise(v)
+		}
+	})
 
 	mctx.Route.Get("/wxtmpl/status", getWxStatusTemplateID)
 	mctx.Route.Get("/wxtmpl/comment", getWxCommentTemplateID)
@@ -141,6 +152,9 @@ func entry(ctx *module.ModuleContext) {
 		tag.Get("/sort/{name:string}", middleware.LoginInterceptor, getAllTagsBySort)
 		tag.Post("/", rbac.PermInterceptor("tag.create"), createTag)
 		tag.Delete("/{id:uint}", rbac.PermInterceptor("tag.delete"), deleteTag)
+		tag.Get("/trash", rbac.PermInterceptor("tag.trash"), getTrashTags)
+		tag.Post("/trash/{id:uint}/restore", rbac.PermInterceptor("tag.restore"), restoreTag)
+		tag.Delete("/trash/{id:uint}", rbac.PermInterceptor("tag.purge"), purgeTag)
 	})
 
 	mctx.Route.PartyFunc("/item", func(item iris.Party) {
@@ -151,14 +165,49 @@ func entry(ctx *module.ModuleContext) {
 		item.Post("/", rbac.PermInterceptor("item.create"), createItem)
 		item.Post("/{id:uint}", rbac.PermInterceptor("item.update"), addItem)
 		item.Delete("/{id:uint}", rbac.PermInterceptor("item.delete"), deleteItem)
+		item.Get("/trash", rbac.PermInterceptor("item.trash"), getTrashItems)
+		item.Post("/trash/{id:uint}/restore", rbac.PermInterceptor("item.restore"), restoreItem)
+		item.Delete("/trash/{id:uint}", rbac.PermInterceptor("item.purge"), purgeItem)
 	})
 
 	mctx.Route.PartyFunc("/comment", func(comment iris.Party) {
 		comment.Delete("/{id:uint}", rbac.PermInterceptor("comment.delete"), deleteComment)
 		comment.Delete("/{id:uint}/force", rbac.PermInterceptor("comment.deleteall"), forceDeleteComment)
+		comment.Get("/trash", rbac.PermInterceptor("comment.trash"), getTrashComments)
+		comment.Post("/trash/{id:uint}/restore", rbac.PermInterceptor("comment.restore"), restoreComment)
+		comment.Delete("/trash/{id:uint}", rbac.PermInterceptor("comment.purge"), purgeComment)
 	})
 }
 
+func start(ctx *module.ModuleContext) {
+	scheduleAutoAppraise(orderConfig.Viper())
+}
+
+func stop(ctx *module.ModuleContext) {
+	if autoAppraiseJob != nil {
+		mctx.Scheduler.RemoveByReference(autoAppraiseJob)
+		autoAppraiseJob = nil
+	}
+}
+
+// scheduleAutoAppraise (re)schedules the auto appraise job with appraise.purge.
+func scheduleAutoAppraise(v *viper.Viper) {
+	purge := v.GetString("appraise.purge")
+	if autoAppraiseJob != nil {
+		if autoAppraisePurge == purge {
+			return
+		}
+		mctx.Scheduler.RemoveByReference(autoAppraiseJob)
+	}
+	job, err := mctx.Scheduler.Every(purge).SingletonMode().Do(autoAppraiseOrderService)
+	if err != nil {
+		mctx.Logger.Errorf("Schedule auto appraise failed: %v", err)
+		return
+	}
+	autoAppraiseJob = job
+	autoAppraisePurge = purge
+}
+
 // getWxStatusTemplateID godoc
 // @Summary      获取 微信 订单状态提醒 模板ID
 // @Description  获取 微信 订单状态提醒 模板ID
@@ -0,0 +1,35 @@
+package order
+
+import (
+	"github.com/xaxys/maintainman/core/database"
+
+	"gorm.io/gorm"
+)
+
+var migrations = []*database.Migration{
+	{
+		Version: "1.3.0",
+		Name:    "add versions of orders and items",
+		Up: func(tx *gorm.DB) error {
+			// existing orders and items start from version 1
+			for _, model := range []any{&Order{}, &Item{}} {
+				if tx.Migrator().HasTable(model) && !tx.Migrator().HasColumn(model, "Version") {
+					if err := tx.Migrator().AddColumn(model, "Version"); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, model := range []any{&Order{}, &Item{}} {
+				if tx.Migrator().HasColumn(model, "Version") {
+					if err := tx.Migrator().DropColumn(model, "Version"); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+}
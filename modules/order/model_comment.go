@@ -27,5 +27,6 @@ type CommentJson struct {
 	UserName    string `json:"user_name"`
 	SequenceNum uint   `json:"sequence_num"` // 发言在该订单内的序号
 	Content     string `json:"content"`
-	CreatedAt   int64  `json:"created_at"` // unix timestamp in seconds (UTC)
+	CreatedAt   int64  `json:"created_at"`           // unix timestamp in seconds (UTC)
+	DeletedAt   int64  `json:"deleted_at,omitempty"` // unix timestamp in seconds (UTC), 仅回收站中的记录有
 }
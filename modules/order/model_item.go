@@ -9,6 +9,7 @@ type Item struct {
 	Price       float64    `gorm:"not null; default:0; comment:物品总价值"`
 	Income      float64    `gorm:"not null; default:0; comment:维修收入"`
 	Count       int        `gorm:"not null; default:0; comment:物品数量"`
+	Version     uint       `gorm:"not null; default:1; comment:版本号 每次修改加一"`
 	ItemLogs    []*ItemLog `gorm:"foreignkey:ItemID"`
 }
 
@@ -24,11 +25,13 @@ type ItemInfoJson struct {
 	Price       float64        `json:"price"`
 	Income      float64        `json:"income"`
 	Count       int            `json:"count"`
+	Version     uint           `json:"version"` // 版本号 用于 If-Match 条件更新
 	ItemLogs    []*ItemLogJson `json:"item_log"`
-	CreatedAt   int64          `json:"created_at"` // unix timestamp in seconds (UTC)
-	UpdatedAt   int64          `json:"updated_at"` // unix timestamp in seconds (UTC)
-	CreatedBy   uint           `json:"created_by"` // 创建用户ID
-	UpdatedBy   uint           `json:"updated_by"` // 更新用户ID
+	CreatedAt   int64          `json:"created_at"`           // unix timestamp in seconds (UTC)
+	UpdatedAt   int64          `json:"updated_at"`           // unix timestamp in seconds (UTC)
+	CreatedBy   uint           `json:"created_by"`           // 创建用户ID
+	UpdatedBy   uint           `json:"updated_by"`           // 更新用户ID
+	DeletedAt   int64          `json:"deleted_at,omitempty"` // unix timestamp in seconds (UTC), 仅回收站中的记录有
 }
 
 type ItemJson struct {
@@ -36,4 +39,5 @@ type ItemJson struct {
 	Name        string `json:"name"`
 	Description string `json:"discription"`
 	Count       int    `json:"count"`
+	Version     uint   `json:"version"` // 版本号 用于 If-Match 条件更新
 }
@@ -21,6 +21,7 @@ type Order struct {
 	ItemLogs     []*ItemLog `gorm:"foreignkey:OrderID"`
 	Tags         []*Tag     `gorm:"many2many:order_tags;"`
 	Appraisal    uint       `gorm:"not null; size:5; default:0; comment:评价 0:未评价 1-5:已评价"`
+	Version      uint       `gorm:"not null; default:1; comment:版本号 每次修改加一"`
 }
 
 type CreateOrderRequest struct {
@@ -81,6 +82,7 @@ type OrderJson struct {
 	CreatedAt    int64          `json:"created_at"` // unix timestamp in seconds (UTC)
 	UpdatedAt    int64          `json:"updated_at"` // unix timestamp in seconds (UTC)
 	Appraisal    uint           `json:"appraisal"`
+	Version      uint           `json:"version"` // 版本号 用于 If-Match 条件更新
 	Tags         []*TagJson     `json:"tags,omitempty"`
 	Comments     []*CommentJson `json:"comments,omitempty"`
 }
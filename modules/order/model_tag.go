@@ -19,9 +19,10 @@ type CreateTagRequest struct {
 }
 
 type TagJson struct {
-	ID       uint   `json:"id"`
-	Sort     string `json:"sort"`
-	Name     string `json:"name"`
-	Level    uint   `json:"level"`
-	Congener uint   `json:"congener"` // 允许与同Sort的Tag共存的数量 0:不限 n:只允许n个(含自身)
+	ID        uint   `json:"id"`
+	Sort      string `json:"sort"`
+	Name      string `json:"name"`
+	Level     uint   `json:"level"`
+	Congener  uint   `json:"congener"`             // 允许与同Sort的Tag共存的数量 0:不限 n:只允许n个(含自身)
+	DeletedAt int64  `json:"deleted_at,omitempty"` // unix timestamp in seconds (UTC), 仅回收站中的记录有
 }
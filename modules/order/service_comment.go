@@ -1,14 +1,19 @@
 package order
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
+	"github.com/xaxys/maintainman/core/audit"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
+
+	"gorm.io/gorm"
 )
 
-func getCommentsByOrderService(id uint, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetOrderWithLastStatus(id)
+func getCommentsByOrderService(ctx context.Context, id uint, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetOrderWithLastStatus(ctx, id)
 	if err != nil || order.ID == 0 {
 		return model.ErrorNotFound(err)
 	}
@@ -18,12 +23,12 @@ func getCommentsByOrderService(id uint, param *model.PageParam, auth *model.Auth
 			return model.ErrorNoPermissions(fmt.Errorf("您不是订单的创建者或指派人，不能查看评论"))
 		}
 	}
-	return forceGetCommentsByOrderService(id, param, auth)
+	return forceGetCommentsByOrderService(ctx, id, param, auth)
 }
 
-func forceGetCommentsByOrderService(id uint, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+func forceGetCommentsByOrderService(ctx context.Context, id uint, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
 	param.OrderBy = util.NotEmpty(param.OrderBy, "id desc")
-	comments, count, err := dbGetCommentsByOrder(id, param)
+	comments, count, err := dbGetCommentsByOrder(ctx, id, param)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -31,8 +36,8 @@ func forceGetCommentsByOrderService(id uint, param *model.PageParam, auth *model
 	return model.SuccessPaged(cs, count, "获取成功")
 }
 
-func createCommentService(id uint, aul *CreateCommentRequest, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetOrderWithLastStatus(id)
+func createCommentService(ctx context.Context, id uint, aul *CreateCommentRequest, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetOrderWithLastStatus(ctx, id)
 	if err != nil || order.ID == 0 {
 		return model.ErrorNotFound(err)
 	}
@@ -45,40 +50,80 @@ func createCommentService(id uint, aul *CreateCommentRequest, auth *model.AuthIn
 	if order.AllowComment == CommentDisallow {
 		return model.ErrorNoPermissions(fmt.Errorf("该订单不允许评论"))
 	}
-	return forceCreateCommentService(id, aul, auth)
+	return forceCreateCommentService(ctx, id, aul, auth)
 }
 
-func forceCreateCommentService(id uint, aul *CreateCommentRequest, auth *model.AuthInfo) *model.ApiJson {
+func forceCreateCommentService(ctx context.Context, id uint, aul *CreateCommentRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	comment, err := dbCreateComment(id, auth.User, auth.Name, aul)
+	comment, err := dbCreateComment(ctx, id, auth.User, auth.Name, aul)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
-	go mctx.EventBus.Emit("order:update:comment", id, comment.ID)
+	audit.Record(mctx.EventBus, auth, "comment", comment.ID, audit.ActionCreate, nil, commentToJson(comment))
+	mctx.Emit("order:update:comment", id, comment.ID)
 	return model.SuccessCreate(commentToJson(comment), "创建成功")
 }
 
-func DeleteCommentService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	comment, err := dbGetCommentByID(id)
+func DeleteCommentService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	comment, err := dbGetCommentByID(ctx, id)
 	if err != nil {
 		return model.ErrorNotFound(err)
 	}
 	if comment.UserID != auth.User {
 		return model.ErrorNoPermissions(fmt.Errorf("操作人不是评论创建者"))
 	}
-	return forceDeleteCommentService(id, auth)
+	return forceDeleteCommentService(ctx, id, auth)
 }
 
-func forceDeleteCommentService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	err := dbDeleteComment(id)
+func forceDeleteCommentService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	comment, _ := dbGetCommentByID(ctx, id)
+	err := dbDeleteComment(ctx, id)
 	if err != nil {
 		return model.ErrorDeleteDatabase(err)
 	}
+	if comment != nil {
+		audit.Record(mctx.EventBus, auth, "comment", id, audit.ActionDelete, commentToJson(comment), nil)
+	}
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
+func getTrashCommentsService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+	if err := util.Validator.Struct(param); err != nil {
+		return model.ErrorValidation(err)
+	}
+	comments, count, err := dbGetTrashComments(ctx, param)
+	if err != nil {
+		return model.ErrorQueryDatabase(err)
+	}
+	js := util.TransSlice(comments, commentToJson)
+	return model.SuccessPaged(js, count, "获取成功")
+}
+
+func restoreCommentService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	comment, err := dbRestoreComment(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorUpdateDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "comment", id, audit.ActionRestore, nil, commentToJson(comment))
+	return model.SuccessUpdate(commentToJson(comment), "恢复成功")
+}
+
+func purgeCommentService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	if err := dbPurgeComment(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorDeleteDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "comment", id, audit.ActionPurge, nil, nil)
+	return model.SuccessUpdate(nil, "彻底删除成功")
+}
+
 func commentToJson(comment *Comment) *CommentJson {
 	if comment == nil {
 		return nil
@@ -91,6 +136,7 @@ func commentToJson(comment *Comment) *CommentJson {
 			SequenceNum: comment.SequenceNum,
 			Content:     comment.Content,
 			CreatedAt:   comment.CreatedAt.Unix(),
+			DeletedAt:   model.DeletedUnix(comment.DeletedAt),
 		}
 	}
 }
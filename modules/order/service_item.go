@@ -1,17 +1,20 @@
 package order
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
 	"gorm.io/gorm"
 )
 
-func getItemByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	item, err := dbGetItemByID(id)
+func getItemByIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	item, err := dbGetItemByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -21,8 +24,8 @@ func getItemByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(itemToJson(item), "获取成功")
 }
 
-func getItemByNameService(name string, auth *model.AuthInfo) *model.ApiJson {
-	item, err := dbGetItemByName(name)
+func getItemByNameService(ctx context.Context, name string, auth *model.AuthInfo) *model.ApiJson {
+	item, err := dbGetItemByName(ctx, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -32,8 +35,8 @@ func getItemByNameService(name string, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(itemToJson(item), "获取成功")
 }
 
-func getItemsByFuzzyNameService(name string, auth *model.AuthInfo) *model.ApiJson {
-	items, err := dbGetItemsByFuzzyName(name)
+func getItemsByFuzzyNameService(ctx context.Context, name string, auth *model.AuthInfo) *model.ApiJson {
+	items, err := dbGetItemsByFuzzyName(ctx, name)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -41,11 +44,11 @@ func getItemsByFuzzyNameService(name string, auth *model.AuthInfo) *model.ApiJso
 	return model.Success(is, "获取成功")
 }
 
-func getAllItemsService(param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+func getAllItemsService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(param); err != nil {
 		return model.ErrorValidation(err)
 	}
-	items, count, err := dbGetAllItems(param)
+	items, count, err := dbGetAllItems(ctx, param)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -53,41 +56,48 @@ func getAllItemsService(param *model.PageParam, auth *model.AuthInfo) *model.Api
 	return model.SuccessPaged(is, count, "获取成功")
 }
 
-func createItemService(aul *CreateItemRequest, auth *model.AuthInfo) *model.ApiJson {
+func createItemService(ctx context.Context, aul *CreateItemRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	item, err := dbCreateItem(aul, auth.User)
+	item, err := dbCreateItem(ctx, aul, auth.User)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "item", item.ID, audit.ActionCreate, nil, itemToInfoJson(item))
 	return model.SuccessCreate(itemToInfoJson(item), "创建成功")
 }
 
-func deleteItemService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	if err := dbDeleteItem(id); err != nil {
+func deleteItemService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	item, _ := dbGetItemByID(ctx, id)
+	if err := dbDeleteItem(ctx, id); err != nil {
 		return model.ErrorDeleteDatabase(err)
 	}
+	if item != nil {
+		audit.Record(mctx.EventBus, auth, "item", id, audit.ActionDelete, itemToInfoJson(item), nil)
+	}
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
-func addItemService(aul *AddItemRequest, auth *model.AuthInfo) *model.ApiJson {
+func addItemService(ctx context.Context, aul *AddItemRequest, version uint, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
+	before, _ := dbGetItemByID(ctx, aul.ItemID)
 	itemlog := dbItemLogAdd(aul)
-	log, err := dbAddItem(itemlog, auth.User)
+	log, err := dbAddItem(ctx, itemlog, version, auth.User)
 	if err != nil {
-		return model.ErrorInsertDatabase(err)
+		return itemUpdateError(ctx, aul.ItemID, err)
 	}
+	audit.Record(mctx.EventBus, auth, "item", log.ID, audit.ActionUpdate, itemToInfoJson(before), itemToInfoJson(log))
 	return model.SuccessUpdate(itemToJson(log), "添加成功")
 }
 
-func consumeItemService(aul *ConsumeItemRequest, auth *model.AuthInfo) *model.ApiJson {
+func consumeItemService(ctx context.Context, aul *ConsumeItemRequest, version uint, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	order, err := dbGetOrderWithLastStatus(aul.OrderID)
+	order, err := dbGetOrderWithLastStatus(ctx, aul.OrderID)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -98,14 +108,65 @@ func consumeItemService(aul *ConsumeItemRequest, auth *model.AuthInfo) *model.Ap
 	if repairer != nil && *repairer != auth.User {
 		return model.ErrorNoPermissions(fmt.Errorf("您不是订单的当前维修员"))
 	}
+	before, _ := dbGetItemByID(ctx, aul.ItemID)
 	itemlog := dbItemLogConsume(aul)
-	log, err := dbConsumeItem(itemlog, auth.User)
+	log, err := dbConsumeItem(ctx, itemlog, version, auth.User)
 	if err != nil {
-		return model.ErrorInsertDatabase(err)
+		return itemUpdateError(ctx, aul.ItemID, err)
 	}
+	audit.Record(mctx.EventBus, auth, "item", log.ID, audit.ActionUpdate, itemToInfoJson(before), itemToInfoJson(log))
 	return model.SuccessUpdate(itemToJson(log), "添加成功")
 }
 
+func getTrashItemsService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+	if err := util.Validator.Struct(param); err != nil {
+		return model.ErrorValidation(err)
+	}
+	items, count, err := dbGetTrashItems(ctx, param)
+	if err != nil {
+		return model.ErrorQueryDatabase(err)
+	}
+	js := util.TransSlice(items, itemToInfoJson)
+	return model.SuccessPaged(js, count, "获取成功")
+}
+
+func restoreItemService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	item, err := dbRestoreItem(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		if errors.Is(err, dao.ErrConflict) {
+			return model.ErrorConflict(err)
+		}
+		return model.ErrorUpdateDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "item", id, audit.ActionRestore, nil, itemToInfoJson(item))
+	return model.SuccessUpdate(itemToInfoJson(item), "恢复成功")
+}
+
+func purgeItemService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	if err := dbPurgeItem(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorDeleteDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "item", id, audit.ActionPurge, nil, nil)
+	return model.SuccessUpdate(nil, "彻底删除成功")
+}
+
+// itemUpdateError responds 409 with the current item if err is dao.ErrStale,
+// i.e. the item has been modified since it was read.
+func itemUpdateError(ctx context.Context, id uint, err error) *model.ApiJson {
+	if errors.Is(err, dao.ErrStale) {
+		if item, err := dbGetItemByID(ctx, id); err == nil {
+			return model.ErrorStale(itemToInfoJson(item))
+		}
+	}
+	return model.ErrorInsertDatabase(err)
+}
+
 func itemToJson(item *Item) *ItemJson {
 	if item == nil {
 		return nil
@@ -115,6 +176,7 @@ func itemToJson(item *Item) *ItemJson {
 			Name:        item.Name,
 			Description: item.Description,
 			Count:       item.Count,
+			Version:     item.Version,
 		}
 	}
 
@@ -131,11 +193,13 @@ func itemToInfoJson(item *Item) *ItemInfoJson {
 			Price:       item.Price,
 			Income:      item.Income,
 			Count:       item.Count,
+			Version:     item.Version,
 			ItemLogs:    util.TransSlice(item.ItemLogs, itemLogToJson),
 			CreatedAt:   item.CreatedAt.Unix(),
 			UpdatedAt:   item.UpdatedAt.Unix(),
 			CreatedBy:   item.CreatedBy,
 			UpdatedBy:   item.UpdatedBy,
+			DeletedAt:   model.DeletedUnix(item.DeletedAt),
 		}
 	}
 
@@ -1,9 +1,12 @@
 package order
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 	"github.com/xaxys/maintainman/modules/user"
@@ -11,8 +14,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func getOrderByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetOrderWithLastStatus(id)
+func getOrderByIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetOrderWithLastStatus(ctx, id)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -25,11 +28,11 @@ func getOrderByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 			return model.ErrorNoPermissions(fmt.Errorf("您不是订单的创建者或指派人，不能查看评论"))
 		}
 	}
-	return forceGetOrderByIDService(id, auth)
+	return forceGetOrderByIDService(ctx, id, auth)
 }
 
-func forceGetOrderByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetOrderByID(id)
+func forceGetOrderByIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetCachedOrderByID(ctx, id)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -38,7 +41,7 @@ func forceGetOrderByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	}
 	json := orderToJson(order)
 	if rid := util.LastElem(order.StatusList).RepairerID; rid != nil {
-		repairer, err := user.GetUserByID(*rid)
+		repairer, err := user.GetUserByID(ctx, *rid)
 		if err != nil {
 			mctx.Logger.Warnf("获取订单%d的指派人%d失败: %+v", id, *rid, err)
 		}
@@ -47,7 +50,7 @@ func forceGetOrderByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(json, "获取成功")
 }
 
-func getOrderByUserService(aul *UserOrderRequest, auth *model.AuthInfo) *model.ApiJson {
+func getOrderByUserService(ctx context.Context, aul *UserOrderRequest, auth *model.AuthInfo) *model.ApiJson {
 	aul.OrderBy = util.NotEmpty(aul.OrderBy, "id desc")
 	allreq := &AllOrderRequest{
 		UserID:    auth.User,
@@ -55,15 +58,15 @@ func getOrderByUserService(aul *UserOrderRequest, auth *model.AuthInfo) *model.A
 		Tags:      aul.Tags,
 		PageParam: aul.PageParam,
 	}
-	return getAllOrdersService(allreq, auth)
+	return getAllOrdersService(ctx, allreq, auth)
 }
 
-func getOrderByRepairerService(id uint, aul *RepairerOrderRequest, auth *model.AuthInfo) *model.ApiJson {
+func getOrderByRepairerService(ctx context.Context, id uint, aul *RepairerOrderRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
 	aul.OrderBy = util.NotEmpty(aul.OrderBy, "order_id desc")
-	orders, count, err := dbGetOrderByRepairer(id, aul)
+	orders, count, err := dbGetOrderByRepairer(ctx, id, aul)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -74,8 +77,8 @@ func getOrderByRepairerService(id uint, aul *RepairerOrderRequest, auth *model.A
 	return model.SuccessPaged(os, count, "获取成功")
 }
 
-func getOrderStatusService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetOrderWithLastStatus(id)
+func getOrderStatusService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetOrderWithLastStatus(ctx, id)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -88,11 +91,11 @@ func getOrderStatusService(id uint, auth *model.AuthInfo) *model.ApiJson {
 			return model.ErrorNoPermissions(fmt.Errorf("您不是订单的创建者或指派人，不能查看评论"))
 		}
 	}
-	return forceGetOrderStatusService(id, auth)
+	return forceGetOrderStatusService(ctx, id, auth)
 }
 
-func forceGetOrderStatusService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	statuses, err := dbGetStatusByOrder(id)
+func forceGetOrderStatusService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	statuses, err := dbGetStatusByOrder(ctx, id)
 	if err != nil || len(statuses) == 0 {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -103,11 +106,11 @@ func forceGetOrderStatusService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(ss, "获取成功")
 }
 
-func getAllOrdersService(aul *AllOrderRequest, auth *model.AuthInfo) *model.ApiJson {
+func getAllOrdersService(ctx context.Context, aul *AllOrderRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	orders, count, err := dbGetAllOrdersWithParam(aul)
+	orders, count, err := dbGetAllOrdersWithParam(ctx, aul)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -118,67 +121,87 @@ func getAllOrdersService(aul *AllOrderRequest, auth *model.AuthInfo) *model.ApiJ
 	return model.SuccessPaged(os, count, "获取成功")
 }
 
-func createOrderService(aul *CreateOrderRequest, auth *model.AuthInfo) *model.ApiJson {
+func createOrderService(ctx context.Context, aul *CreateOrderRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
 	role := util.NilOrBaseValue(auth, func(v *model.AuthInfo) string { return v.Role }, "")
-	if errResp := checkTagsService(aul.Tags, "tag.view", role); errResp != nil {
+	if errResp := checkTagsService(ctx, aul.Tags, "tag.view", role); errResp != nil {
 		return errResp
 	}
-	order, err := dbCreateOrder(aul, auth.User)
+	order, err := dbCreateOrder(ctx, aul, auth.User)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
-	go mctx.EventBus.Emit("order:create", order.ID)
+	audit.Record(mctx.EventBus, auth, "order", order.ID, audit.ActionCreate, nil, orderToJson(order))
+	mctx.Emit("order:create", order.ID)
 	return model.SuccessCreate(orderToJson(order), "创建成功")
 }
 
-func updateOrderService(id uint, aul *UpdateOrderRequest, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func updateOrderService(ctx context.Context, id, version uint, aul *UpdateOrderRequest, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.UserID != auth.User {
 		return model.ErrorUpdateDatabase(fmt.Errorf("操作人不是订单创建者"))
 	}
 	role := util.NilOrBaseValue(auth, func(v *model.AuthInfo) string { return v.Role }, "")
-	if errResp := checkTagsService(aul.AddTags, "tag.add", role); errResp != nil {
+	if errResp := checkTagsService(ctx, aul.AddTags, "tag.add", role); errResp != nil {
 		return errResp
 	}
-	if errResp := checkTagsService(aul.DelTags, "tag.add", role); errResp != nil {
+	if errResp := checkTagsService(ctx, aul.DelTags, "tag.add", role); errResp != nil {
 		return errResp
 	}
-	return forceUpdateOrderService(id, aul, auth)
+	return forceUpdateOrderService(ctx, id, version, aul, auth)
 }
 
-func forceUpdateOrderService(id uint, aul *UpdateOrderRequest, auth *model.AuthInfo) *model.ApiJson {
+func forceUpdateOrderService(ctx context.Context, id, version uint, aul *UpdateOrderRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	order, err := dbUpdateOrder(id, aul, auth.User)
+	before, err := dbGetOrderByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorQueryDatabase(err)
+	}
+	if err := dao.CheckVersion(version, before.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
+	order, err := dbUpdateOrder(ctx, id, before.Version, aul, auth.User)
 	if err != nil {
-		return model.ErrorUpdateDatabase(err)
+		return orderUpdateError(ctx, id, err)
+	}
+	if after, err := dbGetOrderByID(ctx, id); err == nil {
+		audit.Record(mctx.EventBus, auth, "order", id, audit.ActionUpdate, orderToJson(before), orderToJson(after))
 	}
 	fields := util.NotEmptyFieldName(aul)
 	for _, field := range fields {
 		event := fmt.Sprintf("order:update:%s", field)
-		go mctx.EventBus.Emit(event, order.ID)
+		mctx.Emit(event, order.ID)
 	}
 	return model.SuccessUpdate(orderToJson(order), "更新成功")
 }
 
-func releaseOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func releaseOrderService(ctx context.Context, id, version uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusWaiting {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于待处理状态"))
 	}
@@ -186,24 +209,28 @@ func releaseOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已结束，不能再次维修"))
 	}
 	status := NewStatusWaiting(auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:waiting", order.ID, StatusWaiting)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:waiting", order.ID, StatusWaiting)
 	return model.SuccessUpdate(nil, "释放成功")
 }
 
-func assignOrderService(id, repairer uint, auth *model.AuthInfo) *model.ApiJson {
+func assignOrderService(ctx context.Context, id, version, repairer uint, auth *model.AuthInfo) *model.ApiJson {
 	if repairer == 0 {
 		return model.ErrorUpdateDatabase(fmt.Errorf("维修人不能为空"))
 	}
-	order, err := dbGetSimpleOrderByID(id)
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusAssigned {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于已接单状态"))
 	}
@@ -211,21 +238,25 @@ func assignOrderService(id, repairer uint, auth *model.AuthInfo) *model.ApiJson
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单不处于待处理状态，不能指派"))
 	}
 	status := NewStatusAssigned(repairer, auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:assigned", order.ID, StatusAssigned, repairer)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:assigned", order.ID, StatusAssigned, repairer)
 	return model.SuccessUpdate(nil, "指派成功")
 }
 
-func completeOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func completeOrderService(ctx context.Context, id, version uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusCompleted {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于已完成状态"))
 	}
@@ -236,21 +267,25 @@ func completeOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
 		return model.ErrorUpdateDatabase(fmt.Errorf("操作人不是订单当前指派人"))
 	}
 	status := NewStatusCompleted(auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:completed", order.ID, StatusCompleted)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:completed", order.ID, StatusCompleted)
 	return model.SuccessUpdate(nil, "结单成功")
 }
 
-func cancelOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func cancelOrderService(ctx context.Context, id, version uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusCanceled {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于已取消状态"))
 	}
@@ -258,21 +293,25 @@ func cancelOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已完成，不能取消"))
 	}
 	status := NewStatusCanceled(auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:canceled", order.ID, StatusCanceled)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:canceled", order.ID, StatusCanceled)
 	return model.SuccessUpdate(nil, "取消成功")
 }
 
-func rejectOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func rejectOrderService(ctx context.Context, id, version uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusRejected {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于已拒绝状态"))
 	}
@@ -280,21 +319,25 @@ func rejectOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单不处于待处理状态，不能拒绝"))
 	}
 	status := NewStatusRejected(auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:rejected", order.ID, StatusRejected)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:rejected", order.ID, StatusRejected)
 	return model.SuccessUpdate(nil, "拒绝成功")
 }
 
-func appraiseOrderService(id, appraisal uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func appraiseOrderService(ctx context.Context, id, version, appraisal uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusAppraised {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于已评价状态"))
 	}
@@ -304,21 +347,25 @@ func appraiseOrderService(id, appraisal uint, auth *model.AuthInfo) *model.ApiJs
 	if order.UserID != auth.User {
 		return model.ErrorUpdateDatabase(fmt.Errorf("您不是订单的创建者，不能评价"))
 	}
-	if err := dbAppraiseOrder(id, appraisal, auth.User); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbAppraiseOrder(ctx, id, order.Version, appraisal, auth.User); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:appraised", order.ID, StatusAppraised)
+	auditOrderAppraisal(auth, id, order.Appraisal, appraisal)
+	mctx.Emit("order:update:status:appraised", order.ID, StatusAppraised)
 	return model.SuccessUpdate(nil, "评价成功")
 }
 
-func reportOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetOrderWithLastStatus(id)
+func reportOrderService(ctx context.Context, id, version uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetOrderWithLastStatus(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusReported {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于已上报状态"))
 	}
@@ -330,21 +377,25 @@ func reportOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
 		return model.ErrorUpdateDatabase(fmt.Errorf("操作人不是订单指派人，不能上报"))
 	}
 	status := NewStatusReported(auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:reported", order.ID, StatusReported)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:reported", order.ID, StatusReported)
 	return model.SuccessUpdate(nil, "上报成功")
 }
 
-func holdOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	order, err := dbGetSimpleOrderByID(id)
+func holdOrderService(ctx context.Context, id, version uint, auth *model.AuthInfo) *model.ApiJson {
+	order, err := dbGetSimpleOrderByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
+	if err := dao.CheckVersion(version, order.Version); err != nil {
+		return orderUpdateError(ctx, id, err)
+	}
 	if order.Status == StatusHold {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单已处于挂单状态"))
 	}
@@ -352,26 +403,55 @@ func holdOrderService(id uint, auth *model.AuthInfo) *model.ApiJson {
 		return model.ErrorUpdateDatabase(fmt.Errorf("订单不处于待处理或已上报状态，不能挂单"))
 	}
 	status := NewStatusHold(auth.User)
-	if err := dbChangeOrderStatus(id, status); err != nil {
-		return model.ErrorUpdateDatabase(err)
+	if err := dbChangeOrderStatus(ctx, id, order.Version, status); err != nil {
+		return orderUpdateError(ctx, id, err)
 	}
-	go mctx.EventBus.Emit("order:update:status:hold", order.ID, StatusHold)
+	auditOrderStatus(auth, id, order.Status, status)
+	mctx.Emit("order:update:status:hold", order.ID, StatusHold)
 	return model.SuccessUpdate(nil, "挂单成功")
 }
 
+// autoAppraiseOrderService appraises each order in its own transaction, so
+// that no transaction is held while another one writes.
 func autoAppraiseOrderService() {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
-		orders, err := txGetAppraiseTimeoutOrder(tx)
-		if err != nil {
-			return err
+	orders, err := txGetAppraiseTimeoutOrder(mctx.Database)
+	if err != nil {
+		return
+	}
+	for _, order := range orders {
+		def := util.ToUint(orderConfig.GetInt("appraise.default"))
+		if err := dbAppraiseOrder(context.Background(), order, 0, def, 0); err == nil {
+			auditOrderAppraisal(nil, order, 0, def)
 		}
-		for _, order := range orders {
-			def := util.ToUint(orderConfig.GetInt("appraise.default"))
-			_ = dbAppraiseOrder(order, def, 0)
-			go mctx.EventBus.Emit("order:update:status:appraised", order, StatusAppraised)
+		mctx.Emit("order:update:status:appraised", order, StatusAppraised)
+	}
+}
+
+// orderUpdateError responds 409 with the current order if err is dao.ErrStale,
+// i.e. the order has been modified since it was read.
+func orderUpdateError(ctx context.Context, id uint, err error) *model.ApiJson {
+	if errors.Is(err, dao.ErrStale) {
+		if order, err := dbGetOrderByID(ctx, id); err == nil {
+			return model.ErrorStale(orderToJson(order))
 		}
-		return nil
-	})
+	}
+	return model.ErrorUpdateDatabase(err)
+}
+
+// auditOrderStatus records the change of the order status from before.
+func auditOrderStatus(auth *model.AuthInfo, id, before uint, status *Status) {
+	after := map[string]any{"status": status.Status}
+	if status.RepairerID != nil {
+		after["repairer_id"] = *status.RepairerID
+	}
+	audit.Record(mctx.EventBus, auth, "order", id, audit.ActionUpdate, map[string]any{"status": before}, after)
+}
+
+// auditOrderAppraisal records the appraisal of the completed order.
+func auditOrderAppraisal(auth *model.AuthInfo, id, before, appraisal uint) {
+	audit.Record(mctx.EventBus, auth, "order", id, audit.ActionUpdate,
+		map[string]any{"status": StatusCompleted, "appraisal": before},
+		map[string]any{"status": StatusAppraised, "appraisal": appraisal})
 }
 
 func orderToJson(order *Order) *OrderJson {
@@ -387,6 +467,7 @@ func orderToJson(order *Order) *OrderJson {
 		CreatedAt:    order.CreatedAt.Unix(),
 		UpdatedAt:    order.UpdatedAt.Unix(),
 		Appraisal:    order.Appraisal,
+		Version:      order.Version,
 		Tags:         util.TransSlice(order.Tags, tagToJson),
 		AllowComment: order.AllowComment == CommentAllow,
 		Comments:     util.TransSlice(order.Comments, commentToJson),
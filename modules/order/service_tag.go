@@ -1,9 +1,11 @@
 package order
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/xaxys/maintainman/core/audit"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/rbac"
 	"github.com/xaxys/maintainman/core/util"
@@ -11,8 +13,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func getTagByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	tag, err := dbGetTagByID(id)
+func getTagByIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	tag, err := dbGetTagByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -26,16 +28,16 @@ func getTagByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(tagToJson(tag), "获取成功")
 }
 
-func getAllTagSortsService(auth *model.AuthInfo) *model.ApiJson {
-	tags, err := dbGetAllTagSorts()
+func getAllTagSortsService(ctx context.Context, auth *model.AuthInfo) *model.ApiJson {
+	tags, err := dbGetAllTagSorts(ctx)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
 	return model.Success(tags, "获取成功")
 }
 
-func getAllTagsBySortService(sort string, auth *model.AuthInfo) *model.ApiJson {
-	tags, err := dbGetAllTagsBySort(sort)
+func getAllTagsBySortService(ctx context.Context, sort string, auth *model.AuthInfo) *model.ApiJson {
+	tags, err := dbGetAllTagsBySort(ctx, sort)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -49,24 +51,29 @@ func getAllTagsBySortService(sort string, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(ts, "获取成功")
 }
 
-func createTagService(aul *CreateTagRequest, auth *model.AuthInfo) *model.ApiJson {
-	tag, err := dbCreateTag(aul, auth.User)
+func createTagService(ctx context.Context, aul *CreateTagRequest, auth *model.AuthInfo) *model.ApiJson {
+	tag, err := dbCreateTag(ctx, aul, auth.User)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "tag", tag.ID, audit.ActionCreate, nil, tagToJson(tag))
 	return model.SuccessCreate(tagToJson(tag), "创建成功")
 }
 
-func deleteTagService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	err := dbDeleteTag(id)
+func deleteTagService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	tag, _ := dbGetTagByID(ctx, id)
+	err := dbDeleteTag(ctx, id)
 	if err != nil {
 		return model.ErrorDeleteDatabase(err)
 	}
+	if tag != nil {
+		audit.Record(mctx.EventBus, auth, "tag", id, audit.ActionDelete, tagToJson(tag), nil)
+	}
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
-func checkTagsService(tagIDs []uint, perm, role string) *model.ApiJson {
-	tags, err := dbGetTagsByIDs(tagIDs)
+func checkTagsService(ctx context.Context, tagIDs []uint, perm, role string) *model.ApiJson {
+	tags, err := dbGetTagsByIDs(ctx, tagIDs)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
@@ -78,16 +85,52 @@ func checkTagsService(tagIDs []uint, perm, role string) *model.ApiJson {
 	return nil
 }
 
+func getTrashTagsService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+	if err := util.Validator.Struct(param); err != nil {
+		return model.ErrorValidation(err)
+	}
+	tags, count, err := dbGetTrashTags(ctx, param)
+	if err != nil {
+		return model.ErrorQueryDatabase(err)
+	}
+	js := util.TransSlice(tags, tagToJson)
+	return model.SuccessPaged(js, count, "获取成功")
+}
+
+func restoreTagService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	tag, err := dbRestoreTag(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorUpdateDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "tag", id, audit.ActionRestore, nil, tagToJson(tag))
+	return model.SuccessUpdate(tagToJson(tag), "恢复成功")
+}
+
+func purgeTagService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	if err := dbPurgeTag(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorDeleteDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "tag", id, audit.ActionPurge, nil, nil)
+	return model.SuccessUpdate(nil, "彻底删除成功")
+}
+
 func tagToJson(tag *Tag) *TagJson {
 	if tag == nil {
 		return nil
 	} else {
 		return &TagJson{
-			ID:       tag.ID,
-			Sort:     tag.Sort,
-			Name:     tag.Name,
-			Level:    tag.Level,
-			Congener: tag.Congener,
+			ID:        tag.ID,
+			Sort:      tag.Sort,
+			Name:      tag.Name,
+			Level:     tag.Level,
+			Congener:  tag.Congener,
+			DeletedAt: model.DeletedUnix(tag.DeletedAt),
 		}
 	}
 }
@@ -1,11 +1,11 @@
 package role
 
 import (
-	"github.com/spf13/viper"
+	"github.com/xaxys/maintainman/core/config"
 )
 
 var (
-	roleConfig = viper.New()
+	roleConfig = config.New()
 )
 
 func init() {
@@ -111,4 +111,8 @@ func init() {
 			},
 		},
 	})
+
+	config.SetConfigSchema(roleConfig, config.Schema{
+		"role": config.List(),
+	})
 }
@@ -12,7 +12,6 @@ var Module = module.Module{
 	ModuleConfig:  roleConfig,
 	ModuleDepends: []string{},
 	ModuleEnv:     map[string]any{},
-	ModuleExport:  map[string]any{},
 	ModulePerm: map[string]string{
 		"role.view":          "查看当前角色",
 		"role.create":        "创建角色",
@@ -21,11 +20,18 @@ var Module = module.Module{
 		"role.viewall":       "查看所有角色",
 		"permission.viewall": "查看所有权限",
 	},
-	EntryPoint: entry,
+	EntryPoint:   entry,
+	CommandPoint: command,
 }
 
 var mctx *module.ModuleContext
 
+// command loads roles for commands.
+func command(ctx *module.ModuleContext) {
+	mctx = ctx
+	rbac.LoadRole(roleConfig)
+}
+
 func entry(ctx *module.ModuleContext) {
 	mctx = ctx
 	rbac.LoadRole(roleConfig)
@@ -3,6 +3,7 @@ package role
 import (
 	"fmt"
 
+	"github.com/xaxys/maintainman/core/audit"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/rbac"
 	"github.com/xaxys/maintainman/core/util"
@@ -28,6 +29,7 @@ func createRoleService(aul *rbac.CreateRoleRequest, auth *model.AuthInfo) *model
 		return model.ErrorInsertDatabase(err)
 	}
 	role := rbac.GetRole(aul.Name)
+	audit.Record(mctx.EventBus, auth, "role", aul.Name, audit.ActionCreate, nil, role)
 	return model.SuccessCreate(role, "创建成功")
 
 }
@@ -36,8 +38,9 @@ func updateRoleService(name string, aul *rbac.UpdateRoleRequest, auth *model.Aut
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	if rbac.GetRole(name) != nil {
-		return model.ErrorUpdateDatabase(fmt.Errorf("Role %s already exists", name))
+	before := rbac.GetRole(name)
+	if before == nil {
+		return model.ErrorNotFound(fmt.Errorf("Role %s not found", name))
 	}
 
 	err := rbac.UpdateRole(name, aul)
@@ -45,39 +48,46 @@ func updateRoleService(name string, aul *rbac.UpdateRoleRequest, auth *model.Aut
 		return model.ErrorUpdateDatabase(err)
 	}
 	role := rbac.GetRole(name)
+	audit.Record(mctx.EventBus, auth, "role", name, audit.ActionUpdate, before, role)
 	return model.SuccessUpdate(role, "更新成功")
 }
 
 func deleteRoleService(name string, auth *model.AuthInfo) *model.ApiJson {
-	if rbac.GetRole(name) == nil {
+	role := rbac.GetRole(name)
+	if role == nil {
 		return model.ErrorNotFound(fmt.Errorf("Role %s not found", name))
 	}
 	err := rbac.DeleteRole(name)
 	if err != nil {
 		return model.ErrorDeleteDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "role", name, audit.ActionDelete, role, nil)
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
 func setDefaultRoleService(name string, auth *model.AuthInfo) *model.ApiJson {
-	if rbac.GetRole(name) == nil {
+	before := rbac.GetRole(name)
+	if before == nil {
 		return model.ErrorNotFound(fmt.Errorf("Role %s not found", name))
 	}
 	err := rbac.SetDefaultRole(name)
 	if err != nil {
 		return model.ErrorUpdateDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "role", name, audit.ActionUpdate, before, rbac.GetRole(name))
 	return model.SuccessUpdate(nil, "操作成功")
 }
 
 func setGuestRoleService(name string, auth *model.AuthInfo) *model.ApiJson {
-	if rbac.GetRole(name) == nil {
+	before := rbac.GetRole(name)
+	if before == nil {
 		return model.ErrorNotFound(fmt.Errorf("Role %s not found", name))
 	}
 	err := rbac.SetGuestRole(name)
 	if err != nil {
 		return model.ErrorUpdateDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "role", name, audit.ActionUpdate, before, rbac.GetRole(name))
 	return model.SuccessUpdate(nil, "操作成功")
 }
 
@@ -14,7 +14,6 @@ var Module = module.Module{
 	ModuleVersion: "1.0.0",
 	ModuleDepends: []string{},
 	ModuleEnv:     map[string]any{},
-	ModuleExport:  map[string]any{},
 	ModulePerm: map[string]string{
 		"sysinfo.view": "查看系统信息",
 	},
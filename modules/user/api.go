@@ -1,11 +1,80 @@
 package user
 
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xaxys/maintainman/core/util"
+
+	"gorm.io/gorm"
+)
+
 // GetUserByID returns the user with the given ID.
-func GetUserByID(id uint) (*User, error) {
-	return dbGetUserByID(id)
+func GetUserByID(ctx context.Context, id uint) (*User, error) {
+	return dbGetUserByID(ctx, id)
 }
 
 // UserToJson converts a user to a json string.
 func UserToJson(user *User) *UserJson {
 	return userToJson(user)
 }
+
+// UserDirectory is provided by user module for other modules to look up users.
+type UserDirectory interface {
+	GetUserByID(ctx context.Context, id uint) (*User, error)
+	// WechatApp returns the appid and secret of the wechat mini program.
+	WechatApp() (appid, secret string)
+}
+
+type userDirectory struct{}
+
+func (userDirectory) GetUserByID(ctx context.Context, id uint) (*User, error) {
+	return GetUserByID(ctx, id)
+}
+
+func (userDirectory) WechatApp() (string, string) {
+	return userConfig.GetString("wechat.appid"), userConfig.GetString("wechat.secret")
+}
+
+// UserAdmin is provided by user module for administrative tools.
+type UserAdmin interface {
+	CreateUser(ctx context.Context, req *CreateUserRequest) (*User, error)
+	ResetPassword(ctx context.Context, name, password string) error
+	SetRole(ctx context.Context, name, role string) error
+}
+
+type userAdmin struct{}
+
+func (userAdmin) CreateUser(ctx context.Context, req *CreateUserRequest) (*User, error) {
+	if err := util.Validator.Struct(req); err != nil {
+		return nil, err
+	}
+	if util.EmailRegex.MatchString(req.Name) || util.PhoneRegex.MatchString(req.Name) {
+		return nil, fmt.Errorf("user name should not be an email or a phone number")
+	}
+	return dbCreateUser(ctx, req, 0)
+}
+
+func (userAdmin) ResetPassword(ctx context.Context, name, password string) error {
+	return updateUserByName(ctx, name, &UpdateUserRequest{Password: password})
+}
+
+func (userAdmin) SetRole(ctx context.Context, name, role string) error {
+	return updateUserByName(ctx, name, &UpdateUserRequest{RoleName: role})
+}
+
+func updateUserByName(ctx context.Context, name string, req *UpdateUserRequest) error {
+	if err := util.Validator.Struct(req); err != nil {
+		return err
+	}
+	u, err := dbGetUserByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %s not found", name)
+		}
+		return err
+	}
+	_, err = dbUpdateUser(ctx, u.ID, req, 0)
+	return err
+}
@@ -1,34 +1,34 @@
 package user
 
 import (
-	"fmt"
+	"context"
 	"strconv"
+
+	"github.com/xaxys/maintainman/core/cache"
+
+	"gorm.io/gorm"
 )
 
-func cacheGetUserByID(id uint) (*User, error) {
-	obj, ok := mctx.Cache.Get(strconv.FormatUint(uint64(id), 36))
-	if !ok {
-		return nil, fmt.Errorf("未找到用户: id: %d", id)
-	}
-	user, ok := obj.(User)
-	if !ok {
-		err := fmt.Errorf("缓存中的用户不是 User 类型: id: %d", id)
-		mctx.Logger.Warn(err)
-		cacheDeleteUser(id)
-		return nil, err
-	}
-	return &user, nil
+var userLoader *cache.Loader[User]
+
+func initUserCache() {
+	userLoader = cache.NewLoader[User](
+		mctx.Cache,
+		userConfig.GetDuration("cache.ttl"),
+		userConfig.GetDuration("cache.jitter"),
+		userConfig.GetDuration("cache.negative_ttl"),
+		gorm.ErrRecordNotFound,
+	)
 }
 
-func cacheSaveUser(user *User) error {
-	cacheDeleteUser(user.ID)
-	ok := mctx.Cache.Set(strconv.FormatUint(uint64(user.ID), 36), *user, 0)
-	if !ok {
-		return fmt.Errorf("缓存用户失败: id: %d", user.ID)
+func cacheGetUserByID(ctx context.Context, id uint, load func(ctx context.Context) (User, error)) (*User, error) {
+	user, err := userLoader.Get(ctx, strconv.FormatUint(uint64(id), 36), load)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &user, nil
 }
 
 func cacheDeleteUser(id uint) {
-	mctx.Cache.Del(strconv.FormatUint(uint64(id), 36))
+	userLoader.Del(strconv.FormatUint(uint64(id), 36))
 }
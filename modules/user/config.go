@@ -1,8 +1,10 @@
 package user
 
-import "github.com/spf13/viper"
+import (
+	"github.com/xaxys/maintainman/core/config"
+)
 
-var userConfig = viper.New()
+var userConfig = config.New()
 
 func init() {
 	userConfig.SetDefault("wechat.appid", "微信小程序的appid")
@@ -15,4 +17,28 @@ func init() {
 
 	userConfig.SetDefault("cache.driver", "local")
 	userConfig.SetDefault("cache.limit", 268435456) // 256MB
+	userConfig.SetDefault("cache.codec", "json")
+	userConfig.SetDefault("cache.sync", false)
+	userConfig.SetDefault("cache.local_ttl", "1m")
+	userConfig.SetDefault("cache.ttl", "1h")
+	userConfig.SetDefault("cache.jitter", "5m")
+	userConfig.SetDefault("cache.negative_ttl", "1m")
+
+	config.SetConfigSchema(userConfig, config.Schema{
+		"wechat.appid":       config.String(),
+		"wechat.secret":      config.String(),
+		"wechat.fastlogin":   config.Bool(),
+		"admin.name":         config.NonEmpty(),
+		"admin.display_name": config.String(),
+		"admin.password":     config.NonEmpty(),
+		"admin.role_name":    config.NonEmpty(),
+		"cache.driver":       config.OneOf("local", "redis", "tiered"),
+		"cache.limit":        config.IntMin(0),
+		"cache.codec":        config.OneOf("json", "gob", "msgpack"),
+		"cache.sync":         config.Bool(),
+		"cache.local_ttl":    config.DurationMin(0),
+		"cache.ttl":          config.DurationMin(0),
+		"cache.jitter":       config.DurationMin(0),
+		"cache.negative_ttl": config.DurationMin(0),
+	})
 }
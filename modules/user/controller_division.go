@@ -24,7 +24,7 @@ import (
 func getDivision(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getDivisionService(id, auth)
+	response := getDivisionService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -45,7 +45,7 @@ func getDivision(ctx iris.Context) {
 func getDivisionsByParentID(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getDivisionsByParentIDService(id, auth)
+	response := getDivisionsByParentIDService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -71,7 +71,7 @@ func createDivision(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createDivisionService(aul, auth)
+	response := createDivisionService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -98,7 +98,7 @@ func updateDivision(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := updateDivisionService(id, aul, auth)
+	response := updateDivisionService(ctx.Request().Context(), id, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -120,6 +120,6 @@ func updateDivision(ctx iris.Context) {
 func deleteDivision(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := deleteDivisionService(id, auth)
+	response := deleteDivisionService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"github.com/xaxys/maintainman/core/controller"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
@@ -22,7 +23,7 @@ import (
 // @Router       /v1/user [get]
 func getUser(ctx iris.Context) {
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getUserInfoByIDService(auth.User, auth)
+	response := getUserInfoByIDService(ctx.Request().Context(), auth.User, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -43,7 +44,7 @@ func getUser(ctx iris.Context) {
 func getUserByID(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getUserInfoByIDService(id, auth)
+	response := getUserInfoByIDService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -72,7 +73,7 @@ func getUsersByDivision(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getUsersByDivisionService(id, param, auth)
+	response := getUsersByDivisionService(ctx.Request().Context(), id, param, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -101,7 +102,7 @@ func getAllUsers(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := getAllUsersService(aul, auth)
+	response := getAllUsersService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -127,7 +128,7 @@ func userLogin(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := userLoginService(aul, ctx.Request().RemoteAddr, auth)
+	response := userLoginService(ctx.Request().Context(), aul, ctx.Request().RemoteAddr, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -153,7 +154,7 @@ func wxUserLogin(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := wxUserLoginService(aul, ctx.Request().RemoteAddr, auth)
+	response := wxUserLoginService(ctx.Request().Context(), aul, ctx.Request().RemoteAddr, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -179,7 +180,7 @@ func wxUserRegister(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := wxUserRegisterService(aul, ctx.Request().RemoteAddr, auth)
+	response := wxUserRegisterService(ctx.Request().Context(), aul, ctx.Request().RemoteAddr, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -200,7 +201,7 @@ func wxUserRegister(ctx iris.Context) {
 func userRenew(ctx iris.Context) {
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
 	id := util.NilOrBaseValue(auth, func(v *model.AuthInfo) uint { return v.User }, 0)
-	response := userRenewService(id, ctx.Request().RemoteAddr, auth)
+	response := userRenewService(ctx.Request().Context(), id, ctx.Request().RemoteAddr, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -226,7 +227,7 @@ func userRegister(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := registerUserService(aul, auth)
+	response := registerUserService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -252,7 +253,7 @@ func createUser(ctx iris.Context) {
 		return
 	}
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := createUserService(aul, auth)
+	response := createUserService(ctx.Request().Context(), aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -280,7 +281,7 @@ func updateUser(ctx iris.Context) {
 	aul.RoleName = ""
 	aul.DivisionID = 0
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := updateUserService(auth.User, aul, auth)
+	response := updateUserService(ctx.Request().Context(), auth.User, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -308,7 +309,7 @@ func forceUpdateUser(ctx iris.Context) {
 	}
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := updateUserService(id, aul, auth)
+	response := updateUserService(ctx.Request().Context(), id, aul, auth)
 	ctx.Values().Set("response", response)
 }
 
@@ -330,6 +331,72 @@ func forceUpdateUser(ctx iris.Context) {
 func forceDeleteUser(ctx iris.Context) {
 	id := ctx.Params().GetUintDefault("id", 0)
 	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
-	response := deleteUserService(id, auth)
+	response := deleteUserService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// getTrashUsers godoc
+// @Summary      获取已删除的用户
+// @Description  获取已删除的用户 分页 默认按删除时间倒序
+// @Tags         user
+// @Produce      json
+// @Param        order_by  query     string  false  "排序字段"
+// @Param        offset    query     uint    false  "偏移量"
+// @Param        limit     query     uint    false  "每页数据量"
+// @Success      200       {object}  model.ApiJson{data=model.Page{entries=[]UserJson}}
+// @Failure      400       {object}  model.ApiJson{data=[]string}
+// @Failure      401       {object}  model.ApiJson{data=[]string}
+// @Failure      403       {object}  model.ApiJson{data=[]string}
+// @Failure      404       {object}  model.ApiJson{data=[]string}
+// @Failure      422       {object}  model.ApiJson{data=[]string}
+// @Failure      500       {object}  model.ApiJson{data=[]string}
+// @Router       /v1/user/trash [get]
+func getTrashUsers(ctx iris.Context) {
+	param := controller.ExtractPageParam(ctx)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := getTrashUsersService(ctx.Request().Context(), param, auth)
+	ctx.Values().Set("response", response)
+}
+
+// restoreUser godoc
+// @Summary      恢复已删除的用户
+// @Description  通过ID恢复已删除的用户 用户名与现有用户重复时失败
+// @Tags         user
+// @Produce      json
+// @Param        id   path      uint  true  "用户ID"
+// @Success      204  {object}  model.ApiJson{data=UserJson}
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      409  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/user/trash/{id}/restore [post]
+func restoreUser(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := restoreUserService(ctx.Request().Context(), id, auth)
+	ctx.Values().Set("response", response)
+}
+
+// purgeUser godoc
+// @Summary      彻底删除用户
+// @Description  通过ID彻底删除已删除的用户 不可恢复
+// @Tags         user
+// @Produce      json
+// @Param        id   path      uint  true  "用户ID"
+// @Success      204  {object}  model.ApiJson
+// @Failure      400  {object}  model.ApiJson{data=[]string}
+// @Failure      401  {object}  model.ApiJson{data=[]string}
+// @Failure      403  {object}  model.ApiJson{data=[]string}
+// @Failure      404  {object}  model.ApiJson{data=[]string}
+// @Failure      422  {object}  model.ApiJson{data=[]string}
+// @Failure      500  {object}  model.ApiJson{data=[]string}
+// @Router       /v1/user/trash/{id} [delete]
+func purgeUser(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	auth := util.NilOrPtrCast[model.AuthInfo](ctx.Values().Get("auth"))
+	response := purgeUserService(ctx.Request().Context(), id, auth)
 	ctx.Values().Set("response", response)
 }
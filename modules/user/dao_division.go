@@ -1,12 +1,13 @@
 package user
 
 import (
+	"context"
 	"github.com/xaxys/maintainman/core/util"
 	"gorm.io/gorm"
 )
 
-func dbGetDivisionByID(id uint) (*Division, error) {
-	return txGetDivisionByID(mctx.Database, id)
+func dbGetDivisionByID(ctx context.Context, id uint) (*Division, error) {
+	return txGetDivisionByID(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetDivisionByID(tx *gorm.DB, id uint) (*Division, error) {
@@ -18,8 +19,8 @@ func txGetDivisionByID(tx *gorm.DB, id uint) (*Division, error) {
 	return division, nil
 }
 
-func dbGetDivisionsByParentID(id uint) ([]*Division, error) {
-	return txGetDivisionsByParentID(mctx.Database, id)
+func dbGetDivisionsByParentID(ctx context.Context, id uint) ([]*Division, error) {
+	return txGetDivisionsByParentID(mctx.Database.WithContext(ctx), id)
 }
 
 func txGetDivisionsByParentID(tx *gorm.DB, id uint) (divisions []*Division, err error) {
@@ -34,8 +35,8 @@ func txGetDivisionsByParentID(tx *gorm.DB, id uint) (divisions []*Division, err
 	return
 }
 
-func dbCreateDivision(aul *CreateDivisionRequest) (*Division, error) {
-	return txCreateDivision(mctx.Database, aul)
+func dbCreateDivision(ctx context.Context, aul *CreateDivisionRequest) (*Division, error) {
+	return txCreateDivision(mctx.Database.WithContext(ctx), aul)
 }
 
 func txCreateDivision(tx *gorm.DB, aul *CreateDivisionRequest) (*Division, error) {
@@ -50,8 +51,8 @@ func txCreateDivision(tx *gorm.DB, aul *CreateDivisionRequest) (*Division, error
 	return division, nil
 }
 
-func dbUpdateDivision(id uint, aul *UpdateDivisionRequest) (*Division, error) {
-	return txUpdateDivision(mctx.Database, id, aul)
+func dbUpdateDivision(ctx context.Context, id uint, aul *UpdateDivisionRequest) (*Division, error) {
+	return txUpdateDivision(mctx.Database.WithContext(ctx), id, aul)
 }
 
 func txUpdateDivision(tx *gorm.DB, id uint, aul *UpdateDivisionRequest) (*Division, error) {
@@ -72,8 +73,8 @@ func txUpdateDivision(tx *gorm.DB, id uint, aul *UpdateDivisionRequest) (*Divisi
 	return division, nil
 }
 
-func dbDeleteDivision(id uint) error {
-	return txDeleteDivision(mctx.Database, id)
+func dbDeleteDivision(ctx context.Context, id uint) error {
+	return txDeleteDivision(mctx.Database.WithContext(ctx), id)
 }
 
 func txDeleteDivision(tx *gorm.DB, id uint) (err error) {
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -14,8 +15,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func dbGetUserCount() (uint, error) {
-	return txGetUserCount(mctx.Database)
+func dbGetUserCount(ctx context.Context) (uint, error) {
+	return txGetUserCount(mctx.Database.WithContext(ctx))
 }
 
 func txGetUserCount(tx *gorm.DB) (uint, error) {
@@ -27,19 +28,14 @@ func txGetUserCount(tx *gorm.DB) (uint, error) {
 	return uint(count), nil
 }
 
-func dbGetUserByID(id uint) (user *User, err error) {
-	if user, err = cacheGetUserByID(id); err == nil {
-		return
-	}
-	mctx.Logger.Debugf("CacheGetUserByIDErr: %v", err)
-	user, err = txGetUserByID(mctx.Database, id)
-	if err != nil {
-		return
-	}
-	if err := cacheSaveUser(user); err != nil {
-		mctx.Logger.Warnf("CacheSaveUserErr: %v", err)
-	}
-	return
+func dbGetUserByID(ctx context.Context, id uint) (*User, error) {
+	return cacheGetUserByID(ctx, id, func(ctx context.Context) (User, error) {
+		user, err := txGetUserByID(mctx.Database.WithContext(ctx), id)
+		if err != nil {
+			return User{}, err
+		}
+		return *user, nil
+	})
 }
 
 func txGetUserByID(tx *gorm.DB, id uint) (*User, error) {
@@ -51,8 +47,8 @@ func txGetUserByID(tx *gorm.DB, id uint) (*User, error) {
 	return user, nil
 }
 
-func dbGetUserByName(name string) (*User, error) {
-	return txGetUserByName(mctx.Database, name)
+func dbGetUserByName(ctx context.Context, name string) (*User, error) {
+	return txGetUserByName(mctx.Database.WithContext(ctx), name)
 }
 
 func txGetUserByName(tx *gorm.DB, name string) (*User, error) {
@@ -64,8 +60,8 @@ func txGetUserByName(tx *gorm.DB, name string) (*User, error) {
 	return user, nil
 }
 
-func dbGetUserByEmail(email string) (*User, error) {
-	return txGetUserByEmail(mctx.Database, email)
+func dbGetUserByEmail(ctx context.Context, email string) (*User, error) {
+	return txGetUserByEmail(mctx.Database.WithContext(ctx), email)
 }
 
 func txGetUserByEmail(tx *gorm.DB, email string) (*User, error) {
@@ -77,8 +73,8 @@ func txGetUserByEmail(tx *gorm.DB, email string) (*User, error) {
 	return user, nil
 }
 
-func dbGetUserByPhone(phone string) (*User, error) {
-	return txGetUserByPhone(mctx.Database, phone)
+func dbGetUserByPhone(ctx context.Context, phone string) (*User, error) {
+	return txGetUserByPhone(mctx.Database.WithContext(ctx), phone)
 }
 
 func txGetUserByPhone(tx *gorm.DB, phone string) (*User, error) {
@@ -90,8 +86,8 @@ func txGetUserByPhone(tx *gorm.DB, phone string) (*User, error) {
 	return user, nil
 }
 
-func dbGetUserByOpenID(openid string) (*User, error) {
-	return txGetUserByOpenID(mctx.Database, openid)
+func dbGetUserByOpenID(ctx context.Context, openid string) (*User, error) {
+	return txGetUserByOpenID(mctx.Database.WithContext(ctx), openid)
 }
 
 func txGetUserByOpenID(tx *gorm.DB, openid string) (*User, error) {
@@ -103,8 +99,8 @@ func txGetUserByOpenID(tx *gorm.DB, openid string) (*User, error) {
 	return user, nil
 }
 
-func dbGetUsersByDivision(id uint, param *model.PageParam) (users []*User, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetUsersByDivision(ctx context.Context, id uint, param *model.PageParam) (users []*User, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if users, count, err = txGetUserByDivision(tx, id, param); err != nil {
 			mctx.Logger.Warnf("GetUsersByDivisionErr: %v\n", err)
 		}
@@ -131,8 +127,8 @@ func txGetUserByDivision(tx *gorm.DB, id uint, param *model.PageParam) (users []
 	return
 }
 
-func dbGetAllUsersWithParam(aul *AllUserRequest) (users []*User, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetAllUsersWithParam(ctx context.Context, aul *AllUserRequest) (users []*User, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if users, count, err = txGetAllUsersWithParam(tx, aul); err != nil {
 			mctx.Logger.Warnf("GetAllUsersWithParamErr: %v\n", err)
 		}
@@ -158,8 +154,13 @@ func txGetAllUsersWithParam(tx *gorm.DB, aul *AllUserRequest) (users []*User, co
 	return
 }
 
-func dbCreateUser(json *CreateUserRequest, operator uint) (*User, error) {
-	return txCreateUser(mctx.Database, json, operator)
+func dbCreateUser(ctx context.Context, json *CreateUserRequest, operator uint) (*User, error) {
+	user, err := txCreateUser(mctx.Database.WithContext(ctx), json, operator)
+	if err != nil {
+		return nil, err
+	}
+	cacheDeleteUser(user.ID)
+	return user, nil
 }
 
 func txCreateUser(tx *gorm.DB, json *CreateUserRequest, operator uint) (*User, error) {
@@ -187,8 +188,8 @@ func txCreateUser(tx *gorm.DB, json *CreateUserRequest, operator uint) (*User, e
 	return user, nil
 }
 
-func dbUpdateUser(id uint, json *UpdateUserRequest, operator uint) (user *User, err error) {
-	user, err = txUpdateUser(mctx.Database, id, json, operator)
+func dbUpdateUser(ctx context.Context, id uint, json *UpdateUserRequest, operator uint) (user *User, err error) {
+	user, err = txUpdateUser(mctx.Database.WithContext(ctx), id, json, operator)
 	if err != nil {
 		return
 	}
@@ -223,8 +224,8 @@ func txUpdateUser(tx *gorm.DB, id uint, json *UpdateUserRequest, operator uint)
 	return user, nil
 }
 
-func dbAttachOpenIDToUser(id uint, openid string) error {
-	err := txAttachOpenIDToUser(mctx.Database, id, openid)
+func dbAttachOpenIDToUser(ctx context.Context, id uint, openid string) error {
+	err := txAttachOpenIDToUser(mctx.Database.WithContext(ctx), id, openid)
 	if err != nil {
 		return err
 	}
@@ -242,8 +243,8 @@ func txAttachOpenIDToUser(tx *gorm.DB, id uint, openid string) error {
 	return nil
 }
 
-func dbDeleteUser(id uint) error {
-	err := txDeleteUser(mctx.Database, id)
+func dbDeleteUser(ctx context.Context, id uint) error {
+	err := txDeleteUser(mctx.Database.WithContext(ctx), id)
 	if err != nil {
 		return err
 	}
@@ -258,15 +259,54 @@ func txDeleteUser(tx *gorm.DB, id uint) (err error) {
 	return
 }
 
-func dbCheckLogin(user *User, password string) error {
+func dbGetTrashUsers(ctx context.Context, param *model.PageParam) (users []*User, count uint, err error) {
+	if users, count, err = dao.TxGetTrash[User](mctx.ReadDatabase().WithContext(ctx), param); err != nil {
+		mctx.Logger.Warnf("GetTrashUsersErr: %v\n", err)
+	}
+	return
+}
+
+func dbRestoreUser(ctx context.Context, id uint) (user *User, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if user, err = dao.TxRestore[User](tx, id); err != nil {
+			mctx.Logger.Warnf("RestoreUserErr: %v\n", err)
+		}
+		return err
+	})
+	if err == nil {
+		cacheDeleteUser(id)
+	}
+	return
+}
+
+func dbPurgeUser(ctx context.Context, id uint) error {
+	if err := dao.TxPurge[User](mctx.Database.WithContext(ctx), id); err != nil {
+		mctx.Logger.Warnf("PurgeUserErr: %v\n", err)
+		return err
+	}
+	cacheDeleteUser(id)
+	return nil
+}
+
+func dbPurgeTrashUsers(ctx context.Context, before time.Time) {
+	n, err := dao.TxPurgeBefore[User](mctx.Database.WithContext(ctx), before)
+	if err != nil {
+		mctx.Logger.Warnf("PurgeTrashUsersErr: %v\n", err)
+	}
+	if n > 0 {
+		mctx.Logger.Infof("Purged %d deleted users", n)
+	}
+}
+
+func dbCheckLogin(ctx context.Context, user *User, password string) error {
 	if ok := bcrypt.Match(password, user.Password); !ok {
 		return fmt.Errorf("Wrong password")
 	}
-	return dbForceLogin(user.ID, user.LoginIP)
+	return dbForceLogin(ctx, user.ID, user.LoginIP)
 }
 
-func dbForceLogin(id uint, ip string) error {
-	err := txForceLogin(mctx.Database, id, ip)
+func dbForceLogin(ctx context.Context, id uint, ip string) error {
+	err := txForceLogin(mctx.Database.WithContext(ctx), id, ip)
 	if err != nil {
 		return err
 	}
@@ -11,7 +11,7 @@ var Module module.Module
 func init() {
 	Module = module.Module{
 		ModuleName:    "user",
-		ModuleVersion: "1.1.0",
+		ModuleVersion: "1.1.3",
 		ModuleConfig:  userConfig,
 		ModuleDepends: []string{},
 		ModuleEnv: map[string]any{
@@ -20,10 +20,6 @@ func init() {
 				&Division{},
 			},
 		},
-		ModuleExport: map[string]any{
-			"appid":     "",
-			"appsecret": "",
-		},
 		ModulePerm: map[string]string{
 			"user.view":        "查看当前用户",
 			"user.create":      "创建用户",
@@ -31,6 +27,9 @@ func init() {
 			"user.updateall":   "更新所有用户",
 			"user.delete":      "删除用户",
 			"user.viewall":     "查看所有用户",
+			"user.trash":       "查看已删除的用户",
+			"user.restore":     "恢复已删除的用户",
+			"user.purge":       "彻底删除用户",
 			"user.login":       "登录",
 			"user.register":    "注册",
 			"user.wxlogin":     "微信登录",
@@ -41,17 +40,28 @@ func init() {
 			"division.update":  "更新分组",
 			"division.delete":  "删除分组",
 		},
-		EntryPoint: entry,
+		EntryPoint:   entry,
+		CommandPoint: command,
 	}
 }
 
 var mctx *module.ModuleContext
 
+// command provides services for commands, without the default administrator and jobs.
+func command(ctx *module.ModuleContext) {
+	mctx = ctx
+	initUserCache()
+	module.Provide[UserDirectory](mctx.Registry, userDirectory{})
+	module.Provide[UserAdmin](mctx.Registry, userAdmin{})
+}
+
 func entry(ctx *module.ModuleContext) {
 	mctx = ctx
+	initUserCache()
 	initDefaultData()
-	Module.ModuleExport["appid"] = userConfig.GetString("wechat.appid")
-	Module.ModuleExport["appsecret"] = userConfig.GetString("wechat.secret")
+	mctx.ScheduleTrashPurge(dbPurgeTrashUsers)
+	module.Provide[UserDirectory](mctx.Registry, userDirectory{})
+	module.Provide[UserAdmin](mctx.Registry, userAdmin{})
 
 	mctx.Route.Post("/login", rbac.PermInterceptor("user.login"), userLogin)
 	mctx.Route.Post("/wxlogin", rbac.PermInterceptor("user.wxlogin"), wxUserLogin)
@@ -69,6 +79,9 @@ func entry(ctx *module.ModuleContext) {
 		user.Put("/{id:uint}", rbac.PermInterceptor("user.updateall"), forceUpdateUser)
 		user.Delete("/{id:uint}", rbac.PermInterceptor("user.delete"), forceDeleteUser)
 		user.Get("/division/{id:uint}", rbac.PermInterceptor("user.viewall"), getUsersByDivision)
+		user.Get("/trash", rbac.PermInterceptor("user.trash"), getTrashUsers)
+		user.Post("/trash/{id:uint}/restore", rbac.PermInterceptor("user.restore"), restoreUser)
+		user.Delete("/trash/{id:uint}", rbac.PermInterceptor("user.purge"), purgeUser)
 	})
 
 	mctx.Route.PartyFunc("/division", func(division iris.Party) {
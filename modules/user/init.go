@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/xaxys/maintainman/core/logger"
@@ -17,14 +18,15 @@ func createSystemAdmin() {
 	aul.Password = userConfig.GetString("admin.password")
 	aul.RoleName = userConfig.GetString("admin.role_name")
 
-	count, err := dbGetUserCount()
+	count, err := dbGetUserCount(context.Background())
 	if err != nil {
 		panic(err)
 	}
 	if count == 0 {
-		logger.Logger.Debug("Create default administrator account")
-		if _, err := dbCreateUser(aul, 0); err != nil {
+		name := aul.Name
+		if _, err := dbCreateUser(context.Background(), aul, 0); err != nil {
 			panic(fmt.Errorf("failed to create default administrator: %v", err))
 		}
+		logger.Logger.Warnf("Default administrator %s created with the password in user configuration, change it or run `maintainman user reset-password %s`", name, name)
 	}
 }
@@ -87,5 +87,6 @@ type UserJson struct {
 	Phone       string         `json:"phone"`
 	Email       string         `json:"email"`
 	RealName    string         `json:"real_name"`
-	LoginTime   int64          `json:"login_time"` // unix timestamp in seconds (UTC)
+	LoginTime   int64          `json:"login_time"`           // unix timestamp in seconds (UTC)
+	DeletedAt   int64          `json:"deleted_at,omitempty"` // unix timestamp in seconds (UTC), 仅回收站中的记录有
 }
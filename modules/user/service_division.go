@@ -1,16 +1,18 @@
 package user
 
 import (
+	"context"
 	"errors"
 
+	"github.com/xaxys/maintainman/core/audit"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 
 	"gorm.io/gorm"
 )
 
-func getDivisionService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	division, err := dbGetDivisionByID(id)
+func getDivisionService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	division, err := dbGetDivisionByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -20,40 +22,59 @@ func getDivisionService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(divisionToJson(division), "获取成功")
 }
 
-func getDivisionsByParentIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	divisions, err := dbGetDivisionsByParentID(id)
+func getDivisionsByParentIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	divisions, err := dbGetDivisionsByParentID(ctx, id)
 	if err != nil {
 		return model.ErrorQueryDatabase(err)
 	}
 	return model.Success(util.TransSlice(divisions, divisionToJson), "获取成功")
 }
 
-func createDivisionService(aul *CreateDivisionRequest, auth *model.AuthInfo) *model.ApiJson {
+func createDivisionService(ctx context.Context, aul *CreateDivisionRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	division, err := dbCreateDivision(aul)
+	division, err := dbCreateDivision(ctx, aul)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "division", division.ID, audit.ActionCreate, nil, divisionToJson(division))
 	return model.SuccessCreate(divisionToJson(division), "创建成功")
 }
 
-func updateDivisionService(id uint, aul *UpdateDivisionRequest, auth *model.AuthInfo) *model.ApiJson {
+func updateDivisionService(ctx context.Context, id uint, aul *UpdateDivisionRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	division, err := dbUpdateDivision(id, aul)
+	before, err := dbGetDivisionByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorQueryDatabase(err)
+	}
+	division, err := dbUpdateDivision(ctx, id, aul)
 	if err != nil {
 		return model.ErrorUpdateDatabase(err)
 	}
+	if after, err := dbGetDivisionByID(ctx, id); err == nil {
+		audit.Record(mctx.EventBus, auth, "division", id, audit.ActionUpdate, divisionToJson(before), divisionToJson(after))
+	}
 	return model.SuccessUpdate(divisionToJson(division), "更新成功")
 }
 
-func deleteDivisionService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	if err := dbDeleteDivision(id); err != nil {
+func deleteDivisionService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	division, err := dbGetDivisionByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorQueryDatabase(err)
+	}
+	if err := dbDeleteDivision(ctx, id); err != nil {
 		return model.ErrorDeleteDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "division", id, audit.ActionDelete, divisionToJson(division), nil)
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
@@ -1,9 +1,12 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/xaxys/maintainman/core/audit"
+	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/rbac"
 	"github.com/xaxys/maintainman/core/util"
@@ -11,8 +14,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func getUserByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	user, err := dbGetUserByID(id)
+func getUserByIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbGetUserByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -22,8 +25,8 @@ func getUserByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(userToJson(user), "获取成功")
 }
 
-func getUserInfoByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	user, err := dbGetUserByID(id)
+func getUserInfoByIDService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbGetUserByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -35,8 +38,8 @@ func getUserInfoByIDService(id uint, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(json, "获取成功")
 }
 
-func getUserByNameService(name string, auth *model.AuthInfo) *model.ApiJson {
-	user, err := dbGetUserByName(name)
+func getUserByNameService(ctx context.Context, name string, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbGetUserByName(ctx, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -46,8 +49,8 @@ func getUserByNameService(name string, auth *model.AuthInfo) *model.ApiJson {
 	return model.Success(userToJson(user), "获取成功")
 }
 
-func getUserInfoByNameService(name string, auth *model.AuthInfo) *model.ApiJson {
-	user, err := dbGetUserByName(name)
+func getUserInfoByNameService(ctx context.Context, name string, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbGetUserByName(ctx, name)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -59,11 +62,11 @@ func getUserInfoByNameService(name string, auth *model.AuthInfo) *model.ApiJson
 	return model.Success(json, "获取成功")
 }
 
-func getUsersByDivisionService(id uint, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+func getUsersByDivisionService(ctx context.Context, id uint, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(param); err != nil {
 		return model.ErrorValidation(err)
 	}
-	users, count, err := dbGetUsersByDivision(id, param)
+	users, count, err := dbGetUsersByDivision(ctx, id, param)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -74,14 +77,14 @@ func getUsersByDivisionService(id uint, param *model.PageParam, auth *model.Auth
 	return model.SuccessPaged(us, count, "获取成功")
 }
 
-func registerUserService(aul *RegisterUserRequest, auth *model.AuthInfo) *model.ApiJson {
+func registerUserService(ctx context.Context, aul *RegisterUserRequest, auth *model.AuthInfo) *model.ApiJson {
 	req := &CreateUserRequest{
 		RegisterUserRequest: *aul,
 	}
-	return createUserService(req, auth)
+	return createUserService(ctx, req, auth)
 }
 
-func createUserService(aul *CreateUserRequest, auth *model.AuthInfo) *model.ApiJson {
+func createUserService(ctx context.Context, aul *CreateUserRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
@@ -89,50 +92,100 @@ func createUserService(aul *CreateUserRequest, auth *model.AuthInfo) *model.ApiJ
 		return model.ErrorValidation(fmt.Errorf("用户名不能为邮箱或手机号"))
 	}
 	operator := util.NilOrBaseValue(auth, func(v *model.AuthInfo) uint { return v.User }, 0)
-	u, err := dbCreateUser(aul, operator)
+	u, err := dbCreateUser(ctx, aul, operator)
 	if err != nil {
 		return model.ErrorInsertDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "user", u.ID, audit.ActionCreate, nil, userToJson(u))
 	return model.SuccessCreate(userToJson(u), "创建成功")
 
 }
 
-func updateUserService(id uint, aul *UpdateUserRequest, auth *model.AuthInfo) *model.ApiJson {
+func updateUserService(ctx context.Context, id uint, aul *UpdateUserRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	_, err := dbGetUserByID(id)
+	before, err := dbGetUserByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
-	u, err := dbUpdateUser(id, aul, auth.User)
+	u, err := dbUpdateUser(ctx, id, aul, auth.User)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorUpdateDatabase(err)
 	}
+	if after, err := dbGetUserByID(ctx, id); err == nil {
+		audit.Record(mctx.EventBus, auth, "user", id, audit.ActionUpdate, userToJson(before), userToJson(after))
+	}
 	return model.SuccessUpdate(userToJson(u), "更新成功")
 }
 
-func deleteUserService(id uint, auth *model.AuthInfo) *model.ApiJson {
-	if err := dbDeleteUser(id); err != nil {
+func deleteUserService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbGetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorQueryDatabase(err)
+	}
+	if err := dbDeleteUser(ctx, id); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorDeleteDatabase(err)
 	}
+	audit.Record(mctx.EventBus, auth, "user", id, audit.ActionDelete, userToJson(user), nil)
 	return model.SuccessUpdate(nil, "删除成功")
 }
 
-func getAllUsersService(aul *AllUserRequest, auth *model.AuthInfo) *model.ApiJson {
+func getTrashUsersService(ctx context.Context, param *model.PageParam, auth *model.AuthInfo) *model.ApiJson {
+	if err := util.Validator.Struct(param); err != nil {
+		return model.ErrorValidation(err)
+	}
+	users, count, err := dbGetTrashUsers(ctx, param)
+	if err != nil {
+		return model.ErrorQueryDatabase(err)
+	}
+	us := util.TransSlice(users, userToJson)
+	return model.SuccessPaged(us, count, "获取成功")
+}
+
+func restoreUserService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbRestoreUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		if errors.Is(err, dao.ErrConflict) {
+			return model.ErrorConflict(err)
+		}
+		return model.ErrorUpdateDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "user", id, audit.ActionRestore, nil, userToJson(user))
+	return model.SuccessUpdate(userToJson(user), "恢复成功")
+}
+
+func purgeUserService(ctx context.Context, id uint, auth *model.AuthInfo) *model.ApiJson {
+	if err := dbPurgeUser(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ErrorNotFound(err)
+		}
+		return model.ErrorDeleteDatabase(err)
+	}
+	audit.Record(mctx.EventBus, auth, "user", id, audit.ActionPurge, nil, nil)
+	return model.SuccessUpdate(nil, "彻底删除成功")
+}
+
+func getAllUsersService(ctx context.Context, aul *AllUserRequest, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
-	users, count, err := dbGetAllUsersWithParam(aul)
+	users, count, err := dbGetAllUsersWithParam(ctx, aul)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
@@ -145,7 +198,7 @@ func getAllUsersService(aul *AllUserRequest, auth *model.AuthInfo) *model.ApiJso
 
 const wxURL = "https://api.weixin.qq.com/sns/jscode2session"
 
-func wxUserLoginService(aul *WxLoginRequest, ip string, auth *model.AuthInfo) *model.ApiJson {
+func wxUserLoginService(ctx context.Context, aul *WxLoginRequest, ip string, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
@@ -170,7 +223,7 @@ func wxUserLoginService(aul *WxLoginRequest, ip string, auth *model.AuthInfo) *m
 	}
 
 	id := uint(0)
-	user, err := dbGetUserByOpenID(openID)
+	user, err := dbGetUserByOpenID(ctx, openID)
 	if err != nil {
 		// If user related to openid not found, attach openid to current user OR create a new one
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
@@ -178,7 +231,7 @@ func wxUserLoginService(aul *WxLoginRequest, ip string, auth *model.AuthInfo) *m
 		}
 		if auth != nil && auth.User != 0 {
 			// If already login, attach openid to current user
-			if err := dbAttachOpenIDToUser(auth.User, openID); err != nil {
+			if err := dbAttachOpenIDToUser(ctx, auth.User, openID); err != nil {
 				return model.ErrorUpdateDatabase(err)
 			}
 			id = auth.User
@@ -191,7 +244,7 @@ func wxUserLoginService(aul *WxLoginRequest, ip string, auth *model.AuthInfo) *m
 				},
 			}
 			operator := util.NilOrBaseValue(auth, func(v *model.AuthInfo) uint { return v.User }, 0)
-			u, response := createUserWithOpenID(aul, openID, operator)
+			u, response := createUserWithOpenID(ctx, aul, openID, operator)
 			if response != nil {
 				return response
 			}
@@ -207,7 +260,7 @@ func wxUserLoginService(aul *WxLoginRequest, ip string, auth *model.AuthInfo) *m
 		id = user.ID
 	}
 
-	if err := dbForceLogin(id, ip); err != nil {
+	if err := dbForceLogin(ctx, id, ip); err != nil {
 		return model.ErrorUpdateDatabase(fmt.Errorf("登录失败"))
 	}
 	token, err := util.GetJwtString(id, user.Name, user.RoleName)
@@ -217,7 +270,7 @@ func wxUserLoginService(aul *WxLoginRequest, ip string, auth *model.AuthInfo) *m
 	return model.Success(token, "登陆成功")
 }
 
-func wxUserRegisterService(aul *WxRegisterRequest, ip string, auth *model.AuthInfo) *model.ApiJson {
+func wxUserRegisterService(ctx context.Context, aul *WxRegisterRequest, ip string, auth *model.AuthInfo) *model.ApiJson {
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
@@ -246,12 +299,13 @@ func wxUserRegisterService(aul *WxRegisterRequest, ip string, auth *model.AuthIn
 
 	req := &CreateUserRequest{RegisterUserRequest: aul.RegisterUserRequest}
 	operator := util.NilOrBaseValue(auth, func(v *model.AuthInfo) uint { return v.User }, 0)
-	user, response := createUserWithOpenID(req, openID, operator)
+	user, response := createUserWithOpenID(ctx, req, openID, operator)
 	if response != nil {
 		return response
 	}
+	audit.Record(mctx.EventBus, auth, "user", user.ID, audit.ActionCreate, nil, userToJson(user))
 
-	if err := dbForceLogin(user.ID, ip); err != nil {
+	if err := dbForceLogin(ctx, user.ID, ip); err != nil {
 		return model.ErrorUpdateDatabase(fmt.Errorf("登录失败"))
 	}
 	token, err := util.GetJwtString(user.ID, user.Name, user.RoleName)
@@ -261,31 +315,31 @@ func wxUserRegisterService(aul *WxRegisterRequest, ip string, auth *model.AuthIn
 	return model.Success(token, "登陆成功")
 }
 
-func userLoginService(aul *LoginRequest, ip string, auth *model.AuthInfo) *model.ApiJson {
+func userLoginService(ctx context.Context, aul *LoginRequest, ip string, auth *model.AuthInfo) *model.ApiJson {
 	var user *User
 	var err error
 	if err := util.Validator.Struct(aul); err != nil {
 		return model.ErrorValidation(err)
 	}
 	if util.EmailRegex.MatchString(aul.Account) {
-		user, err = dbGetUserByEmail(aul.Account)
+		user, err = dbGetUserByEmail(ctx, aul.Account)
 		if err != nil {
 			return model.ErrorNotFound(fmt.Errorf("邮箱不存在"))
 		}
 	} else if util.PhoneRegex.MatchString(aul.Account) {
-		user, err = dbGetUserByPhone(aul.Account)
+		user, err = dbGetUserByPhone(ctx, aul.Account)
 		if err != nil {
 			return model.ErrorNotFound(fmt.Errorf("手机号不存在"))
 		}
 	} else {
-		user, err = dbGetUserByName(aul.Account)
+		user, err = dbGetUserByName(ctx, aul.Account)
 		if err != nil {
 			return model.ErrorNotFound(fmt.Errorf("用户名不存在"))
 		}
 	}
 
 	user.LoginIP = ip
-	if err := dbCheckLogin(user, aul.Password); err != nil {
+	if err := dbCheckLogin(ctx, user, aul.Password); err != nil {
 		return model.ErrorVerification(fmt.Errorf("密码错误"))
 	}
 	token, err := util.GetJwtString(user.ID, user.Name, user.RoleName)
@@ -301,20 +355,20 @@ func userLoginService(aul *LoginRequest, ip string, auth *model.AuthInfo) *model
 		return ""
 	}, "")
 	if openID != "" && user.OpenID == "" {
-		dbAttachOpenIDToUser(user.ID, openID)
+		dbAttachOpenIDToUser(ctx, user.ID, openID)
 	}
 	return model.Success(token, "登陆成功")
 }
 
-func userRenewService(id uint, ip string, auth *model.AuthInfo) *model.ApiJson {
-	user, err := dbGetUserByID(id)
+func userRenewService(ctx context.Context, id uint, ip string, auth *model.AuthInfo) *model.ApiJson {
+	user, err := dbGetUserByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return model.ErrorNotFound(err)
 		}
 		return model.ErrorQueryDatabase(err)
 	}
-	if err := dbForceLogin(id, ip); err != nil {
+	if err := dbForceLogin(ctx, id, ip); err != nil {
 		return model.ErrorUpdateDatabase(fmt.Errorf("登录失败"))
 	}
 	token, err := util.GetJwtString(id, user.Name, user.RoleName)
@@ -342,19 +396,22 @@ func getWxUserOpenID(code string) (string, error) {
 	return wxres.OpenID, nil
 }
 
-func createUserWithOpenID(aul *CreateUserRequest, openID string, operator uint) (user *User, response *model.ApiJson) {
-	mctx.Database.Transaction(func(tx *gorm.DB) (err error) {
-		user, err = dbCreateUser(aul, operator)
+func createUserWithOpenID(ctx context.Context, aul *CreateUserRequest, openID string, operator uint) (user *User, response *model.ApiJson) {
+	err := mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		user, err = txCreateUser(tx, aul, operator)
 		if err != nil {
 			response = model.ErrorInsertDatabase(err)
 			return err
 		}
-		if err := dbAttachOpenIDToUser(user.ID, openID); err != nil {
+		if err := txAttachOpenIDToUser(tx, user.ID, openID); err != nil {
 			response = model.ErrorUpdateDatabase(err)
 			return err
 		}
 		return nil
 	})
+	if err == nil {
+		cacheDeleteUser(user.ID)
+	}
 	return
 }
 
@@ -371,6 +428,7 @@ func userToJson(user *User) *UserJson {
 			Email:       user.Email,
 			RealName:    user.RealName,
 			LoginTime:   user.LoginTime.Unix(),
+			DeletedAt:   model.DeletedUnix(user.DeletedAt),
 		}
 	}
 }
@@ -25,7 +25,7 @@ func getAllWords(ctx iris.Context) {
 		ctx.Values().Set("response", model.ErrorInvalidData(err))
 		return
 	}
-	response := getAllWordsService(param)
+	response := getAllWordsService(ctx.Request().Context(), param)
 	ctx.Values().Set("response", response)
 }
 
@@ -51,6 +51,6 @@ func getWordsByOrder(ctx iris.Context) {
 		return
 	}
 	id, _ := ctx.Params().GetUint("id")
-	response := getWordsByOrderService(id, param)
+	response := getWordsByOrderService(ctx.Request().Context(), id, param)
 	ctx.Values().Set("response", response)
 }
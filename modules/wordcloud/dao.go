@@ -1,13 +1,15 @@
 package wordcloud
 
 import (
+	"context"
 	"github.com/xaxys/maintainman/core/dao"
 	"github.com/xaxys/maintainman/core/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func dbUploadWord(id uint, json *WordJson) (err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbUploadWord(ctx context.Context, id uint, json *WordJson) (err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err = txUploadWord(tx, id, json); err != nil {
 			mctx.Logger.Warnf("UploadWordErr: %+v", err)
 		}
@@ -28,8 +30,8 @@ func txUploadWord(tx *gorm.DB, id uint, word *WordJson) error {
 	return nil
 }
 
-func dbUploadOrderWord(id uint, json *WordJson) (word *OrderWord, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbUploadOrderWord(ctx context.Context, id uint, json *WordJson) (word *OrderWord, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if word, err = txUploadOrderWord(tx, id, json); err != nil {
 			mctx.Logger.Warnf("UploadOrderWordErr: %+v", err)
 		}
@@ -48,15 +50,15 @@ func txUploadOrderWord(tx *gorm.DB, id uint, json *WordJson) (*OrderWord, error)
 	if err := tx.Where(word).Attrs(word).FirstOrCreate(word).Error; err != nil {
 		return nil, err
 	}
-	if err := tx.Model(word).Update("count", gorm.Expr("count + ?", json.Count)).Error; err != nil {
+	if err := tx.Model(word).Update("count", gorm.Expr("? + ?", clause.Column{Name: "count"}, json.Count)).Error; err != nil {
 		return nil, err
 	}
 	word.Count += json.Count
 	return word, nil
 }
 
-func dbUploadGlobalWord(json *WordJson) (word *GlobalWord, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbUploadGlobalWord(ctx context.Context, json *WordJson) (word *GlobalWord, err error) {
+	mctx.Database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if word, err = txUploadGlobalWord(tx, json); err != nil {
 			mctx.Logger.Warnf("UploadGlobalWordErr: %+v", err)
 		}
@@ -73,15 +75,15 @@ func txUploadGlobalWord(tx *gorm.DB, json *WordJson) (*GlobalWord, error) {
 	if err := tx.Where(word).Attrs(word).FirstOrCreate(word).Error; err != nil {
 		return nil, err
 	}
-	if err := tx.Model(word).Update("count", gorm.Expr("count + ?", json.Count)).Error; err != nil {
+	if err := tx.Model(word).Update("count", gorm.Expr("? + ?", clause.Column{Name: "count"}, json.Count)).Error; err != nil {
 		return nil, err
 	}
 	word.Count += json.Count
 	return word, nil
 }
 
-func dbGetAllWords(aul *model.PageParam) (words []*GlobalWord, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetAllWords(ctx context.Context, aul *model.PageParam) (words []*GlobalWord, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if words, count, err = txGetAllWords(tx, aul); err != nil {
 			mctx.Logger.Warnf("GetAllWordsErr: %+v", err)
 		}
@@ -104,8 +106,8 @@ func txGetAllWords(tx *gorm.DB, aul *model.PageParam) (words []*GlobalWord, coun
 	return
 }
 
-func dbGetOrderWords(id uint, aul *model.PageParam) (words []*OrderWord, count uint, err error) {
-	mctx.Database.Transaction(func(tx *gorm.DB) error {
+func dbGetOrderWords(ctx context.Context, id uint, aul *model.PageParam) (words []*OrderWord, count uint, err error) {
+	mctx.ReadDatabase().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if words, count, err = txGetOrderWords(tx, id, aul); err != nil {
 			mctx.Logger.Warnf("GetOrderWordsErr: %+v", err)
 		}
@@ -4,6 +4,7 @@ import (
 	"github.com/kataras/iris/v12"
 	"github.com/xaxys/maintainman/core/module"
 	"github.com/xaxys/maintainman/core/rbac"
+	"github.com/xaxys/maintainman/modules/order"
 )
 
 var Module = module.Module{
@@ -18,11 +19,12 @@ var Module = module.Module{
 			&GlobalWord{},
 		},
 	},
-	ModuleExport: map[string]any{},
 	ModulePerm: map[string]string{
 		"word.view": "查看词云",
 	},
 	EntryPoint: entry,
+	StartPoint: start,
+	StopPoint:  stop,
 }
 
 var mctx *module.ModuleContext
@@ -34,5 +36,17 @@ func entry(ctx *module.ModuleContext) {
 		word.Get("/", rbac.PermInterceptor("word.view"), getAllWords)
 		word.Get("/{id:uint}", rbac.PermInterceptor("word.view"), getWordsByOrder)
 	})
-	go listener()
+	orders = module.MustResolve[order.OrderReader](mctx.Registry)
+	start(ctx)
+}
+
+func start(ctx *module.ModuleContext) {
+	subscribe()
+	listenerDone = make(chan struct{})
+	go listener(listenerDone)
+}
+
+func stop(ctx *module.ModuleContext) {
+	unsubscribe()
+	<-listenerDone
 }
@@ -1,69 +1,115 @@
 package wordcloud
 
-import "github.com/xaxys/maintainman/modules/order"
+import (
+	"context"
+	"github.com/olebedev/emitter"
+	"github.com/xaxys/maintainman/modules/order"
+)
 
-func listener() {
+var (
+	orders        order.OrderReader
+	createEvents  <-chan emitter.Event
+	titleEvents   <-chan emitter.Event
+	contentEvents <-chan emitter.Event
+	commentEvents <-chan emitter.Event
+	listenerDone  chan struct{}
+)
+
+func subscribe() {
+	createEvents = mctx.EventBus.On("order:create")
+	titleEvents = mctx.EventBus.On("order:update:title")
+	contentEvents = mctx.EventBus.On("order:update:content")
+	commentEvents = mctx.EventBus.On("order:update:comment")
+}
+
+// unsubscribe closes the subscriptions, listener will return after handling buffered events.
+func unsubscribe() {
+	mctx.EventBus.Off("order:create", createEvents)
+	mctx.EventBus.Off("order:update:title", titleEvents)
+	mctx.EventBus.Off("order:update:content", contentEvents)
+	mctx.EventBus.Off("order:update:comment", commentEvents)
+}
+
+func listener(done chan struct{}) {
+	defer close(done)
 	defer func() {
 		if err := recover(); err != nil {
 			mctx.Logger.Errorf("wordcloud listener panic: %s", err)
 		}
 	}()
 
-	for {
+	createCh, titleCh, contentCh, commentCh := createEvents, titleEvents, contentEvents, commentEvents
+	for createCh != nil || titleCh != nil || contentCh != nil || commentCh != nil {
 		select {
 		// order created
-		case ch := <-mctx.EventBus.On("order:create"):
+		case ch, ok := <-createCh:
+			if !ok {
+				createCh = nil
+				continue
+			}
 			orderID, _ := ch.Args[0].(uint)
-			odr, err := order.GetOrderByID(orderID)
+			odr, err := orders.GetOrderByID(context.Background(), orderID)
 			if err != nil {
 				mctx.Logger.Warnf("Get order failed: %s", err)
 				continue
 			}
-			if res := uploadWordsService(odr.ID, odr.Title); !res.Status {
+			if res := uploadWordsService(context.Background(), odr.ID, odr.Title); !res.Status {
 				mctx.Logger.Warnf("Upload words failed: [order: %d, content: %s] errors: %v", odr.ID, odr.Title, res.Data)
 			} else {
 				mctx.Logger.Infof("Upload words success: [order: %d, content: %s]", odr.ID, odr.Title)
 			}
-			if res := uploadWordsService(odr.ID, odr.Content); !res.Status {
+			if res := uploadWordsService(context.Background(), odr.ID, odr.Content); !res.Status {
 				mctx.Logger.Warnf("Upload words failed: [order: %d, content: %s] errors: %v", odr.ID, odr.Content, res.Data)
 			} else {
 				mctx.Logger.Infof("Upload words success: [order: %d, content: %s]", odr.ID, odr.Content)
 			}
 		// order title changed
-		case ch := <-mctx.EventBus.On("order:update:title"):
+		case ch, ok := <-titleCh:
+			if !ok {
+				titleCh = nil
+				continue
+			}
 			orderID, _ := ch.Args[0].(uint)
-			odr, err := order.GetOrderByID(orderID)
+			odr, err := orders.GetOrderByID(context.Background(), orderID)
 			if err != nil {
 				mctx.Logger.Warnf("Get order failed: %s", err)
 				continue
 			}
-			if res := uploadWordsService(odr.ID, odr.Title); !res.Status {
+			if res := uploadWordsService(context.Background(), odr.ID, odr.Title); !res.Status {
 				mctx.Logger.Warnf("Upload words failed: [order: %d, content: %s] errors: %v", odr.ID, odr.Title, res.Data)
 			} else {
 				mctx.Logger.Infof("Upload words success: [order: %d, content: %s]", odr.ID, odr.Title)
 			}
 		// order content changed
-		case ch := <-mctx.EventBus.On("order:update:content"):
+		case ch, ok := <-contentCh:
+			if !ok {
+				contentCh = nil
+				continue
+			}
 			orderID, _ := ch.Args[0].(uint)
-			odr, err := order.GetOrderByID(orderID)
+			odr, err := orders.GetOrderByID(context.Background(), orderID)
 			if err != nil {
 				mctx.Logger.Warnf("Get order failed: %s", err)
 				continue
 			}
-			if res := uploadWordsService(odr.ID, odr.Content); !res.Status {
+			if res := uploadWordsService(context.Background(), odr.ID, odr.Content); !res.Status {
 				mctx.Logger.Warnf("Upload words failed: [order: %d, content: %s] errors: %v", odr.ID, odr.Content, res.Data)
 			} else {
 				mctx.Logger.Infof("Upload words success: [order: %d, content: %s]", odr.ID, odr.Content)
 			}
 		// order comment
-		case ch := <-mctx.EventBus.On("order:update:comment"):
+		case ch, ok := <-commentCh:
+			if !ok {
+				commentCh = nil
+				continue
+			}
 			commentID, _ := ch.Args[1].(uint)
-			comment, err := order.GetCommentByID(commentID)
+			comment, err := orders.GetCommentByID(context.Background(), commentID)
 			if err != nil {
 				mctx.Logger.Warnf("Get comment failed: %s", err)
 				continue
 			}
-			if res := uploadWordsService(comment.OrderID, comment.Content); !res.Status {
+			if res := uploadWordsService(context.Background(), comment.OrderID, comment.Content); !res.Status {
 				mctx.Logger.Warnf("Upload words failed: [order: %d, content: %s] errors: %v", comment.OrderID, comment.Content, res.Data)
 			} else {
 				mctx.Logger.Infof("Upload words success: [order: %d, content: %s]", comment.OrderID, comment.Content)
@@ -1,16 +1,17 @@
 package wordcloud
 
 import (
+	"context"
 	"github.com/xaxys/maintainman/core/model"
 	"github.com/xaxys/maintainman/core/util"
 )
 
-func uploadWordsService(id uint, content string) *model.ApiJson {
+func uploadWordsService(ctx context.Context, id uint, content string) *model.ApiJson {
 	wc := NewWordCollectorWithStr(content)
 	wordSet := wc.Filter(&LengthFilter{}).ToSlice()
 	errs := []error{}
 	for _, word := range wordSet {
-		err := dbUploadWord(id, &word)
+		err := dbUploadWord(ctx, id, &word)
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -21,9 +22,9 @@ func uploadWordsService(id uint, content string) *model.ApiJson {
 	return model.Success(nil, "上传成功")
 }
 
-func getAllWordsService(aul *model.PageParam) *model.ApiJson {
+func getAllWordsService(ctx context.Context, aul *model.PageParam) *model.ApiJson {
 	aul.OrderBy = util.NotEmpty(aul.OrderBy, "count desc")
-	words, count, err := dbGetAllWords(aul)
+	words, count, err := dbGetAllWords(ctx, aul)
 	if err != nil {
 		return model.ErrorInternalServer(err)
 	}
@@ -31,9 +32,9 @@ func getAllWordsService(aul *model.PageParam) *model.ApiJson {
 	return model.SuccessPaged(ws, count, "获取成功")
 }
 
-func getWordsByOrderService(id uint, aul *model.PageParam) *model.ApiJson {
+func getWordsByOrderService(ctx context.Context, id uint, aul *model.PageParam) *model.ApiJson {
 	aul.OrderBy = util.NotEmpty(aul.OrderBy, "count desc")
-	words, count, err := dbGetOrderWords(id, aul)
+	words, count, err := dbGetOrderWords(ctx, id, aul)
 	if err != nil {
 		return model.ErrorInternalServer(err)
 	}
@@ -22,26 +22,10 @@ type wxAccessTokenResponse struct {
 }
 
 func initAccessToken() {
-	userModule := mctx.Registry.Get("user")
-	if userModule == nil {
-		mctx.Logger.Errorf("user module not found")
-		return
-	}
-	expAppid, appidOK := userModule.Export("appid")
-	expSecret, secretOK := userModule.Export("appsecret")
-	if !appidOK {
-		mctx.Logger.Errorf("appid not found")
-		return
-	}
-	if !secretOK {
-		mctx.Logger.Errorf("appsecret not found")
-		return
-	}
-	if expAppid == "" || expSecret == "" {
+	appid, secret := users.WechatApp()
+	if appid == "" || secret == "" {
 		mctx.Logger.Infof("appid or appsecret is empty, access token service will be unavailable")
 	}
-	appid, _ := expAppid.(string)
-	secret, _ := expSecret.(string)
 	param := map[string]string{
 		"grant_type": "client_credential",
 		"appid":      appid,
@@ -1,8 +1,10 @@
 package wxnotify
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/olebedev/emitter"
 	"github.com/xaxys/maintainman/core/module"
 	"github.com/xaxys/maintainman/core/util"
 	"github.com/xaxys/maintainman/modules/order"
@@ -16,18 +18,63 @@ var Module = module.Module{
 		"user",
 		"order",
 	},
-	ModuleEnv:    map[string]any{},
-	ModuleExport: map[string]any{},
-	ModulePerm:   map[string]string{},
-	EntryPoint:   entry,
+	ModuleEnv:   map[string]any{},
+	ModulePerm:  map[string]string{},
+	EntryPoint:  entry,
+	StartPoint:  start,
+	StopPoint:   stop,
+	HealthCheck: healthCheck,
 }
 
-var mctx *module.ModuleContext
+var (
+	mctx          *module.ModuleContext
+	users         user.UserDirectory
+	orders        order.OrderReader
+	templates     order.WechatTemplates
+	statusEvents  <-chan emitter.Event
+	commentEvents <-chan emitter.Event
+	listenerDone  chan struct{}
+)
 
 func entry(ctx *module.ModuleContext) {
 	mctx = ctx
+	users = module.MustResolve[user.UserDirectory](mctx.Registry)
+	orders = module.MustResolve[order.OrderReader](mctx.Registry)
+	templates = module.MustResolve[order.WechatTemplates](mctx.Registry)
 	initAccessToken()
-	go listener()
+	start(ctx)
+}
+
+func start(ctx *module.ModuleContext) {
+	listenerDone = make(chan struct{})
+	statusEvents, commentEvents = nil, nil
+	if getAccessToken() == "" {
+		mctx.Logger.Infof("access token is empty, wechat notification service will be unavailable")
+		close(listenerDone)
+		return
+	}
+	statusEvents = mctx.EventBus.On("order:update:status:*")
+	commentEvents = mctx.EventBus.On("order:update:comment")
+	go listener(listenerDone)
+}
+
+func stop(ctx *module.ModuleContext) {
+	// closing the subscriptions lets listener handle buffered events and return
+	if statusEvents != nil {
+		mctx.EventBus.Off("order:update:status:*", statusEvents)
+	}
+	if commentEvents != nil {
+		mctx.EventBus.Off("order:update:comment", commentEvents)
+	}
+	<-listenerDone
+}
+
+// healthCheck reports wechat notification unavailable if no access token is obtained.
+func healthCheck(ctx context.Context) error {
+	if getAccessToken() == "" {
+		return fmt.Errorf("wechat access token is empty")
+	}
+	return nil
 }
 
 const sendMessageURL = "https://api.weixin.qq.com/cgi-bin/message/template/send"
@@ -37,43 +84,33 @@ type wxSendMessageResponse struct {
 	ErrMsg  string `json:"errmsg"`  // 错误信息
 }
 
-func listener() {
+func listener(done chan struct{}) {
+	defer close(done)
 	defer func() {
 		if err := recover(); err != nil {
 			mctx.Logger.Errorf("wxnotify listener panic: %s", err)
 		}
 	}()
-	if getAccessToken() == "" {
-		mctx.Logger.Infof("access token is empty, wechat notification service will be unavailable")
-		return
-	}
-
-	orderModule := mctx.Registry.Get("order")
 
-	statusTmplID := getExportString(orderModule, "wechat.status.tmpl")
-	if statusTmplID == "" {
+	statusTmpl := templates.StatusTemplate()
+	if statusTmpl.ID == "" {
 		mctx.Logger.Errorf("status template id not found, wechat status notification service will be unavailable")
 	}
-	keyStatusOrder := getExportString(orderModule, "wechat.status.order")
-	keyStatusTitle := getExportString(orderModule, "wechat.status.title")
-	keyStatusStatus := getExportString(orderModule, "wechat.status.status")
-	keyStatusTime := getExportString(orderModule, "wechat.status.time")
-	keyStatusOther := getExportString(orderModule, "wechat.status.other")
-
-	commentTmplID := getExportString(orderModule, "wechat.comment.tmpl")
-	if commentTmplID == "" {
+	commentTmpl := templates.CommentTemplate()
+	if commentTmpl.ID == "" {
 		mctx.Logger.Errorf("comment template id not found, wechat comment notification service will be unavailable")
 	}
-	keyCommentTitle := getExportString(orderModule, "wechat.comment.title")
-	keyCommentName := getExportString(orderModule, "wechat.comment.name")
-	keyCommentMessage := getExportString(orderModule, "wechat.comment.message")
-	keyCommentTime := getExportString(orderModule, "wechat.comment.time")
 
-	for {
+	statusCh, commentCh := statusEvents, commentEvents
+	for statusCh != nil || commentCh != nil {
 		select {
 		// order status changed notification
-		case ch := <-mctx.EventBus.On("order:update:status:*"):
-			if statusTmplID == "" {
+		case ch, ok := <-statusCh:
+			if !ok {
+				statusCh = nil
+				continue
+			}
+			if statusTmpl.ID == "" {
 				continue
 			}
 			orderID, _ := ch.Args[0].(uint)
@@ -81,15 +118,15 @@ func listener() {
 			var odr *order.Order
 			var err error
 			if status == order.StatusAssigned {
-				odr, err = order.GetOrderWithLastStatus(orderID)
+				odr, err = orders.GetOrderWithLastStatus(context.Background(), orderID)
 			} else {
-				odr, err = order.GetOrderByID(orderID)
+				odr, err = orders.GetOrderByID(context.Background(), orderID)
 			}
 			if err != nil {
 				mctx.Logger.Warnf("get order failed: %s", err)
 				continue
 			}
-			usr, err := user.GetUserByID(odr.UserID)
+			usr, err := users.GetUserByID(context.Background(), odr.UserID)
 			if err != nil {
 				mctx.Logger.Warnf("get user failed: %s", err)
 				continue
@@ -101,27 +138,27 @@ func listener() {
 
 			// get template data
 			data := map[string]string{}
-			if keyStatusOrder != "" {
-				data[keyStatusOrder] = fmt.Sprintf("%d", odr.ID)
+			if statusTmpl.Order != "" {
+				data[statusTmpl.Order] = fmt.Sprintf("%d", odr.ID)
 			}
-			if keyStatusTitle != "" {
-				data[keyStatusTitle] = odr.Title
+			if statusTmpl.Title != "" {
+				data[statusTmpl.Title] = odr.Title
 			}
-			if keyStatusStatus != "" {
-				data[keyStatusStatus] = order.StatusName(status)
+			if statusTmpl.Status != "" {
+				data[statusTmpl.Status] = order.StatusName(status)
 			}
-			if keyStatusTime != "" {
-				data[keyStatusTime] = odr.UpdatedAt.Local().Format("2006-01-02 15:04:05")
+			if statusTmpl.Time != "" {
+				data[statusTmpl.Time] = odr.UpdatedAt.Local().Format("2006-01-02 15:04:05")
 			}
-			if keyStatusOther != "" && status == order.StatusAssigned && odr.Status == uint(status) {
+			if statusTmpl.Other != "" && status == order.StatusAssigned && odr.Status == uint(status) {
 				// add repairer info if status is assigned
 				repairerID, _ := ch.Args[2].(uint)
-				repairer, err := user.GetUserByID(repairerID)
+				repairer, err := users.GetUserByID(context.Background(), repairerID)
 				if err != nil {
 					mctx.Logger.Warnf("get repairer failed: %s", err)
 					continue
 				}
-				data[keyStatusOther] = fmt.Sprintf("维修师傅 %s 将尽快为您维修", repairer.Name)
+				data[statusTmpl.Other] = fmt.Sprintf("维修师傅 %s 将尽快为您维修", repairer.Name)
 			}
 
 			// send notification
@@ -131,7 +168,7 @@ func listener() {
 
 			payload := map[string]any{
 				"touser":      usr.OpenID,
-				"template_id": statusTmplID,
+				"template_id": statusTmpl.ID,
 				"data":        data,
 			}
 
@@ -145,18 +182,22 @@ func listener() {
 				continue
 			}
 		// order comment notification
-		case ch := <-mctx.EventBus.On("order:update:comment"):
-			if commentTmplID == "" {
+		case ch, ok := <-commentCh:
+			if !ok {
+				commentCh = nil
+				continue
+			}
+			if commentTmpl.ID == "" {
 				continue
 			}
 			orderID, _ := ch.Args[0].(uint)
 			commentID, _ := ch.Args[1].(uint)
-			comment, err := order.GetCommentByID(commentID)
+			comment, err := orders.GetCommentByID(context.Background(), commentID)
 			if err != nil {
 				mctx.Logger.Warnf("get comment failed: %s", err)
 				continue
 			}
-			odr, err := order.GetOrderWithLastStatus(orderID)
+			odr, err := orders.GetOrderWithLastStatus(context.Background(), orderID)
 			if err != nil {
 				mctx.Logger.Warnf("get order failed: %s", err)
 				continue
@@ -164,23 +205,23 @@ func listener() {
 
 			// get template data
 			data := map[string]string{}
-			if keyCommentTitle != "" {
-				data[keyCommentTitle] = odr.Title
+			if commentTmpl.Title != "" {
+				data[commentTmpl.Title] = odr.Title
 			}
-			if keyCommentName != "" {
-				data[keyCommentName] = comment.UserName
+			if commentTmpl.Name != "" {
+				data[commentTmpl.Name] = comment.UserName
 			}
-			if keyCommentMessage != "" {
-				data[keyCommentMessage] = comment.Content
+			if commentTmpl.Message != "" {
+				data[commentTmpl.Message] = comment.Content
 			}
-			if keyCommentTime != "" {
-				data[keyCommentTime] = comment.CreatedAt.Local().Format("2006-01-02 15:04:05")
+			if commentTmpl.Time != "" {
+				data[commentTmpl.Time] = comment.CreatedAt.Local().Format("2006-01-02 15:04:05")
 			}
 
 			openIDs := []string{}
 			// send notification to user
 			if odr.UserID != comment.UserID {
-				usr, err := user.GetUserByID(odr.UserID)
+				usr, err := users.GetUserByID(context.Background(), odr.UserID)
 				if err != nil {
 					mctx.Logger.Warnf("get user failed: %s", err)
 					continue
@@ -199,7 +240,7 @@ func listener() {
 				if *repairerID == comment.UserID {
 					continue
 				}
-				repairer, err := user.GetUserByID(*repairerID)
+				repairer, err := users.GetUserByID(context.Background(), *repairerID)
 				if err != nil {
 					mctx.Logger.Warnf("get repairer failed: %s", err)
 					continue
@@ -216,7 +257,7 @@ func listener() {
 				}
 				payload := map[string]any{
 					"touser":      openID,
-					"template_id": statusTmplID,
+					"template_id": commentTmpl.ID,
 					"data":        data,
 				}
 
@@ -233,13 +274,3 @@ func listener() {
 		}
 	}
 }
-
-func getExportString(mod module.IModule, key string) string {
-	exp, ok := mod.Export(key)
-	if !ok {
-		mctx.Logger.Warnf(fmt.Sprintf("export key %s not found", key))
-		return ""
-	}
-	s, _ := exp.(string)
-	return s
-}